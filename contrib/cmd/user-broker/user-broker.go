@@ -25,19 +25,34 @@ import (
 	"path"
 	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/audit"
 	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/server"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/trace"
 	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/user_provided/controller"
 	"github.com/kubernetes-incubator/service-catalog/pkg"
 )
 
 var options struct {
-	Port int
+	Port                   int
+	MetricsPort            int
+	AdminToken             string
+	AuditLogPath           string
+	TracingEndpoint        string
+	SlowOperationThreshold time.Duration
+	StateSummaryInterval   time.Duration
 }
 
 func init() {
 	flag.IntVar(&options.Port, "port", 8005, "use '--port' option to specify the port for broker to listen on")
+	flag.IntVar(&options.MetricsPort, "metrics-port", 0, "port to serve /metrics on separately from --port (defaults to serving it alongside OSB traffic on --port)")
+	flag.StringVar(&options.AdminToken, "admin-token", "", "bearer token required to reach the admin endpoints, such as /admin/state (defaults to leaving them unreachable)")
+	flag.StringVar(&options.AuditLogPath, "audit-log-path", "", "path to append a JSON-lines audit record of every OSB operation served to, or \"-\" for stdout (defaults to no audit log)")
+	flag.StringVar(&options.TracingEndpoint, "tracing-endpoint", "", "HTTP endpoint to export a span per OSB operation, and its child spans, to (defaults to no tracing)")
+	flag.DurationVar(&options.SlowOperationThreshold, "slow-operation-threshold", 0, "log a warning and increment a metric for any OSB operation still running past this duration (defaults to no watchdog)")
+	flag.DurationVar(&options.StateSummaryInterval, "state-summary-interval", 0, "log a heartbeat line summarizing instance/binding counts, in-flight operations, and time since the last successful operation at this cadence (defaults to no heartbeat)")
 	flag.Parse()
 }
 
@@ -61,8 +76,23 @@ func runWithContext(ctx context.Context) error {
 		return nil
 	}
 
+	auditLog, err := audit.Open(options.AuditLogPath)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %v", err)
+	}
+	defer auditLog.Close()
+
+	var tracer *trace.Tracer
+	if options.TracingEndpoint != "" {
+		tracer = trace.New(trace.NewHTTPExporter(options.TracingEndpoint))
+	}
+
 	addr := ":" + strconv.Itoa(options.Port)
-	return server.Run(ctx, addr, controller.CreateController())
+	metricsAddr := ""
+	if options.MetricsPort != 0 {
+		metricsAddr = ":" + strconv.Itoa(options.MetricsPort)
+	}
+	return server.Run(ctx, addr, metricsAddr, controller.CreateController(), nil, options.AdminToken, auditLog, tracer, options.SlowOperationThreshold, options.StateSummaryInterval)
 }
 
 // cancelOnInterrupt calls f when os.Interrupt or SIGTERM is received.
@@ -0,0 +1,46 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Drain blocks until wg is done - every background goroutine runWithContext
+// started besides the HTTP server itself, such as the leader election loop,
+// has noticed the root context is cancelled and returned - or until ctx is
+// done, whichever comes first. server.Run already blocks until the HTTP
+// server has drained its in-flight requests or hit its own shutdown
+// timeout, so by the time Drain is called any operation still holding wg
+// open is one that ignored cancellation and is now just racing ctx's
+// deadline.
+func Drain(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("drain: background operations did not finish before the shutdown deadline: %v", ctx.Err())
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestDrainWaitsForSlowOperation simulates a provision that keeps running
+// past shutdown: Drain must block until it finishes, as long as that
+// finishes before ctx's deadline.
+func TestDrainWaitsForSlowOperation(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	finished := false
+	go func() {
+		defer wg.Done()
+		time.Sleep(10 * time.Millisecond)
+		finished = true
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := Drain(ctx, &wg); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if !finished {
+		t.Error("Drain returned before the outstanding operation finished")
+	}
+}
+
+// TestDrainGivesUpAtDeadline simulates an operation that never respects
+// cancellation: Drain must not block past ctx's deadline.
+func TestDrainGivesUpAtDeadline(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	defer wg.Done() // let the goroutine leaked by this test's Drain call be collected
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := Drain(ctx, &wg); err == nil {
+		t.Error("expected Drain to return an error once the deadline passed")
+	}
+}
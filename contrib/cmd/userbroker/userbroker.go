@@ -0,0 +1,366 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command userbroker hosts the in-cluster service brokers that provision
+// real Kubernetes workloads on behalf of a service instance (as opposed to
+// the purely in-memory contrib/cmd/user-broker sample).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/audit"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+	heketicontroller "github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/heketi/controller"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/leaderelection"
+	mongodbcontroller "github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/mongodb/controller"
+	nginxcontroller "github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/nginx/controller"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/server"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/trace"
+	"github.com/kubernetes-incubator/service-catalog/pkg"
+)
+
+var options struct {
+	Port                        int
+	MetricsPort                 int
+	Service                     string
+	ImagePullSecret             string
+	DialHealthChecks            bool
+	SkipPVCDelete               bool
+	DefaultResources            bool
+	KeepFailedInstances         bool
+	AllowAdminBind              bool
+	HeketiImage                 string
+	AllowDestructiveDeprovision bool
+	NginxMaxContentSize         int
+	NginxReadinessTimeout       time.Duration
+	NginxImage                  string
+	NginxGitImage               string
+	NginxLogSidecarImage        string
+	NginxRotateOnAdminUnbind    bool
+	NginxAllowExternalProxy     bool
+	Kubeconfig                  string
+	KubeContext                 string
+	DefaultNamespace            string
+	AllowDefaultNamespace       bool
+	BrokerNamespace             string
+	ServiceAccount              string
+	PreflightDryRun             bool
+	NamespacePerInstance        bool
+	RetryAttempts               int
+	RetryBaseDelay              time.Duration
+	ProvisionTimeout            time.Duration
+	BindTimeout                 time.Duration
+	TemplatesDir                string
+	InstanceLabelKey            string
+	ExtraLabels                 string
+	KubeAPIQPS                  float64
+	KubeAPIBurst                int
+	DisableInformers            bool
+	EnableLeaderElection        bool
+	LeaderElectionNamespace     string
+	AdminToken                  string
+	AuditLogPath                string
+	TracingEndpoint             string
+	SlowOperationThreshold      time.Duration
+	StateSummaryInterval        time.Duration
+}
+
+func init() {
+	flag.IntVar(&options.Port, "port", 8005, "use '--port' option to specify the port for broker to listen on")
+	flag.IntVar(&options.MetricsPort, "metrics-port", 0, "port to serve /metrics on separately from --port (defaults to serving it alongside OSB traffic on --port)")
+	flag.StringVar(&options.Service, "service", "mongodb", "the service this broker instance provisions")
+	flag.StringVar(&options.ImagePullSecret, "image-pull-secret", "", "name of a secret in the broker's namespace used by default to pull instance images")
+	flag.StringVar(&options.BrokerNamespace, "broker-namespace", "", "namespace this broker's own pod runs in, where --image-pull-secret is looked up (defaults to $POD_NAMESPACE, or \"default\" if that isn't set either)")
+	flag.BoolVar(&options.DialHealthChecks, "dial-health-checks", false, "actively dial the instance's database port when reporting instance health")
+	flag.BoolVar(&options.SkipPVCDelete, "skip-pvc-delete", false, "leave instance PersistentVolumeClaims in place on deprovision")
+	flag.BoolVar(&options.DefaultResources, "default-resources", false, "apply the small resource tier to instances that don't request one of their own")
+	flag.BoolVar(&options.KeepFailedInstances, "keep-failed-instances", false, "leave a failed provisioning attempt's Kubernetes objects and instance record in place for debugging, instead of rolling them back")
+	flag.BoolVar(&options.AllowAdminBind, "allow-admin-bind", false, "allow a bind request with an \"admin: true\" (heketi) or \"role: admin\" (nginx) parameter to receive the shared admin credential instead of a per-binding one")
+	flag.StringVar(&options.HeketiImage, "heketi-image", "", "image, including tag, to run for heketi instances that don't override it with the imageTag parameter (defaults to a pinned release)")
+	flag.BoolVar(&options.AllowDestructiveDeprovision, "allow-destructive-deprovision", false, "allow a heketi instance that still manages volumes to be deprovisioned without a \"force\" parameter")
+	flag.IntVar(&options.NginxMaxContentSize, "nginx-max-content-size", 0, "maximum combined size, in bytes, of an nginx instance's indexHtml/files parameters (defaults to a size safely under the ConfigMap cap)")
+	flag.DurationVar(&options.NginxReadinessTimeout, "nginx-readiness-timeout", 0, "how long a synchronous nginx provision waits for the new instance to become available (defaults to a timeout suited to pulling a small image)")
+	flag.StringVar(&options.NginxImage, "nginx-image", "", "image, including tag, to run for nginx instances that don't override it with the imageTag parameter (defaults to a pinned release)")
+	flag.StringVar(&options.NginxGitImage, "nginx-git-image", "", "image, including a git binary, to run as the init container that clones an instance's gitRepo parameter (defaults to a pinned release)")
+	flag.StringVar(&options.NginxLogSidecarImage, "nginx-log-sidecar-image", "", "image to run as the second container that tails an instance's access log when it's provisioned with logSidecar: true (defaults to a pinned release)")
+	flag.BoolVar(&options.NginxRotateOnAdminUnbind, "nginx-rotate-on-admin-unbind", false, "rotate a basic-auth nginx instance's shared admin credential whenever an admin-role binding is unbound")
+	flag.BoolVar(&options.NginxAllowExternalProxy, "allow-external-proxy", false, "allow an nginx instance's proxyPass parameter to name an upstream outside the cluster")
+	flag.StringVar(&options.Kubeconfig, "kubeconfig", "", "path to a kubeconfig file to use instead of the in-cluster service account config, for running the broker outside the cluster it manages (defaults to $KUBECONFIG)")
+	flag.StringVar(&options.KubeContext, "kube-context", "", "context to use from --kubeconfig, instead of its current-context; when set, the kubeconfig is loaded even when running in-cluster")
+	flag.StringVar(&options.DefaultNamespace, "default-namespace", "default", "namespace used for a provision request that names none, when --allow-default-namespace is set")
+	flag.BoolVar(&options.AllowDefaultNamespace, "allow-default-namespace", true, "fall back to --default-namespace for a provision request that names no namespace, instead of rejecting it")
+	flag.StringVar(&options.ServiceAccount, "service-account", "default", "name of the service account this broker runs as, named in the error when the Kubernetes API rejects a request as forbidden")
+	flag.BoolVar(&options.PreflightDryRun, "preflight-dry-run", false, "accepted for compatibility; this broker's Kubernetes client cannot submit a dry-run create, so setting it only logs a one-time warning and no preflight check runs")
+	flag.BoolVar(&options.NamespacePerInstance, "namespace-per-instance", false, "provision every instance into a dedicated namespace this broker creates and owns, instead of the requesting namespace")
+	flag.IntVar(&options.RetryAttempts, "retry-attempts", 0, "number of times to attempt a Kubernetes API call before giving up on a transient error (defaults to a small built-in count)")
+	flag.DurationVar(&options.RetryBaseDelay, "retry-base-delay", 0, "delay before the first retry of a Kubernetes API call that failed with a transient error, doubling on each subsequent retry (defaults to a small built-in delay)")
+	flag.DurationVar(&options.ProvisionTimeout, "provision-timeout", 0, "how long a provision or deprovision operation runs before its context is canceled (defaults to a small built-in timeout)")
+	flag.DurationVar(&options.BindTimeout, "bind-timeout", 0, "how long a bind or unbind operation runs before its context is canceled (defaults to a small built-in timeout)")
+	flag.StringVar(&options.TemplatesDir, "templates-dir", "", "directory checked for a <service>-pod.yaml overriding the resources, node selector, tolerations, and annotations of instance pods (defaults to using only the built-in shapes)")
+	flag.StringVar(&options.InstanceLabelKey, "instance-label-key", "", "label key used to tag and select an instance's resources (defaults to kube.DefaultInstanceLabelKey; changing it on a broker with existing instances stops those instances' resources from being found)")
+	flag.StringVar(&options.ExtraLabels, "extra-labels", "", "comma-separated key=value pairs applied as extra labels to every resource this broker creates")
+	flag.Float64Var(&options.KubeAPIQPS, "kube-api-qps", 0, "sustained rate of Kubernetes API calls this broker's client makes (defaults to client-go's built-in QPS)")
+	flag.IntVar(&options.KubeAPIBurst, "kube-api-burst", 0, "burst of Kubernetes API calls this broker's client allows above --kube-api-qps (defaults to client-go's built-in burst)")
+	flag.BoolVar(&options.DisableInformers, "disable-informers", false, "look up instance pods with a direct List against the API on every request, instead of a shared informer's cache")
+	flag.BoolVar(&options.EnableLeaderElection, "enable-leader-election", false, "contest an Endpoints lease with other replicas of this broker and only serve mutating OSB operations while holding it")
+	flag.StringVar(&options.LeaderElectionNamespace, "leader-election-namespace", "default", "namespace holding the Endpoints lease used for --enable-leader-election")
+	flag.StringVar(&options.AdminToken, "admin-token", "", "bearer token required to reach the admin endpoints, such as /admin/state (defaults to leaving them unreachable)")
+	flag.StringVar(&options.AuditLogPath, "audit-log-path", "", "path to append a JSON-lines audit record of every OSB operation served to, or \"-\" for stdout (defaults to no audit log)")
+	flag.StringVar(&options.TracingEndpoint, "tracing-endpoint", "", "HTTP endpoint to export a span per OSB operation, and its child spans, to (defaults to no tracing)")
+	flag.DurationVar(&options.SlowOperationThreshold, "slow-operation-threshold", 0, "log a warning and increment a metric for any OSB operation still running past this duration (defaults to no watchdog)")
+	flag.DurationVar(&options.StateSummaryInterval, "state-summary-interval", 0, "log a heartbeat line summarizing instance/binding counts, in-flight operations, and time since the last successful operation at this cadence (defaults to no heartbeat)")
+	flag.Parse()
+}
+
+// parseExtraLabels parses a comma-separated key=value list, as accepted by
+// the --extra-labels flag, into a label map. It returns an error naming the
+// malformed pair rather than silently dropping it.
+func parseExtraLabels(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid --extra-labels pair %q: expected key=value", pair)
+		}
+		labels[kv[0]] = kv[1]
+	}
+	return labels, nil
+}
+
+func main() {
+	if err := run(); err != nil && err != context.Canceled && err != context.DeadlineExceeded {
+		glog.Fatalln(err)
+	}
+}
+
+func run() error {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	cancelOnInterrupt(ctx, cancelFunc)
+
+	return runWithContext(ctx)
+}
+
+func runWithContext(ctx context.Context) error {
+	if flag.Arg(0) == "version" {
+		fmt.Printf("%s/%s\n", path.Base(os.Args[0]), pkg.VERSION)
+		return nil
+	}
+
+	extraLabels, err := parseExtraLabels(options.ExtraLabels)
+	if err != nil {
+		return err
+	}
+	kube.Configure(options.InstanceLabelKey, extraLabels)
+
+	c, err := createController()
+	if err != nil {
+		return err
+	}
+
+	var elector leaderelection.Elector
+	var wg sync.WaitGroup
+	if options.EnableLeaderElection {
+		e, err := createElector()
+		if err != nil {
+			return err
+		}
+		stop := make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ctx.Done()
+			close(stop)
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.Run(stop)
+		}()
+		elector = e
+	}
+
+	auditLog, err := audit.Open(options.AuditLogPath)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %v", err)
+	}
+	defer auditLog.Close()
+
+	var tracer *trace.Tracer
+	if options.TracingEndpoint != "" {
+		tracer = trace.New(trace.NewHTTPExporter(options.TracingEndpoint))
+	}
+
+	addr := ":" + strconv.Itoa(options.Port)
+	metricsAddr := ""
+	if options.MetricsPort != 0 {
+		metricsAddr = ":" + strconv.Itoa(options.MetricsPort)
+	}
+	runErr := server.Run(ctx, addr, metricsAddr, c, elector, options.AdminToken, auditLog, tracer, options.SlowOperationThreshold, options.StateSummaryInterval)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := Drain(drainCtx, &wg); err != nil {
+		glog.Warningf("shutdown: %v", err)
+	}
+
+	return runErr
+}
+
+// drainTimeout bounds how long runWithContext waits, once the HTTP server
+// has finished shutting down, for the remaining background goroutines (the
+// leader election loop) to notice the root context is cancelled and return.
+const drainTimeout = 5 * time.Second
+
+// createElector builds the leaderelection.LeaseElector for --enable-leader-
+// election, using the broker's own --service and --kubeconfig so it
+// contests the same lock as its sibling replicas without a separate flag.
+func createElector() (*leaderelection.LeaseElector, error) {
+	identity, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine leader election identity: %v", err)
+	}
+
+	return leaderelection.New(leaderelection.Config{
+		KubeconfigPath: options.Kubeconfig,
+		KubeContext:    options.KubeContext,
+		Namespace:      options.LeaderElectionNamespace,
+		Service:        options.Service,
+		Identity:       identity,
+	})
+}
+
+// controllerFactories maps a --service name to the constructor for its
+// controller. Supporting a new service is a matter of adding an entry here
+// instead of growing a switch statement.
+var controllerFactories = map[string]func() (controller.Controller, error){
+	"mongodb": func() (controller.Controller, error) {
+		return mongodbcontroller.CreateController(mongodbcontroller.Options{
+			ImagePullSecret:       options.ImagePullSecret,
+			BrokerNamespace:       options.BrokerNamespace,
+			DialHealthChecks:      options.DialHealthChecks,
+			DefaultNamespace:      options.DefaultNamespace,
+			AllowDefaultNamespace: options.AllowDefaultNamespace,
+			ServiceAccount:        options.ServiceAccount,
+			PreflightDryRun:       options.PreflightDryRun,
+			NamespacePerInstance:  options.NamespacePerInstance,
+			KubeconfigPath:        options.Kubeconfig,
+			KubeContext:           options.KubeContext,
+			RetryAttempts:         options.RetryAttempts,
+			RetryBaseDelay:        options.RetryBaseDelay,
+			ProvisionTimeout:      options.ProvisionTimeout,
+			BindTimeout:           options.BindTimeout,
+			TemplatesDir:          options.TemplatesDir,
+			KubeAPIQPS:            float32(options.KubeAPIQPS),
+			KubeAPIBurst:          options.KubeAPIBurst,
+		})
+	},
+	"heketi": func() (controller.Controller, error) {
+		return heketicontroller.CreateController(heketicontroller.Options{
+			SkipPVCDelete:               options.SkipPVCDelete,
+			DefaultResources:            options.DefaultResources,
+			KeepFailedInstances:         options.KeepFailedInstances,
+			AllowAdminBind:              options.AllowAdminBind,
+			HeketiImage:                 options.HeketiImage,
+			ImagePullSecret:             options.ImagePullSecret,
+			BrokerNamespace:             options.BrokerNamespace,
+			AllowDestructiveDeprovision: options.AllowDestructiveDeprovision,
+			DefaultNamespace:            options.DefaultNamespace,
+			AllowDefaultNamespace:       options.AllowDefaultNamespace,
+			ServiceAccount:              options.ServiceAccount,
+			PreflightDryRun:             options.PreflightDryRun,
+			NamespacePerInstance:        options.NamespacePerInstance,
+			KubeconfigPath:              options.Kubeconfig,
+			KubeContext:                 options.KubeContext,
+			RetryAttempts:               options.RetryAttempts,
+			RetryBaseDelay:              options.RetryBaseDelay,
+			ProvisionTimeout:            options.ProvisionTimeout,
+			BindTimeout:                 options.BindTimeout,
+			TemplatesDir:                options.TemplatesDir,
+			KubeAPIQPS:                  float32(options.KubeAPIQPS),
+			KubeAPIBurst:                options.KubeAPIBurst,
+			DisableInformers:            options.DisableInformers,
+		})
+	},
+	"nginx": func() (controller.Controller, error) {
+		return nginxcontroller.CreateController(nginxcontroller.Options{
+			MaxContentSize:        options.NginxMaxContentSize,
+			ReadinessTimeout:      options.NginxReadinessTimeout,
+			NginxImage:            options.NginxImage,
+			ImagePullSecret:       options.ImagePullSecret,
+			BrokerNamespace:       options.BrokerNamespace,
+			AllowAdminBind:        options.AllowAdminBind,
+			GitImage:              options.NginxGitImage,
+			LogSidecarImage:       options.NginxLogSidecarImage,
+			RotateOnAdminUnbind:   options.NginxRotateOnAdminUnbind,
+			KeepFailedInstances:   options.KeepFailedInstances,
+			AllowExternalProxy:    options.NginxAllowExternalProxy,
+			DefaultNamespace:      options.DefaultNamespace,
+			AllowDefaultNamespace: options.AllowDefaultNamespace,
+			ServiceAccount:        options.ServiceAccount,
+			PreflightDryRun:       options.PreflightDryRun,
+			NamespacePerInstance:  options.NamespacePerInstance,
+			KubeconfigPath:        options.Kubeconfig,
+			KubeContext:           options.KubeContext,
+			RetryAttempts:         options.RetryAttempts,
+			RetryBaseDelay:        options.RetryBaseDelay,
+			ProvisionTimeout:      options.ProvisionTimeout,
+			BindTimeout:           options.BindTimeout,
+			TemplatesDir:          options.TemplatesDir,
+			KubeAPIQPS:            float32(options.KubeAPIQPS),
+			KubeAPIBurst:          options.KubeAPIBurst,
+			DisableInformers:      options.DisableInformers,
+		})
+	},
+}
+
+func createController() (controller.Controller, error) {
+	factory, ok := controllerFactories[options.Service]
+	if !ok {
+		return nil, fmt.Errorf("unknown service %q", options.Service)
+	}
+	return factory()
+}
+
+// cancelOnInterrupt calls f when os.Interrupt or SIGTERM is received.
+// It ignores subsequent interrupts on purpose - program should exit correctly after the first signal.
+func cancelOnInterrupt(ctx context.Context, f context.CancelFunc) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c:
+			f()
+		}
+	}()
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit records a compliance trail of every OSB operation a broker
+// serves: who asked for what, when, and whether it succeeded. Records never
+// carry request parameters or credentials, only enough to answer "who did
+// what to which instance/binding, and when" after the fact.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Record is one audited OSB operation, written as a single line of JSON.
+type Record struct {
+	Time       time.Time     `json:"time"`
+	Operation  string        `json:"operation"`
+	InstanceID string        `json:"instanceID,omitempty"`
+	BindingID  string        `json:"bindingID,omitempty"`
+	ServiceID  string        `json:"serviceID,omitempty"`
+	Identity   string        `json:"identity,omitempty"`
+	Outcome    string        `json:"outcome"`
+	Cause      string        `json:"cause,omitempty"`
+	Latency    time.Duration `json:"latencyMS"`
+}
+
+// MarshalJSON renders Latency in milliseconds, so a record reads naturally
+// next to a Time that's already in a human unit, rather than nanoseconds.
+func (r Record) MarshalJSON() ([]byte, error) {
+	type alias Record
+	return json.Marshal(struct {
+		alias
+		Latency int64 `json:"latencyMS"`
+	}{alias(r), r.Latency.Nanoseconds() / int64(time.Millisecond)})
+}
+
+// Writer asynchronously appends Records to an underlying io.Writer as JSON
+// lines. The zero value is not usable; construct one with New. A nil
+// *Writer is valid and silently discards every Record, so a broker that
+// wasn't given an audit log path can hold one without a nil check at every
+// call site.
+type Writer struct {
+	records chan Record
+	done    chan struct{}
+	dropped uint64 // accessed atomically
+}
+
+// bufferSize bounds how many Records can be queued before Record starts
+// dropping them. It's sized generously enough to absorb a burst without a
+// slow disk stalling OSB traffic.
+const bufferSize = 1024
+
+// New creates a Writer that appends JSON-encoded Records to w, one per
+// line, from a single background goroutine. Call Close to flush and stop
+// it.
+func New(w io.Writer) *Writer {
+	a := &Writer{
+		records: make(chan Record, bufferSize),
+		done:    make(chan struct{}),
+	}
+	go a.run(w)
+	return a
+}
+
+// Open creates a Writer appending JSON records to the file at path. An
+// empty path disables audit logging: Open returns a nil *Writer and a nil
+// error. The special path "-" writes records to stdout instead of a file.
+func Open(path string) (*Writer, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if path == "-" {
+		return New(os.Stdout), nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return New(f), nil
+}
+
+func (a *Writer) run(w io.Writer) {
+	defer close(a.done)
+	enc := json.NewEncoder(w)
+	for r := range a.records {
+		if err := enc.Encode(r); err != nil {
+			glog.Errorf("audit: failed to write record: %v", err)
+		}
+	}
+}
+
+// Record enqueues r to be written. It never blocks: if the buffer is full,
+// r is dropped and counted instead, so a slow or stuck disk can't stall the
+// OSB request that triggered it.
+func (a *Writer) Record(r Record) {
+	if a == nil {
+		return
+	}
+	select {
+	case a.records <- r:
+	default:
+		atomic.AddUint64(&a.dropped, 1)
+		glog.Errorf("audit: buffer full, dropped record for operation %q", r.Operation)
+	}
+}
+
+// Dropped returns the number of Records dropped so far because the buffer
+// was full.
+func (a *Writer) Dropped() uint64 {
+	if a == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Close stops accepting new Records and blocks until every already-queued
+// Record has been written.
+func (a *Writer) Close() {
+	if a == nil {
+		return
+	}
+	close(a.records)
+	<-a.done
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriterWritesOneJSONLinePerRecord(t *testing.T) {
+	var buf bytes.Buffer
+	w := New(&buf)
+
+	w.Record(Record{Operation: "provision", InstanceID: "instance-1", ServiceID: "test-service", Outcome: "success", Latency: 250 * time.Millisecond})
+	w.Record(Record{Operation: "bind", InstanceID: "instance-1", BindingID: "binding-1", Outcome: "success"})
+	w.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+
+	var r Record
+	if err := json.Unmarshal([]byte(lines[0]), &r); err != nil {
+		t.Fatalf("line 0 did not decode as a Record: %v", err)
+	}
+	if r.Operation != "provision" || r.InstanceID != "instance-1" || r.ServiceID != "test-service" || r.Outcome != "success" {
+		t.Errorf("decoded record = %+v, missing expected fields", r)
+	}
+	if !strings.Contains(lines[0], `"latencyMS":250`) {
+		t.Errorf("expected latency to be rendered in milliseconds, got %q", lines[0])
+	}
+}
+
+func TestRecordFieldsAreTheOnlyKeysInTheEncodedLine(t *testing.T) {
+	var buf bytes.Buffer
+	w := New(&buf)
+	w.Record(Record{Operation: "provision", InstanceID: "instance-1", Identity: "alice", Outcome: "success"})
+	w.Close()
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("failed to decode record: %v", err)
+	}
+
+	allowed := map[string]bool{"time": true, "operation": true, "instanceID": true, "bindingID": true, "serviceID": true, "identity": true, "outcome": true, "cause": true, "latencyMS": true}
+	for key := range decoded {
+		if !allowed[key] {
+			t.Errorf("unexpected key %q in audit record -- Record must never carry request parameters or credentials", key)
+		}
+	}
+}
+
+func TestRecordDropsWithoutBlockingWhenTheBufferIsFull(t *testing.T) {
+	// A Writer with no run goroutine draining it lets the buffer fill, so
+	// Record must start dropping rather than blocking the caller.
+	w := &Writer{records: make(chan Record, 2), done: make(chan struct{})}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			w.Record(Record{Operation: "provision"})
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() { wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Record blocked instead of dropping once the buffer filled")
+	}
+
+	if got := w.Dropped(); got == 0 {
+		t.Errorf("expected some records to be dropped once the buffer filled, got 0")
+	}
+}
+
+func TestNilWriterDiscardsRecordsWithoutPanicking(t *testing.T) {
+	var w *Writer
+	w.Record(Record{Operation: "provision"})
+	if got := w.Dropped(); got != 0 {
+		t.Errorf("Dropped() on a nil Writer = %d, want 0", got)
+	}
+	w.Close()
+}
+
+func TestOpenWithAnEmptyPathDisablesAuditLogging(t *testing.T) {
+	w, err := Open("")
+	if err != nil {
+		t.Fatalf("Open(\"\") returned an error: %v", err)
+	}
+	if w != nil {
+		t.Errorf("Open(\"\") = %v, want nil", w)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import "time"
+
+// ConditionStatus is the tri-state value of a Condition, mirroring
+// k8s.io/apimachinery's convention of leaving room for "not known yet"
+// alongside true and false.
+type ConditionStatus string
+
+// The possible values of a Condition's Status.
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// The condition types a Controller's instances report. They answer the
+// questions an operator or a piece of tooling actually has about an
+// instance: did provisioning finish, is it usable right now, has it drifted
+// from a good state since, and is it on its way out.
+const (
+	ConditionProvisioned     = "Provisioned"
+	ConditionReady           = "Ready"
+	ConditionDegraded        = "Degraded"
+	ConditionDeletionPending = "DeletionPending"
+)
+
+// Condition is one Kubernetes-style status condition on a service instance:
+// a type, its current status, and enough detail for a human or a piece of
+// tooling to understand why without correlating against the broker's logs.
+type Condition struct {
+	Type               string          `json:"type"`
+	Status             ConditionStatus `json:"status"`
+	Reason             string          `json:"reason,omitempty"`
+	Message            string          `json:"message,omitempty"`
+	LastTransitionTime time.Time       `json:"lastTransitionTime"`
+}
+
+// SetCondition returns conditions with newCondition applied: inserted if no
+// condition of its Type is present yet, or merged into the existing one of
+// that Type otherwise. newCondition.LastTransitionTime is honored only when
+// the Status is actually changing (including on insertion); an update that
+// only changes Reason or Message keeps the existing condition's
+// LastTransitionTime, since nothing about the instance actually transitioned.
+// conditions is left unmodified; the returned slice is always a copy.
+func SetCondition(conditions []Condition, newCondition Condition) []Condition {
+	updated := make([]Condition, len(conditions))
+	copy(updated, conditions)
+
+	for i, existing := range updated {
+		if existing.Type != newCondition.Type {
+			continue
+		}
+		if existing.Status == newCondition.Status {
+			newCondition.LastTransitionTime = existing.LastTransitionTime
+		}
+		updated[i] = newCondition
+		return updated
+	}
+	return append(updated, newCondition)
+}
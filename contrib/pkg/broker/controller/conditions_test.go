@@ -0,0 +1,118 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetConditionInsertsNewCondition(t *testing.T) {
+	now := time.Now()
+	conditions := SetCondition(nil, Condition{
+		Type:               ConditionReady,
+		Status:             ConditionFalse,
+		Reason:             "Provisioning",
+		LastTransitionTime: now,
+	})
+
+	if len(conditions) != 1 {
+		t.Fatalf("len(conditions) = %d, want 1", len(conditions))
+	}
+	if conditions[0].Type != ConditionReady || conditions[0].Status != ConditionFalse {
+		t.Errorf("conditions[0] = %+v, want a False Ready condition", conditions[0])
+	}
+	if !conditions[0].LastTransitionTime.Equal(now) {
+		t.Errorf("LastTransitionTime = %v, want %v", conditions[0].LastTransitionTime, now)
+	}
+
+	conditions = SetCondition(conditions, Condition{
+		Type:               ConditionProvisioned,
+		Status:             ConditionFalse,
+		Reason:             "Provisioning",
+		LastTransitionTime: now,
+	})
+	if len(conditions) != 2 {
+		t.Fatalf("len(conditions) = %d, want 2", len(conditions))
+	}
+}
+
+func TestSetConditionUpdatesLastTransitionTimeOnFlip(t *testing.T) {
+	before := time.Now().Add(-time.Hour)
+	conditions := []Condition{{
+		Type:               ConditionReady,
+		Status:             ConditionFalse,
+		Reason:             "Provisioning",
+		LastTransitionTime: before,
+	}}
+
+	after := time.Now()
+	conditions = SetCondition(conditions, Condition{
+		Type:               ConditionReady,
+		Status:             ConditionTrue,
+		Reason:             "Ready",
+		LastTransitionTime: after,
+	})
+
+	if len(conditions) != 1 {
+		t.Fatalf("len(conditions) = %d, want 1", len(conditions))
+	}
+	if conditions[0].Status != ConditionTrue {
+		t.Errorf("Status = %v, want %v", conditions[0].Status, ConditionTrue)
+	}
+	if !conditions[0].LastTransitionTime.Equal(after) {
+		t.Errorf("LastTransitionTime = %v, want %v (the flip's timestamp)", conditions[0].LastTransitionTime, after)
+	}
+}
+
+func TestSetConditionLeavesLastTransitionTimeOnNoOpUpdate(t *testing.T) {
+	original := time.Now().Add(-time.Hour)
+	conditions := []Condition{{
+		Type:               ConditionReady,
+		Status:             ConditionTrue,
+		Reason:             "Ready",
+		Message:            "instance is ready",
+		LastTransitionTime: original,
+	}}
+
+	conditions = SetCondition(conditions, Condition{
+		Type:               ConditionReady,
+		Status:             ConditionTrue,
+		Reason:             "Ready",
+		Message:            "instance is ready, admin key verified",
+		LastTransitionTime: time.Now(),
+	})
+
+	if len(conditions) != 1 {
+		t.Fatalf("len(conditions) = %d, want 1", len(conditions))
+	}
+	if conditions[0].Message != "instance is ready, admin key verified" {
+		t.Errorf("Message = %q, want the updated message", conditions[0].Message)
+	}
+	if !conditions[0].LastTransitionTime.Equal(original) {
+		t.Errorf("LastTransitionTime = %v, want unchanged %v since Status did not flip", conditions[0].LastTransitionTime, original)
+	}
+}
+
+func TestSetConditionDoesNotMutateInput(t *testing.T) {
+	original := []Condition{{Type: ConditionReady, Status: ConditionFalse}}
+	SetCondition(original, Condition{Type: ConditionReady, Status: ConditionTrue})
+
+	if original[0].Status != ConditionFalse {
+		t.Errorf("input conditions were mutated: %+v", original)
+	}
+}
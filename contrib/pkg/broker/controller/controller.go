@@ -17,18 +17,89 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+	"time"
+
 	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
 )
 
 // Controller defines the APIs that all controllers are expected to support. Implementations
 // should be concurrency-safe
+//
+// Every method except Catalog takes a context.Context, sourced by the OSB
+// handler layer from the incoming HTTP request. Implementations that make
+// Kubernetes API calls should honor its cancellation/deadline instead of
+// running to completion regardless of whether the caller is still waiting.
 type Controller interface {
 	Catalog() (*brokerapi.Catalog, error)
 
-	GetServiceInstanceLastOperation(instanceID, serviceID, planID, operation string) (*brokerapi.LastOperationResponse, error)
-	CreateServiceInstance(instanceID string, req *brokerapi.CreateServiceInstanceRequest) (*brokerapi.CreateServiceInstanceResponse, error)
-	RemoveServiceInstance(instanceID, serviceID, planID string, acceptsIncomplete bool) (*brokerapi.DeleteServiceInstanceResponse, error)
+	GetServiceInstanceLastOperation(ctx context.Context, instanceID, serviceID, planID, operation string) (*brokerapi.LastOperationResponse, error)
+	CreateServiceInstance(ctx context.Context, instanceID string, req *brokerapi.CreateServiceInstanceRequest) (*brokerapi.CreateServiceInstanceResponse, error)
+	UpdateServiceInstance(ctx context.Context, instanceID string, req *brokerapi.UpdateServiceInstanceRequest) (*brokerapi.UpdateServiceInstanceResponse, error)
+	RemoveServiceInstance(ctx context.Context, instanceID, serviceID, planID string, acceptsIncomplete, force bool) (*brokerapi.DeleteServiceInstanceResponse, error)
+
+	Bind(ctx context.Context, instanceID, bindingID string, req *brokerapi.BindingRequest) (*brokerapi.CreateServiceBindingResponse, error)
+	UnBind(ctx context.Context, instanceID, bindingID, serviceID, planID string) error
+}
+
+// ReadinessChecker is optionally implemented by a Controller with its own
+// dependencies, such as the Kubernetes API server, worth verifying before
+// a replica is marked ready for traffic. The server package runs these
+// checks for /readyz and treats a Controller that doesn't implement this
+// interface as always ready.
+type ReadinessChecker interface {
+	// CheckReadiness runs this controller's readiness checks against ctx
+	// and returns a map from check name to the error it failed with. A
+	// check that passed is omitted, so a non-nil empty map means every
+	// check passed.
+	CheckReadiness(ctx context.Context) map[string]error
+}
+
+// InstanceSnapshot summarizes one service instance for StateSnapshot. It
+// carries no credential material by construction: only what a caller needs
+// to correlate an instance with the cluster resources it manages.
+type InstanceSnapshot struct {
+	ID        string `json:"id"`
+	Namespace string `json:"namespace,omitempty"`
+	ServiceID string `json:"serviceID,omitempty"`
+	PlanID    string `json:"planID,omitempty"`
+	Phase     string `json:"phase,omitempty"`
+
+	// Conditions is this instance's Kubernetes-style status conditions, for
+	// controllers that maintain them. It is nil for controllers that only
+	// report Phase.
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// CreatedAt is when this instance was provisioned. It is the zero
+	// Time for controllers that don't track it, in which case age-based
+	// reporting derived from it should be treated as unknown rather than
+	// zero.
+	CreatedAt time.Time `json:"createdAt,omitempty"`
+
+	// BindingCount is how many bindings this instance currently has, for
+	// controllers that track bindings per instance. It is 0 for
+	// controllers that don't.
+	BindingCount int `json:"bindingCount,omitempty"`
+}
+
+// StateSnapshot is a point-in-time, sanitized view of a Controller's
+// in-memory state, returned by the admin state endpoint.
+type StateSnapshot struct {
+	Instances []InstanceSnapshot `json:"instances"`
+
+	// Config summarizes the non-secret options this controller was
+	// started with, as strings, for operators comparing behavior across
+	// replicas.
+	Config map[string]string `json:"config,omitempty"`
+}
 
-	Bind(instanceID, bindingID string, req *brokerapi.BindingRequest) (*brokerapi.CreateServiceBindingResponse, error)
-	UnBind(instanceID, bindingID, serviceID, planID string) error
+// StateReporter is optionally implemented by a Controller that keeps
+// enough in-memory state to be worth exposing for debugging. The server
+// package serves it from the admin state endpoint, behind the broker's
+// admin auth mechanism, and treats a Controller that doesn't implement
+// this interface as having no state to report.
+type StateReporter interface {
+	// SnapshotState returns this controller's current state. It must not
+	// include credentials or other secret values.
+	SnapshotState() StateSnapshot
 }
@@ -0,0 +1,53 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+)
+
+// authConfig captures how JWT authentication should be configured for a
+// Heketi instance, taken from the "authEnabled" and "key" provision
+// parameters.
+type authConfig struct {
+	// Enabled controls whether the generated heketi.json turns on JWT auth.
+	// Defaults to true.
+	Enabled bool
+	// Key is the caller-supplied admin key. Empty means the broker should
+	// generate one.
+	Key string
+}
+
+// parseAuthConfig validates the "authEnabled" and "key" provision
+// parameters. authEnabled defaults to true. An explicitly empty key
+// parameter combined with authEnabled true is rejected, since it would
+// leave use_auth set with no admin key able to authenticate against it.
+func parseAuthConfig(req *brokerapi.CreateServiceInstanceRequest) (*authConfig, error) {
+	enabled := true
+	if v, ok := req.Parameters["authEnabled"].(bool); ok {
+		enabled = v
+	}
+
+	key, hasKey := req.Parameters["key"].(string)
+	if enabled && hasKey && key == "" {
+		return nil, fmt.Errorf("authEnabled requires a non-empty key parameter")
+	}
+
+	return &authConfig{Enabled: enabled, Key: key}, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+)
+
+func TestParseAuthConfigDefaultsToEnabled(t *testing.T) {
+	auth, err := parseAuthConfig(&brokerapi.CreateServiceInstanceRequest{})
+	if err != nil {
+		t.Fatalf("parseAuthConfig: %v", err)
+	}
+	if !auth.Enabled {
+		t.Error("expected auth enabled by default")
+	}
+	if auth.Key != "" {
+		t.Errorf("expected no key by default, got %q", auth.Key)
+	}
+}
+
+func TestParseAuthConfigHonorsAuthEnabledParameter(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"authEnabled": false}}
+	auth, err := parseAuthConfig(req)
+	if err != nil {
+		t.Fatalf("parseAuthConfig: %v", err)
+	}
+	if auth.Enabled {
+		t.Error("expected auth disabled when authEnabled is false")
+	}
+}
+
+func TestParseAuthConfigHonorsKeyParameter(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"key": "my-key"}}
+	auth, err := parseAuthConfig(req)
+	if err != nil {
+		t.Fatalf("parseAuthConfig: %v", err)
+	}
+	if auth.Key != "my-key" {
+		t.Errorf("expected key %q, got %q", "my-key", auth.Key)
+	}
+}
+
+func TestParseAuthConfigRejectsEmptyKeyWithAuthEnabled(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"key": ""}}
+	if _, err := parseAuthConfig(req); err == nil {
+		t.Fatal("expected an error for an explicitly empty key with auth enabled")
+	}
+}
+
+func TestParseAuthConfigAllowsEmptyKeyWithAuthDisabled(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"authEnabled": false, "key": ""}}
+	if _, err := parseAuthConfig(req); err != nil {
+		t.Errorf("parseAuthConfig: %v", err)
+	}
+}
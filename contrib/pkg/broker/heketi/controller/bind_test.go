@@ -0,0 +1,200 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestDoHeketiBindReturnsFullCredential(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-admin", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("s3cr3t")},
+	})
+	instance := &heketiServiceInstance{
+		ID:          "test",
+		Namespace:   "default",
+		SecretName:  "heketi-test-admin",
+		ServiceName: "heketi-test",
+		AuthEnabled: true,
+	}
+
+	cred, err := doHeketiBind(client, nil, instance, "binding-1", false)
+	if err != nil {
+		t.Fatalf("doHeketiBind: %v", err)
+	}
+
+	if cred["url"] != "http://heketi-test.default.svc:8080" {
+		t.Errorf("credential[url] = %v, want endpoint URL", cred["url"])
+	}
+	if cred["tls"] != false {
+		t.Errorf("credential[tls] = %v, want false", cred["tls"])
+	}
+	if cred["username"] != "admin" {
+		t.Errorf("credential[username] = %v, want admin", cred["username"])
+	}
+	if cred["role"] != "user" {
+		t.Errorf("credential[role] = %v, want user", cred["role"])
+	}
+	key, _ := cred["key"].(string)
+	if key == "" || key == "s3cr3t" {
+		t.Errorf("credential[key] = %v, want a freshly issued binding key", cred["key"])
+	}
+
+	secret, err := client.Core().Secrets("default").Get("heketi-test-admin", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(secret.Data[bindingKeyDataKey("binding-1")]) != key {
+		t.Errorf("secret does not record the issued binding key")
+	}
+
+	again, err := doHeketiBind(client, nil, instance, "binding-1", false)
+	if err != nil {
+		t.Fatalf("doHeketiBind (rebind): %v", err)
+	}
+	if again["key"] != key {
+		t.Errorf("rebinding binding-1 returned a different key: %v, want %v", again["key"], key)
+	}
+}
+
+func TestDoHeketiBindAdminReturnsSharedAdminKey(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-admin", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("s3cr3t")},
+	})
+	instance := &heketiServiceInstance{
+		ID:          "test",
+		Namespace:   "default",
+		SecretName:  "heketi-test-admin",
+		ServiceName: "heketi-test",
+		AuthEnabled: true,
+	}
+
+	cred, err := doHeketiBind(client, nil, instance, "binding-1", true)
+	if err != nil {
+		t.Fatalf("doHeketiBind: %v", err)
+	}
+
+	if cred["role"] != "admin" {
+		t.Errorf("credential[role] = %v, want admin", cred["role"])
+	}
+	if cred["key"] != "s3cr3t" {
+		t.Errorf("credential[key] = %v, want the shared admin key", cred["key"])
+	}
+
+	secret, err := client.Core().Secrets("default").Get("heketi-test-admin", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if _, ok := secret.Data[bindingKeyDataKey("binding-1")]; ok {
+		t.Errorf("expected admin bind not to issue a per-binding key")
+	}
+}
+
+func TestDoHeketiBindDegradesWithoutSecret(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	instance := &heketiServiceInstance{
+		ID:          "test",
+		Namespace:   "default",
+		ServiceName: "heketi-test",
+		AuthEnabled: true,
+	}
+
+	cred, err := doHeketiBind(client, nil, instance, "binding-1", false)
+	if err != nil {
+		t.Fatalf("doHeketiBind: %v", err)
+	}
+
+	if _, ok := cred["username"]; ok {
+		t.Errorf("expected no username in endpoint-only credential, got %v", cred)
+	}
+	if cred["url"] != "http://heketi-test.default.svc:8080" {
+		t.Errorf("credential[url] = %v, want endpoint URL", cred["url"])
+	}
+}
+
+func TestDoHeketiBindAuthDisabledOmitsKey(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-admin", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("s3cr3t")},
+	})
+	instance := &heketiServiceInstance{
+		ID:          "test",
+		Namespace:   "default",
+		SecretName:  "heketi-test-admin",
+		ServiceName: "heketi-test",
+		AuthEnabled: false,
+	}
+
+	cred, err := doHeketiBind(client, nil, instance, "binding-1", false)
+	if err != nil {
+		t.Fatalf("doHeketiBind: %v", err)
+	}
+
+	if cred["authEnabled"] != false {
+		t.Errorf("credential[authEnabled] = %v, want false", cred["authEnabled"])
+	}
+	if _, ok := cred["key"]; ok {
+		t.Errorf("expected no key in credential when auth is disabled, got %v", cred)
+	}
+	if _, ok := cred["username"]; ok {
+		t.Errorf("expected no username in credential when auth is disabled, got %v", cred)
+	}
+}
+
+func TestDoHeketiUnbindRevokesBindingKey(t *testing.T) {
+	secretName := adminSecretName("test")
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: "default"},
+		Data: map[string][]byte{
+			"key": []byte("s3cr3t"),
+			bindingKeyDataKey("binding-1"): []byte("binding-key"),
+		},
+	})
+
+	if err := revokeBindingKey(client, "default", secretName, "binding-1"); err != nil {
+		t.Fatalf("revokeBindingKey: %v", err)
+	}
+
+	secret, err := client.Core().Secrets("default").Get(secretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if _, ok := secret.Data[bindingKeyDataKey("binding-1")]; ok {
+		t.Errorf("expected binding-1's key to be revoked")
+	}
+	if string(secret.Data["key"]) != "s3cr3t" {
+		t.Errorf("expected admin key to be unaffected, got %q", secret.Data["key"])
+	}
+}
+
+func TestDoHeketiUnbindOfUnknownBindingIsNotAnError(t *testing.T) {
+	secretName := adminSecretName("test")
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("s3cr3t")},
+	})
+
+	if err := revokeBindingKey(client, "default", secretName, "never-bound"); err != nil {
+		t.Fatalf("revokeBindingKey: %v", err)
+	}
+}
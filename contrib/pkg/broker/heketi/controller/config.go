@@ -0,0 +1,197 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	configVolumeName = "config"
+	configMountPath  = "/etc/heketi"
+
+	executorMock = "mock"
+	executorSSH  = "ssh"
+
+	defaultLogLevel = "info"
+
+	// configHashAnnotation records the fingerprint of the heketi.json a pod
+	// template was created against. The Deployment's PodSpec never changes
+	// when only the ConfigMap's contents do, so without this annotation a
+	// config-only update would leave the running pod on the old config.
+	configHashAnnotation = "heketi.gluster.org/config-hash"
+)
+
+// heketiConfigOptions is the typed shape of the settings this broker
+// controls in an instance's heketi.json: the generated admin key, executor
+// selection, and log verbosity. Settings Heketi itself defaults, such as
+// the mock executor's simulated node behavior, are left unset.
+type heketiConfigOptions struct {
+	AdminKey    string
+	Executor    string
+	LogLevel    string
+	AuthEnabled bool
+}
+
+// resolveConfigOptions builds a heketiConfigOptions from an instance's admin
+// key, auth setting, and provision parameters. The executor is ssh when
+// cluster is set and mock otherwise; LogLevel defaults to "info" unless
+// overridden by the logLevel parameter.
+func resolveConfigOptions(req *brokerapi.CreateServiceInstanceRequest, adminKey string, cluster *existingCluster, auth *authConfig) *heketiConfigOptions {
+	logLevel, ok := req.Parameters["logLevel"].(string)
+	if !ok || logLevel == "" {
+		logLevel = defaultLogLevel
+	}
+
+	executor := executorMock
+	if cluster != nil {
+		executor = executorSSH
+	}
+
+	return &heketiConfigOptions{
+		AdminKey:    adminKey,
+		Executor:    executor,
+		LogLevel:    logLevel,
+		AuthEnabled: auth.Enabled,
+	}
+}
+
+// heketiConfigJSON renders opts into a heketi.json document. When
+// AuthEnabled is false, use_auth is turned off and the jwt section is
+// omitted entirely, since an admin key with nothing to check it against is
+// misleading rather than harmless.
+func heketiConfigJSON(opts *heketiConfigOptions) ([]byte, error) {
+	glusterfs := map[string]interface{}{
+		"executor": opts.Executor,
+		"db":       dbMountPath + "/heketi.db",
+		"loglevel": opts.LogLevel,
+	}
+	if opts.Executor == executorSSH {
+		glusterfs["sshexec"] = map[string]interface{}{
+			"keyfile": sshKeyMountPath,
+			"user":    sshExecutorUser,
+			"port":    sshExecutorPort,
+			"sudo":    false,
+		}
+	}
+
+	config := map[string]interface{}{
+		"port":      fmt.Sprintf("%d", heketiPort),
+		"use_auth":  opts.AuthEnabled,
+		"glusterfs": glusterfs,
+	}
+	if opts.AuthEnabled {
+		config["jwt"] = map[string]interface{}{
+			"admin": map[string]interface{}{"key": opts.AdminKey},
+		}
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
+
+// configHash fingerprints a rendered heketi.json for use in
+// configHashAnnotation.
+func configHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// setConfigHashAnnotation stamps a pod template with hash, so that changing
+// only heketi.json's contents still triggers a Deployment rollout.
+func setConfigHashAnnotation(template *v1.PodTemplateSpec, hash string) {
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[configHashAnnotation] = hash
+}
+
+// configMapName returns the name of instanceID's heketi.json ConfigMap.
+func configMapName(instanceID string) string {
+	return names.InstanceResourceName(brokerName, instanceID, "config")
+}
+
+// createHeketiConfigMap renders opts and stores it as instanceID's
+// heketi.json ConfigMap, returning its name and configHash.
+func createHeketiConfigMap(client kubernetes.Interface, namespace, instanceID string, opts *heketiConfigOptions, labels, annotations map[string]string) (name, hash string, err error) {
+	data, err := heketiConfigJSON(opts)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render heketi.json: %v", err)
+	}
+
+	name = configMapName(instanceID)
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Data: map[string]string{"heketi.json": string(data)},
+	}
+	if _, err := client.Core().ConfigMaps(namespace).Create(cm); err != nil {
+		return "", "", fmt.Errorf("failed to create heketi config map: %v", err)
+	}
+	return name, configHash(data), nil
+}
+
+// updateHeketiConfigMap re-renders opts into instanceID's existing
+// heketi.json ConfigMap, returning the new configHash.
+func updateHeketiConfigMap(client kubernetes.Interface, namespace, instanceID string, opts *heketiConfigOptions) (string, error) {
+	name := configMapName(instanceID)
+	cm, err := client.Core().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up heketi config map: %v", err)
+	}
+
+	data, err := heketiConfigJSON(opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to render heketi.json: %v", err)
+	}
+	cm.Data["heketi.json"] = string(data)
+
+	if _, err := client.Core().ConfigMaps(namespace).Update(cm); err != nil {
+		return "", fmt.Errorf("failed to update heketi config map: %v", err)
+	}
+	return configHash(data), nil
+}
+
+// attachHeketiConfig mounts an instance's rendered heketi.json ConfigMap
+// into the heketi container.
+func attachHeketiConfig(spec *v1.PodSpec, configMapName string) {
+	spec.Volumes = append(spec.Volumes, v1.Volume{
+		Name: configVolumeName,
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: configMapName},
+			},
+		},
+	})
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts,
+			v1.VolumeMount{Name: configVolumeName, MountPath: configMountPath},
+		)
+	}
+}
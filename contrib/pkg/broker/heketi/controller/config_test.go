@@ -0,0 +1,169 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+)
+
+// heketiJSONSchema is the subset of Heketi's own config schema this broker
+// populates; it's the shape a real Heketi server expects to parse.
+type heketiJSONSchema struct {
+	Port    string `json:"port"`
+	UseAuth bool   `json:"use_auth"`
+	JWT     struct {
+		Admin struct {
+			Key string `json:"key"`
+		} `json:"admin"`
+	} `json:"jwt"`
+	Glusterfs struct {
+		Executor string `json:"executor"`
+		DB       string `json:"db"`
+		LogLevel string `json:"loglevel"`
+		SSHExec  *struct {
+			Keyfile string `json:"keyfile"`
+			User    string `json:"user"`
+			Port    string `json:"port"`
+			Sudo    bool   `json:"sudo"`
+		} `json:"sshexec,omitempty"`
+	} `json:"glusterfs"`
+}
+
+func TestResolveConfigOptionsDefaults(t *testing.T) {
+	opts := resolveConfigOptions(&brokerapi.CreateServiceInstanceRequest{}, "admin-key", nil, &authConfig{Enabled: true})
+	if opts.Executor != executorMock {
+		t.Errorf("expected default executor %q, got %q", executorMock, opts.Executor)
+	}
+	if opts.LogLevel != defaultLogLevel {
+		t.Errorf("expected default logLevel %q, got %q", defaultLogLevel, opts.LogLevel)
+	}
+	if !opts.AuthEnabled {
+		t.Error("expected auth enabled by default")
+	}
+}
+
+func TestResolveConfigOptionsExistingClusterUsesSSHExecutor(t *testing.T) {
+	cluster := &existingCluster{Nodes: []string{"node1"}, SSHKeySecretName: "gluster-ssh"}
+	opts := resolveConfigOptions(&brokerapi.CreateServiceInstanceRequest{}, "admin-key", cluster, &authConfig{Enabled: true})
+	if opts.Executor != executorSSH {
+		t.Errorf("expected executor %q for an existing cluster, got %q", executorSSH, opts.Executor)
+	}
+}
+
+func TestResolveConfigOptionsHonorsLogLevelParameter(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"logLevel": "debug"}}
+	opts := resolveConfigOptions(req, "admin-key", nil, &authConfig{Enabled: true})
+	if opts.LogLevel != "debug" {
+		t.Errorf("expected logLevel %q, got %q", "debug", opts.LogLevel)
+	}
+}
+
+func TestResolveConfigOptionsHonorsAuthEnabled(t *testing.T) {
+	opts := resolveConfigOptions(&brokerapi.CreateServiceInstanceRequest{}, "admin-key", nil, &authConfig{Enabled: false})
+	if opts.AuthEnabled {
+		t.Error("expected auth disabled when authConfig.Enabled is false")
+	}
+}
+
+func TestHeketiConfigJSONMatchesSchema(t *testing.T) {
+	cases := []struct {
+		name string
+		opts *heketiConfigOptions
+	}{
+		{"mockExecutorInfoLog", &heketiConfigOptions{AdminKey: "key1", Executor: executorMock, LogLevel: "info", AuthEnabled: true}},
+		{"mockExecutorDebugLog", &heketiConfigOptions{AdminKey: "key2", Executor: executorMock, LogLevel: "debug", AuthEnabled: true}},
+		{"sshExecutor", &heketiConfigOptions{AdminKey: "key3", Executor: executorSSH, LogLevel: "warning", AuthEnabled: true}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := heketiConfigJSON(tc.opts)
+			if err != nil {
+				t.Fatalf("heketiConfigJSON: %v", err)
+			}
+
+			var parsed heketiJSONSchema
+			if err := json.Unmarshal(data, &parsed); err != nil {
+				t.Fatalf("generated heketi.json does not match Heketi's config schema: %v\n%s", err, data)
+			}
+
+			if !parsed.UseAuth {
+				t.Error("expected use_auth to be true")
+			}
+			if parsed.JWT.Admin.Key != tc.opts.AdminKey {
+				t.Errorf("expected jwt.admin.key %q, got %q", tc.opts.AdminKey, parsed.JWT.Admin.Key)
+			}
+			if parsed.Glusterfs.Executor != tc.opts.Executor {
+				t.Errorf("expected glusterfs.executor %q, got %q", tc.opts.Executor, parsed.Glusterfs.Executor)
+			}
+			if parsed.Glusterfs.LogLevel != tc.opts.LogLevel {
+				t.Errorf("expected glusterfs.loglevel %q, got %q", tc.opts.LogLevel, parsed.Glusterfs.LogLevel)
+			}
+
+			if tc.opts.Executor == executorSSH {
+				if parsed.Glusterfs.SSHExec == nil {
+					t.Fatal("expected sshexec to be set for the ssh executor")
+				}
+				if parsed.Glusterfs.SSHExec.Keyfile != sshKeyMountPath {
+					t.Errorf("expected sshexec.keyfile %q, got %q", sshKeyMountPath, parsed.Glusterfs.SSHExec.Keyfile)
+				}
+			} else if parsed.Glusterfs.SSHExec != nil {
+				t.Error("expected sshexec to be unset for the mock executor")
+			}
+		})
+	}
+}
+
+func TestConfigHashChangesWithContent(t *testing.T) {
+	a, err := heketiConfigJSON(&heketiConfigOptions{AdminKey: "key1", Executor: executorMock, LogLevel: "info", AuthEnabled: true})
+	if err != nil {
+		t.Fatalf("heketiConfigJSON: %v", err)
+	}
+	b, err := heketiConfigJSON(&heketiConfigOptions{AdminKey: "key1", Executor: executorMock, LogLevel: "debug", AuthEnabled: true})
+	if err != nil {
+		t.Fatalf("heketiConfigJSON: %v", err)
+	}
+
+	if configHash(a) == configHash(b) {
+		t.Error("expected different heketi.json contents to hash differently")
+	}
+	if configHash(a) != configHash(a) {
+		t.Error("expected configHash to be deterministic")
+	}
+}
+
+func TestHeketiConfigJSONOmitsJWTWhenAuthDisabled(t *testing.T) {
+	data, err := heketiConfigJSON(&heketiConfigOptions{AdminKey: "key1", Executor: executorMock, LogLevel: "info", AuthEnabled: false})
+	if err != nil {
+		t.Fatalf("heketiConfigJSON: %v", err)
+	}
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+
+	if parsed["use_auth"] != false {
+		t.Errorf("expected use_auth false, got %v", parsed["use_auth"])
+	}
+	if _, ok := parsed["jwt"]; ok {
+		t.Errorf("expected no jwt section when auth is disabled, got %v", parsed["jwt"])
+	}
+}
@@ -0,0 +1,1419 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package controller implements a broker that provisions Heketi, the
+// GlusterFS volume management REST service, as a Deployment in the cluster
+// the broker is running in.
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/reqlog"
+	"github.com/kubernetes-incubator/service-catalog/pkg"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/pkg/api"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// brokerName identifies this broker in the "broker" label applied to every
+// Kubernetes object it creates.
+const brokerName = "heketi"
+
+const (
+	serviceID = "b1c9c9c9-9c9c-4c9c-9c9c-9c9c9c9c9c9c"
+
+	planEphemeral  = "d2d2d2d2-2d2d-2d2d-2d2d-2d2d2d2d2d2d"
+	planPersistent = "e3e3e3e3-3e3e-3e3e-3e3e-3e3e3e3e3e3e"
+
+	// planID is kept as an alias of the original plan for existing callers.
+	planID = planEphemeral
+
+	planSmall  = "f4f4f4f4-4f4f-4f4f-4f4f-4f4f4f4f4f4f"
+	planMedium = "a5a5a5a5-5a5a-5a5a-5a5a-5a5a5a5a5a5a"
+	planLarge  = "b6b6b6b6-6b6b-6b6b-6b6b-6b6b6b6b6b6b"
+
+	heketiPort     = 8080
+	heketiPortName = "heketi"
+
+	dbVolumeName  = "db"
+	dbMountPath   = "/var/lib/heketi"
+	defaultDBSize = "1Gi"
+)
+
+// Provisioning phases reported through GetServiceInstanceLastOperation,
+// recorded on the instance as it moves from creation through to a running,
+// topology-loaded Heketi.
+const (
+	phaseCreatingResources = "creating resources"
+	phaseWaitingForPod     = "waiting for pod"
+	phaseWaitingForAPI     = "waiting for heketi API"
+	phaseLoadingTopology   = "loading topology"
+	phaseReady             = "ready"
+	phaseFailed            = "failed"
+)
+
+const defaultNamespace = "default"
+
+// defaultServiceAccount names the service account this controller reports
+// itself running as when Options.ServiceAccount is unset.
+const defaultServiceAccount = "default"
+
+type heketiServiceInstance struct {
+	ID             string
+	Namespace      string
+	DeploymentName string
+	SecretName     string
+	ServiceName    string
+	PlanID         string
+	PVCName        string
+	RetainData     bool
+	ConfigMapName  string
+	ExposeMode     string
+	ExposeHost     string
+	IngressName    string
+
+	// RouteName is set instead of IngressName when expose.Mode is
+	// exposeRoute, naming the Route created in place of an Ingress.
+	// ExposeHost still holds its host.
+	RouteName string
+
+	ResourceTier string
+	Executor     string
+	LogLevel     string
+
+	// Image is the resolved image, including tag, this instance's
+	// Deployment was created with: the broker's configured default,
+	// overridden by the imageTag provision parameter when given.
+	Image string
+
+	// StorageClassName is the name of the StorageClass created for
+	// dynamic GlusterFS provisioning against this instance, if the
+	// createStorageClass provision parameter was set. Empty otherwise.
+	StorageClassName string
+
+	// AuthEnabled records whether this instance's heketi.json currently
+	// has JWT auth turned on, so Bind knows whether to hand out keys and
+	// UpdateServiceInstance knows whether the authEnabled parameter is
+	// actually changing anything.
+	AuthEnabled bool
+
+	// AdminKeyMismatch records the error message from the most recent
+	// Bind's checkAdminKeyConsistency check, if it found the secret's
+	// admin key out of sync with the running pod. Empty otherwise. It
+	// surfaces the problem on later status polls too, since the
+	// mismatch was already caught at bind time rather than there.
+	AdminKeyMismatch string
+
+	// Phase is this instance's current provisioning phase, one of the
+	// phase* constants. GetServiceInstanceLastOperation answers from this
+	// field directly once it reaches phaseReady or phaseFailed.
+	Phase string
+
+	// FailureDetail explains why Phase is phaseFailed. Empty otherwise.
+	FailureDetail string
+
+	// Conditions is this instance's Kubernetes-style status conditions,
+	// kept alongside Phase for tooling that wants to key off a stable set
+	// of condition types instead of parsing the free-form phase strings.
+	// Reported through SnapshotState.
+	Conditions []controller.Condition
+
+	// Description caches the operator-facing status text computed when
+	// Phase reached phaseReady, so a succeeded instance can be polled
+	// without re-querying the cluster or the Heketi API.
+	Description string
+
+	// CreatedAt is when this instance was provisioned, reported through
+	// SnapshotState for age-based metrics and admin output.
+	CreatedAt time.Time
+
+	// Bindings tracks which bindingIDs currently have a live key issued
+	// against this instance, so SnapshotState can report a binding count
+	// that reflects distinct bindings rather than the number of Bind
+	// calls received (doHeketiBind is idempotent on bindingID).
+	Bindings map[string]struct{}
+}
+
+type heketiController struct {
+	rwMutex     sync.RWMutex
+	instanceMap map[string]*heketiServiceInstance
+
+	// skipPVCDelete leaves a persistent-plan instance's database
+	// PersistentVolumeClaim in place on deprovision, letting operators
+	// recover its data even when the instance itself did not request
+	// retainData.
+	skipPVCDelete bool
+
+	// defaultResourceTier is applied to instances that don't request a
+	// "resources" tier of their own. Empty means BestEffort, which keeps
+	// existing users' behavior unchanged unless the broker opts in.
+	defaultResourceTier string
+
+	// statusReader retrieves cluster summary info for GetServiceInstanceLastOperation.
+	// It is an interface so tests can substitute a fake Heketi API.
+	statusReader heketiStatusReader
+
+	// keepFailedInstances skips rollback of a failed provisioning attempt's
+	// Kubernetes objects, and keeps the instance's record around in
+	// phaseFailed, so an operator can inspect what was created instead of
+	// having it torn down automatically.
+	keepFailedInstances bool
+
+	// allowAdminBind lets a Bind request opt into receiving the shared
+	// admin key, via an "admin: true" bind parameter, instead of a
+	// per-binding user key. Off by default, since handing out the admin
+	// key defeats the purpose of scoping each binding to its own key.
+	allowAdminBind bool
+
+	// heketiImage is the image, including tag, run for an instance that
+	// doesn't override it with the imageTag provision parameter.
+	heketiImage string
+
+	// imagePullSecret, when set, names a secret (in brokerNamespace) to
+	// use for pulling heketiImage on every instance that does not
+	// override it via the imagePullSecret parameter.
+	imagePullSecret string
+
+	// brokerNamespace is the namespace this broker's own pod runs in,
+	// where imagePullSecret is looked up.
+	brokerNamespace string
+
+	// allowDestructiveDeprovision lets a deprovision request through even
+	// when the instance still manages Gluster volumes, without requiring
+	// the request to carry a "force" parameter. Off by default, since a
+	// deprovision that strands live volumes is rarely what an operator
+	// meant to do.
+	allowDestructiveDeprovision bool
+
+	// allowedNamespaces restricts which namespaces an instance may be
+	// provisioned into. Empty means unrestricted.
+	allowedNamespaces map[string]bool
+
+	// defaultNamespace is used for a request whose context carries no
+	// namespace, when allowDefaultNamespace permits it.
+	defaultNamespace string
+
+	// allowDefaultNamespace gates falling back to defaultNamespace for a
+	// request that names no namespace, instead of rejecting it.
+	allowDefaultNamespace bool
+
+	// namespacePerInstance provisions every instance into a dedicated
+	// namespace this controller creates and owns, instead of the requesting
+	// namespace.
+	namespacePerInstance bool
+
+	// serviceAccount is the name of the service account this controller
+	// runs as, used only to name it in a kube.ErrForbidden message when the
+	// Kubernetes API rejects a request as forbidden.
+	serviceAccount string
+
+	// kubeClient is built once, at construction, and reused for every
+	// request instead of dialing a fresh clientset per call.
+	kubeClient kubernetes.Interface
+
+	// platform is kube.PlatformOpenShift when Options.Platform requested
+	// it, allowing expose: route in addition to expose: ingress. Defaults
+	// to kube.PlatformKubernetes.
+	platform kube.Platform
+
+	// routeClient talks to the Route API and is non-nil only when platform
+	// is kube.PlatformOpenShift.
+	routeClient kube.RouteClient
+
+	// recorder publishes Kubernetes Events for instance lifecycle
+	// transitions, so an operator running `kubectl describe` on an
+	// instance's Deployment sees why it failed without digging through
+	// the broker's own logs.
+	recorder record.EventRecorder
+
+	// retryAttempts and retryBaseDelay configure kube.Retry for the calls
+	// this controller makes against the Kubernetes API.
+	retryAttempts  int
+	retryBaseDelay time.Duration
+
+	// provisionTimeout and bindTimeout bound how long a provision/deprovision
+	// or bind/unbind operation's context runs before it is canceled.
+	provisionTimeout time.Duration
+	bindTimeout      time.Duration
+
+	// podSpecOverride, when non-nil, is applied on top of every instance
+	// Deployment's pod template, loaded once at construction from
+	// Options.TemplatesDir.
+	podSpecOverride *kube.PodSpecOverride
+
+	// podLister, when non-nil, is read instead of listing Pods directly
+	// against the API for every pod address and status lookup. It's backed
+	// by a shared informer started at construction, unless
+	// Options.DisableInformers opted out of it.
+	podLister v1listers.PodLister
+}
+
+// Options configures the Heketi broker controller.
+type Options struct {
+	// SkipPVCDelete leaves every instance's PersistentVolumeClaim in place
+	// on deprovision, regardless of that instance's retainData parameter.
+	SkipPVCDelete bool
+
+	// DefaultResources applies the small resource tier to instances that
+	// don't request one of their own, instead of leaving them BestEffort.
+	DefaultResources bool
+
+	// KeepFailedInstances skips rollback of a failed provisioning attempt,
+	// leaving its Kubernetes objects and instance record in place for an
+	// operator to debug instead of cleaning them up automatically.
+	KeepFailedInstances bool
+
+	// AllowAdminBind lets a Bind request receive the shared admin key via
+	// an "admin: true" bind parameter, instead of a per-binding user key.
+	AllowAdminBind bool
+
+	// HeketiImage is the image, including tag, run for instances that
+	// don't override it with the imageTag provision parameter. Defaults
+	// to defaultHeketiImage.
+	HeketiImage string
+
+	// ImagePullSecret is the name of a secret, in BrokerNamespace, used by
+	// default to pull the Heketi image.
+	ImagePullSecret string
+
+	// BrokerNamespace is the namespace this broker's own pod runs in,
+	// where ImagePullSecret is looked up. Defaults to $POD_NAMESPACE, or
+	// "default" if that isn't set either.
+	BrokerNamespace string
+
+	// AllowDestructiveDeprovision lets a deprovision request through even
+	// when the instance still manages Gluster volumes, without requiring
+	// the request to carry a "force" parameter.
+	AllowDestructiveDeprovision bool
+
+	// AllowedNamespaces restricts which namespaces an instance may be
+	// provisioned into. Empty means unrestricted.
+	AllowedNamespaces []string
+
+	// DefaultNamespace is used for a request whose context carries no
+	// namespace, when AllowDefaultNamespace permits it. Defaults to
+	// "default".
+	DefaultNamespace string
+
+	// AllowDefaultNamespace lets a request that names no namespace fall
+	// back to DefaultNamespace, instead of being rejected.
+	AllowDefaultNamespace bool
+
+	// ServiceAccount is the name of the service account this broker runs
+	// as. It is used only to name it in an error naming the RBAC a
+	// forbidden request is missing; it is not used to authenticate.
+	// Defaults to "default".
+	ServiceAccount string
+
+	// PreflightDryRun has no effect beyond a one-time warning logged by
+	// CreateController: this broker's Kubernetes client cannot submit a
+	// dry-run create, so no preflight check runs and an admission webhook
+	// rejection still surfaces only on the real create.
+	PreflightDryRun bool
+
+	// NamespacePerInstance provisions every instance's Deployment into a
+	// dedicated namespace this controller creates and owns, instead of the
+	// requesting namespace, so quota and RBAC scoped to that namespace
+	// apply to exactly one instance. AllowedNamespaces and DefaultNamespace
+	// still gate the requesting namespace the request came in on.
+	NamespacePerInstance bool
+
+	// KubeconfigPath, when set, is used to build the Kubernetes client if
+	// the broker is not running in-cluster, for local development.
+	KubeconfigPath string
+
+	// KubeContext selects a context by name from the kubeconfig at
+	// KubeconfigPath, instead of its current-context. Setting it skips the
+	// in-cluster configuration, so the kubeconfig is loaded even in-cluster.
+	KubeContext string
+
+	// Platform is "kubernetes" (the default) or "openshift". On
+	// "openshift", expose: route is additionally accepted, creating a
+	// Route instead of an Ingress, since OpenShift clusters don't run an
+	// Ingress controller by default.
+	Platform string
+
+	// KubeAPIQPS and KubeAPIBurst cap the rate of Kubernetes API calls this
+	// broker's client makes, easing apiserver load in a large cluster.
+	// Zero keeps client-go's built-in defaults.
+	KubeAPIQPS   float32
+	KubeAPIBurst int
+
+	// RetryAttempts is the number of times a Kubernetes API call is
+	// attempted before giving up on a transient error. Defaults to
+	// kube.DefaultRetryAttempts.
+	RetryAttempts int
+
+	// RetryBaseDelay is the delay before the first retry of a Kubernetes
+	// API call that failed with a transient error, doubling on each
+	// subsequent retry. Defaults to kube.DefaultRetryBaseDelay.
+	RetryBaseDelay time.Duration
+
+	// ProvisionTimeout bounds how long a provision or deprovision operation
+	// runs before its context is canceled. Defaults to
+	// kube.DefaultProvisionTimeout.
+	ProvisionTimeout time.Duration
+
+	// BindTimeout bounds how long a bind or unbind operation runs before its
+	// context is canceled. Defaults to kube.DefaultBindTimeout.
+	BindTimeout time.Duration
+
+	// TemplatesDir, when set, is checked for a heketi-pod.yaml overriding
+	// the resource requests/limits, node selector, tolerations, and
+	// annotations of every instance's pod, without requiring a rebuild of
+	// the broker to tune them. A missing file falls back to the built-in
+	// shape; a malformed one fails CreateController.
+	TemplatesDir string
+
+	// DisableInformers falls back to a direct Pods List for every pod
+	// address and status lookup, instead of a shared informer's cache.
+	// Useful on a tiny cluster where the informer's List-and-Watch isn't
+	// worth the extra apiserver connection.
+	DisableInformers bool
+}
+
+// CreateController creates an instance of a Heketi service broker
+// controller, building its Kubernetes client up front so a broken
+// in-cluster config or kubeconfig fails the broker at startup instead of
+// on its first request.
+func CreateController(opts Options) (controller.Controller, error) {
+	client, err := kube.NewClient(opts.KubeconfigPath, kube.ClientOptions{
+		Context:   opts.KubeContext,
+		QPS:       opts.KubeAPIQPS,
+		Burst:     opts.KubeAPIBurst,
+		UserAgent: fmt.Sprintf("%s/%s", brokerName, pkg.VERSION),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	allowedNamespaces := make(map[string]bool, len(opts.AllowedNamespaces))
+	for _, ns := range opts.AllowedNamespaces {
+		allowedNamespaces[ns] = true
+	}
+	defaultNS := opts.DefaultNamespace
+	if defaultNS == "" {
+		defaultNS = defaultNamespace
+	}
+	serviceAccount := opts.ServiceAccount
+	if serviceAccount == "" {
+		serviceAccount = defaultServiceAccount
+	}
+	if opts.PreflightDryRun {
+		kube.WarnPreflightDryRunUnsupported()
+	}
+	platform, err := kube.ParsePlatform(opts.Platform)
+	if err != nil {
+		return nil, err
+	}
+	var routeClient kube.RouteClient
+	if platform == kube.PlatformOpenShift {
+		restConfig, err := kube.RestConfig(opts.KubeconfigPath, opts.KubeContext)
+		if err != nil {
+			return nil, err
+		}
+		routeClient, err = kube.NewRouteClient(restConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defaultTier := ""
+	if opts.DefaultResources {
+		defaultTier = tierSmall
+	}
+	image := opts.HeketiImage
+	if image == "" {
+		image = defaultHeketiImage
+	}
+	retryAttempts := opts.RetryAttempts
+	if retryAttempts <= 0 {
+		retryAttempts = kube.DefaultRetryAttempts
+	}
+	retryBaseDelay := opts.RetryBaseDelay
+	if retryBaseDelay <= 0 {
+		retryBaseDelay = kube.DefaultRetryBaseDelay
+	}
+	provisionTimeout := opts.ProvisionTimeout
+	if provisionTimeout <= 0 {
+		provisionTimeout = kube.DefaultProvisionTimeout
+	}
+	bindTimeout := opts.BindTimeout
+	if bindTimeout <= 0 {
+		bindTimeout = kube.DefaultBindTimeout
+	}
+	var podSpecOverride *kube.PodSpecOverride
+	if opts.TemplatesDir != "" {
+		podSpecOverride, err = kube.LoadPodSpecOverride(filepath.Join(opts.TemplatesDir, "heketi-pod.yaml"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	var podLister v1listers.PodLister
+	if !opts.DisableInformers {
+		podLister, err = kube.NewPodInformer(client, wait.NeverStop)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &heketiController{
+		instanceMap:                 make(map[string]*heketiServiceInstance),
+		skipPVCDelete:               opts.SkipPVCDelete,
+		defaultResourceTier:         defaultTier,
+		statusReader:                newHTTPHeketiStatusReader(),
+		keepFailedInstances:         opts.KeepFailedInstances,
+		allowAdminBind:              opts.AllowAdminBind,
+		heketiImage:                 image,
+		imagePullSecret:             opts.ImagePullSecret,
+		brokerNamespace:             kube.PodNamespace(opts.BrokerNamespace),
+		allowDestructiveDeprovision: opts.AllowDestructiveDeprovision,
+		allowedNamespaces:           allowedNamespaces,
+		defaultNamespace:            defaultNS,
+		allowDefaultNamespace:       opts.AllowDefaultNamespace,
+		namespacePerInstance:        opts.NamespacePerInstance,
+		serviceAccount:              serviceAccount,
+		kubeClient:                  client,
+		platform:                    platform,
+		routeClient:                 routeClient,
+		recorder:                    kube.NewEventRecorder(client, brokerName),
+		retryAttempts:               retryAttempts,
+		retryBaseDelay:              retryBaseDelay,
+		provisionTimeout:            provisionTimeout,
+		bindTimeout:                 bindTimeout,
+		podSpecOverride:             podSpecOverride,
+		podLister:                   podLister,
+	}, nil
+}
+
+// resolveImagePullSecret returns the name of the secret to reference on the
+// instance's Deployment, copying a broker-namespace secret into the
+// instance namespace when necessary. It returns an error if the referenced
+// secret cannot be found, so that provisioning fails before the Deployment
+// is created rather than leaving it stuck ImagePullBackOff. brokerPullSecret
+// is the broker-wide default (the --image-pull-secret flag); an
+// imagePullSecret provision parameter overrides it for one instance.
+func resolveImagePullSecret(client kubernetes.Interface, namespace, brokerPullSecret, brokerNamespace, serviceAccount string, req *brokerapi.CreateServiceInstanceRequest, instanceID string) (string, error) {
+	name := brokerPullSecret
+	if v, ok := req.Parameters["imagePullSecret"]; ok {
+		if s, ok := v.(string); ok && s != "" {
+			name = s
+		}
+	}
+	if name == "" {
+		return "", nil
+	}
+
+	secret, err := client.Core().Secrets(brokerNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("image pull secret %q not found in namespace %q: %v", name, brokerNamespace, err)
+	}
+	if namespace == brokerNamespace {
+		return name, nil
+	}
+
+	copyName := names.InstanceResourceName(name, instanceID, "")
+	copySecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      copyName,
+			Namespace: namespace,
+			Labels:    map[string]string{kube.InstanceLabelKey(): instanceID},
+		},
+		Type: secret.Type,
+		Data: secret.Data,
+	}
+	if _, err := client.Core().Secrets(namespace).Create(copySecret); err != nil {
+		err = kube.TranslateForbidden(err, "create", "secrets", namespace, serviceAccount)
+		return "", fmt.Errorf("failed to copy image pull secret %q into namespace %q: %v", name, namespace, err)
+	}
+	return copyName, nil
+}
+
+func (c *heketiController) Catalog() (*brokerapi.Catalog, error) {
+	glog.Info("Catalog()")
+	return &brokerapi.Catalog{
+		Services: []*brokerapi.Service{
+			{
+				Name:        "heketi",
+				ID:          serviceID,
+				Description: "A GlusterFS volume management service",
+				Plans: []brokerapi.ServicePlan{
+					{
+						Name:        "default",
+						ID:          planEphemeral,
+						Description: "A single Heketi instance with ephemeral storage",
+						Free:        true,
+					},
+					{
+						Name:        "persistent",
+						ID:          planPersistent,
+						Description: "A single Heketi instance whose database is backed by a PersistentVolumeClaim",
+						Free:        true,
+					},
+					{
+						Name:        "small",
+						ID:          planSmall,
+						Description: "A Heketi instance sized for " + resourceTiers[tierSmall].Description,
+						Free:        true,
+						Metadata:    resourceTiers[tierSmall],
+					},
+					{
+						Name:        "medium",
+						ID:          planMedium,
+						Description: "A Heketi instance sized for " + resourceTiers[tierMedium].Description,
+						Free:        true,
+						Metadata:    resourceTiers[tierMedium],
+					},
+					{
+						Name:        "large",
+						ID:          planLarge,
+						Description: "A Heketi instance sized for " + resourceTiers[tierLarge].Description,
+						Free:        true,
+						Metadata:    resourceTiers[tierLarge],
+					},
+				},
+				Bindable:       true,
+				PlanUpdateable: true,
+			},
+		},
+	}, nil
+}
+
+// resolveNamespace determines the effective namespace for a new instance
+// from its request context, falling back to c.defaultNamespace only when
+// c.allowDefaultNamespace permits it; otherwise a request naming no
+// namespace is rejected instead of silently landing in one the caller
+// never asked for.
+func (c *heketiController) resolveNamespace(req *brokerapi.CreateServiceInstanceRequest) (string, error) {
+	if req.ContextProfile.Namespace != "" {
+		return req.ContextProfile.Namespace, nil
+	}
+	if !c.allowDefaultNamespace {
+		return "", fmt.Errorf("no namespace given in the request context, and default namespace is disabled (enable it with --allow-default-namespace)")
+	}
+	return c.defaultNamespace, nil
+}
+
+// resolveInstanceNamespace returns requestingNamespace unchanged, unless
+// c.namespacePerInstance is set, in which case it creates (or reuses, on
+// retry) a dedicated namespace for id via kube.EnsureInstanceNamespace and
+// returns that instead. requestingNamespace has already been validated
+// against c.allowedNamespaces by the time this is called, so quota and
+// allow-list checks still apply to the namespace the request named, not the
+// one actually used.
+func (c *heketiController) resolveInstanceNamespace(client kubernetes.Interface, id, requestingNamespace string) (string, error) {
+	if !c.namespacePerInstance {
+		return requestingNamespace, nil
+	}
+	instanceNamespace := kube.InstanceNamespaceName(brokerName, id)
+	if err := kube.EnsureInstanceNamespace(client, brokerName, id, instanceNamespace); err != nil {
+		return "", err
+	}
+	return instanceNamespace, nil
+}
+
+// CreateServiceInstance validates the request and registers instance id in
+// phaseCreatingResources, then either hands the remaining provisioning work
+// off to provisionInstance in a goroutine (when the caller accepts an
+// incomplete response) or runs it inline and waits for the outcome. Either
+// way, the same provisionInstance drives resource creation and readiness, so
+// GetServiceInstanceLastOperation sees the same phase progression regardless
+// of which path was taken.
+func (c *heketiController) CreateServiceInstance(
+	ctx context.Context,
+	id string,
+	req *brokerapi.CreateServiceInstanceRequest,
+) (*brokerapi.CreateServiceInstanceResponse, error) {
+	reqlog.FromContext(ctx).Infof("CreateServiceInstance()")
+	client := c.kubeClient
+	namespace, err := c.resolveNamespace(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := kube.ValidateTargetNamespace(client, namespace, c.allowedNamespaces); err != nil {
+		return nil, err
+	}
+	namespace, err = c.resolveInstanceNamespace(client, id, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	topo, err := parseTopology(req)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster, err := parseExistingCluster(client, namespace, req)
+	if err != nil {
+		return nil, err
+	}
+
+	expose, err := parseExposeConfig(req)
+	if err != nil {
+		return nil, err
+	}
+
+	tier, err := resolveResourceTier(req, c.defaultResourceTier)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := parseAuthConfig(req)
+	if err != nil {
+		return nil, err
+	}
+
+	instance := &heketiServiceInstance{
+		ID:          id,
+		Namespace:   namespace,
+		PlanID:      req.PlanID,
+		RetainData:  retainData(req),
+		AuthEnabled: auth.Enabled,
+		Phase:       phaseCreatingResources,
+		CreatedAt:   time.Now(),
+		Bindings:    make(map[string]struct{}),
+	}
+	setInstanceCondition(instance, controller.ConditionProvisioned, controller.ConditionFalse, "Provisioning", "")
+	setInstanceCondition(instance, controller.ConditionReady, controller.ConditionFalse, "Provisioning", "")
+	c.rwMutex.Lock()
+	c.instanceMap[id] = instance
+	c.rwMutex.Unlock()
+
+	c.recorder.Event(kube.ObjectRef("Namespace", namespace, namespace), api.EventTypeNormal, kube.ReasonProvisioning, "Provisioning Heketi instance")
+
+	provision := func(ctx context.Context) error {
+		rollback := &kube.RollbackTracker{}
+		adminKey, err := createInstanceResources(ctx, client, instance, req, cluster, expose, tier, auth, c.heketiImage, c.imagePullSecret, c.brokerNamespace, c.serviceAccount, c.retryAttempts, c.retryBaseDelay, c.podSpecOverride, c.platform, c.routeClient, rollback)
+		if err == nil {
+			err = c.provisionInstance(ctx, client, instance, adminKey, topo, wantsStorageClass(req))
+		}
+		if err != nil {
+			if !c.keepFailedInstances {
+				if rbErr := rollback.Run(ctx); rbErr != nil {
+					glog.Errorf("rollback: %v", rbErr)
+				}
+			}
+			kube.RecordProvisionFailed(c.recorder, c.instanceEventRef(instance), "Heketi", err)
+			return err
+		}
+		rollback.Commit()
+		c.recorder.Event(c.instanceEventRef(instance), api.EventTypeNormal, kube.ReasonProvisioned, "Provisioned Heketi instance")
+		return nil
+	}
+
+	if req.AcceptsIncomplete {
+		go func() {
+			// A background provision outlives the request that triggered
+			// it, so it gets its own timeout instead of the request
+			// context, which is canceled as soon as this handler returns.
+			ctx, cancel := context.WithTimeout(context.Background(), c.provisionTimeout)
+			defer cancel()
+			if err := provision(ctx); err != nil {
+				c.failInstance(id, err)
+			}
+		}()
+		return &brokerapi.CreateServiceInstanceResponse{Operation: "provision"}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.provisionTimeout)
+	defer cancel()
+	if err := provision(ctx); err != nil {
+		if c.keepFailedInstances {
+			c.failInstance(id, err)
+		} else {
+			c.rwMutex.Lock()
+			delete(c.instanceMap, id)
+			c.rwMutex.Unlock()
+		}
+		return nil, fmt.Errorf("failed to provision heketi instance: %v", err)
+	}
+
+	glog.Infof("Created Heketi Service Instance:\n%v\n", instance)
+	return &brokerapi.CreateServiceInstanceResponse{}, nil
+}
+
+// instanceEventRef returns the object an Event about instance should be
+// attached to: its Deployment once createInstanceResources has created one,
+// or its namespace before that, so `kubectl describe` on either surfaces
+// the instance's lifecycle history.
+func (c *heketiController) instanceEventRef(instance *heketiServiceInstance) *v1.ObjectReference {
+	if instance.DeploymentName != "" {
+		return kube.ObjectRef("Deployment", instance.Namespace, instance.DeploymentName)
+	}
+	return kube.ObjectRef("Namespace", instance.Namespace, instance.Namespace)
+}
+
+// createInstanceResources creates the Kubernetes objects backing instance:
+// the admin secret, its heketi.json config map, the Deployment, and,
+// depending on req, a database PersistentVolumeClaim, an SSH key mount for
+// an existing GlusterFS cluster, and a Service/Ingress pair (or, on
+// platform kube.PlatformOpenShift, a Service/Route pair). It fills in
+// instance's fields as each resource is created, registers an undo for it
+// with rollback, and returns the admin key provisionInstance needs to talk
+// to the running instance. routeClient is non-nil exactly when platform is
+// kube.PlatformOpenShift.
+func createInstanceResources(
+	ctx context.Context,
+	client kubernetes.Interface,
+	instance *heketiServiceInstance,
+	req *brokerapi.CreateServiceInstanceRequest,
+	cluster *existingCluster,
+	expose *exposeConfig,
+	tier *resourceTier,
+	auth *authConfig,
+	defaultImage, brokerPullSecret, brokerNamespace, serviceAccount string,
+	retryAttempts int,
+	retryBaseDelay time.Duration,
+	podSpecOverride *kube.PodSpecOverride,
+	platform kube.Platform,
+	routeClient kube.RouteClient,
+	rollback *kube.RollbackTracker,
+) (adminKey string, err error) {
+	id, namespace := instance.ID, instance.Namespace
+	labels := kube.CommonLabels(brokerName, pkg.VERSION, serviceID, req.PlanID, id, namespace)
+	annotations := kube.TraceAnnotations(reqlog.FromContext(ctx).CorrelationID(), "provision")
+
+	secretName, err := createAdminSecret(client, namespace, id, auth.Key, labels, annotations)
+	if err != nil {
+		return "", err
+	}
+	instance.SecretName = secretName
+	rollback.Add("delete heketi admin secret", func(ctx context.Context) error {
+		return client.Core().Secrets(namespace).Delete(secretName, nil)
+	})
+
+	adminKey, err = readAdminKey(client, namespace, secretName)
+	if err != nil {
+		return "", err
+	}
+
+	image, err := resolveImage(defaultImage, req)
+	if err != nil {
+		return "", err
+	}
+	instance.Image = image
+
+	pullSecretName, err := resolveImagePullSecret(client, namespace, brokerPullSecret, brokerNamespace, serviceAccount, req, id)
+	if err != nil {
+		return "", err
+	}
+	if pullSecretName != "" && namespace != brokerNamespace {
+		rollback.Add("delete heketi image pull secret", func(ctx context.Context) error {
+			return client.Core().Secrets(namespace).Delete(pullSecretName, nil)
+		})
+	}
+
+	deployment := newHeketiInstanceResources(deploymentName(id), namespace, id, secretName, labels, annotations, &deploymentOptions{
+		Image:           image,
+		ImagePullSecret: pullSecretName,
+		Tier:            tier,
+	})
+	setAdminKeyHashAnnotation(&deployment.Spec.Template, adminKey)
+	instance.DeploymentName = deployment.Name
+	if tier != nil {
+		instance.ResourceTier = tier.Name
+	}
+
+	if req.PlanID == planPersistent {
+		instance.PVCName = names.InstanceResourceName(brokerName, id, "db")
+		if err := createDBVolumeClaim(client, namespace, instance.PVCName, req, labels, annotations); err != nil {
+			return "", err
+		}
+		rollback.Add("delete heketi instance PVC", func(ctx context.Context) error {
+			return client.Core().PersistentVolumeClaims(namespace).Delete(instance.PVCName, nil)
+		})
+		attachDBVolume(&deployment.Spec.Template.Spec, instance.PVCName)
+	}
+
+	configOpts := resolveConfigOptions(req, adminKey, cluster, auth)
+	configMapName, configHashValue, err := createHeketiConfigMap(client, namespace, id, configOpts, labels, annotations)
+	if err != nil {
+		return "", err
+	}
+	instance.ConfigMapName = configMapName
+	instance.Executor = configOpts.Executor
+	instance.LogLevel = configOpts.LogLevel
+	rollback.Add("delete heketi config configmap", func(ctx context.Context) error {
+		return client.Core().ConfigMaps(namespace).Delete(configMapName, nil)
+	})
+	attachHeketiConfig(&deployment.Spec.Template.Spec, configMapName)
+	setConfigHashAnnotation(&deployment.Spec.Template, configHashValue)
+	if cluster != nil {
+		attachSSHKey(&deployment.Spec.Template.Spec, cluster.SSHKeySecretName)
+	}
+	kube.ApplyPodSpecOverride(&deployment.Spec.Template.Spec, &deployment.Spec.Template.ObjectMeta, podSpecOverride)
+
+	createErr := kube.Retry(ctx, retryAttempts, retryBaseDelay, "create heketi instance deployment", func() error {
+		_, err := client.Apps().Deployments(namespace).Create(deployment)
+		return kube.TranslateForbidden(err, "create", "deployments", namespace, serviceAccount)
+	})
+	if createErr != nil {
+		return "", fmt.Errorf("failed to create heketi instance deployment: %v", createErr)
+	}
+	rollback.Add("delete heketi instance deployment", func(ctx context.Context) error {
+		return client.Apps().Deployments(namespace).Delete(deployment.Name, nil)
+	})
+
+	serviceName, err := createHeketiService(client, namespace, id, expose.Mode, labels, annotations)
+	if err != nil {
+		return "", err
+	}
+	instance.ServiceName = serviceName
+	instance.ExposeMode = expose.Mode
+	instance.ExposeHost = expose.Host
+	rollback.Add("delete heketi instance service", func(ctx context.Context) error {
+		return client.Core().Services(namespace).Delete(serviceName, nil)
+	})
+
+	if expose.Mode == exposeRoute && platform != kube.PlatformOpenShift {
+		return "", kube.ErrPlatformNotConfigured{Resource: "expose: route", Required: kube.PlatformOpenShift}
+	}
+
+	if expose.Mode == exposeIngress {
+		ingressName, err := createHeketiIngress(client, namespace, id, serviceName, expose.Host, labels, annotations)
+		if err != nil {
+			return "", err
+		}
+		instance.IngressName = ingressName
+		rollback.Add("delete heketi ingress", func(ctx context.Context) error {
+			return client.Extensions().Ingresses(namespace).Delete(ingressName, nil)
+		})
+	} else if expose.Mode == exposeRoute {
+		routeName, err := createHeketiRoute(routeClient, namespace, id, serviceName, expose.Host, labels, annotations)
+		if err != nil {
+			return "", err
+		}
+		instance.RouteName = routeName
+		rollback.Add("delete heketi route", func(ctx context.Context) error {
+			return routeClient.Delete(namespace, routeName)
+		})
+	}
+
+	return adminKey, nil
+}
+
+// provisionInstance advances instance through phaseWaitingForPod,
+// phaseWaitingForAPI and, if topo was requested, phaseLoadingTopology,
+// recording each phase as it's entered. If createStorageClass is set, it
+// creates instance's StorageClass once the Heketi API is confirmed ready,
+// so dynamic GlusterFS provisioning has a resturl to reach. It marks
+// instance phaseReady with a cached description on success, and otherwise
+// returns the first error encountered, leaving the failure phase and
+// rollback decision to the caller.
+func (c *heketiController) provisionInstance(ctx context.Context, client kubernetes.Interface, instance *heketiServiceInstance, adminKey string, topo *topology, createStorageClass bool) error {
+	c.setPhase(instance.ID, phaseWaitingForPod)
+	if err := waitForPodRunning(ctx, client, instance.Namespace, instance.ID); err != nil {
+		return err
+	}
+
+	c.setPhase(instance.ID, phaseWaitingForAPI)
+	serviceURL := heketiServiceURL(instance.Namespace, instance.ServiceName)
+	if err := waitForHeketiReady(ctx, serviceURL); err != nil {
+		return err
+	}
+
+	if topo != nil {
+		c.setPhase(instance.ID, phaseLoadingTopology)
+		if err := loadTopology(serviceURL, adminKey, topo); err != nil {
+			return fmt.Errorf("failed to load heketi topology: %v", err)
+		}
+	}
+
+	if createStorageClass {
+		labels := kube.CommonLabels(brokerName, pkg.VERSION, serviceID, instance.PlanID, instance.ID, instance.Namespace)
+		annotations := kube.TraceAnnotations(reqlog.FromContext(ctx).CorrelationID(), "provision")
+		name, err := createHeketiStorageClass(client, instance.ID, instance.Namespace, instance.SecretName, serviceURL, labels, annotations)
+		if err != nil {
+			return err
+		}
+		instance.StorageClassName = name
+	}
+
+	c.markReady(instance.ID, c.buildReadyDescription(client, instance))
+	return nil
+}
+
+// setPhase records instance's current provisioning phase, so
+// GetServiceInstanceLastOperation can answer terminal instances without
+// re-probing the cluster.
+func (c *heketiController) setPhase(instanceID, phase string) {
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+	if instance, ok := c.instanceMap[instanceID]; ok {
+		instance.Phase = phase
+	}
+}
+
+// failInstance records instanceID as phaseFailed, with err's detail
+// surfaced by GetServiceInstanceLastOperation.
+func (c *heketiController) failInstance(instanceID string, err error) {
+	glog.Errorf("heketi instance %s failed to provision: %v", instanceID, err)
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+	if instance, ok := c.instanceMap[instanceID]; ok {
+		instance.Phase = phaseFailed
+		instance.FailureDetail = err.Error()
+		setInstanceCondition(instance, controller.ConditionProvisioned, controller.ConditionFalse, "Failed", err.Error())
+		setInstanceCondition(instance, controller.ConditionReady, controller.ConditionFalse, "Failed", err.Error())
+		setInstanceCondition(instance, controller.ConditionDegraded, controller.ConditionTrue, "Failed", err.Error())
+	}
+}
+
+// markReady records instanceID as phaseReady with description cached for
+// GetServiceInstanceLastOperation to return without further work.
+func (c *heketiController) markReady(instanceID, description string) {
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+	if instance, ok := c.instanceMap[instanceID]; ok {
+		instance.Phase = phaseReady
+		instance.Description = description
+		setInstanceCondition(instance, controller.ConditionProvisioned, controller.ConditionTrue, "Provisioned", description)
+		setInstanceCondition(instance, controller.ConditionReady, controller.ConditionTrue, "Ready", description)
+		setInstanceCondition(instance, controller.ConditionDegraded, controller.ConditionFalse, "Ready", "")
+	}
+}
+
+// setInstanceCondition applies a Provisioned/Ready/Degraded/DeletionPending
+// transition to instance.Conditions. Callers must hold c.rwMutex.
+func setInstanceCondition(instance *heketiServiceInstance, condType string, status controller.ConditionStatus, reason, message string) {
+	instance.Conditions = controller.SetCondition(instance.Conditions, controller.Condition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: time.Now(),
+	})
+}
+
+// UpdateServiceInstance applies a resource tier resize and/or a
+// logLevel/authEnabled change to an instance, named either by req.PlanID
+// (the small/medium/large catalog plans) or the "resources"/"logLevel"/
+// "authEnabled" update parameters. A logLevel or authEnabled change
+// regenerates the instance's heketi.json ConfigMap and re-stamps the
+// Deployment's pod template so the resulting rollout picks it up; ConfigMap
+// contents alone don't trigger a restart. It is a no-op, successful update
+// if nothing changed.
+func (c *heketiController) UpdateServiceInstance(
+	ctx context.Context,
+	instanceID string,
+	req *brokerapi.UpdateServiceInstanceRequest,
+) (*brokerapi.UpdateServiceInstanceResponse, error) {
+	reqlog.FromContext(ctx).Infof("UpdateServiceInstance()")
+	ctx, cancel := context.WithTimeout(ctx, c.provisionTimeout)
+	defer cancel()
+
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+
+	instance, ok := c.instanceMap[instanceID]
+	if !ok {
+		return nil, kube.ErrNoSuchInstance{InstanceID: instanceID}
+	}
+
+	tier, err := resolveUpdateResourceTier(req)
+	if err != nil {
+		return nil, err
+	}
+
+	logLevel, hasLogLevel := req.Parameters["logLevel"].(string)
+	hasLogLevel = hasLogLevel && logLevel != "" && logLevel != instance.LogLevel
+
+	authEnabled, hasAuthChange := req.Parameters["authEnabled"].(bool)
+	hasAuthChange = hasAuthChange && authEnabled != instance.AuthEnabled
+	hasConfigChange := hasLogLevel || hasAuthChange
+
+	if tier == nil && !hasConfigChange {
+		return &brokerapi.UpdateServiceInstanceResponse{}, nil
+	}
+
+	client := c.kubeClient
+	deployment, err := client.Apps().Deployments(instance.Namespace).Get(instance.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up heketi instance deployment: %v", err)
+	}
+
+	if tier != nil {
+		applyResourceTier(&deployment.Spec.Template.Spec, tier)
+	}
+
+	if hasConfigChange {
+		adminKey, err := readAdminKey(client, instance.Namespace, instance.SecretName)
+		if err != nil {
+			return nil, err
+		}
+		newLogLevel := instance.LogLevel
+		if hasLogLevel {
+			newLogLevel = logLevel
+		}
+		newAuthEnabled := instance.AuthEnabled
+		if hasAuthChange {
+			newAuthEnabled = authEnabled
+		}
+		configOpts := &heketiConfigOptions{AdminKey: adminKey, Executor: instance.Executor, LogLevel: newLogLevel, AuthEnabled: newAuthEnabled}
+		hash, err := updateHeketiConfigMap(client, instance.Namespace, instance.ID, configOpts)
+		if err != nil {
+			return nil, err
+		}
+		setConfigHashAnnotation(&deployment.Spec.Template, hash)
+	}
+
+	if _, err := client.Apps().Deployments(instance.Namespace).Update(deployment); err != nil {
+		return nil, fmt.Errorf("failed to update heketi instance deployment: %v", err)
+	}
+
+	if tier != nil {
+		instance.ResourceTier = tier.Name
+	}
+	if hasLogLevel {
+		instance.LogLevel = logLevel
+	}
+	if hasAuthChange {
+		instance.AuthEnabled = authEnabled
+	}
+	return &brokerapi.UpdateServiceInstanceResponse{}, nil
+}
+
+func (c *heketiController) GetServiceInstanceLastOperation(
+	ctx context.Context,
+	instanceID,
+	serviceID,
+	planID,
+	operation string,
+) (*brokerapi.LastOperationResponse, error) {
+	reqlog.FromContext(ctx).Infof("GetServiceInstanceLastOperation()")
+	c.rwMutex.RLock()
+	instance, ok := c.instanceMap[instanceID]
+	c.rwMutex.RUnlock()
+	if !ok {
+		return nil, kube.ErrNoSuchInstance{InstanceID: instanceID}
+	}
+
+	client := c.kubeClient
+	return c.describeInstance(client, instance), nil
+}
+
+func (c *heketiController) RemoveServiceInstance(
+	ctx context.Context,
+	instanceID,
+	serviceID,
+	planID string,
+	acceptsIncomplete, force bool,
+) (*brokerapi.DeleteServiceInstanceResponse, error) {
+	reqlog.FromContext(ctx).Infof("RemoveServiceInstance()")
+	dctx, cancel := context.WithTimeout(ctx, c.provisionTimeout)
+	defer cancel()
+
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+
+	instance, ok := c.instanceMap[instanceID]
+	if !ok {
+		return &brokerapi.DeleteServiceInstanceResponse{}, nil
+	}
+
+	client := c.kubeClient
+	if err := c.checkVolumesBeforeDeprovision(client, instance, force); err != nil {
+		return nil, err
+	}
+
+	setInstanceCondition(instance, controller.ConditionDeletionPending, controller.ConditionTrue, "Deprovisioning", "")
+
+	if c.namespacePerInstance {
+		if err := kube.DeleteInstanceNamespace(dctx, client, instance.Namespace, c.retryBaseDelay); err != nil {
+			return nil, fmt.Errorf("failed to delete Heketi instance namespace: %v", err)
+		}
+	} else if err := doHeketiDeprovision(dctx, client, c.routeClient, instance, c.skipPVCDelete); err != nil {
+		return nil, err
+	}
+	c.recorder.Event(c.instanceEventRef(instance), api.EventTypeNormal, kube.ReasonDeprovisioned, "Deprovisioned Heketi instance")
+	delete(c.instanceMap, instanceID)
+	return &brokerapi.DeleteServiceInstanceResponse{}, nil
+}
+
+// errInstanceHasVolumes means a deprovision request was refused because the
+// instance still manages Gluster volumes that would be stranded by deleting
+// it.
+type errInstanceHasVolumes struct {
+	instanceID string
+	volumes    []string
+}
+
+func (e errInstanceHasVolumes) Error() string {
+	return fmt.Sprintf("heketi instance %q still manages volume(s) %s; pass the \"force\" parameter or run the broker with --allow-destructive-deprovision to delete it anyway",
+		e.instanceID, strings.Join(e.volumes, ", "))
+}
+
+// checkVolumesBeforeDeprovision refuses to deprovision an instance that
+// still manages Gluster volumes, unless force or allowDestructiveDeprovision
+// is set, in which case it lets the deprovision through but logs an
+// explicit warning naming the volumes it's about to strand. An instance
+// whose Heketi API can't be reached is let through unconditionally: there
+// is nothing left to check, and refusing would make an already-broken
+// instance impossible to remove.
+func (c *heketiController) checkVolumesBeforeDeprovision(client kubernetes.Interface, instance *heketiServiceInstance, force bool) error {
+	if instance.SecretName == "" {
+		return nil
+	}
+	adminKey, err := readAdminKey(client, instance.Namespace, instance.SecretName)
+	if err != nil {
+		return nil
+	}
+
+	baseURL := heketiServiceURL(instance.Namespace, instance.ServiceName)
+	volumes, err := c.statusReader.VolumeNames(baseURL, adminKey)
+	if err != nil || len(volumes) == 0 {
+		return nil
+	}
+
+	if force || c.allowDestructiveDeprovision {
+		glog.Warningf("deleting heketi instance %s, which still manages volume(s) %s", instance.ID, strings.Join(volumes, ", "))
+		return nil
+	}
+	return errInstanceHasVolumes{instanceID: instance.ID, volumes: volumes}
+}
+
+// errAdminKeyMismatch means the admin key currently in an instance's secret
+// does not match the key its running pod was started with, most likely
+// because the secret was edited by hand after the pod came up. The fix is
+// to rotate the instance (update its secret and roll the pod) rather than
+// bind against credentials the running server won't accept.
+type errAdminKeyMismatch struct {
+	instanceID string
+}
+
+func (e errAdminKeyMismatch) Error() string {
+	return fmt.Sprintf("heketi instance %s: secret admin key does not match the key its pod was started with; rotate the instance's admin key before binding", e.instanceID)
+}
+
+// checkAdminKeyConsistency compares the admin key currently in instance's
+// secret against the adminKeyHashAnnotation stamped on its pod at
+// provision time, returning errAdminKeyMismatch on a mismatch. A pod that
+// can't be found, or one with no annotation to compare against (stamped by
+// a version of this broker predating the check), is treated as consistent,
+// since there's nothing to compare.
+func checkAdminKeyConsistency(client kubernetes.Interface, podLister v1listers.PodLister, instance *heketiServiceInstance) error {
+	if instance.SecretName == "" {
+		return nil
+	}
+
+	key, err := readAdminKey(client, instance.Namespace, instance.SecretName)
+	if err != nil {
+		return err
+	}
+
+	pod, err := findHeketiPod(client, podLister, instance.Namespace, instance.ID)
+	if err != nil {
+		return nil
+	}
+
+	wantHash, ok := pod.Annotations[adminKeyHashAnnotation]
+	if !ok {
+		return nil
+	}
+	if wantHash != adminKeyHash(key) {
+		return errAdminKeyMismatch{instanceID: instance.ID}
+	}
+	return nil
+}
+
+// doHeketiBind builds the credential handed back to a binding caller. It
+// issues bindingID its own key in the instance's existing secret rather
+// than handing out the shared admin key, so unbind can later revoke this
+// binding's access without disturbing the admin key or other bindings.
+// Issuing is idempotent: re-binding the same bindingID returns the key it
+// was already given. If admin is true, the shared admin key is returned
+// instead, with role "admin" rather than "user". If the instance's JWT auth
+// is turned off (the authEnabled provision/update parameter), no key is
+// issued at all and the credential's authEnabled field is a prominent
+// false, since a key would imply an authentication check the server isn't
+// actually making. Instances provisioned before the admin secret existed
+// have no SecretName to look up; those degrade gracefully to an
+// endpoint-only credential. Before handing out either key, it checks the
+// secret against the running pod via checkAdminKeyConsistency and returns
+// errAdminKeyMismatch rather than credentials the server won't accept.
+func doHeketiBind(client kubernetes.Interface, podLister v1listers.PodLister, instance *heketiServiceInstance, bindingID string, admin bool) (brokerapi.Credential, error) {
+	cred := brokerapi.Credential{
+		"url": heketiServiceURL(instance.Namespace, instance.ServiceName),
+		"tls": false,
+	}
+
+	if externalURL, err := externalHeketiURL(client, instance); err == nil && externalURL != "" {
+		cred["externalURL"] = externalURL
+	}
+
+	if instance.StorageClassName != "" {
+		cred["storageClass"] = instance.StorageClassName
+	}
+
+	cred["authEnabled"] = instance.AuthEnabled
+	if !instance.AuthEnabled {
+		return cred, nil
+	}
+
+	if instance.SecretName == "" {
+		glog.Warningf("heketi instance %s has no admin secret; returning endpoint-only credential", instance.ID)
+		return cred, nil
+	}
+
+	if err := checkAdminKeyConsistency(client, podLister, instance); err != nil {
+		return nil, err
+	}
+
+	if admin {
+		key, err := readAdminKey(client, instance.Namespace, instance.SecretName)
+		if err != nil {
+			return nil, err
+		}
+		cred["username"] = "admin"
+		cred["role"] = "admin"
+		cred["key"] = key
+		return cred, nil
+	}
+
+	key, err := issueBindingKey(client, instance.Namespace, instance.SecretName, bindingID)
+	if err != nil {
+		return nil, err
+	}
+
+	cred["username"] = "admin"
+	cred["role"] = "user"
+	cred["key"] = key
+	return cred, nil
+}
+
+// doHeketiUnbind revokes the key issued to bindingID by doHeketiBind. It
+// derives the instance's admin secret name from instanceID rather than
+// requiring the full controller state, so it can run without holding the
+// controller lock across a network call.
+func (c *heketiController) doHeketiUnbind(instanceID, bindingID, ns string) error {
+	client := c.kubeClient
+	return revokeBindingKey(client, ns, adminSecretName(instanceID), bindingID)
+}
+
+func (c *heketiController) Bind(
+	ctx context.Context,
+	instanceID,
+	bindingID string,
+	req *brokerapi.BindingRequest,
+) (*brokerapi.CreateServiceBindingResponse, error) {
+	reqlog.FromContext(ctx).Infof("Bind()")
+	ctx, cancel := context.WithTimeout(ctx, c.bindTimeout)
+	defer cancel()
+
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+
+	instance, ok := c.instanceMap[instanceID]
+	if !ok {
+		return nil, kube.ErrNoSuchInstance{InstanceID: instanceID}
+	}
+
+	wantAdmin, _ := req.Parameters["admin"].(bool)
+	if wantAdmin && !c.allowAdminBind {
+		return nil, fmt.Errorf("admin bind parameter requires the broker to be started with --allow-admin-bind")
+	}
+
+	client := c.kubeClient
+	cred, err := doHeketiBind(client, c.podLister, instance, bindingID, wantAdmin)
+	if mismatch, ok := err.(errAdminKeyMismatch); ok {
+		instance.AdminKeyMismatch = mismatch.Error()
+		setInstanceCondition(instance, controller.ConditionDegraded, controller.ConditionTrue, "AdminKeyMismatch", mismatch.Error())
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	instance.AdminKeyMismatch = ""
+	setInstanceCondition(instance, controller.ConditionDegraded, controller.ConditionFalse, "Ready", "")
+	instance.Bindings[bindingID] = struct{}{}
+	c.recorder.Event(c.instanceEventRef(instance), api.EventTypeNormal, kube.ReasonBound, "Bound Heketi instance")
+	return &brokerapi.CreateServiceBindingResponse{Credentials: cred}, nil
+}
+
+func (c *heketiController) UnBind(ctx context.Context, instanceID, bindingID, serviceID, planID string) error {
+	reqlog.FromContext(ctx).Infof("UnBind()")
+	c.rwMutex.RLock()
+	instance, ok := c.instanceMap[instanceID]
+	c.rwMutex.RUnlock()
+	if !ok {
+		return kube.ErrNoSuchInstance{InstanceID: instanceID}
+	}
+	if err := c.doHeketiUnbind(instanceID, bindingID, instance.Namespace); err != nil {
+		return err
+	}
+	c.rwMutex.Lock()
+	delete(instance.Bindings, bindingID)
+	c.rwMutex.Unlock()
+	c.recorder.Event(c.instanceEventRef(instance), api.EventTypeNormal, kube.ReasonUnbound, "Unbound Heketi instance")
+	return nil
+}
+
+// CheckReadiness implements controller.ReadinessChecker.
+func (c *heketiController) CheckReadiness(ctx context.Context) map[string]error {
+	failures := map[string]error{}
+	if err := kube.CheckAPIServerReachable(c.kubeClient); err != nil {
+		failures["kube-api"] = err
+	}
+	return failures
+}
+
+// SnapshotState implements controller.StateReporter.
+func (c *heketiController) SnapshotState() controller.StateSnapshot {
+	c.rwMutex.RLock()
+	defer c.rwMutex.RUnlock()
+
+	instances := make([]controller.InstanceSnapshot, 0, len(c.instanceMap))
+	for _, instance := range c.instanceMap {
+		instances = append(instances, controller.InstanceSnapshot{
+			ID:           instance.ID,
+			Namespace:    instance.Namespace,
+			ServiceID:    serviceID,
+			PlanID:       instance.PlanID,
+			Phase:        instance.Phase,
+			Conditions:   instance.Conditions,
+			CreatedAt:    instance.CreatedAt,
+			BindingCount: len(instance.Bindings),
+		})
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+
+	return controller.StateSnapshot{
+		Instances: instances,
+		Config: map[string]string{
+			"skipPVCDelete":               strconv.FormatBool(c.skipPVCDelete),
+			"defaultResourceTier":         c.defaultResourceTier,
+			"keepFailedInstances":         strconv.FormatBool(c.keepFailedInstances),
+			"allowDestructiveDeprovision": strconv.FormatBool(c.allowDestructiveDeprovision),
+			"namespacePerInstance":        strconv.FormatBool(c.namespacePerInstance),
+		},
+	}
+}
@@ -0,0 +1,358 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func newTestController(instances ...*heketiServiceInstance) *heketiController {
+	c := &heketiController{instanceMap: make(map[string]*heketiServiceInstance)}
+	for _, instance := range instances {
+		c.instanceMap[instance.ID] = instance
+	}
+	return c
+}
+
+func TestResolveNamespaceDefaultsToContextProfile(t *testing.T) {
+	c := newTestController()
+	req := &brokerapi.CreateServiceInstanceRequest{ContextProfile: brokerapi.ContextProfile{Namespace: "team-a"}}
+
+	ns, err := c.resolveNamespace(req)
+	if err != nil {
+		t.Fatalf("resolveNamespace: %v", err)
+	}
+	if ns != "team-a" {
+		t.Errorf("ns = %q, want %q", ns, "team-a")
+	}
+}
+
+func TestResolveNamespaceRejectsMissingNamespaceByDefault(t *testing.T) {
+	c := newTestController()
+
+	if _, err := c.resolveNamespace(&brokerapi.CreateServiceInstanceRequest{}); err == nil {
+		t.Fatal("expected an error for a request with no namespace and allowDefaultNamespace unset")
+	}
+}
+
+func TestResolveNamespaceFallsBackToDefaultNamespaceWhenAllowed(t *testing.T) {
+	c := &heketiController{allowDefaultNamespace: true, defaultNamespace: "team-default"}
+
+	ns, err := c.resolveNamespace(&brokerapi.CreateServiceInstanceRequest{})
+	if err != nil {
+		t.Fatalf("resolveNamespace: %v", err)
+	}
+	if ns != "team-default" {
+		t.Errorf("ns = %q, want %q", ns, "team-default")
+	}
+}
+
+func TestResolveInstanceNamespacePassesThroughByDefault(t *testing.T) {
+	c := newTestController()
+
+	ns, err := c.resolveInstanceNamespace(fake.NewSimpleClientset(), "test", "team-a")
+	if err != nil {
+		t.Fatalf("resolveInstanceNamespace: %v", err)
+	}
+	if ns != "team-a" {
+		t.Errorf("ns = %q, want %q", ns, "team-a")
+	}
+}
+
+func TestResolveInstanceNamespaceCreatesDedicatedNamespaceWhenNamespacePerInstance(t *testing.T) {
+	c := &heketiController{namespacePerInstance: true}
+
+	ns, err := c.resolveInstanceNamespace(fake.NewSimpleClientset(), "test", "team-a")
+	if err != nil {
+		t.Fatalf("resolveInstanceNamespace: %v", err)
+	}
+	if want := "heketi-test"; ns != want {
+		t.Errorf("ns = %q, want %q", ns, want)
+	}
+}
+
+func TestSetPhaseUpdatesRecordedInstance(t *testing.T) {
+	instance := &heketiServiceInstance{ID: "test", Phase: phaseCreatingResources}
+	c := newTestController(instance)
+
+	c.setPhase("test", phaseWaitingForPod)
+
+	if instance.Phase != phaseWaitingForPod {
+		t.Errorf("expected phase %q, got %q", phaseWaitingForPod, instance.Phase)
+	}
+}
+
+func TestSetPhaseIgnoresUnknownInstance(t *testing.T) {
+	c := newTestController()
+	c.setPhase("missing", phaseWaitingForPod)
+}
+
+func TestFailInstanceRecordsFailureDetail(t *testing.T) {
+	instance := &heketiServiceInstance{ID: "test", Phase: phaseWaitingForAPI}
+	c := newTestController(instance)
+
+	c.failInstance("test", kube.ErrNoSuchInstance{InstanceID: "boom"})
+
+	if instance.Phase != phaseFailed {
+		t.Errorf("expected phase %q, got %q", phaseFailed, instance.Phase)
+	}
+	if instance.FailureDetail == "" {
+		t.Error("expected a non-empty failure detail")
+	}
+	assertConditionStatus(t, instance.Conditions, controller.ConditionReady, controller.ConditionFalse)
+	assertConditionStatus(t, instance.Conditions, controller.ConditionDegraded, controller.ConditionTrue)
+}
+
+func TestMarkReadyRecordsDescription(t *testing.T) {
+	instance := &heketiServiceInstance{ID: "test", Phase: phaseLoadingTopology}
+	c := newTestController(instance)
+
+	c.markReady("test", "heketi pod is running")
+
+	if instance.Phase != phaseReady {
+		t.Errorf("expected phase %q, got %q", phaseReady, instance.Phase)
+	}
+	if instance.Description != "heketi pod is running" {
+		t.Errorf("expected description to be recorded, got %q", instance.Description)
+	}
+	assertConditionStatus(t, instance.Conditions, controller.ConditionProvisioned, controller.ConditionTrue)
+	assertConditionStatus(t, instance.Conditions, controller.ConditionReady, controller.ConditionTrue)
+}
+
+// assertConditionStatus fails t unless conditions has a condition of type
+// condType whose Status is want.
+func assertConditionStatus(t *testing.T, conditions []controller.Condition, condType string, want controller.ConditionStatus) {
+	t.Helper()
+	for _, cond := range conditions {
+		if cond.Type == condType {
+			if cond.Status != want {
+				t.Errorf("condition %s status = %s, want %s", condType, cond.Status, want)
+			}
+			return
+		}
+	}
+	t.Errorf("no condition of type %s found in %+v", condType, conditions)
+}
+
+func TestResolveImagePullSecretUsesBrokerDefault(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry-creds", Namespace: defaultNamespace},
+	})
+
+	name, err := resolveImagePullSecret(client, defaultNamespace, "registry-creds", defaultNamespace, defaultServiceAccount, &brokerapi.CreateServiceInstanceRequest{}, "test")
+	if err != nil {
+		t.Fatalf("resolveImagePullSecret: %v", err)
+	}
+	if name != "registry-creds" {
+		t.Errorf("name = %q, want %q", name, "registry-creds")
+	}
+}
+
+func TestResolveImagePullSecretCopiesIntoInstanceNamespace(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry-creds", Namespace: defaultNamespace},
+		Data:       map[string][]byte{".dockerconfigjson": []byte("{}")},
+	})
+
+	name, err := resolveImagePullSecret(client, "other-namespace", "registry-creds", defaultNamespace, defaultServiceAccount, &brokerapi.CreateServiceInstanceRequest{}, "test")
+	if err != nil {
+		t.Fatalf("resolveImagePullSecret: %v", err)
+	}
+	if name != "registry-creds-test" {
+		t.Errorf("name = %q, want %q", name, "registry-creds-test")
+	}
+
+	if _, err := client.Core().Secrets("other-namespace").Get(name, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the secret to be copied into other-namespace: %v", err)
+	}
+}
+
+func TestResolveImagePullSecretHonorsParameterOverride(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "override-creds", Namespace: defaultNamespace},
+	})
+
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"imagePullSecret": "override-creds"}}
+	name, err := resolveImagePullSecret(client, defaultNamespace, "", defaultNamespace, defaultServiceAccount, req, "test")
+	if err != nil {
+		t.Fatalf("resolveImagePullSecret: %v", err)
+	}
+	if name != "override-creds" {
+		t.Errorf("name = %q, want %q", name, "override-creds")
+	}
+}
+
+func TestResolveImagePullSecretEmptyWhenUnconfigured(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	name, err := resolveImagePullSecret(client, defaultNamespace, "", defaultNamespace, defaultServiceAccount, &brokerapi.CreateServiceInstanceRequest{}, "test")
+	if err != nil {
+		t.Fatalf("resolveImagePullSecret: %v", err)
+	}
+	if name != "" {
+		t.Errorf("name = %q, want empty", name)
+	}
+}
+
+func TestCheckVolumesBeforeDeprovisionRefusesWhenVolumesExist(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-admin", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("s3cr3t")},
+	}
+	client := fake.NewSimpleClientset(secret)
+	instance := &heketiServiceInstance{ID: "test", Namespace: "default", SecretName: "heketi-test-admin"}
+	c := &heketiController{statusReader: &fakeStatusReader{volumes: []string{"vol_one", "vol_two"}}}
+
+	err := c.checkVolumesBeforeDeprovision(client, instance, false)
+	if err == nil {
+		t.Fatal("expected an error for an instance with existing volumes")
+	}
+	if _, ok := err.(errInstanceHasVolumes); !ok {
+		t.Errorf("expected errInstanceHasVolumes, got %T: %v", err, err)
+	}
+}
+
+func TestCheckVolumesBeforeDeprovisionAllowsEmptyInstance(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-admin", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("s3cr3t")},
+	}
+	client := fake.NewSimpleClientset(secret)
+	instance := &heketiServiceInstance{ID: "test", Namespace: "default", SecretName: "heketi-test-admin"}
+	c := &heketiController{statusReader: &fakeStatusReader{volumes: nil}}
+
+	if err := c.checkVolumesBeforeDeprovision(client, instance, false); err != nil {
+		t.Errorf("checkVolumesBeforeDeprovision: %v", err)
+	}
+}
+
+func TestCheckVolumesBeforeDeprovisionAllowsUnreachableInstance(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-admin", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("s3cr3t")},
+	}
+	client := fake.NewSimpleClientset(secret)
+	instance := &heketiServiceInstance{ID: "test", Namespace: "default", SecretName: "heketi-test-admin"}
+	c := &heketiController{statusReader: &fakeStatusReader{volumesErr: fmt.Errorf("connection refused")}}
+
+	if err := c.checkVolumesBeforeDeprovision(client, instance, false); err != nil {
+		t.Errorf("expected an unreachable instance to be let through, got %v", err)
+	}
+}
+
+func TestCheckVolumesBeforeDeprovisionHonorsForce(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-admin", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("s3cr3t")},
+	}
+	client := fake.NewSimpleClientset(secret)
+	instance := &heketiServiceInstance{ID: "test", Namespace: "default", SecretName: "heketi-test-admin"}
+	c := &heketiController{statusReader: &fakeStatusReader{volumes: []string{"vol_one"}}}
+
+	if err := c.checkVolumesBeforeDeprovision(client, instance, true); err != nil {
+		t.Errorf("expected force to allow deprovision of an instance with volumes, got %v", err)
+	}
+}
+
+func TestCheckVolumesBeforeDeprovisionHonorsAllowDestructiveDeprovision(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-admin", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("s3cr3t")},
+	}
+	client := fake.NewSimpleClientset(secret)
+	instance := &heketiServiceInstance{ID: "test", Namespace: "default", SecretName: "heketi-test-admin"}
+	c := &heketiController{statusReader: &fakeStatusReader{volumes: []string{"vol_one"}}, allowDestructiveDeprovision: true}
+
+	if err := c.checkVolumesBeforeDeprovision(client, instance, false); err != nil {
+		t.Errorf("expected allowDestructiveDeprovision to allow deprovision of an instance with volumes, got %v", err)
+	}
+}
+
+func TestCheckAdminKeyConsistencyMatch(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "heketi-test-abcde",
+			Namespace:   "default",
+			Labels:      map[string]string{"instanceID": "test"},
+			Annotations: map[string]string{adminKeyHashAnnotation: adminKeyHash("s3cr3t")},
+		},
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-admin", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("s3cr3t")},
+	}
+	client := fake.NewSimpleClientset(pod, secret)
+	instance := &heketiServiceInstance{ID: "test", Namespace: "default", SecretName: "heketi-test-admin"}
+
+	if err := checkAdminKeyConsistency(client, nil, instance); err != nil {
+		t.Errorf("checkAdminKeyConsistency: %v", err)
+	}
+}
+
+func TestCheckAdminKeyConsistencyMismatch(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "heketi-test-abcde",
+			Namespace:   "default",
+			Labels:      map[string]string{"instanceID": "test"},
+			Annotations: map[string]string{adminKeyHashAnnotation: adminKeyHash("original-key")},
+		},
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-admin", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("hand-edited-key")},
+	}
+	client := fake.NewSimpleClientset(pod, secret)
+	instance := &heketiServiceInstance{ID: "test", Namespace: "default", SecretName: "heketi-test-admin"}
+
+	err := checkAdminKeyConsistency(client, nil, instance)
+	if err == nil {
+		t.Fatal("expected an error for a mismatched admin key")
+	}
+	if _, ok := err.(errAdminKeyMismatch); !ok {
+		t.Errorf("expected errAdminKeyMismatch, got %T: %v", err, err)
+	}
+}
+
+func TestCheckAdminKeyConsistencyMissingAnnotation(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "heketi-test-abcde",
+			Namespace: "default",
+			Labels:    map[string]string{"instanceID": "test"},
+		},
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-admin", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("s3cr3t")},
+	}
+	client := fake.NewSimpleClientset(pod, secret)
+	instance := &heketiServiceInstance{ID: "test", Namespace: "default", SecretName: "heketi-test-admin"}
+
+	if err := checkAdminKeyConsistency(client, nil, instance); err != nil {
+		t.Errorf("expected no error for a pod predating the annotation, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1beta1 "k8s.io/client-go/pkg/apis/apps/v1beta1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+var deploymentReplicas int32 = 1
+
+// newHeketiInstanceResources builds the single-replica Deployment backing a
+// Heketi instance. It uses the Recreate strategy so that a rollout always
+// tears the old pod down before the new one starts, since two Heketi
+// processes must never open the same BoltDB file at once. It is a pure
+// function of opts: every setting that varies the resulting Deployment's
+// shape is resolved by the caller and passed in through opts, rather than
+// applied to the Deployment afterward.
+func newHeketiInstanceResources(name, namespace, instanceID, secretName string, labels, annotations map[string]string, opts *deploymentOptions) *appsv1beta1.Deployment {
+	selector := map[string]string{kube.InstanceLabelKey(): instanceID}
+
+	container := v1.Container{
+		Name:  "heketi",
+		Image: opts.Image,
+		Ports: []v1.ContainerPort{{Name: heketiPortName, ContainerPort: heketiPort}},
+		Env:   []v1.EnvVar{adminKeyEnv(secretName)},
+	}
+	if opts.Tier != nil {
+		container.Resources = opts.Tier.Resources
+	}
+
+	podSpec := v1.PodSpec{Containers: []v1.Container{container}}
+	if opts.ImagePullSecret != "" {
+		podSpec.ImagePullSecrets = []v1.LocalObjectReference{{Name: opts.ImagePullSecret}}
+	}
+
+	return &appsv1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: appsv1beta1.DeploymentSpec{
+			Replicas: &deploymentReplicas,
+			Selector: &metav1.LabelSelector{MatchLabels: selector},
+			Strategy: appsv1beta1.DeploymentStrategy{
+				Type: appsv1beta1.RecreateDeploymentStrategyType,
+			},
+			Template: v1.PodTemplateSpec{
+				// The pod template gets its own copy of annotations, since
+				// setAdminKeyHashAnnotation and setConfigHashAnnotation
+				// (and any operator podSpecOverride) add to it afterward,
+				// and it must not mutate the Deployment's own copy in the
+				// process.
+				ObjectMeta: metav1.ObjectMeta{Labels: labels, Annotations: kube.CopyStringMap(annotations)},
+				Spec:       podSpec,
+			},
+		},
+	}
+}
+
+func deploymentName(instanceID string) string {
+	return names.InstanceResourceName(brokerName, instanceID, "")
+}
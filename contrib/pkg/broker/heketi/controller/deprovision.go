@@ -0,0 +1,111 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/waitutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// deletionWaitTimeout bounds how long doHeketiDeprovision waits for the
+// Deployment and Service it just deleted to actually disappear, so a
+// deprovision that reports complete doesn't leave a caller free to
+// immediately re-provision into a namespace whose old Service is still
+// terminating.
+const deletionWaitTimeout = 30 * time.Second
+
+// doHeketiDeprovision deletes every Kubernetes resource labeled with the
+// instance's ID and this broker's serviceID, across every resource type the
+// controller may have created for it (deployments, pods, secrets, services,
+// configmaps, ingresses, storage classes, and PVCs). Matching on both labels,
+// rather than instanceID alone, keeps this from ever touching another
+// broker's resources in a garbage-collection or recovery pass over a shared
+// namespace. Every resource type is attempted regardless of earlier
+// failures, and the caller gets back an aggregate of every error
+// encountered; it succeeds only when every type was deleted or already
+// absent.
+//
+// The instance's PersistentVolumeClaim is left in place when the instance
+// was created with retainData, or when skipPVCDelete is set broker-wide.
+// routeClient is non-nil only when the controller is running with platform
+// kube.PlatformOpenShift, in which case Routes are cleaned up too.
+//
+// Once every resource type is deleted, it blocks until the instance's
+// Deployment and Service are actually gone, using waitutil.ForDeletion, so
+// a caller never reports the deprovision complete while a Service that
+// could collide with a subsequent provision is still terminating. It
+// returns early if ctx is canceled or hits its deadline first.
+func doHeketiDeprovision(ctx context.Context, client kubernetes.Interface, routeClient kube.RouteClient, instance *heketiServiceInstance, skipPVCDelete bool) error {
+	selector := metav1.ListOptions{LabelSelector: fmt.Sprintf("%s,serviceID=%s", kube.InstanceLabelSelector(instance.ID), serviceID)}
+
+	var failures []string
+	deleteCollection := func(resource string, delete func() error) {
+		if err := delete(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", resource, err))
+		}
+	}
+
+	deleteCollection("deployments", func() error {
+		return client.Apps().Deployments(instance.Namespace).DeleteCollection(nil, selector)
+	})
+	deleteCollection("pods", func() error {
+		return client.Core().Pods(instance.Namespace).DeleteCollection(nil, selector)
+	})
+	deleteCollection("secrets", func() error {
+		return client.Core().Secrets(instance.Namespace).DeleteCollection(nil, selector)
+	})
+	deleteCollection("services", func() error {
+		return client.Core().Services(instance.Namespace).DeleteCollection(nil, selector)
+	})
+	deleteCollection("configmaps", func() error {
+		return client.Core().ConfigMaps(instance.Namespace).DeleteCollection(nil, selector)
+	})
+	deleteCollection("ingresses", func() error {
+		return client.Extensions().Ingresses(instance.Namespace).DeleteCollection(nil, selector)
+	})
+	deleteCollection("storageclasses", func() error {
+		return client.Storage().StorageClasses().DeleteCollection(nil, selector)
+	})
+	if routeClient != nil {
+		deleteCollection("routes", func() error {
+			return routeClient.DeleteCollection(instance.Namespace, selector)
+		})
+	}
+	if !instance.RetainData && !skipPVCDelete {
+		deleteCollection("persistentvolumeclaims", func() error {
+			return client.Core().PersistentVolumeClaims(instance.Namespace).DeleteCollection(nil, selector)
+		})
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to delete heketi instance resources: %s", strings.Join(failures, "; "))
+	}
+
+	kinds := []waitutil.Kind{waitutil.Deployments, waitutil.Services}
+	if err := waitutil.ForDeletion(ctx, client, instance.Namespace, selector.LabelSelector, kinds, deletionWaitTimeout); err != nil {
+		return fmt.Errorf("waiting for heketi instance resources to finish deleting: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/pkg/api/v1"
+	appsv1beta1 "k8s.io/client-go/pkg/apis/apps/v1beta1"
+	extensionsv1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	ktesting "k8s.io/client-go/testing"
+)
+
+// failDeleteCollection makes client return err for every DeleteCollection
+// against resource, simulating the API server rejecting one particular kind
+// of object.
+func failDeleteCollection(client *fake.Clientset, resource string, err error) {
+	client.PrependReactor("delete-collection", resource, func(ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, err
+	})
+}
+
+func seededHeketiResources(namespace, id string) []runtime.Object {
+	labels := map[string]string{"instanceID": id, "serviceID": serviceID}
+	return []runtime.Object{
+		&appsv1beta1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "heketi-" + id, Namespace: namespace, Labels: labels}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "heketi-" + id, Namespace: namespace, Labels: labels}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "heketi-" + id + "-admin", Namespace: namespace, Labels: labels}},
+		&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "heketi-" + id, Namespace: namespace, Labels: labels}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "heketi-" + id + "-config", Namespace: namespace, Labels: labels}},
+		&v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "heketi-" + id + "-db", Namespace: namespace, Labels: labels}},
+		&extensionsv1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "heketi-" + id, Namespace: namespace, Labels: labels}},
+	}
+}
+
+func TestDoHeketiDeprovisionDeletesEverything(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset(seededHeketiResources(namespace, id)...)
+
+	instance := &heketiServiceInstance{
+		ID:             id,
+		Namespace:      namespace,
+		DeploymentName: "heketi-" + id,
+		SecretName:     "heketi-" + id + "-admin",
+		ServiceName:    "heketi-" + id,
+		ConfigMapName:  "heketi-" + id + "-config",
+		PVCName:        "heketi-" + id + "-db",
+	}
+
+	if err := doHeketiDeprovision(context.Background(), client, nil, instance, false); err != nil {
+		t.Fatalf("doHeketiDeprovision: %v", err)
+	}
+
+	selector := metav1.ListOptions{LabelSelector: "instanceID=" + id}
+	deployments, _ := client.Apps().Deployments(namespace).List(selector)
+	pods, _ := client.Core().Pods(namespace).List(selector)
+	secrets, _ := client.Core().Secrets(namespace).List(selector)
+	services, _ := client.Core().Services(namespace).List(selector)
+	configMaps, _ := client.Core().ConfigMaps(namespace).List(selector)
+	pvcs, _ := client.Core().PersistentVolumeClaims(namespace).List(selector)
+	ingresses, _ := client.Extensions().Ingresses(namespace).List(selector)
+
+	if len(deployments.Items) != 0 || len(pods.Items) != 0 || len(secrets.Items) != 0 || len(services.Items) != 0 ||
+		len(configMaps.Items) != 0 || len(pvcs.Items) != 0 || len(ingresses.Items) != 0 {
+		t.Fatalf("expected no labeled resources to remain: deployments=%d pods=%d secrets=%d services=%d configmaps=%d pvcs=%d ingresses=%d",
+			len(deployments.Items), len(pods.Items), len(secrets.Items), len(services.Items), len(configMaps.Items), len(pvcs.Items), len(ingresses.Items))
+	}
+}
+
+func TestDoHeketiDeprovisionFailsWhenAResourceNeverFinishesDeleting(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset(seededHeketiResources(namespace, id)...)
+	// Accept the delete-collection call but don't actually remove the
+	// Deployment, simulating one still terminating behind the scenes.
+	client.PrependReactor("delete-collection", "deployments", func(ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	instance := &heketiServiceInstance{
+		ID:             id,
+		Namespace:      namespace,
+		DeploymentName: "heketi-" + id,
+		ServiceName:    "heketi-" + id,
+		PVCName:        "heketi-" + id + "-db",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := doHeketiDeprovision(ctx, client, nil, instance, false); err == nil {
+		t.Fatal("expected doHeketiDeprovision to fail waiting for the lingering deployment")
+	}
+}
+
+func TestDoHeketiDeprovisionSkipsPVCWhenRequested(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset(seededHeketiResources(namespace, id)...)
+
+	instance := &heketiServiceInstance{
+		ID:             id,
+		Namespace:      namespace,
+		DeploymentName: "heketi-" + id,
+		ServiceName:    "heketi-" + id,
+		PVCName:        "heketi-" + id + "-db",
+	}
+
+	if err := doHeketiDeprovision(context.Background(), client, nil, instance, true); err != nil {
+		t.Fatalf("doHeketiDeprovision: %v", err)
+	}
+
+	selector := metav1.ListOptions{LabelSelector: "instanceID=" + id}
+	pvcs, err := client.Core().PersistentVolumeClaims(namespace).List(selector)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(pvcs.Items) != 1 {
+		t.Fatalf("expected the PVC to survive with skipPVCDelete, got %d", len(pvcs.Items))
+	}
+}
+
+func TestDoHeketiDeprovisionAggregatesErrorWhenPodDeleteFails(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset(seededHeketiResources(namespace, id)...)
+	failDeleteCollection(client, "pods", fmt.Errorf("boom"))
+
+	instance := &heketiServiceInstance{ID: id, Namespace: namespace}
+
+	err := doHeketiDeprovision(context.Background(), client, nil, instance, false)
+	if err == nil {
+		t.Fatal("expected an error when pod deletion fails")
+	}
+	if !strings.Contains(err.Error(), "pods") {
+		t.Errorf("error = %q, want it to mention pods", err.Error())
+	}
+}
+
+func TestDoHeketiDeprovisionAggregatesErrorWhenSecretDeleteFails(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset(seededHeketiResources(namespace, id)...)
+	failDeleteCollection(client, "secrets", fmt.Errorf("boom"))
+
+	instance := &heketiServiceInstance{ID: id, Namespace: namespace}
+
+	err := doHeketiDeprovision(context.Background(), client, nil, instance, false)
+	if err == nil {
+		t.Fatal("expected an error when secret deletion fails")
+	}
+	if !strings.Contains(err.Error(), "secrets") {
+		t.Errorf("error = %q, want it to mention secrets", err.Error())
+	}
+}
+
+func TestDoHeketiDeprovisionAggregatesErrorsAcrossKinds(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset(seededHeketiResources(namespace, id)...)
+	failDeleteCollection(client, "pods", fmt.Errorf("boom"))
+	failDeleteCollection(client, "secrets", fmt.Errorf("boom"))
+
+	instance := &heketiServiceInstance{ID: id, Namespace: namespace}
+
+	err := doHeketiDeprovision(context.Background(), client, nil, instance, false)
+	if err == nil {
+		t.Fatal("expected an aggregate error")
+	}
+	if !strings.Contains(err.Error(), "pods") || !strings.Contains(err.Error(), "secrets") {
+		t.Errorf("error = %q, want it to mention both failing kinds", err.Error())
+	}
+}
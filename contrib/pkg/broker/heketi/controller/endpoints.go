@@ -0,0 +1,100 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// currentPodName resolves the pod currently backing an instance's Service by
+// reading the Service's Endpoints, rather than assuming a fixed pod name.
+// This is necessary because the instance runs as a Deployment: its pod is
+// replaced, with a freshly generated name, on every restart or rollout.
+func currentPodName(client kubernetes.Interface, namespace, serviceName string) (string, error) {
+	endpoints, err := client.Core().Endpoints(namespace).Get(serviceName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, subset := range endpoints.Subsets {
+		for _, addr := range subset.Addresses {
+			if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" {
+				return addr.TargetRef.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no ready pod behind service %s", serviceName)
+}
+
+// errNoHeketiPod is returned by getHeketiPodIP when instanceID has no pod
+// that could serve traffic, so callers can tell "not up yet" apart from a
+// transport failure talking to a pod that does exist.
+type errNoHeketiPod struct {
+	instanceID string
+}
+
+func (e errNoHeketiPod) Error() string {
+	return fmt.Sprintf("no running heketi pod found for instance %s", e.instanceID)
+}
+
+// findHeketiPod resolves instanceID's Heketi pod, from podLister's cache
+// when non-nil or directly against the Pods API otherwise. Pods that have
+// already exited (Succeeded or Failed) are skipped, since neither can serve
+// requests; a namespace with no eligible pod returns errNoHeketiPod.
+func findHeketiPod(client kubernetes.Interface, podLister v1listers.PodLister, namespace, instanceID string) (*v1.Pod, error) {
+	pods, err := kube.ListInstancePods(client, podLister, namespace, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		return &pods[i], nil
+	}
+	return nil, errNoHeketiPod{instanceID: instanceID}
+}
+
+// getHeketiPodIP resolves instanceID's Heketi pod address. It selects the
+// "heketi" container by name and its port by name, rather than assuming
+// either is Containers[0]/Ports[0], since an admission webhook may inject a
+// sidecar ahead of the heketi container.
+func getHeketiPodIP(client kubernetes.Interface, podLister v1listers.PodLister, namespace, instanceID string) (ip string, port int32, err error) {
+	pod, err := findHeketiPod(client, podLister, namespace, instanceID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	for _, container := range pod.Spec.Containers {
+		if container.Name != "heketi" {
+			continue
+		}
+		for _, p := range container.Ports {
+			if p.Name == heketiPortName {
+				return pod.Status.PodIP, p.ContainerPort, nil
+			}
+		}
+	}
+	return "", 0, errNoHeketiPod{instanceID: instanceID}
+}
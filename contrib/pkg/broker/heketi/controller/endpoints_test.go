@@ -0,0 +1,80 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestGetHeketiPodIPNoPodsReturnsTypedError(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	if _, _, err := getHeketiPodIP(client, nil, "default", "test"); err == nil {
+		t.Fatal("expected an error with no pods")
+	} else if _, ok := err.(errNoHeketiPod); !ok {
+		t.Errorf("expected errNoHeketiPod, got %T: %v", err, err)
+	}
+}
+
+func TestGetHeketiPodIPSkipsSidecarAndSelectsHeketiContainer(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "heketi-test-abcde",
+			Namespace: "default",
+			Labels:    map[string]string{"instanceID": "test"},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{Name: "istio-proxy", Ports: []v1.ContainerPort{{Name: "http-proxy", ContainerPort: 15001}}},
+				{Name: "heketi", Ports: []v1.ContainerPort{{Name: heketiPortName, ContainerPort: heketiPort}}},
+			},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning, PodIP: "10.1.2.3"},
+	}
+	client := fake.NewSimpleClientset(pod)
+
+	ip, port, err := getHeketiPodIP(client, nil, "default", "test")
+	if err != nil {
+		t.Fatalf("getHeketiPodIP: %v", err)
+	}
+	if ip != "10.1.2.3" || port != heketiPort {
+		t.Errorf("expected 10.1.2.3:%d, got %s:%d", heketiPort, ip, port)
+	}
+}
+
+func TestGetHeketiPodIPSkipsTerminatedPods(t *testing.T) {
+	dead := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "heketi-test-old",
+			Namespace: "default",
+			Labels:    map[string]string{"instanceID": "test"},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "heketi", Ports: []v1.ContainerPort{{Name: heketiPortName, ContainerPort: heketiPort}}}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodFailed, PodIP: "10.1.2.4"},
+	}
+	client := fake.NewSimpleClientset(dead)
+
+	if _, _, err := getHeketiPodIP(client, nil, "default", "test"); err == nil {
+		t.Fatal("expected an error when the only matching pod has exited")
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	extensionsv1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	exposeNone     = "none"
+	exposeNodePort = "nodeport"
+	exposeIngress  = "ingress"
+
+	// exposeRoute is only accepted when the broker is running with
+	// platform kube.PlatformOpenShift; see createInstanceResources.
+	exposeRoute = "route"
+)
+
+// exposeConfig captures how an instance should be reached from outside the
+// cluster, taken from the "expose" and "host" provision parameters.
+type exposeConfig struct {
+	Mode string
+	Host string
+}
+
+// parseExposeConfig validates the "expose" provision parameter, defaulting
+// to exposeNone. exposeIngress and exposeRoute additionally require a
+// "host" parameter that is a plausible DNS name; whether exposeRoute is
+// actually usable on this broker is checked later, by
+// createInstanceResources, once the broker's platform is in scope.
+func parseExposeConfig(req *brokerapi.CreateServiceInstanceRequest) (*exposeConfig, error) {
+	mode, _ := req.Parameters["expose"].(string)
+	if mode == "" {
+		mode = exposeNone
+	}
+
+	switch mode {
+	case exposeNone, exposeNodePort:
+		return &exposeConfig{Mode: mode}, nil
+	case exposeIngress, exposeRoute:
+		host, _ := req.Parameters["host"].(string)
+		if host == "" {
+			return nil, fmt.Errorf("expose=%s requires a host parameter", mode)
+		}
+		if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid host %q: %s", host, strings.Join(errs, "; "))
+		}
+		return &exposeConfig{Mode: mode, Host: host}, nil
+	default:
+		return nil, fmt.Errorf("unknown expose mode %q", mode)
+	}
+}
+
+// createHeketiIngress creates an Ingress routing instanceID's host to the
+// instance's Service.
+func createHeketiIngress(client kubernetes.Interface, namespace, instanceID, serviceName, host string, labels, annotations map[string]string) (string, error) {
+	name := names.InstanceResourceName(brokerName, instanceID, "")
+	ing := &extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: extensionsv1beta1.IngressSpec{
+			Rules: []extensionsv1beta1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: serviceName,
+										ServicePort: intstr.FromInt(heketiPort),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if _, err := client.Extensions().Ingresses(namespace).Create(ing); err != nil {
+		return "", fmt.Errorf("failed to create heketi ingress: %v", err)
+	}
+	return name, nil
+}
+
+// createHeketiRoute creates a Route routing instanceID's host to the
+// instance's Service. It's only called when the broker is running with
+// platform kube.PlatformOpenShift, since routeClient is otherwise nil.
+func createHeketiRoute(routeClient kube.RouteClient, namespace, instanceID, serviceName, host string, labels, annotations map[string]string) (string, error) {
+	name := names.InstanceResourceName(brokerName, instanceID, "route")
+	route := kube.NewRoute(name, namespace, host, serviceName, heketiPort, labels, annotations)
+	if _, err := routeClient.Create(namespace, route); err != nil {
+		return "", fmt.Errorf("failed to create heketi route: %v", err)
+	}
+	return name, nil
+}
+
+// externalHeketiURL resolves the URL external tooling should use to reach
+// instance, or "" when the instance was not exposed outside the cluster.
+func externalHeketiURL(client kubernetes.Interface, instance *heketiServiceInstance) (string, error) {
+	switch instance.ExposeMode {
+	case exposeIngress, exposeRoute:
+		return fmt.Sprintf("http://%s", instance.ExposeHost), nil
+	case exposeNodePort:
+		svc, err := client.Core().Services(instance.Namespace).Get(instance.ServiceName, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		if len(svc.Spec.Ports) == 0 || svc.Spec.Ports[0].NodePort == 0 {
+			return "", fmt.Errorf("service %s has no allocated node port", instance.ServiceName)
+		}
+		nodeAddress, err := firstNodeAddress(client)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("http://%s:%d", nodeAddress, svc.Spec.Ports[0].NodePort), nil
+	default:
+		return "", nil
+	}
+}
+
+// firstNodeAddress returns a reachable address for any node in the
+// cluster, preferring an external IP over an internal one.
+func firstNodeAddress(client kubernetes.Interface) (string, error) {
+	nodes, err := client.Core().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	var internal string
+	for _, node := range nodes.Items {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type == v1.NodeExternalIP {
+				return addr.Address, nil
+			}
+			if addr.Type == v1.NodeInternalIP && internal == "" {
+				internal = addr.Address
+			}
+		}
+	}
+	if internal != "" {
+		return internal, nil
+	}
+	return "", fmt.Errorf("no node addresses found")
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestParseExposeConfigDefaultsToNone(t *testing.T) {
+	expose, err := parseExposeConfig(&brokerapi.CreateServiceInstanceRequest{})
+	if err != nil {
+		t.Fatalf("parseExposeConfig: %v", err)
+	}
+	if expose.Mode != exposeNone {
+		t.Fatalf("expected default mode %q, got %q", exposeNone, expose.Mode)
+	}
+}
+
+func TestParseExposeConfigNodePort(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"expose": "nodeport"}}
+	expose, err := parseExposeConfig(req)
+	if err != nil {
+		t.Fatalf("parseExposeConfig: %v", err)
+	}
+	if expose.Mode != exposeNodePort {
+		t.Fatalf("expected mode %q, got %q", exposeNodePort, expose.Mode)
+	}
+}
+
+func TestParseExposeConfigIngressRequiresHost(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"expose": "ingress"}}
+	if _, err := parseExposeConfig(req); err == nil {
+		t.Fatal("expected an error when expose=ingress is missing a host")
+	}
+}
+
+func TestParseExposeConfigIngressRejectsInvalidHost(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{
+		Parameters: map[string]interface{}{"expose": "ingress", "host": "not a valid host!"},
+	}
+	if _, err := parseExposeConfig(req); err == nil {
+		t.Fatal("expected an error for an invalid host")
+	}
+}
+
+func TestParseExposeConfigIngressAcceptsValidHost(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{
+		Parameters: map[string]interface{}{"expose": "ingress", "host": "heketi.example.com"},
+	}
+	expose, err := parseExposeConfig(req)
+	if err != nil {
+		t.Fatalf("parseExposeConfig: %v", err)
+	}
+	if expose.Mode != exposeIngress || expose.Host != "heketi.example.com" {
+		t.Fatalf("expected ingress expose to %s, got %+v", "heketi.example.com", expose)
+	}
+}
+
+func TestParseExposeConfigRouteRequiresHost(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"expose": "route"}}
+	if _, err := parseExposeConfig(req); err == nil {
+		t.Fatal("expected an error when expose=route is missing a host")
+	}
+}
+
+func TestParseExposeConfigRouteAcceptsValidHost(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{
+		Parameters: map[string]interface{}{"expose": "route", "host": "heketi.example.com"},
+	}
+	expose, err := parseExposeConfig(req)
+	if err != nil {
+		t.Fatalf("parseExposeConfig: %v", err)
+	}
+	if expose.Mode != exposeRoute || expose.Host != "heketi.example.com" {
+		t.Fatalf("expected route expose to %s, got %+v", "heketi.example.com", expose)
+	}
+}
+
+func TestParseExposeConfigRejectsUnknownMode(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"expose": "carrier-pigeon"}}
+	if _, err := parseExposeConfig(req); err == nil {
+		t.Fatal("expected an error for an unknown expose mode")
+	}
+}
+
+func TestExternalHeketiURLNoneIsEmpty(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	instance := &heketiServiceInstance{ExposeMode: exposeNone}
+
+	url, err := externalHeketiURL(client, instance)
+	if err != nil {
+		t.Fatalf("externalHeketiURL: %v", err)
+	}
+	if url != "" {
+		t.Fatalf("expected no external URL for exposeNone, got %q", url)
+	}
+}
+
+func TestExternalHeketiURLIngress(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	instance := &heketiServiceInstance{ExposeMode: exposeIngress, ExposeHost: "heketi.example.com"}
+
+	url, err := externalHeketiURL(client, instance)
+	if err != nil {
+		t.Fatalf("externalHeketiURL: %v", err)
+	}
+	if url != "http://heketi.example.com" {
+		t.Fatalf("expected the ingress host as the external URL, got %q", url)
+	}
+}
+
+func TestExternalHeketiURLRoute(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	instance := &heketiServiceInstance{ExposeMode: exposeRoute, ExposeHost: "heketi.example.com"}
+
+	url, err := externalHeketiURL(client, instance)
+	if err != nil {
+		t.Fatalf("externalHeketiURL: %v", err)
+	}
+	if url != "http://heketi.example.com" {
+		t.Fatalf("expected the route host as the external URL, got %q", url)
+	}
+}
+
+func TestExternalHeketiURLNodePort(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+			Status: v1.NodeStatus{
+				Addresses: []v1.NodeAddress{{Type: v1.NodeExternalIP, Address: "203.0.113.5"}},
+			},
+		},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "heketi-test", Namespace: "default"},
+			Spec: v1.ServiceSpec{
+				Type:  v1.ServiceTypeNodePort,
+				Ports: []v1.ServicePort{{NodePort: 31234}},
+			},
+		},
+	)
+	instance := &heketiServiceInstance{ExposeMode: exposeNodePort, Namespace: "default", ServiceName: "heketi-test"}
+
+	url, err := externalHeketiURL(client, instance)
+	if err != nil {
+		t.Fatalf("externalHeketiURL: %v", err)
+	}
+	if url != "http://203.0.113.5:31234" {
+		t.Fatalf("expected the node address and node port, got %q", url)
+	}
+}
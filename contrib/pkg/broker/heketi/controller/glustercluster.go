@@ -0,0 +1,101 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	sshKeyVolumeName = "sshkey"
+	sshKeyMountPath  = "/etc/heketi/private_key"
+	sshExecutorPort  = "22"
+	sshExecutorUser  = "root"
+)
+
+// existingCluster holds the parameters needed to point a Heketi instance at
+// an already-running GlusterFS cluster over the ssh executor, instead of
+// having Heketi manage cluster nodes itself.
+type existingCluster struct {
+	Nodes            []string
+	SSHKeySecretName string
+}
+
+// parseExistingCluster extracts and validates the glusterNodes/sshKeySecret
+// provision parameters. Both parameters are optional, but if either is set
+// the other is required. Returns nil, nil when neither is set, in which
+// case the instance falls back to Heketi's built-in mock/kubernetes
+// executor behavior.
+func parseExistingCluster(client kubernetes.Interface, namespace string, req *brokerapi.CreateServiceInstanceRequest) (*existingCluster, error) {
+	rawNodes, hasNodes := req.Parameters["glusterNodes"]
+	secretName, _ := req.Parameters["sshKeySecret"].(string)
+	hasSecret := secretName != ""
+
+	if !hasNodes && !hasSecret {
+		return nil, nil
+	}
+	if !hasNodes || !hasSecret {
+		return nil, fmt.Errorf("glusterNodes and sshKeySecret must both be set to use an existing GlusterFS cluster")
+	}
+
+	raw, err := json.Marshal(rawNodes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glusterNodes parameter: %v", err)
+	}
+	var nodes []string
+	if err := json.Unmarshal(raw, &nodes); err != nil {
+		return nil, fmt.Errorf("invalid glusterNodes parameter: %v", err)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("glusterNodes must list at least one node")
+	}
+
+	if _, err := client.Core().Secrets(namespace).Get(secretName, metav1.GetOptions{}); err != nil {
+		return nil, fmt.Errorf("sshKeySecret %q not found: %v", secretName, err)
+	}
+
+	return &existingCluster{Nodes: nodes, SSHKeySecretName: secretName}, nil
+}
+
+// attachSSHKey mounts an existing cluster's SSH private key into the heketi
+// container, alongside the heketi.json config attached separately by
+// attachHeketiConfig.
+func attachSSHKey(spec *v1.PodSpec, sshKeySecretName string) {
+	spec.Volumes = append(spec.Volumes, v1.Volume{
+		Name: sshKeyVolumeName,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{
+				SecretName: sshKeySecretName,
+				Items: []v1.KeyToPath{
+					{Key: v1.SSHAuthPrivateKey, Path: "private_key"},
+				},
+			},
+		},
+	})
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts,
+			v1.VolumeMount{Name: sshKeyVolumeName, MountPath: sshKeyMountPath, SubPath: "private_key"},
+		)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestParseExistingClusterAbsentFallsBack(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	cluster, err := parseExistingCluster(client, "default", &brokerapi.CreateServiceInstanceRequest{})
+	if err != nil {
+		t.Fatalf("parseExistingCluster: %v", err)
+	}
+	if cluster != nil {
+		t.Fatalf("expected nil cluster when no parameters are set, got %v", cluster)
+	}
+}
+
+func TestParseExistingClusterRequiresBothParameters(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	req := &brokerapi.CreateServiceInstanceRequest{
+		Parameters: map[string]interface{}{"glusterNodes": []interface{}{"node1"}},
+	}
+	if _, err := parseExistingCluster(client, "default", req); err == nil {
+		t.Fatal("expected an error when sshKeySecret is missing")
+	}
+}
+
+func TestParseExistingClusterRejectsEmptyNodeList(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "gluster-ssh", Namespace: "default"},
+	})
+	req := &brokerapi.CreateServiceInstanceRequest{
+		Parameters: map[string]interface{}{
+			"glusterNodes": []interface{}{},
+			"sshKeySecret": "gluster-ssh",
+		},
+	}
+	if _, err := parseExistingCluster(client, "default", req); err == nil {
+		t.Fatal("expected an error for an empty glusterNodes list")
+	}
+}
+
+func TestParseExistingClusterRejectsMissingSecret(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	req := &brokerapi.CreateServiceInstanceRequest{
+		Parameters: map[string]interface{}{
+			"glusterNodes": []interface{}{"node1"},
+			"sshKeySecret": "gluster-ssh",
+		},
+	}
+	if _, err := parseExistingCluster(client, "default", req); err == nil {
+		t.Fatal("expected an error when sshKeySecret does not exist")
+	}
+}
+
+func TestParseExistingClusterSucceeds(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "gluster-ssh", Namespace: "default"},
+	})
+	req := &brokerapi.CreateServiceInstanceRequest{
+		Parameters: map[string]interface{}{
+			"glusterNodes": []interface{}{"node1", "node2"},
+			"sshKeySecret": "gluster-ssh",
+		},
+	}
+	cluster, err := parseExistingCluster(client, "default", req)
+	if err != nil {
+		t.Fatalf("parseExistingCluster: %v", err)
+	}
+	if len(cluster.Nodes) != 2 || cluster.SSHKeySecretName != "gluster-ssh" {
+		t.Fatalf("unexpected cluster: %+v", cluster)
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+)
+
+// defaultHeketiImage is a specific, pinned Heketi release rather than a
+// moving tag, so a disconnected cluster mirroring images into its own
+// registry doesn't have existing instances silently start running a
+// different Heketi version out from under them on their next rollout. Pass
+// "latest" explicitly, via --heketi-image or the imageTag provision
+// parameter, to opt back into the old moving-target behavior.
+const defaultHeketiImage = "heketi/heketi:9"
+
+// imageTagPattern is the subset of Docker's tag grammar this broker accepts
+// for the imageTag provision parameter: no ":" or "/", so a caller can't
+// smuggle a different repository or a digest reference in through what's
+// meant to be just a tag.
+var imageTagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// resolveImage returns the image to run for an instance: defaultImage, as
+// configured on the broker, with its tag replaced by the imageTag
+// provision parameter when one is given.
+func resolveImage(defaultImage string, req *brokerapi.CreateServiceInstanceRequest) (string, error) {
+	tag, ok := req.Parameters["imageTag"].(string)
+	if !ok || tag == "" {
+		return defaultImage, nil
+	}
+	if !imageTagPattern.MatchString(tag) {
+		return "", fmt.Errorf("imageTag %q is not a valid image tag", tag)
+	}
+
+	repo := defaultImage
+	if i := strings.LastIndex(defaultImage, ":"); i >= 0 {
+		repo = defaultImage[:i]
+	}
+	return fmt.Sprintf("%s:%s", repo, tag), nil
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+)
+
+func TestResolveImageDefaultsToBrokerImage(t *testing.T) {
+	image, err := resolveImage(defaultHeketiImage, &brokerapi.CreateServiceInstanceRequest{})
+	if err != nil {
+		t.Fatalf("resolveImage: %v", err)
+	}
+	if image != defaultHeketiImage {
+		t.Errorf("image = %q, want %q", image, defaultHeketiImage)
+	}
+}
+
+func TestResolveImageHonorsImageTagParameter(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"imageTag": "latest"}}
+	image, err := resolveImage("heketi/heketi:9", req)
+	if err != nil {
+		t.Fatalf("resolveImage: %v", err)
+	}
+	if image != "heketi/heketi:latest" {
+		t.Errorf("image = %q, want %q", image, "heketi/heketi:latest")
+	}
+}
+
+func TestResolveImageRejectsInvalidImageTag(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"imageTag": "not/a-tag:9"}}
+	if _, err := resolveImage(defaultHeketiImage, req); err == nil {
+		t.Fatal("expected an error for an imageTag containing a repository or digest separator")
+	}
+}
@@ -0,0 +1,295 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/reqlog"
+	"github.com/kubernetes-incubator/service-catalog/pkg"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ktesting "k8s.io/client-go/testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// failCreate makes client return err for every Create against resource,
+// simulating the API server rejecting one particular kind of object.
+func failCreate(client *fake.Clientset, resource string, err error) {
+	client.PrependReactor("create", resource, func(ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, err
+	})
+}
+
+func TestCreateInstanceResourcesRollsBackWhateverWasCreatedOnFailure(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	cases := []struct {
+		name     string
+		resource string
+		planID   string
+	}{
+		{"configmap", "configmaps", planEphemeral},
+		{"deployment", "deployments", planEphemeral},
+		{"service", "services", planEphemeral},
+		{"persistentvolumeclaim", "persistentvolumeclaims", planPersistent},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			failCreate(client, tc.resource, fmt.Errorf("induced failure"))
+
+			instance := &heketiServiceInstance{ID: id, Namespace: namespace}
+			req := &brokerapi.CreateServiceInstanceRequest{PlanID: tc.planID}
+			expose := &exposeConfig{Mode: exposeNone}
+			rollback := &kube.RollbackTracker{}
+
+			if _, err := createInstanceResources(context.Background(), client, instance, req, nil, expose, nil, &authConfig{Enabled: true}, defaultHeketiImage, "", defaultNamespace, defaultServiceAccount, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, rollback); err == nil {
+				t.Fatal("expected createInstanceResources to fail")
+			}
+
+			if err := rollback.Run(context.Background()); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			if instance.SecretName != "" {
+				if _, err := client.Core().Secrets(namespace).Get(instance.SecretName, metav1.GetOptions{}); err == nil {
+					t.Errorf("admin secret %s survived rollback", instance.SecretName)
+				}
+			}
+			if instance.ConfigMapName != "" {
+				if _, err := client.Core().ConfigMaps(namespace).Get(instance.ConfigMapName, metav1.GetOptions{}); err == nil {
+					t.Errorf("config map %s survived rollback", instance.ConfigMapName)
+				}
+			}
+			if instance.PVCName != "" {
+				if _, err := client.Core().PersistentVolumeClaims(namespace).Get(instance.PVCName, metav1.GetOptions{}); err == nil {
+					t.Errorf("PVC %s survived rollback", instance.PVCName)
+				}
+			}
+			if instance.DeploymentName != "" {
+				if _, err := client.Apps().Deployments(namespace).Get(instance.DeploymentName, metav1.GetOptions{}); err == nil {
+					t.Errorf("deployment %s survived rollback", instance.DeploymentName)
+				}
+			}
+			if instance.ServiceName != "" {
+				if _, err := client.Core().Services(namespace).Get(instance.ServiceName, metav1.GetOptions{}); err == nil {
+					t.Errorf("service %s survived rollback", instance.ServiceName)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateInstanceResourcesTranslatesForbiddenDeploymentCreate(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset()
+	failCreate(client, "deployments", apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, id, fmt.Errorf("denied")))
+
+	instance := &heketiServiceInstance{ID: id, Namespace: namespace}
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: planEphemeral}
+	expose := &exposeConfig{Mode: exposeNone}
+	rollback := &kube.RollbackTracker{}
+
+	_, err := createInstanceResources(context.Background(), client, instance, req, nil, expose, nil, &authConfig{Enabled: true}, defaultHeketiImage, "", defaultNamespace, defaultServiceAccount, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, rollback)
+	if err == nil {
+		t.Fatal("expected createInstanceResources to fail")
+	}
+	if !strings.Contains(err.Error(), "not permitted to create deployments") {
+		t.Errorf("err = %v, want it to name the forbidden verb and resource", err)
+	}
+}
+
+func TestCreateInstanceResourcesLabelsEveryResource(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset()
+
+	instance := &heketiServiceInstance{ID: id, Namespace: namespace}
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: planPersistent}
+	expose := &exposeConfig{Mode: exposeIngress, Host: "heketi.example.com"}
+	rollback := &kube.RollbackTracker{}
+
+	if _, err := createInstanceResources(context.Background(), client, instance, req, nil, expose, nil, &authConfig{Enabled: true}, defaultHeketiImage, "", defaultNamespace, defaultServiceAccount, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, rollback); err != nil {
+		t.Fatalf("createInstanceResources: %v", err)
+	}
+
+	want := kube.CommonLabels(brokerName, pkg.VERSION, serviceID, req.PlanID, id, namespace)
+
+	secret, err := client.Core().Secrets(namespace).Get(instance.SecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get secret: %v", err)
+	}
+	if !reflect.DeepEqual(secret.Labels, want) {
+		t.Errorf("secret labels = %+v, want %+v", secret.Labels, want)
+	}
+
+	pvc, err := client.Core().PersistentVolumeClaims(namespace).Get(instance.PVCName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get pvc: %v", err)
+	}
+	if !reflect.DeepEqual(pvc.Labels, want) {
+		t.Errorf("pvc labels = %+v, want %+v", pvc.Labels, want)
+	}
+
+	configMap, err := client.Core().ConfigMaps(namespace).Get(instance.ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get configmap: %v", err)
+	}
+	if !reflect.DeepEqual(configMap.Labels, want) {
+		t.Errorf("configmap labels = %+v, want %+v", configMap.Labels, want)
+	}
+
+	deployment, err := client.Apps().Deployments(namespace).Get(instance.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get deployment: %v", err)
+	}
+	if !reflect.DeepEqual(deployment.Labels, want) {
+		t.Errorf("deployment labels = %+v, want %+v", deployment.Labels, want)
+	}
+	if !reflect.DeepEqual(deployment.Spec.Template.Labels, want) {
+		t.Errorf("pod template labels = %+v, want %+v", deployment.Spec.Template.Labels, want)
+	}
+
+	service, err := client.Core().Services(namespace).Get(instance.ServiceName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get service: %v", err)
+	}
+	if !reflect.DeepEqual(service.Labels, want) {
+		t.Errorf("service labels = %+v, want %+v", service.Labels, want)
+	}
+
+	ingress, err := client.Extensions().Ingresses(namespace).Get(instance.IngressName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get ingress: %v", err)
+	}
+	if !reflect.DeepEqual(ingress.Labels, want) {
+		t.Errorf("ingress labels = %+v, want %+v", ingress.Labels, want)
+	}
+}
+
+func TestCreateInstanceResourcesAnnotatesEveryResource(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	logger := reqlog.New()
+	ctx := reqlog.NewContext(context.Background(), logger)
+	want := kube.TraceAnnotations(logger.CorrelationID(), "provision")
+
+	client := fake.NewSimpleClientset()
+
+	instance := &heketiServiceInstance{ID: id, Namespace: namespace}
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: planEphemeral}
+	expose := &exposeConfig{Mode: exposeNone}
+	rollback := &kube.RollbackTracker{}
+
+	if _, err := createInstanceResources(ctx, client, instance, req, nil, expose, nil, &authConfig{Enabled: true}, defaultHeketiImage, "", defaultNamespace, defaultServiceAccount, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, rollback); err != nil {
+		t.Fatalf("createInstanceResources: %v", err)
+	}
+
+	secret, err := client.Core().Secrets(namespace).Get(instance.SecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get secret: %v", err)
+	}
+	if !reflect.DeepEqual(secret.Annotations, want) {
+		t.Errorf("secret annotations = %+v, want %+v", secret.Annotations, want)
+	}
+
+	deployment, err := client.Apps().Deployments(namespace).Get(instance.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get deployment: %v", err)
+	}
+	if !reflect.DeepEqual(deployment.Annotations, want) {
+		t.Errorf("deployment annotations = %+v, want %+v", deployment.Annotations, want)
+	}
+	for k, v := range want {
+		if deployment.Spec.Template.Annotations[k] != v {
+			t.Errorf("pod template annotations = %+v, want to include %+v", deployment.Spec.Template.Annotations, want)
+		}
+	}
+
+	service, err := client.Core().Services(namespace).Get(instance.ServiceName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get service: %v", err)
+	}
+	if !reflect.DeepEqual(service.Annotations, want) {
+		t.Errorf("service annotations = %+v, want %+v", service.Annotations, want)
+	}
+}
+
+func TestCreateInstanceResourcesRollsBackIngressOnFailure(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset()
+	failCreate(client, "ingresses", fmt.Errorf("induced failure"))
+
+	instance := &heketiServiceInstance{ID: id, Namespace: namespace}
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: planEphemeral}
+	expose := &exposeConfig{Mode: exposeIngress, Host: "heketi.example.com"}
+	rollback := &kube.RollbackTracker{}
+
+	if _, err := createInstanceResources(context.Background(), client, instance, req, nil, expose, nil, &authConfig{Enabled: true}, defaultHeketiImage, "", defaultNamespace, defaultServiceAccount, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, rollback); err == nil {
+		t.Fatal("expected createInstanceResources to fail")
+	}
+
+	if err := rollback.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if _, err := client.Core().Services(namespace).Get(instance.ServiceName, metav1.GetOptions{}); err == nil {
+		t.Errorf("service %s survived rollback", instance.ServiceName)
+	}
+	if _, err := client.Apps().Deployments(namespace).Get(instance.DeploymentName, metav1.GetOptions{}); err == nil {
+		t.Errorf("deployment %s survived rollback", instance.DeploymentName)
+	}
+}
+
+func TestCreateInstanceResourcesAppliesPodSpecOverride(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset()
+
+	instance := &heketiServiceInstance{ID: id, Namespace: namespace}
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: planEphemeral}
+	expose := &exposeConfig{Mode: exposeNone}
+	rollback := &kube.RollbackTracker{}
+	override := &kube.PodSpecOverride{NodeSelector: map[string]string{"disktype": "ssd"}}
+
+	if _, err := createInstanceResources(context.Background(), client, instance, req, nil, expose, nil, &authConfig{Enabled: true}, defaultHeketiImage, "", defaultNamespace, defaultServiceAccount, 1, time.Millisecond, override, kube.PlatformKubernetes, nil, rollback); err != nil {
+		t.Fatalf("createInstanceResources: %v", err)
+	}
+
+	deployment, err := client.Apps().Deployments(namespace).Get(instance.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get deployment: %v", err)
+	}
+	if got, want := deployment.Spec.Template.Spec.NodeSelector["disktype"], "ssd"; got != want {
+		t.Errorf("NodeSelector[disktype] = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/waitutil"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// readinessTimeout bounds how long waitForHeketiReady waits for a freshly
+// created instance to start answering requests.
+const readinessTimeout = 60 * time.Second
+
+const readinessPollInterval = 2 * time.Second
+
+// waitForHeketiReady polls Heketi's /hello endpoint, which it answers as
+// soon as its HTTP server is up, independent of cluster/topology state. It
+// returns early if ctx is canceled or hits its deadline first.
+func waitForHeketiReady(ctx context.Context, baseURL string) error {
+	deadline := time.Now().Add(readinessTimeout)
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for heketi instance at %s to answer /hello: %v", baseURL, ctx.Err())
+		default:
+		}
+
+		resp, err := http.Get(baseURL + "/hello")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("heketi instance at %s never answered /hello", baseURL)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for heketi instance at %s to answer /hello: %v", baseURL, ctx.Err())
+		case <-time.After(readinessPollInterval):
+		}
+	}
+}
+
+// podReadinessTimeout bounds how long waitForPodRunning waits for a freshly
+// created instance's pod to reach the Ready condition.
+const podReadinessTimeout = 60 * time.Second
+
+// waitForPodRunning waits for instanceID's pod to become Ready, using
+// waitutil.ForPodReady. This runs before waitForHeketiReady, since a pod
+// that hasn't started yet won't answer /hello. It returns early if ctx is
+// canceled or hits its deadline first.
+func waitForPodRunning(ctx context.Context, client kubernetes.Interface, namespace, instanceID string) error {
+	if err := waitutil.ForPodReady(ctx, client, namespace, kube.InstanceLabelSelector(instanceID), podReadinessTimeout); err != nil {
+		return fmt.Errorf("waiting for heketi pod for instance %s to become ready: %v", instanceID, err)
+	}
+	return nil
+}
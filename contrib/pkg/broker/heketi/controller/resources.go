@@ -0,0 +1,144 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// Resource tier names. A tier name is the "resources" provision parameter
+// value; tierNameForPlanID maps an UpdateServiceInstance plan ID to one.
+const (
+	tierSmall  = "small"
+	tierMedium = "medium"
+	tierLarge  = "large"
+)
+
+// resourceTier names a CPU/memory sizing applied to a Heketi instance's
+// container.
+type resourceTier struct {
+	Name        string
+	Description string
+	Resources   v1.ResourceRequirements
+}
+
+func mustResources(cpuRequest, memRequest, cpuLimit, memLimit string) v1.ResourceRequirements {
+	return v1.ResourceRequirements{
+		Requests: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpuRequest),
+			v1.ResourceMemory: resource.MustParse(memRequest),
+		},
+		Limits: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpuLimit),
+			v1.ResourceMemory: resource.MustParse(memLimit),
+		},
+	}
+}
+
+// resourceTiers are the sizes offered for a Heketi instance's container.
+// Instances that don't request a tier stay BestEffort, since Heketi's
+// footprint scales with topology size and a fixed default would either
+// starve large clusters or waste capacity on small ones.
+var resourceTiers = map[string]resourceTier{
+	tierSmall: {
+		Name:        tierSmall,
+		Description: "100m CPU / 128Mi memory request, 250m CPU / 256Mi memory limit",
+		Resources:   mustResources("100m", "128Mi", "250m", "256Mi"),
+	},
+	tierMedium: {
+		Name:        tierMedium,
+		Description: "250m CPU / 256Mi memory request, 500m CPU / 512Mi memory limit",
+		Resources:   mustResources("250m", "256Mi", "500m", "512Mi"),
+	},
+	tierLarge: {
+		Name:        tierLarge,
+		Description: "500m CPU / 512Mi memory request, 1 CPU / 1Gi memory limit",
+		Resources:   mustResources("500m", "512Mi", "1", "1Gi"),
+	},
+}
+
+// resolveResourceTier reads the "resources" provision parameter, falling
+// back to defaultTier when it is unset. An empty defaultTier means
+// BestEffort. Returns an error if the parameter names an unknown tier.
+func resolveResourceTier(req *brokerapi.CreateServiceInstanceRequest, defaultTier string) (*resourceTier, error) {
+	name, ok := req.Parameters["resources"].(string)
+	if !ok || name == "" {
+		name = defaultTier
+	}
+	if name == "" {
+		return nil, nil
+	}
+	tier, ok := resourceTiers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown resource tier %q", name)
+	}
+	return &tier, nil
+}
+
+// tierNameForPlanID maps the small/medium/large catalog plan IDs to their
+// resource tier name, so UpdateServiceInstance can resize an instance by
+// plan_id in addition to the "resources" parameter.
+var tierNameForPlanID = map[string]string{
+	planSmall:  tierSmall,
+	planMedium: tierMedium,
+	planLarge:  tierLarge,
+}
+
+// resolveUpdateResourceTier reads the desired tier from an update request,
+// preferring an explicit "resources" parameter and falling back to req.PlanID
+// when it names one of the small/medium/large plans. Returns nil, nil when
+// the request doesn't ask for a resize.
+func resolveUpdateResourceTier(req *brokerapi.UpdateServiceInstanceRequest) (*resourceTier, error) {
+	name, ok := req.Parameters["resources"].(string)
+	if !ok || name == "" {
+		name = tierNameForPlanID[req.PlanID]
+	}
+	if name == "" {
+		return nil, nil
+	}
+	tier, ok := resourceTiers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown resource tier %q", name)
+	}
+	return &tier, nil
+}
+
+// applyResourceTier sets every container's resource requirements to tier's.
+func applyResourceTier(spec *v1.PodSpec, tier *resourceTier) {
+	if tier == nil {
+		return
+	}
+	for i := range spec.Containers {
+		spec.Containers[i].Resources = tier.Resources
+	}
+}
+
+// deploymentOptions bundles the settings that determine a Heketi instance's
+// Deployment shape: the image to run, an optional pull secret, and resource
+// sizing. Resolving all three up front and passing them into
+// newHeketiInstanceResources, rather than mutating the Deployment it
+// returns, keeps that constructor a pure function of its inputs.
+type deploymentOptions struct {
+	Image           string
+	ImagePullSecret string
+	Tier            *resourceTier
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestResolveResourceTierDefaultsToNilWithoutDefault(t *testing.T) {
+	tier, err := resolveResourceTier(&brokerapi.CreateServiceInstanceRequest{}, "")
+	if err != nil {
+		t.Fatalf("resolveResourceTier: %v", err)
+	}
+	if tier != nil {
+		t.Fatalf("expected no tier, got %+v", tier)
+	}
+}
+
+func TestResolveResourceTierFallsBackToDefaultTier(t *testing.T) {
+	tier, err := resolveResourceTier(&brokerapi.CreateServiceInstanceRequest{}, tierSmall)
+	if err != nil {
+		t.Fatalf("resolveResourceTier: %v", err)
+	}
+	if tier == nil || tier.Name != tierSmall {
+		t.Fatalf("expected the default tier %q, got %+v", tierSmall, tier)
+	}
+}
+
+func TestResolveResourceTierHonorsParameter(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"resources": "large"}}
+	tier, err := resolveResourceTier(req, tierSmall)
+	if err != nil {
+		t.Fatalf("resolveResourceTier: %v", err)
+	}
+	if tier == nil || tier.Name != tierLarge {
+		t.Fatalf("expected the requested tier %q, got %+v", tierLarge, tier)
+	}
+}
+
+func TestResolveResourceTierRejectsUnknownTier(t *testing.T) {
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"resources": "gigantic"}}
+	if _, err := resolveResourceTier(req, ""); err == nil {
+		t.Fatal("expected an error for an unknown resource tier")
+	}
+}
+
+func TestApplyResourceTierSetsEveryContainer(t *testing.T) {
+	spec := &v1.PodSpec{Containers: []v1.Container{{Name: "heketi"}, {Name: "sidecar"}}}
+	tier := resourceTiers[tierMedium]
+
+	applyResourceTier(spec, &tier)
+
+	for _, c := range spec.Containers {
+		if c.Resources.Requests.Cpu().String() != "250m" {
+			t.Fatalf("expected container %q to get the medium tier's CPU request, got %v", c.Name, c.Resources.Requests.Cpu())
+		}
+	}
+}
+
+func TestApplyResourceTierNilIsANoOp(t *testing.T) {
+	spec := &v1.PodSpec{Containers: []v1.Container{{Name: "heketi"}}}
+	applyResourceTier(spec, nil)
+
+	if len(spec.Containers[0].Resources.Requests) != 0 {
+		t.Fatalf("expected no resources to be set, got %+v", spec.Containers[0].Resources)
+	}
+}
+
+func TestResolveUpdateResourceTierHonorsPlanID(t *testing.T) {
+	tier, err := resolveUpdateResourceTier(&brokerapi.UpdateServiceInstanceRequest{PlanID: planLarge})
+	if err != nil {
+		t.Fatalf("resolveUpdateResourceTier: %v", err)
+	}
+	if tier == nil || tier.Name != tierLarge {
+		t.Fatalf("expected the plan's tier %q, got %+v", tierLarge, tier)
+	}
+}
+
+func TestResolveUpdateResourceTierHonorsParameterOverPlanID(t *testing.T) {
+	req := &brokerapi.UpdateServiceInstanceRequest{
+		PlanID:     planLarge,
+		Parameters: map[string]interface{}{"resources": "small"},
+	}
+	tier, err := resolveUpdateResourceTier(req)
+	if err != nil {
+		t.Fatalf("resolveUpdateResourceTier: %v", err)
+	}
+	if tier == nil || tier.Name != tierSmall {
+		t.Fatalf("expected the requested tier %q, got %+v", tierSmall, tier)
+	}
+}
+
+func TestResolveUpdateResourceTierNoTierRequested(t *testing.T) {
+	tier, err := resolveUpdateResourceTier(&brokerapi.UpdateServiceInstanceRequest{})
+	if err != nil {
+		t.Fatalf("resolveUpdateResourceTier: %v", err)
+	}
+	if tier != nil {
+		t.Fatalf("expected no tier, got %+v", tier)
+	}
+}
+
+// TestResourceTiersSerializeAsPlanMetadata guards the shape of the value
+// handed to brokerapi.ServicePlan.Metadata for each tier, since that field
+// is a bare interface{} and a typo in a struct tag would otherwise only
+// surface once a client parsed a real catalog response.
+func TestResourceTiersSerializeAsPlanMetadata(t *testing.T) {
+	for name, tier := range resourceTiers {
+		data, err := json.Marshal(tier)
+		if err != nil {
+			t.Fatalf("marshal tier %q: %v", name, err)
+		}
+
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("unmarshal tier %q: %v", name, err)
+		}
+
+		if decoded["Name"] != name {
+			t.Errorf("tier %q: expected serialized Name %q, got %v", name, name, decoded["Name"])
+		}
+		if decoded["Description"] == "" {
+			t.Errorf("tier %q: expected a non-empty Description", name)
+		}
+		if _, ok := decoded["Resources"]; !ok {
+			t.Errorf("tier %q: expected a serialized Resources field", name)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeRouteClient is an in-memory kube.RouteClient, used in tests in place
+// of a real OpenShift cluster.
+type fakeRouteClient struct {
+	routes map[string]*unstructured.Unstructured
+}
+
+func newFakeRouteClient() *fakeRouteClient {
+	return &fakeRouteClient{routes: make(map[string]*unstructured.Unstructured)}
+}
+
+func (f *fakeRouteClient) Create(namespace string, route *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	f.routes[namespace+"/"+route.GetName()] = route
+	return route, nil
+}
+
+func (f *fakeRouteClient) List(namespace string, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{}
+	for _, route := range f.routes {
+		if route.GetNamespace() == namespace {
+			list.Items = append(list.Items, *route)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeRouteClient) Delete(namespace, name string) error {
+	key := namespace + "/" + name
+	if _, ok := f.routes[key]; !ok {
+		return fmt.Errorf("route %s not found", key)
+	}
+	delete(f.routes, key)
+	return nil
+}
+
+func (f *fakeRouteClient) DeleteCollection(namespace string, opts metav1.ListOptions) error {
+	for key, route := range f.routes {
+		if route.GetNamespace() == namespace {
+			delete(f.routes, key)
+		}
+	}
+	return nil
+}
+
+func TestCreateInstanceResourcesCreatesRouteOnOpenShift(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset()
+	routeClient := newFakeRouteClient()
+
+	instance := &heketiServiceInstance{ID: id, Namespace: namespace}
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: planEphemeral}
+	expose := &exposeConfig{Mode: exposeRoute, Host: "heketi.example.com"}
+	rollback := &kube.RollbackTracker{}
+
+	if _, err := createInstanceResources(context.Background(), client, instance, req, nil, expose, nil, &authConfig{Enabled: true}, defaultHeketiImage, "", defaultNamespace, defaultServiceAccount, 1, time.Millisecond, nil, kube.PlatformOpenShift, routeClient, rollback); err != nil {
+		t.Fatalf("createInstanceResources: %v", err)
+	}
+
+	if instance.RouteName == "" {
+		t.Fatal("expected instance.RouteName to be set")
+	}
+	if instance.IngressName != "" {
+		t.Errorf("instance.IngressName = %q, want empty for expose=route", instance.IngressName)
+	}
+
+	list, err := routeClient.List(namespace, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("len(list.Items) = %d, want 1", len(list.Items))
+	}
+}
+
+func TestCreateInstanceResourcesRejectsRouteWithoutOpenShift(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset()
+
+	instance := &heketiServiceInstance{ID: id, Namespace: namespace}
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: planEphemeral}
+	expose := &exposeConfig{Mode: exposeRoute, Host: "heketi.example.com"}
+	rollback := &kube.RollbackTracker{}
+
+	_, err := createInstanceResources(context.Background(), client, instance, req, nil, expose, nil, &authConfig{Enabled: true}, defaultHeketiImage, "", defaultNamespace, defaultServiceAccount, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, rollback)
+	if _, ok := err.(kube.ErrPlatformNotConfigured); !ok {
+		t.Fatalf("err = %v (%T), want a kube.ErrPlatformNotConfigured", err, err)
+	}
+}
+
+// failingRouteClient is a kube.RouteClient whose Create always fails,
+// simulating the Route API rejecting the object.
+type failingRouteClient struct {
+	*fakeRouteClient
+}
+
+func (f *failingRouteClient) Create(namespace string, route *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return nil, fmt.Errorf("induced failure")
+}
+
+func TestCreateInstanceResourcesFailsWhenRouteCreateFails(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset()
+	routeClient := &failingRouteClient{newFakeRouteClient()}
+
+	instance := &heketiServiceInstance{ID: id, Namespace: namespace}
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: planEphemeral}
+	expose := &exposeConfig{Mode: exposeRoute, Host: "heketi.example.com"}
+	rollback := &kube.RollbackTracker{}
+
+	if _, err := createInstanceResources(context.Background(), client, instance, req, nil, expose, nil, &authConfig{Enabled: true}, defaultHeketiImage, "", defaultNamespace, defaultServiceAccount, 1, time.Millisecond, nil, kube.PlatformOpenShift, routeClient, rollback); err == nil {
+		t.Fatal("expected createInstanceResources to fail")
+	}
+}
+
+func TestDoHeketiDeprovisionDeletesRoutes(t *testing.T) {
+	const namespace, id = "default", "test"
+
+	client := fake.NewSimpleClientset()
+	routeClient := newFakeRouteClient()
+	route := kube.NewRoute("r", namespace, "heketi.example.com", "heketi-"+id, heketiPort, map[string]string{"instanceID": id, "serviceID": serviceID}, nil)
+	if _, err := routeClient.Create(namespace, route); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	instance := &heketiServiceInstance{ID: id, Namespace: namespace}
+	if err := doHeketiDeprovision(context.Background(), client, routeClient, instance, false); err != nil {
+		t.Fatalf("doHeketiDeprovision: %v", err)
+	}
+
+	list, err := routeClient.List(namespace, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("%d routes remain after deprovision", len(list.Items))
+	}
+}
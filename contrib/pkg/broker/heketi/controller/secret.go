@@ -0,0 +1,175 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const adminKeyEnvVar = "HEKETI_ADMIN_KEY"
+
+// adminKeyHashAnnotation records the fingerprint of the admin key a pod was
+// started with. Bind compares this against the current contents of the
+// instance's secret to catch a secret that was edited by hand after the pod
+// started, rather than handing out a key the running server will reject.
+const adminKeyHashAnnotation = "heketi.gluster.org/admin-key-hash"
+
+// adminKeyHash fingerprints an admin key for use in adminKeyHashAnnotation,
+// so the comparison never has to hold two plaintext keys side by side.
+func adminKeyHash(key string) string {
+	return configHash([]byte(key))
+}
+
+// setAdminKeyHashAnnotation stamps a pod template with the hash of the
+// admin key it was created against.
+func setAdminKeyHashAnnotation(template *v1.PodTemplateSpec, key string) {
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[adminKeyHashAnnotation] = adminKeyHash(key)
+}
+
+// adminSecretName returns the name of the secret holding instanceID's
+// generated Heketi admin key and per-binding keys.
+func adminSecretName(instanceID string) string {
+	return names.InstanceResourceName(brokerName, instanceID, "admin")
+}
+
+// generateAdminKey returns a random hex string used as the Heketi admin
+// user's key.
+func generateAdminKey() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate heketi admin key: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// createAdminSecret creates the secret holding the Heketi admin key and
+// returns its name. When key is empty, a random one is generated; a
+// caller-supplied key (the "key" provision parameter) is used verbatim
+// otherwise, so the same secret backs the instance regardless of whether
+// authEnabled is later flipped off and back on.
+func createAdminSecret(client kubernetes.Interface, namespace, instanceID, key string, labels, annotations map[string]string) (string, error) {
+	if key == "" {
+		generated, err := generateAdminKey()
+		if err != nil {
+			return "", err
+		}
+		key = generated
+	}
+
+	name := adminSecretName(instanceID)
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Data: map[string][]byte{"key": []byte(key)},
+	}
+	if _, err := client.Core().Secrets(namespace).Create(secret); err != nil {
+		return "", fmt.Errorf("failed to create heketi admin secret: %v", err)
+	}
+	return name, nil
+}
+
+// adminKeyEnv returns the container env var that wires the admin secret's
+// key into the Heketi process.
+func adminKeyEnv(secretName string) v1.EnvVar {
+	return v1.EnvVar{
+		Name: adminKeyEnvVar,
+		ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: secretName},
+				Key: "key",
+			},
+		},
+	}
+}
+
+// bindingKeyDataKey names the entry in the instance's admin secret that
+// holds a given binding's key, so it can be revoked independently of other
+// bindings on unbind.
+func bindingKeyDataKey(bindingID string) string {
+	return fmt.Sprintf("binding-%s", bindingID)
+}
+
+// issueBindingKey returns the key issued to bindingID, generating and
+// storing a fresh one in the instance's secret on first call. Later calls
+// for the same bindingID return the key already on record, so a repeated
+// bind request is idempotent instead of invalidating the key a caller may
+// already be using.
+func issueBindingKey(client kubernetes.Interface, namespace, secretName, bindingID string) (string, error) {
+	secret, err := client.Core().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up heketi admin secret: %v", err)
+	}
+
+	dataKey := bindingKeyDataKey(bindingID)
+	if existing, ok := secret.Data[dataKey]; ok {
+		return string(existing), nil
+	}
+
+	key, err := generateAdminKey()
+	if err != nil {
+		return "", err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[dataKey] = []byte(key)
+
+	if _, err := client.Core().Secrets(namespace).Update(secret); err != nil {
+		return "", fmt.Errorf("failed to store heketi binding key: %v", err)
+	}
+	return key, nil
+}
+
+// revokeBindingKey removes bindingID's key from the instance's secret named
+// secretName in namespace ns, if present. Revoking an unknown or
+// already-revoked binding is not an error.
+func revokeBindingKey(client kubernetes.Interface, ns, secretName, bindingID string) error {
+	secret, err := client.Core().Secrets(ns).Get(secretName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up heketi admin secret: %v", err)
+	}
+
+	dataKey := bindingKeyDataKey(bindingID)
+	if _, ok := secret.Data[dataKey]; !ok {
+		return nil
+	}
+	delete(secret.Data, dataKey)
+
+	if _, err := client.Core().Secrets(ns).Update(secret); err != nil {
+		return fmt.Errorf("failed to revoke heketi binding key: %v", err)
+	}
+	return nil
+}
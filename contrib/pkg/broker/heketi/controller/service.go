@@ -0,0 +1,68 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// createHeketiService creates the Service fronting an instance's pod, so
+// binding and status can address the instance by a stable DNS name rather
+// than a pod IP that changes across restarts. It is a NodePort Service when
+// expose is exposeNodePort, and a ClusterIP Service otherwise; an
+// exposeIngress instance is still fronted by a ClusterIP Service, with an
+// Ingress created separately to route to it.
+func createHeketiService(client kubernetes.Interface, namespace, instanceID, expose string, labels, annotations map[string]string) (string, error) {
+	name := names.InstanceResourceName(brokerName, instanceID, "")
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: v1.ServiceSpec{
+			Selector: map[string]string{kube.InstanceLabelKey(): instanceID},
+			Ports: []v1.ServicePort{
+				{
+					Name:       "rest",
+					Port:       heketiPort,
+					TargetPort: intstr.FromString(heketiPortName),
+				},
+			},
+		},
+	}
+	if expose == exposeNodePort {
+		svc.Spec.Type = v1.ServiceTypeNodePort
+	}
+	if _, err := client.Core().Services(namespace).Create(svc); err != nil {
+		return "", fmt.Errorf("failed to create heketi service: %v", err)
+	}
+	return name, nil
+}
+
+func heketiServiceURL(namespace, serviceName string) string {
+	return fmt.Sprintf("http://%s.%s.svc:%d", serviceName, namespace, heketiPort)
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// heketiAPITimeout bounds every request made to a Heketi instance's REST
+// API while assembling a status summary.
+const heketiAPITimeout = 5 * time.Second
+
+// heketiClusterSummary is the operator-facing summary of what a Heketi
+// instance manages.
+type heketiClusterSummary struct {
+	ClusterCount int
+	NodeCount    int
+	TotalBytes   uint64
+	FreeBytes    uint64
+}
+
+// heketiStatusReader retrieves a cluster summary from a running Heketi
+// instance's REST API. It is an interface so GetServiceInstanceLastOperation
+// can be tested without a live Heketi server.
+type heketiStatusReader interface {
+	ClusterSummary(baseURL, adminKey string) (*heketiClusterSummary, error)
+
+	// VolumeNames lists the names of every Gluster volume the Heketi
+	// instance manages.
+	VolumeNames(baseURL, adminKey string) ([]string, error)
+}
+
+// httpHeketiStatusReader is the production heketiStatusReader.
+type httpHeketiStatusReader struct {
+	client *http.Client
+}
+
+func newHTTPHeketiStatusReader() *httpHeketiStatusReader {
+	return &httpHeketiStatusReader{client: &http.Client{Timeout: heketiAPITimeout}}
+}
+
+func (r *httpHeketiStatusReader) get(baseURL, adminKey, path string, out interface{}) error {
+	httpReq, err := http.NewRequest(http.MethodGet, baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if adminKey != "" {
+		httpReq.SetBasicAuth("admin", adminKey)
+	}
+
+	resp, err := r.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("heketi returned status %d for %s", resp.StatusCode, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ClusterSummary walks Heketi's /clusters, /clusters/{id} and /nodes/{id}
+// endpoints to add up cluster count, node count, and storage capacity.
+func (r *httpHeketiStatusReader) ClusterSummary(baseURL, adminKey string) (*heketiClusterSummary, error) {
+	var clusters struct {
+		Clusters []string `json:"clusters"`
+	}
+	if err := r.get(baseURL, adminKey, "/clusters", &clusters); err != nil {
+		return nil, err
+	}
+
+	summary := &heketiClusterSummary{ClusterCount: len(clusters.Clusters)}
+	for _, clusterID := range clusters.Clusters {
+		var cluster struct {
+			Nodes []string `json:"nodes"`
+		}
+		if err := r.get(baseURL, adminKey, "/clusters/"+clusterID, &cluster); err != nil {
+			return nil, err
+		}
+		summary.NodeCount += len(cluster.Nodes)
+
+		for _, nodeID := range cluster.Nodes {
+			var node struct {
+				Storage struct {
+					Total uint64 `json:"total"`
+					Free  uint64 `json:"free"`
+				} `json:"storage"`
+			}
+			if err := r.get(baseURL, adminKey, "/nodes/"+nodeID, &node); err != nil {
+				return nil, err
+			}
+			// Heketi reports storage in KiB.
+			summary.TotalBytes += node.Storage.Total * 1024
+			summary.FreeBytes += node.Storage.Free * 1024
+		}
+	}
+	return summary, nil
+}
+
+// VolumeNames walks Heketi's /volumes endpoint to list the names of every
+// volume the instance manages, so a deprovision request can refuse to
+// strand them.
+func (r *httpHeketiStatusReader) VolumeNames(baseURL, adminKey string) ([]string, error) {
+	var volumes struct {
+		Volumes []string `json:"volumes"`
+	}
+	if err := r.get(baseURL, adminKey, "/volumes", &volumes); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(volumes.Volumes))
+	for _, volumeID := range volumes.Volumes {
+		var volume struct {
+			Name string `json:"name"`
+		}
+		if err := r.get(baseURL, adminKey, "/volumes/"+volumeID, &volume); err != nil {
+			return nil, err
+		}
+		names = append(names, volume.Name)
+	}
+	return names, nil
+}
+
+// humanizeBytes renders a byte count in GiB, to one decimal place, for operator-facing
+// status messages.
+func humanizeBytes(n uint64) string {
+	return fmt.Sprintf("%.1fGiB", float64(n)/(1<<30))
+}
+
+// describeInstance builds the LastOperationResponse reported for a Heketi
+// instance from its recorded Phase. Terminal phases (phaseReady,
+// phaseFailed) answer directly from the instance record, so polling a
+// finished instance is cheap. An instance still in progress gets a live
+// refresh of its pod status, since that's the one signal cheap enough to
+// check on every poll and useful enough to be worth it.
+func (c *heketiController) describeInstance(client kubernetes.Interface, instance *heketiServiceInstance) *brokerapi.LastOperationResponse {
+	c.rwMutex.RLock()
+	phase, description, failureDetail, adminKeyMismatch := instance.Phase, instance.Description, instance.FailureDetail, instance.AdminKeyMismatch
+	c.rwMutex.RUnlock()
+
+	switch phase {
+	case phaseReady:
+		if adminKeyMismatch != "" {
+			description = fmt.Sprintf("%s; %s", description, adminKeyMismatch)
+		}
+		return &brokerapi.LastOperationResponse{State: brokerapi.StateSucceeded, Description: description}
+	case phaseFailed:
+		return &brokerapi.LastOperationResponse{
+			State:       brokerapi.StateFailed,
+			Description: fmt.Sprintf("failed to provision heketi instance: %s", failureDetail),
+		}
+	default:
+		return &brokerapi.LastOperationResponse{State: brokerapi.StateInProgress, Description: c.liveInProgressDescription(client, instance, phase)}
+	}
+}
+
+// liveInProgressDescription refines an in-progress phase with the current
+// pod phase, when a pod already exists to check.
+func (c *heketiController) liveInProgressDescription(client kubernetes.Interface, instance *heketiServiceInstance, phase string) string {
+	if phase == "" {
+		phase = phaseCreatingResources
+	}
+	if phase != phaseWaitingForPod {
+		return phase
+	}
+
+	podName, err := currentPodName(client, instance.Namespace, instance.ServiceName)
+	if err != nil {
+		return phase
+	}
+	pod, err := client.Core().Pods(instance.Namespace).Get(podName, metav1.GetOptions{})
+	if err != nil {
+		return phase
+	}
+	description := fmt.Sprintf("%s (pod phase: %s)", phase, pod.Status.Phase)
+	if ip, port, err := getHeketiPodIP(client, c.podLister, instance.Namespace, instance.ID); err == nil {
+		description += fmt.Sprintf(", address: %s:%d", ip, port)
+	}
+	return description
+}
+
+// buildReadyDescription computes the operator-facing status text cached on
+// an instance once it reaches phaseReady. It degrades gracefully rather than
+// failing: a Heketi API that can't be reached is noted rather than treated
+// as a provisioning failure, since the pod itself is already known to be
+// running by this point.
+func (c *heketiController) buildReadyDescription(client kubernetes.Interface, instance *heketiServiceInstance) string {
+	description := "heketi pod is running"
+	if instance.PVCName != "" {
+		description += fmt.Sprintf("; database backed by PersistentVolumeClaim %s", instance.PVCName)
+	}
+	if externalURL, err := externalHeketiURL(client, instance); err == nil && externalURL != "" {
+		description += fmt.Sprintf("; reachable externally at %s", externalURL)
+	}
+
+	if instance.SecretName != "" {
+		if adminKey, err := readAdminKey(client, instance.Namespace, instance.SecretName); err == nil {
+			baseURL := heketiServiceURL(instance.Namespace, instance.ServiceName)
+			if summary, err := c.statusReader.ClusterSummary(baseURL, adminKey); err == nil {
+				description += fmt.Sprintf("; managing %d cluster(s) and %d node(s), %s free of %s",
+					summary.ClusterCount, summary.NodeCount, humanizeBytes(summary.FreeBytes), humanizeBytes(summary.TotalBytes))
+			} else {
+				description += fmt.Sprintf("; cluster status unavailable: %v", err)
+			}
+		}
+	}
+
+	return description
+}
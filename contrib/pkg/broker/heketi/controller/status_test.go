@@ -0,0 +1,183 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+type fakeStatusReader struct {
+	summary *heketiClusterSummary
+	err     error
+
+	volumes    []string
+	volumesErr error
+}
+
+func (f *fakeStatusReader) ClusterSummary(baseURL, adminKey string) (*heketiClusterSummary, error) {
+	return f.summary, f.err
+}
+
+func (f *fakeStatusReader) VolumeNames(baseURL, adminKey string) ([]string, error) {
+	return f.volumes, f.volumesErr
+}
+
+func endpointsFor(namespace, serviceName, podName string) *v1.Endpoints {
+	return &v1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: serviceName, Namespace: namespace},
+		Subsets: []v1.EndpointSubset{
+			{
+				Addresses: []v1.EndpointAddress{
+					{TargetRef: &v1.ObjectReference{Kind: "Pod", Name: podName}},
+				},
+			},
+		},
+	}
+}
+
+func TestDescribeInstanceWaitsForPod(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	c := &heketiController{statusReader: &fakeStatusReader{}}
+	instance := &heketiServiceInstance{ID: "test", Namespace: "default", ServiceName: "heketi-test", Phase: phaseWaitingForPod}
+
+	resp := c.describeInstance(client, instance)
+	if resp.State != brokerapi.StateInProgress {
+		t.Fatalf("expected in-progress state before the pod exists, got %s", resp.State)
+	}
+	if resp.Description != phaseWaitingForPod {
+		t.Errorf("expected description %q, got %q", phaseWaitingForPod, resp.Description)
+	}
+}
+
+func TestDescribeInstanceRefreshesPodPhaseWhileWaiting(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		endpointsFor("default", "heketi-test", "heketi-test-abcde"),
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-abcde", Namespace: "default"},
+			Status:     v1.PodStatus{Phase: v1.PodPending},
+		},
+	)
+	c := &heketiController{statusReader: &fakeStatusReader{}}
+	instance := &heketiServiceInstance{ID: "test", Namespace: "default", ServiceName: "heketi-test", Phase: phaseWaitingForPod}
+
+	resp := c.describeInstance(client, instance)
+	if resp.State != brokerapi.StateInProgress {
+		t.Fatalf("expected in-progress state, got %s", resp.State)
+	}
+	if !strings.Contains(resp.Description, "pod phase: Pending") {
+		t.Errorf("expected description to include the live pod phase, got %q", resp.Description)
+	}
+}
+
+func TestDescribeInstanceReadyIsAnsweredFromTheRecord(t *testing.T) {
+	c := &heketiController{statusReader: &fakeStatusReader{err: fmt.Errorf("should not be called")}}
+	instance := &heketiServiceInstance{ID: "test", Namespace: "default", Phase: phaseReady, Description: "heketi pod is running"}
+
+	resp := c.describeInstance(fake.NewSimpleClientset(), instance)
+	if resp.State != brokerapi.StateSucceeded {
+		t.Fatalf("expected succeeded state, got %s", resp.State)
+	}
+	if resp.Description != instance.Description {
+		t.Errorf("expected cached description %q, got %q", instance.Description, resp.Description)
+	}
+}
+
+func TestDescribeInstanceReadyAppendsAdminKeyMismatch(t *testing.T) {
+	c := &heketiController{statusReader: &fakeStatusReader{err: fmt.Errorf("should not be called")}}
+	instance := &heketiServiceInstance{
+		ID:               "test",
+		Namespace:        "default",
+		Phase:            phaseReady,
+		Description:      "heketi pod is running",
+		AdminKeyMismatch: "heketi instance test: secret admin key does not match the key its pod was started with; rotate the instance's admin key before binding",
+	}
+
+	resp := c.describeInstance(fake.NewSimpleClientset(), instance)
+	if resp.State != brokerapi.StateSucceeded {
+		t.Fatalf("expected succeeded state, got %s", resp.State)
+	}
+	if !strings.Contains(resp.Description, instance.AdminKeyMismatch) {
+		t.Errorf("expected description to include the admin key mismatch, got %q", resp.Description)
+	}
+}
+
+func TestDescribeInstanceFailedIsAnsweredFromTheRecord(t *testing.T) {
+	c := &heketiController{statusReader: &fakeStatusReader{err: fmt.Errorf("should not be called")}}
+	instance := &heketiServiceInstance{ID: "test", Namespace: "default", Phase: phaseFailed, FailureDetail: "heketi instance at http://heketi-test never answered /hello"}
+
+	resp := c.describeInstance(fake.NewSimpleClientset(), instance)
+	if resp.State != brokerapi.StateFailed {
+		t.Fatalf("expected failed state, got %s", resp.State)
+	}
+	if !strings.Contains(resp.Description, instance.FailureDetail) {
+		t.Errorf("expected description to include the failure detail, got %q", resp.Description)
+	}
+}
+
+func TestBuildReadyDescriptionReportsClusterSummary(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-admin", Namespace: "default"},
+			Data:       map[string][]byte{"key": []byte("s3cr3t")},
+		},
+	)
+	c := &heketiController{statusReader: &fakeStatusReader{
+		summary: &heketiClusterSummary{ClusterCount: 1, NodeCount: 3, TotalBytes: 10 << 30, FreeBytes: 4 << 30},
+	}}
+	instance := &heketiServiceInstance{
+		ID:          "test",
+		Namespace:   "default",
+		SecretName:  "heketi-test-admin",
+		ServiceName: "heketi-test",
+	}
+
+	description := c.buildReadyDescription(client, instance)
+	for _, want := range []string{"1 cluster(s)", "3 node(s)", "4.0GiB free of 10.0GiB"} {
+		if !strings.Contains(description, want) {
+			t.Errorf("description %q missing %q", description, want)
+		}
+	}
+}
+
+func TestBuildReadyDescriptionDegradesWhenAPIUnreachable(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "heketi-test-admin", Namespace: "default"},
+			Data:       map[string][]byte{"key": []byte("s3cr3t")},
+		},
+	)
+	c := &heketiController{statusReader: &fakeStatusReader{err: fmt.Errorf("connection refused")}}
+	instance := &heketiServiceInstance{
+		ID:          "test",
+		Namespace:   "default",
+		SecretName:  "heketi-test-admin",
+		ServiceName: "heketi-test",
+	}
+
+	description := c.buildReadyDescription(client, instance)
+	if !strings.Contains(description, "cluster status unavailable") {
+		t.Errorf("expected description to note unreachable cluster status, got %q", description)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// createDBVolumeClaim creates the PVC backing a persistent-plan instance's
+// BoltDB directory. Size and storage class are taken from the "size" and
+// "storageClass" provision parameters, falling back to defaultDBSize and the
+// cluster default storage class respectively.
+func createDBVolumeClaim(client kubernetes.Interface, namespace, name string, req *brokerapi.CreateServiceInstanceRequest, labels, annotations map[string]string) error {
+	sizeStr := defaultDBSize
+	if v, ok := req.Parameters["size"].(string); ok && v != "" {
+		sizeStr = v
+	}
+	size, err := resource.ParseQuantity(sizeStr)
+	if err != nil {
+		return fmt.Errorf("invalid size parameter %q: %v", sizeStr, err)
+	}
+
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: size},
+			},
+		},
+	}
+	if v, ok := req.Parameters["storageClass"].(string); ok && v != "" {
+		pvc.Spec.StorageClassName = &v
+	}
+
+	if _, err := client.Core().PersistentVolumeClaims(namespace).Create(pvc); err != nil {
+		return fmt.Errorf("failed to create heketi database volume claim: %v", err)
+	}
+	return nil
+}
+
+// attachDBVolume mounts the named PVC into the instance pod template's
+// heketi container at dbMountPath.
+func attachDBVolume(spec *v1.PodSpec, pvcName string) {
+	spec.Volumes = append(spec.Volumes, v1.Volume{
+		Name: dbVolumeName,
+		VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName},
+		},
+	})
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, v1.VolumeMount{
+			Name:      dbVolumeName,
+			MountPath: dbMountPath,
+		})
+	}
+}
+
+// retainData reports whether the "retainData" provision parameter was set,
+// which keeps a persistent-plan instance's PVC around after deprovision.
+func retainData(req *brokerapi.CreateServiceInstanceRequest) bool {
+	v, ok := req.Parameters["retainData"].(bool)
+	return ok && v
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestEphemeralPlanHasNoVolumeClaim(t *testing.T) {
+	spec := &v1.PodSpec{
+		Containers: []v1.Container{{Name: "heketi"}},
+	}
+	if len(spec.Volumes) != 0 {
+		t.Fatalf("expected no volumes for the ephemeral plan, got %v", spec.Volumes)
+	}
+}
+
+func TestPersistentPlanAttachesVolumeClaim(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: planPersistent}
+
+	labels := map[string]string{"instanceID": "test"}
+	if err := createDBVolumeClaim(client, "default", "heketi-test-db", req, labels, nil); err != nil {
+		t.Fatalf("createDBVolumeClaim: %v", err)
+	}
+
+	spec := &v1.PodSpec{
+		Containers: []v1.Container{{Name: "heketi"}},
+	}
+	attachDBVolume(spec, "heketi-test-db")
+
+	if len(spec.Volumes) != 1 || spec.Volumes[0].PersistentVolumeClaim.ClaimName != "heketi-test-db" {
+		t.Fatalf("expected pod to reference the db PVC, got %v", spec.Volumes)
+	}
+	mounts := spec.Containers[0].VolumeMounts
+	if len(mounts) != 1 || mounts[0].MountPath != dbMountPath {
+		t.Fatalf("expected db volume mounted at %s, got %v", dbMountPath, mounts)
+	}
+}
+
+func TestRetainData(t *testing.T) {
+	if retainData(&brokerapi.CreateServiceInstanceRequest{}) {
+		t.Fatal("expected retainData to default to false")
+	}
+	req := &brokerapi.CreateServiceInstanceRequest{
+		Parameters: map[string]interface{}{"retainData": true},
+	}
+	if !retainData(req) {
+		t.Fatal("expected retainData to honor the retainData parameter")
+	}
+}
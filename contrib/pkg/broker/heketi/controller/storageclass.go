@@ -0,0 +1,71 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	storagev1 "k8s.io/client-go/pkg/apis/storage/v1"
+)
+
+// wantsStorageClass reports whether the "createStorageClass" provision
+// parameter was set, requesting a StorageClass for dynamic GlusterFS
+// provisioning against this instance once it is ready.
+func wantsStorageClass(req *brokerapi.CreateServiceInstanceRequest) bool {
+	v, ok := req.Parameters["createStorageClass"].(bool)
+	return ok && v
+}
+
+// storageClassName returns the name of instanceID's StorageClass.
+func storageClassName(instanceID string) string {
+	return fmt.Sprintf("glusterfs-%s", instanceID)
+}
+
+// createHeketiStorageClass creates a StorageClass that dynamically
+// provisions GlusterFS volumes through instanceID's Heketi REST API at
+// restURL, authenticating with the admin key held in secretName.
+// StorageClasses are cluster-scoped, so unlike the rest of an instance's
+// resources this one carries no namespace of its own; secretNamespace
+// points back at the namespace holding the admin secret. Creating it
+// requires cluster-wide RBAC access to storageclasses.storage.k8s.io,
+// which the broker's ClusterRole must grant.
+func createHeketiStorageClass(client kubernetes.Interface, instanceID, secretNamespace, secretName, restURL string, labels, annotations map[string]string) (string, error) {
+	name := storageClassName(instanceID)
+	sc := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Provisioner: "kubernetes.io/glusterfs",
+		Parameters: map[string]string{
+			"resturl":         restURL,
+			"restauthenabled": "true",
+			"restuser":        "admin",
+			"secretNamespace": secretNamespace,
+			"secretName":      secretName,
+		},
+	}
+	if _, err := client.Storage().StorageClasses().Create(sc); err != nil {
+		return "", fmt.Errorf("failed to create heketi storage class (requires cluster-wide RBAC access to storageclasses.storage.k8s.io): %v", err)
+	}
+	return name, nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/pkg"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWantsStorageClassReadsProvisionParameter(t *testing.T) {
+	if wantsStorageClass(&brokerapi.CreateServiceInstanceRequest{}) {
+		t.Error("expected false with no parameters")
+	}
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"createStorageClass": true}}
+	if !wantsStorageClass(req) {
+		t.Error("expected true when createStorageClass is set")
+	}
+}
+
+func TestCreateHeketiStorageClassPointsAtInstance(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	labels := kube.CommonLabels(brokerName, pkg.VERSION, serviceID, "plan-1", "test", "default")
+	name, err := createHeketiStorageClass(client, "test", "default", "heketi-test-admin", "http://heketi-test.default.svc:8080", labels, nil)
+	if err != nil {
+		t.Fatalf("createHeketiStorageClass: %v", err)
+	}
+	if name != "glusterfs-test" {
+		t.Errorf("expected name glusterfs-test, got %q", name)
+	}
+
+	sc, err := client.Storage().StorageClasses().Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if sc.Provisioner != "kubernetes.io/glusterfs" {
+		t.Errorf("provisioner = %q, want kubernetes.io/glusterfs", sc.Provisioner)
+	}
+	if sc.Parameters["resturl"] != "http://heketi-test.default.svc:8080" {
+		t.Errorf("resturl = %q", sc.Parameters["resturl"])
+	}
+	if sc.Parameters["secretNamespace"] != "default" || sc.Parameters["secretName"] != "heketi-test-admin" {
+		t.Errorf("unexpected secret reference: %+v", sc.Parameters)
+	}
+	if !reflect.DeepEqual(sc.Labels, labels) {
+		t.Errorf("labels = %+v, want %+v", sc.Labels, labels)
+	}
+}
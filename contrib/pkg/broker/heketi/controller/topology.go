@@ -0,0 +1,150 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// readAdminKey fetches the plaintext admin key back out of the secret
+// created by createAdminSecret.
+func readAdminKey(client kubernetes.Interface, namespace, secretName string) (string, error) {
+	secret, err := client.Core().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up heketi admin secret: %v", err)
+	}
+	return string(secret.Data["key"]), nil
+}
+
+// topologyNode describes one node's storage devices in the topology
+// provision parameter.
+type topologyNode struct {
+	Hostnames struct {
+		Manage  []string `json:"manage"`
+		Storage []string `json:"storage"`
+	} `json:"hostnames"`
+	Zone    int      `json:"zone"`
+	Devices []string `json:"devices"`
+}
+
+// topologyCluster is one cluster's worth of nodes in the topology
+// provision parameter.
+type topologyCluster struct {
+	Nodes []topologyNode `json:"nodes"`
+}
+
+// topology is the shape of the `topology` provision parameter, matching
+// heketi's own topology.json format.
+type topology struct {
+	Clusters []topologyCluster `json:"clusters"`
+}
+
+// parseTopology extracts and validates a topology provision parameter, if
+// present.
+func parseTopology(req *brokerapi.CreateServiceInstanceRequest) (*topology, error) {
+	v, ok := req.Parameters["topology"]
+	if !ok {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid topology parameter: %v", err)
+	}
+	var t topology
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return nil, fmt.Errorf("invalid topology parameter: %v", err)
+	}
+	return &t, nil
+}
+
+// loadTopology registers each cluster, node, and device in t with a
+// running Heketi instance over its REST API. It is called once the
+// instance is confirmed ready, since Heketi has no way to accept its
+// topology before it is serving.
+func loadTopology(baseURL, adminKey string, t *topology) error {
+	for _, cluster := range t.Clusters {
+		clusterID, err := heketiPost(baseURL, adminKey, "/clusters", map[string]interface{}{})
+		if err != nil {
+			return fmt.Errorf("failed to create heketi cluster: %v", err)
+		}
+		for _, node := range cluster.Nodes {
+			nodeID, err := heketiPost(baseURL, adminKey, "/nodes", map[string]interface{}{
+				"cluster": clusterID,
+				"hostnames": map[string]interface{}{
+					"manage":  node.Hostnames.Manage,
+					"storage": node.Hostnames.Storage,
+				},
+				"zone": node.Zone,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create heketi node %v: %v", node.Hostnames.Manage, err)
+			}
+			for _, device := range node.Devices {
+				if _, err := heketiPost(baseURL, adminKey, "/devices", map[string]interface{}{
+					"node": nodeID,
+					"name": device,
+				}); err != nil {
+					return fmt.Errorf("failed to add heketi device %s: %v", device, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// heketiPost issues an authenticated POST against a Heketi REST endpoint
+// and returns the "id" field of the JSON response.
+func heketiPost(baseURL, adminKey, path string, body map[string]interface{}) (string, error) {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, baseURL+path, bytes.NewReader(buf))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if adminKey != "" {
+		httpReq.SetBasicAuth("admin", adminKey)
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("heketi returned status %d for %s", resp.StatusCode, path)
+	}
+
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	return out.ID, nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import "net/http"
+
+// ErrorKind categorizes an error the way an OSB client needs it categorized:
+// by the HTTP status it should see, not by which package or type produced
+// it. It lets the server layer answer "what status code" with a single
+// switch instead of re-deriving an answer ClassifyError already knows.
+type ErrorKind string
+
+const (
+	// KindNotFound means the named instance, binding, or namespace does not
+	// exist.
+	KindNotFound ErrorKind = "NotFound"
+	// KindGone means the thing the caller asked about used to exist but has
+	// already been removed, so retrying the request that produced this
+	// error cannot succeed.
+	KindGone ErrorKind = "Gone"
+	// KindConflict means the request raced another change to the same
+	// instance or binding.
+	KindConflict ErrorKind = "Conflict"
+	// KindQuotaExceeded means the request was rejected because it would
+	// exceed a resource quota.
+	KindQuotaExceeded ErrorKind = "QuotaExceeded"
+	// KindAsyncRequired means the operation cannot complete synchronously
+	// and the caller must retry with accepts_incomplete=true.
+	KindAsyncRequired ErrorKind = "AsyncRequired"
+	// KindNotReady means the instance or binding exists but is not yet in a
+	// state that can serve the request; retrying later may succeed.
+	KindNotReady ErrorKind = "NotReady"
+	// KindValidationFailed means the request itself was malformed or names
+	// a target the broker will never accept, such as a disallowed
+	// namespace.
+	KindValidationFailed ErrorKind = "ValidationFailed"
+	// KindForbidden means the broker's own service account isn't permitted
+	// to perform the Kubernetes API calls the request requires.
+	KindForbidden ErrorKind = "Forbidden"
+	// KindInternal means none of the above apply; the failure is the
+	// broker's own, not something the caller can fix by changing the
+	// request.
+	KindInternal ErrorKind = "Internal"
+)
+
+// Error is a typed error tagged with the ErrorKind that best describes it,
+// so a caller such as the server layer can decide how to respond to it
+// without needing to know which package produced it. Err, when set, is the
+// underlying error this one wraps; Unwrap and Is make Error compatible with
+// errors.Is and errors.As.
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the error e wraps, if any, so errors.Is and errors.As can
+// see through it to a cause.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *Error of the same Kind, so callers can
+// write errors.Is(err, kube.NewNotFoundError("")) instead of a type switch
+// on Kind(err).
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind
+}
+
+// NewNotFoundError returns a KindNotFound Error for message.
+func NewNotFoundError(message string) error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+// NewGoneError returns a KindGone Error for message.
+func NewGoneError(message string) error {
+	return &Error{Kind: KindGone, Message: message}
+}
+
+// NewConflictError returns a KindConflict Error for message.
+func NewConflictError(message string) error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+// NewQuotaExceededError returns a KindQuotaExceeded Error wrapping err.
+func NewQuotaExceededError(message string, err error) error {
+	return &Error{Kind: KindQuotaExceeded, Message: message, Err: err}
+}
+
+// NewAsyncRequiredError returns a KindAsyncRequired Error for message.
+func NewAsyncRequiredError(message string) error {
+	return &Error{Kind: KindAsyncRequired, Message: message}
+}
+
+// NewNotReadyError returns a KindNotReady Error for message.
+func NewNotReadyError(message string) error {
+	return &Error{Kind: KindNotReady, Message: message}
+}
+
+// NewValidationFailedError returns a KindValidationFailed Error for message.
+func NewValidationFailedError(message string) error {
+	return &Error{Kind: KindValidationFailed, Message: message}
+}
+
+// NewForbiddenError returns a KindForbidden Error wrapping err.
+func NewForbiddenError(message string, err error) error {
+	return &Error{Kind: KindForbidden, Message: message, Err: err}
+}
+
+// NewInternalError returns a KindInternal Error wrapping err.
+func NewInternalError(message string, err error) error {
+	return &Error{Kind: KindInternal, Message: message, Err: err}
+}
+
+// Kind returns the ErrorKind that best describes err. It recognizes this
+// package's own *Error and, for the older typed errors defined before it,
+// maps each to the Kind it would have been constructed with had it been
+// written against this taxonomy. A nil error or one Kind doesn't recognize
+// classifies as KindInternal, matching ClassifyError's own default.
+func Kind(err error) ErrorKind {
+	if err == nil {
+		return KindInternal
+	}
+
+	if e, ok := err.(*Error); ok {
+		return e.Kind
+	}
+
+	switch e := err.(type) {
+	case ErrForbidden:
+		if ClassifyError(e) == CauseQuota {
+			return KindQuotaExceeded
+		}
+		return KindForbidden
+	case ErrNoSuchInstance, ErrNamespaceNotFound:
+		return KindNotFound
+	case ErrNamespaceTerminating, ErrNamespaceNotAllowed, ErrEmptyNamespace:
+		return KindValidationFailed
+	}
+
+	return KindInternal
+}
+
+// HTTPStatus returns the HTTP status code the server layer should respond
+// with for err, per the Open Service Broker API's status mapping for each
+// ErrorKind.
+func HTTPStatus(err error) int {
+	switch Kind(err) {
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindGone:
+		return http.StatusGone
+	case KindConflict:
+		return http.StatusConflict
+	case KindQuotaExceeded, KindAsyncRequired:
+		return http.StatusUnprocessableEntity
+	case KindNotReady:
+		return http.StatusServiceUnavailable
+	case KindValidationFailed:
+		return http.StatusBadRequest
+	case KindForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
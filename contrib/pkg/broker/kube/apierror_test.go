@@ -0,0 +1,118 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestKind(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{"nil", nil, KindInternal},
+		{"NewNotFoundError", NewNotFoundError("no such widget"), KindNotFound},
+		{"NewGoneError", NewGoneError("already removed"), KindGone},
+		{"NewConflictError", NewConflictError("racing update"), KindConflict},
+		{"NewQuotaExceededError", NewQuotaExceededError("over quota", nil), KindQuotaExceeded},
+		{"NewAsyncRequiredError", NewAsyncRequiredError("retry async"), KindAsyncRequired},
+		{"NewNotReadyError", NewNotReadyError("still provisioning"), KindNotReady},
+		{"NewValidationFailedError", NewValidationFailedError("bad namespace"), KindValidationFailed},
+		{"NewForbiddenError", NewForbiddenError("denied", nil), KindForbidden},
+		{"NewInternalError", NewInternalError("boom", nil), KindInternal},
+		{"ErrNoSuchInstance", ErrNoSuchInstance{InstanceID: "instance-1"}, KindNotFound},
+		{"ErrNamespaceNotFound", ErrNamespaceNotFound{Namespace: "team-a"}, KindNotFound},
+		{"ErrNamespaceTerminating", ErrNamespaceTerminating{Namespace: "team-a"}, KindValidationFailed},
+		{"ErrNamespaceNotAllowed", ErrNamespaceNotAllowed{Namespace: "team-a"}, KindValidationFailed},
+		{"ErrEmptyNamespace", ErrEmptyNamespace{}, KindValidationFailed},
+		{"ErrForbidden rbac denial", ErrForbidden{Verb: "create", Resource: "pods", Err: fmt.Errorf("denied")}, KindForbidden},
+		{"ErrForbidden quota rejection", ErrForbidden{Verb: "create", Resource: "pods", Err: fmt.Errorf("exceeded quota: compute-resources")}, KindQuotaExceeded},
+		{"unrecognized error", fmt.Errorf("something went wrong"), KindInternal},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Kind(c.err); got != c.want {
+				t.Errorf("Kind(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	cases := []struct {
+		kind ErrorKind
+		want int
+	}{
+		{KindNotFound, http.StatusNotFound},
+		{KindGone, http.StatusGone},
+		{KindConflict, http.StatusConflict},
+		{KindQuotaExceeded, http.StatusUnprocessableEntity},
+		{KindAsyncRequired, http.StatusUnprocessableEntity},
+		{KindNotReady, http.StatusServiceUnavailable},
+		{KindValidationFailed, http.StatusBadRequest},
+		{KindForbidden, http.StatusForbidden},
+		{KindInternal, http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		t.Run(string(c.kind), func(t *testing.T) {
+			err := &Error{Kind: c.kind, Message: "test"}
+			if got := HTTPStatus(err); got != c.want {
+				t.Errorf("HTTPStatus(%v) = %d, want %d", err, got, c.want)
+			}
+		})
+	}
+
+	if got := HTTPStatus(nil); got != http.StatusInternalServerError {
+		t.Errorf("HTTPStatus(nil) = %d, want %d", got, http.StatusInternalServerError)
+	}
+	if got := HTTPStatus(ErrNoSuchInstance{InstanceID: "instance-1"}); got != http.StatusNotFound {
+		t.Errorf("HTTPStatus(ErrNoSuchInstance) = %d, want %d", got, http.StatusNotFound)
+	}
+}
+
+func TestErrorMessageIncludesWrappedError(t *testing.T) {
+	cause := fmt.Errorf("apiserver said no")
+	err := NewForbiddenError("cannot create pod", cause)
+	if want := "cannot create pod: apiserver said no"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	bare := NewNotFoundError("no such instance")
+	if want := "no such instance"; bare.Error() != want {
+		t.Errorf("Error() = %q, want %q", bare.Error(), want)
+	}
+}
+
+func TestErrorUnwrapAndIs(t *testing.T) {
+	cause := fmt.Errorf("apiserver said no")
+	err := NewForbiddenError("cannot create pod", cause)
+
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is(err, cause) = false, want true")
+	}
+	if !errors.Is(err, NewForbiddenError("different message", nil)) {
+		t.Error("errors.Is against another KindForbidden Error = false, want true")
+	}
+	if errors.Is(err, NewNotFoundError("")) {
+		t.Error("errors.Is against a different Kind = true, want false")
+	}
+}
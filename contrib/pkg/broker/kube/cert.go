@@ -0,0 +1,86 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// SelfSignedCertValidity is how long a certificate generated by
+// NewSelfSignedCert remains valid. No broker that calls it implements
+// renewal yet, so this errs on the long side.
+const SelfSignedCertValidity = 10 * 365 * 24 * time.Hour
+
+// SelfSignedCert is a self-signed certificate and its private key,
+// PEM-encoded for storing in a Secret, plus the parsed certificate for
+// callers that need one of its fields, such as NotAfter.
+type SelfSignedCert struct {
+	CertPEM []byte
+	KeyPEM  []byte
+	Cert    *x509.Certificate
+}
+
+// NewSelfSignedCert generates a self-signed certificate valid for
+// commonName and dnsNames, for brokers whose plan needs to serve TLS
+// without an external CA. Because the certificate is self-signed, it is
+// its own CA: a caller that needs to hand clients something to trust
+// returns CertPEM again for that purpose.
+func NewSelfSignedCert(commonName string, dnsNames []string) (*SelfSignedCert, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate a private key: %v", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate a certificate serial number: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              dnsNames,
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(SelfSignedCertValidity),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a self-signed certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the generated certificate: %v", err)
+	}
+
+	return &SelfSignedCert{
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		Cert:    cert,
+	}, nil
+}
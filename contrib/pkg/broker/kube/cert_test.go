@@ -0,0 +1,66 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func TestNewSelfSignedCertIsValidForItsDNSNames(t *testing.T) {
+	cert, err := NewSelfSignedCert("my-svc.my-ns.svc", []string{"my-svc.my-ns.svc", "my-svc.my-ns"})
+	if err != nil {
+		t.Fatalf("NewSelfSignedCert: %v", err)
+	}
+
+	if err := cert.Cert.VerifyHostname("my-svc.my-ns.svc"); err != nil {
+		t.Errorf("cert isn't valid for its own common name: %v", err)
+	}
+	if err := cert.Cert.VerifyHostname("my-svc.my-ns"); err != nil {
+		t.Errorf("cert isn't valid for a requested dnsName: %v", err)
+	}
+	if err := cert.Cert.VerifyHostname("someone-else.example.com"); err == nil {
+		t.Error("expected the cert to be invalid for a hostname it wasn't generated for")
+	}
+}
+
+func TestNewSelfSignedCertProducesDecodablePEM(t *testing.T) {
+	cert, err := NewSelfSignedCert("my-svc.my-ns.svc", []string{"my-svc.my-ns.svc"})
+	if err != nil {
+		t.Fatalf("NewSelfSignedCert: %v", err)
+	}
+
+	if block, _ := pem.Decode(cert.CertPEM); block == nil || block.Type != "CERTIFICATE" {
+		t.Error("CertPEM does not decode to a CERTIFICATE block")
+	}
+	if block, _ := pem.Decode(cert.KeyPEM); block == nil || block.Type != "RSA PRIVATE KEY" {
+		t.Error("KeyPEM does not decode to an RSA PRIVATE KEY block")
+	}
+}
+
+func TestNewSelfSignedCertIsCurrentlyValid(t *testing.T) {
+	cert, err := NewSelfSignedCert("my-svc.my-ns.svc", []string{"my-svc.my-ns.svc"})
+	if err != nil {
+		t.Fatalf("NewSelfSignedCert: %v", err)
+	}
+
+	now := time.Now()
+	if now.Before(cert.Cert.NotBefore) || now.After(cert.Cert.NotAfter) {
+		t.Errorf("cert is not valid at the current time: NotBefore=%v NotAfter=%v now=%v", cert.Cert.NotBefore, cert.Cert.NotAfter, now)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/waitutil"
+)
+
+// Failure causes shared by the errors_total metric, the Events a
+// controller records against a failed instance, and the audit log, so all
+// three name a failed operation's cause the same way.
+const (
+	CauseQuota     = "quota"
+	CauseForbidden = "forbidden"
+	CauseImagePull = "image-pull"
+	CauseTimeout   = "timeout"
+	CauseAdmission = "admission"
+	CauseNotFound  = "not-found"
+	CauseConflict  = "conflict"
+	CauseInternal  = "internal"
+)
+
+// ClassifyError maps err to the cause that best explains it, so a caller
+// can label a metric, an Event, or an audit record with a single word
+// instead of the full error string. It recognizes this package's own typed
+// errors, waitutil's, and the Kubernetes API server's status errors; a nil
+// error or one it doesn't recognize classifies as CauseInternal rather
+// than panicking, since an unclassifiable failure is still an internal one
+// from the caller's point of view.
+func ClassifyError(err error) string {
+	if err == nil {
+		return CauseInternal
+	}
+
+	switch e := err.(type) {
+	case *Error:
+		return classifyKind(e)
+	case ErrForbidden:
+		return classifyForbidden(e.Err)
+	case ErrNoSuchInstance, ErrNamespaceNotFound:
+		return CauseNotFound
+	case ErrNamespaceTerminating, ErrNamespaceNotAllowed, ErrEmptyNamespace:
+		return CauseAdmission
+	case waitutil.TimeoutError:
+		return CauseTimeout
+	case waitutil.PodFailedError:
+		switch e.Reason {
+		case "ErrImagePull", "ImagePullBackOff":
+			return CauseImagePull
+		}
+		return CauseInternal
+	}
+
+	switch {
+	case apierrors.IsForbidden(err):
+		return classifyForbidden(err)
+	case apierrors.IsInvalid(err):
+		return CauseAdmission
+	case apierrors.IsConflict(err), apierrors.IsAlreadyExists(err):
+		return CauseConflict
+	case apierrors.IsNotFound(err):
+		return CauseNotFound
+	case apierrors.IsServerTimeout(err), apierrors.IsTimeout(err), apierrors.IsTooManyRequests(err):
+		return CauseTimeout
+	}
+
+	return CauseInternal
+}
+
+// classifyForbidden distinguishes a resource quota rejection, which the
+// API server also reports as Forbidden, from an ordinary RBAC denial.
+func classifyForbidden(err error) string {
+	if err != nil && strings.Contains(err.Error(), "exceeded quota") {
+		return CauseQuota
+	}
+	return CauseForbidden
+}
+
+// classifyKind maps an *Error's Kind to the cause that best explains it.
+func classifyKind(e *Error) string {
+	switch e.Kind {
+	case KindNotFound, KindGone:
+		return CauseNotFound
+	case KindConflict:
+		return CauseConflict
+	case KindQuotaExceeded:
+		return CauseQuota
+	case KindForbidden:
+		return CauseForbidden
+	case KindAsyncRequired, KindNotReady, KindValidationFailed:
+		return CauseAdmission
+	default:
+		return CauseInternal
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/waitutil"
+)
+
+func TestClassifyError(t *testing.T) {
+	gr := schema.GroupResource{Resource: "pods"}
+	cases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, CauseInternal},
+		{"forbidden rbac denial", errors.NewForbidden(gr, "test", fmt.Errorf("denied")), CauseForbidden},
+		{"forbidden quota exceeded", errors.NewForbidden(gr, "test", fmt.Errorf("exceeded quota: compute-resources, requested: pods=1, used: pods=4, limited: pods=4")), CauseQuota},
+		{"ErrForbidden wrapping a plain rbac denial", ErrForbidden{Verb: "create", Resource: "pods", Err: errors.NewForbidden(gr, "test", fmt.Errorf("denied"))}, CauseForbidden},
+		{"ErrForbidden wrapping a quota rejection", ErrForbidden{Verb: "create", Resource: "pods", Err: fmt.Errorf("exceeded quota: compute-resources")}, CauseQuota},
+		{"invalid", errors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "test", nil), CauseAdmission},
+		{"conflict", errors.NewConflict(gr, "test", fmt.Errorf("boom")), CauseConflict},
+		{"already exists", errors.NewAlreadyExists(gr, "test"), CauseConflict},
+		{"not found", errors.NewNotFound(gr, "test"), CauseNotFound},
+		{"ErrNoSuchInstance", ErrNoSuchInstance{InstanceID: "instance-1"}, CauseNotFound},
+		{"ErrNamespaceNotFound", ErrNamespaceNotFound{Namespace: "team-a"}, CauseNotFound},
+		{"ErrNamespaceTerminating", ErrNamespaceTerminating{Namespace: "team-a"}, CauseAdmission},
+		{"ErrNamespaceNotAllowed", ErrNamespaceNotAllowed{Namespace: "team-a"}, CauseAdmission},
+		{"ErrEmptyNamespace", ErrEmptyNamespace{}, CauseAdmission},
+		{"server timeout", errors.NewServerTimeout(gr, "get", 1), CauseTimeout},
+		{"too many requests", errors.NewGenericServerResponse(429, "get", gr, "test", "", 1, false), CauseTimeout},
+		{"waitutil.TimeoutError", waitutil.TimeoutError{Waiting: "pod to become ready", Timeout: time.Minute}, CauseTimeout},
+		{"waitutil.PodFailedError image pull", waitutil.PodFailedError{Namespace: "team-a", Name: "mongo-0", Reason: "ErrImagePull"}, CauseImagePull},
+		{"waitutil.PodFailedError image pull backoff", waitutil.PodFailedError{Namespace: "team-a", Name: "mongo-0", Reason: "ImagePullBackOff"}, CauseImagePull},
+		{"waitutil.PodFailedError crash loop", waitutil.PodFailedError{Namespace: "team-a", Name: "mongo-0", Reason: "CrashLoopBackOff"}, CauseInternal},
+		{"plain error", fmt.Errorf("boom"), CauseInternal},
+	}
+	for _, c := range cases {
+		if got := ClassifyError(c.err); got != c.want {
+			t.Errorf("%s: ClassifyError() = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+	"os"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// inClusterConfig is a var so tests can fake NewClient running outside a
+// cluster without an actual service account mount.
+var inClusterConfig = rest.InClusterConfig
+
+// ClientOptions tunes the rest.Config NewClient builds its Clientset from.
+// A zero value keeps client-go's own defaults.
+type ClientOptions struct {
+	// Context selects a context by name from the loaded kubeconfig, instead
+	// of its current-context. Setting it skips the in-cluster configuration:
+	// NewClient loads the kubeconfig even if a service account is mounted,
+	// since a context only makes sense against one.
+	Context string
+
+	// QPS caps the sustained rate of Kubernetes API calls this client
+	// makes, and Burst the size of the token bucket allowing short spikes
+	// above that rate. Zero keeps client-go's built-in defaults, which are
+	// tuned for a small cluster and easily throttle a broker managing many
+	// instances against a busy apiserver.
+	QPS   float32
+	Burst int
+
+	// UserAgent is sent on every request this client makes, so it shows up
+	// in the apiserver's audit log identifying which broker made the call.
+	// Empty keeps client-go's generic default.
+	UserAgent string
+}
+
+// NewClient builds a Kubernetes clientset, preferring the in-cluster service
+// account configuration. Outside a cluster - for local development, or if
+// the service account token isn't mounted - it falls back to the kubeconfig
+// at kubeconfigPath, or $KUBECONFIG if kubeconfigPath is empty, instead of
+// failing outright.
+func NewClient(kubeconfigPath string, opts ClientOptions) (kubernetes.Interface, error) {
+	config, err := RestConfig(kubeconfigPath, opts.Context)
+	if err != nil {
+		return nil, err
+	}
+	applyClientOptions(config, opts)
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Kubernetes client: %v", err)
+	}
+	return client, nil
+}
+
+// applyClientOptions overlays opts onto config, leaving client-go's own
+// defaults in place for any field opts doesn't set.
+func applyClientOptions(config *rest.Config, opts ClientOptions) {
+	if opts.QPS > 0 {
+		config.QPS = opts.QPS
+	}
+	if opts.Burst > 0 {
+		config.Burst = opts.Burst
+	}
+	if opts.UserAgent != "" {
+		config.UserAgent = opts.UserAgent
+	}
+}
+
+// RestConfig builds a rest.Config the same way NewClient does: preferring
+// the in-cluster service account configuration, and falling back to the
+// kubeconfig at kubeconfigPath (or $KUBECONFIG) outside a cluster. Callers
+// that need a client for something other than kubernetes.Interface, such as
+// the internal clientset leader election requires, use this instead of
+// duplicating the fallback logic.
+//
+// context selects a context by name from the loaded kubeconfig instead of
+// its current-context; an empty context leaves the kubeconfig's own choice
+// in place. Giving a context skips the in-cluster preference, since a
+// context only makes sense when loading a kubeconfig.
+func RestConfig(kubeconfigPath, context string) (*rest.Config, error) {
+	if context == "" {
+		if config, err := inClusterConfig(); err == nil {
+			return config, nil
+		}
+	}
+	if kubeconfigPath == "" {
+		kubeconfigPath = os.Getenv("KUBECONFIG")
+	}
+	if kubeconfigPath == "" {
+		return nil, fmt.Errorf("not running in a cluster and no kubeconfig path given; set --kubeconfig or $KUBECONFIG")
+	}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfigPath},
+		&clientcmd.ConfigOverrides{CurrentContext: context},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig %q (context %q): %v", kubeconfigPath, context, err)
+	}
+	return config, nil
+}
+
+// PodNamespace returns the namespace this broker's own pod is running in:
+// override, if set (from a --broker-namespace flag), then the downward
+// API's $POD_NAMESPACE, then defaultNamespace. It's for a broker to find
+// its own namespace, e.g. to look up a pull secret it owns, as distinct
+// from the namespace a provision request targets.
+func PodNamespace(override string) string {
+	if override != "" {
+		return override
+	}
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
+	}
+	return defaultNamespace
+}
+
+// defaultNamespace is PodNamespace's last-resort fallback, for a broker
+// run without --broker-namespace or a $POD_NAMESPACE downward API entry.
+const defaultNamespace = "default"
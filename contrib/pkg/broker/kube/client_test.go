@@ -0,0 +1,232 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+const fakeKubeconfig = `
+apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://example.invalid:6443
+  name: test-cluster
+contexts:
+- context:
+    cluster: test-cluster
+    user: test-user
+  name: test-context
+current-context: test-context
+users:
+- name: test-user
+  user:
+    token: fake-token
+`
+
+// fakeMultiContextKubeconfig has two contexts pointing at different
+// clusters, neither of which is current-context, so RestConfig must honor
+// an explicit context argument rather than falling back to it.
+const fakeMultiContextKubeconfig = `
+apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://staging.invalid:6443
+  name: staging-cluster
+- cluster:
+    server: https://prod.invalid:6443
+  name: prod-cluster
+contexts:
+- context:
+    cluster: staging-cluster
+    user: staging-user
+  name: staging
+- context:
+    cluster: prod-cluster
+    user: prod-user
+  name: prod
+current-context: staging
+users:
+- name: staging-user
+  user:
+    token: fake-staging-token
+- name: prod-user
+  user:
+    token: fake-prod-token
+`
+
+func writeFakeKubeconfig(t *testing.T, contents string) string {
+	f, err := ioutil.TempFile("", "kubeconfig")
+	if err != nil {
+		t.Fatalf("failed to create temp kubeconfig: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatalf("failed to write temp kubeconfig: %v", err)
+	}
+	return f.Name()
+}
+
+// fakeNotInCluster makes inClusterConfig fail, as if running outside a
+// cluster with no service account mounted, and returns a func that restores
+// the original so callers can `defer fakeNotInCluster(t)()`.
+func fakeNotInCluster(t *testing.T) func() {
+	original := inClusterConfig
+	inClusterConfig = func() (*rest.Config, error) {
+		return nil, fmt.Errorf("not running in a cluster")
+	}
+	return func() { inClusterConfig = original }
+}
+
+func TestRestConfigPrefersInClusterConfig(t *testing.T) {
+	original := inClusterConfig
+	defer func() { inClusterConfig = original }()
+	want := &rest.Config{Host: "https://in-cluster.invalid"}
+	inClusterConfig = func() (*rest.Config, error) { return want, nil }
+
+	got, err := RestConfig("", "")
+	if err != nil {
+		t.Fatalf("restConfig: %v", err)
+	}
+	if got.Host != want.Host {
+		t.Errorf("RestConfig() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRestConfigFallsBackToKubeconfigPath(t *testing.T) {
+	defer fakeNotInCluster(t)()
+	path := writeFakeKubeconfig(t, fakeKubeconfig)
+	defer os.Remove(path)
+
+	config, err := RestConfig(path, "")
+	if err != nil {
+		t.Fatalf("restConfig: %v", err)
+	}
+	if config.Host != "https://example.invalid:6443" {
+		t.Errorf("config.Host = %q, want https://example.invalid:6443", config.Host)
+	}
+}
+
+func TestRestConfigFallsBackToKubeconfigEnvVar(t *testing.T) {
+	defer fakeNotInCluster(t)()
+	path := writeFakeKubeconfig(t, fakeKubeconfig)
+	defer os.Remove(path)
+	os.Setenv("KUBECONFIG", path)
+	defer os.Unsetenv("KUBECONFIG")
+
+	config, err := RestConfig("", "")
+	if err != nil {
+		t.Fatalf("restConfig: %v", err)
+	}
+	if config.Host != "https://example.invalid:6443" {
+		t.Errorf("config.Host = %q, want https://example.invalid:6443", config.Host)
+	}
+}
+
+func TestRestConfigErrorsWithoutInClusterOrKubeconfig(t *testing.T) {
+	defer fakeNotInCluster(t)()
+	os.Unsetenv("KUBECONFIG")
+
+	if _, err := RestConfig("", ""); err == nil {
+		t.Fatal("expected an error when not in a cluster and no kubeconfig is available")
+	}
+}
+
+func TestRestConfigSelectsNamedContext(t *testing.T) {
+	defer fakeNotInCluster(t)()
+	path := writeFakeKubeconfig(t, fakeMultiContextKubeconfig)
+	defer os.Remove(path)
+
+	config, err := RestConfig(path, "prod")
+	if err != nil {
+		t.Fatalf("restConfig: %v", err)
+	}
+	if config.Host != "https://prod.invalid:6443" {
+		t.Errorf("config.Host = %q, want https://prod.invalid:6443 (the prod context, not current-context)", config.Host)
+	}
+}
+
+func TestRestConfigDefaultsToCurrentContextWhenContextEmpty(t *testing.T) {
+	defer fakeNotInCluster(t)()
+	path := writeFakeKubeconfig(t, fakeMultiContextKubeconfig)
+	defer os.Remove(path)
+
+	config, err := RestConfig(path, "")
+	if err != nil {
+		t.Fatalf("restConfig: %v", err)
+	}
+	if config.Host != "https://staging.invalid:6443" {
+		t.Errorf("config.Host = %q, want https://staging.invalid:6443 (current-context)", config.Host)
+	}
+}
+
+func TestRestConfigWithContextSkipsInClusterConfig(t *testing.T) {
+	original := inClusterConfig
+	defer func() { inClusterConfig = original }()
+	inClusterConfig = func() (*rest.Config, error) {
+		return &rest.Config{Host: "https://in-cluster.invalid"}, nil
+	}
+	path := writeFakeKubeconfig(t, fakeMultiContextKubeconfig)
+	defer os.Remove(path)
+
+	config, err := RestConfig(path, "prod")
+	if err != nil {
+		t.Fatalf("restConfig: %v", err)
+	}
+	if config.Host != "https://prod.invalid:6443" {
+		t.Errorf("config.Host = %q, want https://prod.invalid:6443 (an explicit context must not be ignored in favor of in-cluster config)", config.Host)
+	}
+}
+
+func TestApplyClientOptionsSetsQPSBurstAndUserAgent(t *testing.T) {
+	config := &rest.Config{}
+
+	applyClientOptions(config, ClientOptions{QPS: 50, Burst: 100, UserAgent: "heketi-broker/v1.2.3"})
+
+	if config.QPS != 50 {
+		t.Errorf("config.QPS = %v, want 50", config.QPS)
+	}
+	if config.Burst != 100 {
+		t.Errorf("config.Burst = %v, want 100", config.Burst)
+	}
+	if config.UserAgent != "heketi-broker/v1.2.3" {
+		t.Errorf("config.UserAgent = %q, want %q", config.UserAgent, "heketi-broker/v1.2.3")
+	}
+}
+
+func TestApplyClientOptionsLeavesDefaultsWhenUnset(t *testing.T) {
+	config := &rest.Config{QPS: 5, Burst: 10, UserAgent: "default-agent"}
+
+	applyClientOptions(config, ClientOptions{})
+
+	if config.QPS != 5 {
+		t.Errorf("config.QPS = %v, want unchanged 5", config.QPS)
+	}
+	if config.Burst != 10 {
+		t.Errorf("config.Burst = %v, want unchanged 10", config.Burst)
+	}
+	if config.UserAgent != "default-agent" {
+		t.Errorf("config.UserAgent = %q, want unchanged %q", config.UserAgent, "default-agent")
+	}
+}
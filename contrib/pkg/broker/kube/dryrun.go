@@ -0,0 +1,40 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+var warnPreflightDryRunUnsupportedOnce sync.Once
+
+// WarnPreflightDryRunUnsupported logs, once per process, that a broker's
+// --preflight-dry-run flag is being honored as a no-op. This client's
+// generated Create methods predate the CreateOptions.DryRun field the
+// Kubernetes API added in 1.13, so there is no way to ask the apiserver to
+// validate a pod or deployment without persisting it; sending an
+// unrecognized dryRun query parameter to an older apiserver gets silently
+// ignored and creates the object for real, which is worse than not asking.
+// A controller calls this once at startup instead of on every provision, so
+// a broker running under load doesn't spam its log with the same fact.
+func WarnPreflightDryRunUnsupported() {
+	warnPreflightDryRunUnsupportedOnce.Do(func() {
+		glog.Warning("--preflight-dry-run was requested, but this broker's Kubernetes client cannot submit a dry-run create; every provision will skip the preflight step and create real resources as before")
+	})
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// ResolveEndpoint returns the address a binding should use to reach
+// serviceName in namespace: the Service's ClusterIP, when one exists and is
+// assigned, so a bound credential keeps working across pod replacement and
+// with more than one replica behind it. A headless Service, whose
+// ClusterIP is "None", is treated the same as a missing one, since there is
+// no single address to hand back. When no such Service exists - a legacy
+// instance provisioned before its broker started creating one - this falls
+// back to a Ready pod's IP, selected by podLabelSelector, which is only
+// ever asked to find some working pod rather than a specific one.
+//
+// operation tags the API calls this makes (e.g. "bind") for TimeAPICall,
+// so a slow lookup can be attributed to the apiserver rather than to
+// whatever triggered it.
+func ResolveEndpoint(client kubernetes.Interface, operation, namespace, serviceName, podLabelSelector string) (string, error) {
+	getDone := TimeAPICall(operation, "get", "services")
+	svc, err := client.Core().Services(namespace).Get(serviceName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		getDone(err)
+	} else {
+		getDone(nil)
+	}
+	switch {
+	case err == nil:
+		if svc.Spec.ClusterIP != "" && svc.Spec.ClusterIP != v1.ClusterIPNone {
+			return svc.Spec.ClusterIP, nil
+		}
+	case !apierrors.IsNotFound(err):
+		return "", err
+	}
+
+	listDone := TimeAPICall(operation, "list", "pods")
+	pods, err := client.Core().Pods(namespace).List(metav1.ListOptions{LabelSelector: podLabelSelector})
+	listDone(err)
+	if err != nil {
+		return "", err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.PodIP == "" {
+			continue
+		}
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == v1.PodReady && cond.Status == v1.ConditionTrue {
+				return pod.Status.PodIP, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no Service and no ready pod found for %q in namespace %q", serviceName, namespace)
+}
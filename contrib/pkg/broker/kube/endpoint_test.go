@@ -0,0 +1,101 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestResolveEndpointPrefersServiceClusterIP(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "mongodb-test", Namespace: "default"},
+		Spec:       v1.ServiceSpec{ClusterIP: "10.0.0.1"},
+	}
+	client := fake.NewSimpleClientset(svc)
+
+	got, err := ResolveEndpoint(client, "bind", "default", "mongodb-test", InstanceLabelSelector("test"))
+	if err != nil {
+		t.Fatalf("ResolveEndpoint: %v", err)
+	}
+	if got != "10.0.0.1" {
+		t.Errorf("endpoint = %q, want %q", got, "10.0.0.1")
+	}
+}
+
+func TestResolveEndpointFallsBackToReadyPodWhenServiceMissing(t *testing.T) {
+	pod := readyPod("mongodb-test", "10.0.0.5", "test")
+	client := fake.NewSimpleClientset(pod)
+
+	got, err := ResolveEndpoint(client, "bind", "default", "mongodb-test", InstanceLabelSelector("test"))
+	if err != nil {
+		t.Fatalf("ResolveEndpoint: %v", err)
+	}
+	if got != "10.0.0.5" {
+		t.Errorf("endpoint = %q, want %q", got, "10.0.0.5")
+	}
+}
+
+func TestResolveEndpointSkipsUnreadyPods(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "mongodb-test", Namespace: "default", Labels: map[string]string{InstanceLabelKey(): "test"}},
+		Status:     v1.PodStatus{PodIP: "10.0.0.5"},
+	}
+	client := fake.NewSimpleClientset(pod)
+
+	if _, err := ResolveEndpoint(client, "bind", "default", "mongodb-test", InstanceLabelSelector("test")); err == nil {
+		t.Fatal("expected an error when the only pod isn't Ready")
+	}
+}
+
+func TestResolveEndpointFailsWhenNothingFound(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	if _, err := ResolveEndpoint(client, "bind", "default", "mongodb-test", InstanceLabelSelector("test")); err == nil {
+		t.Fatal("expected an error when neither a Service nor a pod exists")
+	}
+}
+
+func TestResolveEndpointTreatsHeadlessServiceAsMissing(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "mongodb-test", Namespace: "default"},
+		Spec:       v1.ServiceSpec{ClusterIP: v1.ClusterIPNone},
+	}
+	pod := readyPod("mongodb-test", "10.0.0.5", "test")
+	client := fake.NewSimpleClientset(svc, pod)
+
+	got, err := ResolveEndpoint(client, "bind", "default", "mongodb-test", InstanceLabelSelector("test"))
+	if err != nil {
+		t.Fatalf("ResolveEndpoint: %v", err)
+	}
+	if got != "10.0.0.5" {
+		t.Errorf("endpoint = %q, want %q", got, "10.0.0.5")
+	}
+}
+
+func readyPod(name, ip, instanceID string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{InstanceLabelKey(): instanceID}},
+		Status: v1.PodStatus{
+			PodIP:      ip,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ErrNoSuchInstance is returned by an in-cluster controller when an
+// operation names an instance ID that isn't in its instance map, shared so
+// every such controller reports the same error instead of each defining its
+// own copy.
+type ErrNoSuchInstance struct {
+	InstanceID string
+}
+
+func (e ErrNoSuchInstance) Error() string {
+	return fmt.Sprintf("no such instance with ID %s", e.InstanceID)
+}
+
+// ErrEmptyNamespace is returned by ValidateTargetNamespace when the target
+// namespace is the empty string.
+type ErrEmptyNamespace struct{}
+
+func (e ErrEmptyNamespace) Error() string {
+	return "target namespace must not be empty"
+}
+
+// ErrNamespaceNotFound is returned by ValidateTargetNamespace when the
+// target namespace does not exist.
+type ErrNamespaceNotFound struct {
+	Namespace string
+}
+
+func (e ErrNamespaceNotFound) Error() string {
+	return fmt.Sprintf("namespace %q not found", e.Namespace)
+}
+
+// ErrNamespaceTerminating is returned by ValidateTargetNamespace when the
+// target namespace exists but is being deleted, and so cannot host a new
+// instance.
+type ErrNamespaceTerminating struct {
+	Namespace string
+}
+
+func (e ErrNamespaceTerminating) Error() string {
+	return fmt.Sprintf("namespace %q is terminating", e.Namespace)
+}
+
+// ErrNamespaceNotAllowed is returned by ValidateTargetNamespace when the
+// target namespace exists but is not in the broker's configured
+// allowed-namespaces list.
+type ErrNamespaceNotAllowed struct {
+	Namespace string
+}
+
+func (e ErrNamespaceNotAllowed) Error() string {
+	return fmt.Sprintf("namespace %q is not in the allowed-namespaces list", e.Namespace)
+}
+
+// ErrForbidden is returned by TranslateForbidden when the Kubernetes API
+// server rejected a request as forbidden. It names the verb, resource, and
+// namespace that were denied and the service account that attempted them,
+// so whoever reads it knows exactly what RBAC to grant instead of having to
+// dig the same information out of the apiserver's raw "forbidden" message.
+type ErrForbidden struct {
+	Verb           string
+	Resource       string
+	Namespace      string
+	ServiceAccount string
+	Err            error
+}
+
+func (e ErrForbidden) Error() string {
+	return fmt.Sprintf("service account %q is not permitted to %s %s in namespace %q; grant it that permission: %v", e.ServiceAccount, e.Verb, e.Resource, e.Namespace, e.Err)
+}
+
+// TranslateForbidden checks err for a Kubernetes RBAC rejection and, if
+// found, wraps it as an ErrForbidden naming verb, resource, namespace, and
+// serviceAccount, so a caller's error message tells whoever's reading it
+// exactly what to grant instead of surfacing the apiserver's raw "forbidden"
+// string. Any other error is returned unchanged. The result is never
+// IsRetryable, since it's no longer a StatusError, so a caller that retries
+// only IsRetryable errors fails fast on it instead of spending its retry
+// budget on a request no retry can fix.
+func TranslateForbidden(err error, verb, resource, namespace, serviceAccount string) error {
+	if !apierrors.IsForbidden(err) {
+		return err
+	}
+	return ErrForbidden{
+		Verb:           verb,
+		Resource:       resource,
+		Namespace:      namespace,
+		ServiceAccount: serviceAccount,
+		Err:            err,
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"errors"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestErrNoSuchInstanceIncludesInstanceID(t *testing.T) {
+	err := ErrNoSuchInstance{InstanceID: "test-instance"}
+	if got, want := err.Error(), "no such instance with ID test-instance"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestTranslateForbiddenWrapsForbiddenSecretsError(t *testing.T) {
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "mongo-pull-secret", errors.New("denied"))
+
+	err := TranslateForbidden(forbidden, "create", "secrets", "team-a", "mongodb-broker")
+
+	var got ErrForbidden
+	if !errorsAs(err, &got) {
+		t.Fatalf("TranslateForbidden(%v) = %v, want an ErrForbidden", forbidden, err)
+	}
+	if got.Verb != "create" || got.Resource != "secrets" || got.Namespace != "team-a" || got.ServiceAccount != "mongodb-broker" {
+		t.Errorf("TranslateForbidden() = %+v, want verb/resource/namespace/serviceAccount to be preserved", got)
+	}
+}
+
+func TestTranslateForbiddenWrapsForbiddenPodsError(t *testing.T) {
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "mongo-0", errors.New("denied"))
+
+	err := TranslateForbidden(forbidden, "create", "pods", "team-a", "mongodb-broker")
+
+	var got ErrForbidden
+	if !errorsAs(err, &got) {
+		t.Fatalf("TranslateForbidden(%v) = %v, want an ErrForbidden", forbidden, err)
+	}
+	if got.Resource != "pods" {
+		t.Errorf("TranslateForbidden() Resource = %q, want %q", got.Resource, "pods")
+	}
+}
+
+func TestTranslateForbiddenWrapsForbiddenDeploymentsError(t *testing.T) {
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "nginx-0", errors.New("denied"))
+
+	err := TranslateForbidden(forbidden, "create", "deployments", "team-a", "nginx-broker")
+
+	var got ErrForbidden
+	if !errorsAs(err, &got) {
+		t.Fatalf("TranslateForbidden(%v) = %v, want an ErrForbidden", forbidden, err)
+	}
+	if got.Resource != "deployments" {
+		t.Errorf("TranslateForbidden() Resource = %q, want %q", got.Resource, "deployments")
+	}
+}
+
+func TestTranslateForbiddenLeavesOtherErrorsUnchanged(t *testing.T) {
+	notFound := apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "mongo-pull-secret")
+
+	if got := TranslateForbidden(notFound, "get", "secrets", "team-a", "mongodb-broker"); got != notFound {
+		t.Errorf("TranslateForbidden(%v) = %v, want it returned unchanged", notFound, got)
+	}
+}
+
+func TestTranslateForbiddenResultIsNotRetryable(t *testing.T) {
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "mongo-pull-secret", errors.New("denied"))
+
+	if err := TranslateForbidden(forbidden, "create", "secrets", "team-a", "mongodb-broker"); IsRetryable(err) {
+		t.Errorf("IsRetryable(TranslateForbidden(...)) = true, want false")
+	}
+}
+
+// errorsAs is a tiny stand-in for errors.As against this package's
+// concrete, non-wrapped ErrForbidden value, since TranslateForbidden
+// returns it by value rather than as a pointer.
+func errorsAs(err error, target *ErrForbidden) bool {
+	f, ok := err.(ErrForbidden)
+	if ok {
+		*target = f
+	}
+	return ok
+}
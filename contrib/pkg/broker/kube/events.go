@@ -0,0 +1,122 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"sync"
+
+	"github.com/golang/glog"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"k8s.io/client-go/kubernetes"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/pkg/api"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Event reasons shared by every in-cluster broker's instance lifecycle.
+// Provisioning/Provisioned/ProvisionFailed and Bound/Unbound/Deprovisioned
+// bracket the operations users most often check `kubectl describe` for.
+const (
+	ReasonProvisioning    = "Provisioning"
+	ReasonProvisioned     = "Provisioned"
+	ReasonProvisionFailed = "ProvisionFailed"
+	ReasonBound           = "Bound"
+	ReasonUnbound         = "Unbound"
+	ReasonDeprovisioned   = "Deprovisioned"
+)
+
+// ObjectRef builds an ObjectReference to emit an Event against, given only
+// the involved object's kind, namespace, and name. It lets a broker record
+// an Event for an object it hasn't created yet (or one it only tracks by
+// name, like these controllers' instance records), rather than requiring
+// the live object in hand.
+func ObjectRef(kind, namespace, name string) *v1.ObjectReference {
+	return &v1.ObjectReference{Kind: kind, Namespace: namespace, Name: name}
+}
+
+// RecordProvisionFailed emits a ReasonProvisionFailed warning Event for a
+// failed provision of the named service, against ref, naming err's
+// ClassifyError cause alongside its message. Every controller should call
+// this instead of recording its own ProvisionFailed event, so `kubectl
+// describe`, the errors_total metric, and the audit log always agree on
+// why a provision failed.
+func RecordProvisionFailed(recorder record.EventRecorder, ref runtime.Object, service string, err error) {
+	recorder.Eventf(ref, api.EventTypeWarning, ReasonProvisionFailed, "Failed to provision %s instance (%s): %v", service, ClassifyError(err), err)
+}
+
+// NewEventRecorder returns an EventRecorder that publishes Events for
+// component (e.g. "mongodb-broker") through client, alongside logging every
+// event at V(4). If the broker's service account isn't allowed to create
+// Events, the first rejection logs a single warning and every event after
+// that is dropped instead of retried, so a missing RBAC grant never floods
+// the log.
+func NewEventRecorder(client kubernetes.Interface, component string) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(glog.V(4).Infof)
+	broadcaster.StartRecordingToSink(&deniedOnceEventSink{
+		sink: &v1core.EventSinkImpl{Interface: client.Core().Events("")},
+	})
+	return broadcaster.NewRecorder(api.Scheme, v1.EventSource{Component: component})
+}
+
+// deniedOnceEventSink wraps an EventSink and stops publishing Events after
+// the API server rejects one as forbidden, so a broker without events RBAC
+// logs one warning instead of an error per event for the rest of its life.
+type deniedOnceEventSink struct {
+	sink record.EventSink
+
+	mu     sync.Mutex
+	denied bool
+}
+
+func (s *deniedOnceEventSink) Create(event *v1.Event) (*v1.Event, error) {
+	return s.do(event, s.sink.Create)
+}
+
+func (s *deniedOnceEventSink) Update(event *v1.Event) (*v1.Event, error) {
+	return s.do(event, s.sink.Update)
+}
+
+func (s *deniedOnceEventSink) Patch(event *v1.Event, data []byte) (*v1.Event, error) {
+	return s.do(event, func(e *v1.Event) (*v1.Event, error) { return s.sink.Patch(e, data) })
+}
+
+func (s *deniedOnceEventSink) do(event *v1.Event, call func(*v1.Event) (*v1.Event, error)) (*v1.Event, error) {
+	s.mu.Lock()
+	denied := s.denied
+	s.mu.Unlock()
+	if denied {
+		return event, nil
+	}
+
+	result, err := call(event)
+	if err != nil && errors.IsForbidden(err) {
+		s.mu.Lock()
+		alreadyWarned := s.denied
+		s.denied = true
+		s.mu.Unlock()
+		if !alreadyWarned {
+			glog.Warningf("events: %s is not permitted to record Kubernetes Events (RBAC?); disabling event recording", event.Source.Component)
+		}
+		return event, nil
+	}
+	return result, err
+}
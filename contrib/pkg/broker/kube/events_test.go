@@ -0,0 +1,120 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/pkg/api"
+	"k8s.io/client-go/pkg/api/v1"
+	ktesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+type countingEventSink struct {
+	creates int
+}
+
+func (s *countingEventSink) Create(event *v1.Event) (*v1.Event, error) {
+	s.creates++
+	return event, nil
+}
+
+func (s *countingEventSink) Update(event *v1.Event) (*v1.Event, error) { return event, nil }
+
+func (s *countingEventSink) Patch(event *v1.Event, data []byte) (*v1.Event, error) {
+	return event, nil
+}
+
+func TestDeniedOnceEventSinkPassesThroughOnSuccess(t *testing.T) {
+	sink := &countingEventSink{}
+	denied := &deniedOnceEventSink{sink: sink}
+
+	for i := 0; i < 3; i++ {
+		if _, err := denied.Create(&v1.Event{}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	if sink.creates != 3 {
+		t.Errorf("creates = %d, want 3", sink.creates)
+	}
+}
+
+func TestDeniedOnceEventSinkStopsAfterForbidden(t *testing.T) {
+	forbidden := errors.NewForbidden(v1.Resource("events"), "", nil)
+	denied := &deniedOnceEventSink{}
+
+	calls := 0
+	call := func(*v1.Event) (*v1.Event, error) {
+		calls++
+		return nil, forbidden
+	}
+
+	if _, err := denied.do(&v1.Event{}, call); err != nil {
+		t.Fatalf("do: %v (a forbidden error must be swallowed, not surfaced to the caller)", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := denied.do(&v1.Event{}, call); err != nil {
+			t.Fatalf("do: %v", err)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("underlying call made %d times after the first forbidden response, want 1 (should stop retrying)", calls)
+	}
+}
+
+func TestNewEventRecorderDegradesWithoutEventsRBAC(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "events", func(ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewForbidden(v1.Resource("events"), "", nil)
+	})
+
+	recorder := NewEventRecorder(client, "test-broker")
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+
+	// None of these should panic or block despite every Create being denied.
+	for i := 0; i < 3; i++ {
+		recorder.Event(ns, api.EventTypeNormal, ReasonProvisioning, "provisioning instance")
+	}
+}
+
+func TestRecordProvisionFailedNamesTheClassifiedCause(t *testing.T) {
+	recorder := record.NewFakeRecorder(1)
+	forbidden := errors.NewForbidden(schema.GroupResource{Resource: "pods"}, "mongo-0", fmt.Errorf("denied"))
+
+	RecordProvisionFailed(recorder, ObjectRef("Pod", "team-a", "mongo-0"), "mongodb", forbidden)
+
+	event := <-recorder.Events
+	if !strings.Contains(event, ReasonProvisionFailed) {
+		t.Errorf("event = %q, want it to carry reason %q", event, ReasonProvisionFailed)
+	}
+	if !strings.Contains(event, CauseForbidden) {
+		t.Errorf("event = %q, want it to name cause %q", event, CauseForbidden)
+	}
+	if !strings.Contains(event, "mongodb") {
+		t.Errorf("event = %q, want it to name the service", event)
+	}
+}
@@ -0,0 +1,28 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import "k8s.io/client-go/kubernetes"
+
+// CheckAPIServerReachable performs a cheap call against the API server's
+// discovery endpoint, verifying the broker's client can actually reach and
+// authenticate to it, without touching any of the objects a broker
+// manages.
+func CheckAPIServerReachable(client kubernetes.Interface) error {
+	_, err := client.Discovery().ServerVersion()
+	return err
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// The failure path isn't covered here: the vendored FakeDiscovery.
+// ServerVersion() runs the reactor chain but discards whatever error it
+// returns, always answering with a hardcoded version instead, so there is
+// no way to make this fake client's Discovery() call fail.
+func TestCheckAPIServerReachableSucceedsAgainstAFakeServer(t *testing.T) {
+	if err := CheckAPIServerReachable(fake.NewSimpleClientset()); err != nil {
+		t.Fatalf("CheckAPIServerReachable: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// podInformerResync is how often the informer's cache does a full relist
+// against the apiserver, on top of the incremental updates its watch
+// delivers, to protect against a missed or dropped watch event.
+const podInformerResync = 30 * time.Second
+
+// NewPodInformer starts a shared Pod informer against client, covering
+// every namespace, and blocks until its cache completes its initial List so
+// a lookup against the returned lister never races an empty cache. Callers
+// should scope reads to a single instance with InstanceLabelSelector, since
+// the informer itself watches unfiltered. Close stopCh to stop the informer
+// when the broker shuts down.
+func NewPodInformer(client kubernetes.Interface, stopCh <-chan struct{}) (v1listers.PodLister, error) {
+	factory := informers.NewSharedInformerFactory(client, podInformerResync)
+	pods := factory.Core().V1().Pods()
+	informer := pods.Informer()
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+		return nil, fmt.Errorf("failed to sync pod informer cache")
+	}
+	return pods.Lister(), nil
+}
+
+// ListInstancePods returns instanceID's pods in namespace. When lister is
+// non-nil it reads from the shared informer's cache; otherwise it falls
+// back to a direct, uncached List against client. Every broker's pod
+// lookup goes through this so that enabling or disabling the shared
+// informer, via a lister that's nil or not, is a single switch rather than
+// a per-call-site change.
+func ListInstancePods(client kubernetes.Interface, lister v1listers.PodLister, namespace, instanceID string) ([]v1.Pod, error) {
+	if lister == nil {
+		list, err := client.Core().Pods(namespace).List(metav1.ListOptions{LabelSelector: InstanceLabelSelector(instanceID)})
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	}
+
+	selector, err := labels.Parse(InstanceLabelSelector(instanceID))
+	if err != nil {
+		return nil, err
+	}
+	pods, err := lister.Pods(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]v1.Pod, len(pods))
+	for i, pod := range pods {
+		items[i] = *pod
+	}
+	return items, nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestListInstancePodsFromInformerMatchesDirectList(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "instance-1-pod",
+				Namespace: "default",
+				Labels:    map[string]string{DefaultInstanceLabelKey: "instance-1"},
+			},
+		},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "instance-2-pod",
+				Namespace: "default",
+				Labels:    map[string]string{DefaultInstanceLabelKey: "instance-2"},
+			},
+		},
+	)
+
+	direct, err := ListInstancePods(client, nil, "default", "instance-1")
+	if err != nil {
+		t.Fatalf("ListInstancePods (direct): %v", err)
+	}
+	if len(direct) != 1 || direct[0].Name != "instance-1-pod" {
+		t.Fatalf("ListInstancePods (direct) = %v, want a single instance-1-pod", direct)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	lister, err := NewPodInformer(client, stopCh)
+	if err != nil {
+		t.Fatalf("NewPodInformer: %v", err)
+	}
+
+	cached, err := ListInstancePods(client, lister, "default", "instance-1")
+	if err != nil {
+		t.Fatalf("ListInstancePods (cached): %v", err)
+	}
+	if len(cached) != len(direct) || cached[0].Name != direct[0].Name {
+		t.Errorf("ListInstancePods (cached) = %v, want %v", cached, direct)
+	}
+}
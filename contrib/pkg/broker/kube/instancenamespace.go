@@ -0,0 +1,114 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// InstanceNamespaceOwnerLabel marks a namespace as created and owned by a
+// broker running --namespace-per-instance, with that broker's name as its
+// value, so a namespace an operator created and pointed a request at
+// directly is never mistaken for one this package is free to delete.
+const InstanceNamespaceOwnerLabel = "service-catalog.k8s.io/instance-namespace-owner"
+
+// InstanceNamespaceName derives the dedicated namespace name for an
+// instance under --namespace-per-instance: brokerName and instanceID,
+// sanitized and joined the same deterministic way every other
+// instance-scoped resource name is.
+func InstanceNamespaceName(brokerName, instanceID string) string {
+	return names.InstanceResourceName(brokerName, instanceID, "")
+}
+
+// EnsureInstanceNamespace creates name as the dedicated namespace for
+// instanceID, labeled with InstanceNamespaceOwnerLabel so IsInstanceNamespace
+// recognizes it later. It's idempotent: if name already exists and carries
+// that label for brokerName, it's left alone instead of failing the
+// request, so a provision retried after a partial failure reuses the same
+// namespace instead of erroring on AlreadyExists. A name that exists but
+// isn't a namespace this broker created is left untouched and reported as
+// an error, since creating instance resources in it could collide with
+// whatever an operator is already using it for.
+func EnsureInstanceNamespace(client kubernetes.Interface, brokerName, instanceID, name string) error {
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				InstanceNamespaceOwnerLabel: brokerName,
+				InstanceLabelKey():          instanceID,
+			},
+		},
+	}
+	_, err := client.Core().Namespaces().Create(ns)
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	existing, getErr := client.Core().Namespaces().Get(name, metav1.GetOptions{})
+	if getErr != nil {
+		return err
+	}
+	if !IsInstanceNamespace(existing, brokerName) {
+		return fmt.Errorf("namespace %q already exists and was not created by this broker", name)
+	}
+	return nil
+}
+
+// IsInstanceNamespace reports whether ns was created by EnsureInstanceNamespace
+// for brokerName, as opposed to one an operator created by hand.
+func IsInstanceNamespace(ns *v1.Namespace, brokerName string) bool {
+	return ns.Labels[InstanceNamespaceOwnerLabel] == brokerName
+}
+
+// DeleteInstanceNamespace deletes an instance's dedicated namespace and
+// blocks, polling every pollInterval, until the apiserver reports it gone.
+// A deprovision that reports complete only after this returns never tells
+// the platform an instance is gone while its namespace - and whatever quota
+// or finalizers it holds - is still terminating.
+func DeleteInstanceNamespace(ctx context.Context, client kubernetes.Interface, name string, pollInterval time.Duration) error {
+	if err := client.Core().Namespaces().Delete(name, nil); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	for {
+		_, err := client.Core().Namespaces().Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for namespace %q to terminate: %v", name, ctx.Err())
+		case <-time.After(pollInterval):
+		}
+	}
+}
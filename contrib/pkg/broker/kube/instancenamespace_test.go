@@ -0,0 +1,129 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/pkg/api/v1"
+	ktesting "k8s.io/client-go/testing"
+)
+
+func TestInstanceNamespaceNameSanitizesAndPrefixes(t *testing.T) {
+	name := InstanceNamespaceName("mongodb", "Some_ID.123")
+	if name != "mongodb-some-id-123" {
+		t.Errorf("InstanceNamespaceName = %q, want %q", name, "mongodb-some-id-123")
+	}
+}
+
+func TestInstanceNamespaceNameTruncatesToNameLimit(t *testing.T) {
+	name := InstanceNamespaceName("heketi", strings.Repeat("a", 100))
+	if len(name) > 63 {
+		t.Errorf("len(InstanceNamespaceName(...)) = %d, want <= 63", len(name))
+	}
+}
+
+func TestEnsureInstanceNamespaceCreatesNamespace(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	if err := EnsureInstanceNamespace(client, "mongodb", "test", "mongodb-test"); err != nil {
+		t.Fatalf("EnsureInstanceNamespace: %v", err)
+	}
+
+	ns, err := client.Core().Namespaces().Get("mongodb-test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get namespace: %v", err)
+	}
+	if !IsInstanceNamespace(ns, "mongodb") {
+		t.Errorf("IsInstanceNamespace(ns, \"mongodb\") = false, want true")
+	}
+}
+
+func TestEnsureInstanceNamespaceReusesOwnNamespaceOnRetry(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "mongodb-test",
+			Labels: map[string]string{InstanceNamespaceOwnerLabel: "mongodb"},
+		},
+	})
+
+	if err := EnsureInstanceNamespace(client, "mongodb", "test", "mongodb-test"); err != nil {
+		t.Errorf("EnsureInstanceNamespace on retry = %v, want nil", err)
+	}
+}
+
+func TestEnsureInstanceNamespaceRejectsForeignNamespace(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "mongodb-test"},
+	})
+
+	if err := EnsureInstanceNamespace(client, "mongodb", "test", "mongodb-test"); err == nil {
+		t.Fatal("expected an error for a namespace this broker did not create")
+	}
+}
+
+func TestDeleteInstanceNamespaceIgnoresAlreadyGone(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	if err := DeleteInstanceNamespace(context.Background(), client, "mongodb-test", time.Millisecond); err != nil {
+		t.Errorf("DeleteInstanceNamespace = %v, want nil for an already-gone namespace", err)
+	}
+}
+
+func TestDeleteInstanceNamespaceWaitsForTermination(t *testing.T) {
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "mongodb-test"}}
+	client := fake.NewSimpleClientset(ns)
+
+	gets := 0
+	client.PrependReactor("get", "namespaces", func(ktesting.Action) (bool, runtime.Object, error) {
+		gets++
+		if gets < 3 {
+			return true, ns, nil
+		}
+		return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, "mongodb-test")
+	})
+
+	if err := DeleteInstanceNamespace(context.Background(), client, "mongodb-test", time.Millisecond); err != nil {
+		t.Fatalf("DeleteInstanceNamespace: %v", err)
+	}
+	if gets < 3 {
+		t.Errorf("Get was called %d times, want at least 3 (DeleteInstanceNamespace should poll until termination)", gets)
+	}
+}
+
+func TestDeleteInstanceNamespaceRespectsContextTimeout(t *testing.T) {
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "mongodb-test"}}
+	client := fake.NewSimpleClientset(ns)
+	client.PrependReactor("get", "namespaces", func(ktesting.Action) (bool, runtime.Object, error) {
+		return true, ns, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := DeleteInstanceNamespace(ctx, client, "mongodb-test", time.Millisecond); err == nil {
+		t.Fatal("expected an error when the context deadline is exceeded before the namespace terminates")
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg"
+)
+
+// ManagedBy is the value every in-cluster broker sets on its "managed-by"
+// label, so a cluster-wide audit can tell service-catalog-managed objects
+// apart from everything else without knowing about any particular broker.
+const ManagedBy = "service-catalog"
+
+// DefaultInstanceLabelKey is the label key used to tag and select an
+// instance's resources unless Configure overrides it.
+const DefaultInstanceLabelKey = "instanceID"
+
+var (
+	instanceLabelKey = DefaultInstanceLabelKey
+	extraLabels      map[string]string
+)
+
+// Configure sets the instance label key and the extra, operator-supplied
+// labels applied by every subsequent CommonLabels call. It's meant to run
+// once, at broker startup, before any request is served. Overriding
+// instanceLabelKey away from DefaultInstanceLabelKey on a broker that
+// already has instances is logged as a warning: every label-selector-based
+// lookup this package's callers make against those existing resources
+// switches to the new key immediately, so it will no longer find anything
+// created under the old one.
+func Configure(instanceLabelKeyOverride string, extra map[string]string) {
+	key := instanceLabelKeyOverride
+	if key == "" {
+		key = DefaultInstanceLabelKey
+	}
+	if key != DefaultInstanceLabelKey {
+		glog.Warningf("instance label key overridden to %q: resources already created under the default key %q will no longer be found by label-selector-based lookups", key, DefaultInstanceLabelKey)
+	}
+	instanceLabelKey = key
+	extraLabels = extra
+}
+
+// InstanceLabelKey returns the label key used to tag and select an
+// instance's resources, DefaultInstanceLabelKey unless overridden by
+// Configure.
+func InstanceLabelKey() string {
+	return instanceLabelKey
+}
+
+// InstanceLabelSelector returns the label selector matching every resource
+// belonging to instanceID.
+func InstanceLabelSelector(instanceID string) string {
+	return fmt.Sprintf("%s=%s", instanceLabelKey, instanceID)
+}
+
+// CommonLabels returns the label set an in-cluster broker should apply to
+// every Kubernetes object it creates for instanceID: which broker (and
+// broker build) created it, which catalog service and plan it was
+// provisioned from, which instance it belongs to, and which namespace the
+// ServiceInstance that requested it lives in, plus any operator-configured
+// extra labels set through Configure. Applying the same set consistently
+// lets an operator distinguish, say, a heketi broker's objects from a
+// mongodb broker's in a shared cluster, lets each broker's own deprovision
+// logic select exactly the objects it created, and lets an audit trace an
+// orphaned object back to the namespace that requested it without
+// cross-referencing the instance record.
+func CommonLabels(broker, version, serviceID, planID, instanceID, namespace string) map[string]string {
+	labels := map[string]string{
+		instanceLabelKey: instanceID,
+		"serviceID":      serviceID,
+		"planID":         planID,
+		"broker":         broker,
+		"version":        version,
+		"namespace":      namespace,
+		"managed-by":     ManagedBy,
+	}
+	for k, v := range extraLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// BuildAnnotations returns the annotations an in-cluster broker should
+// apply to every Kubernetes object it creates, recording the exact build
+// that created it -- version, git commit, and build date -- alongside the
+// version label CommonLabels already sets. Labels are for selecting; these
+// are for the finer-grained detail an operator doing cluster archaeology
+// needs but would never want to select or aggregate on.
+func BuildAnnotations() map[string]string {
+	return map[string]string{
+		"service-catalog.k8s.io/version":    pkg.VERSION,
+		"service-catalog.k8s.io/git-commit": pkg.GitCommit,
+		"service-catalog.k8s.io/build-date": pkg.BuildDate,
+	}
+}
+
+// CopyStringMap returns a shallow copy of m, so a caller that hands the
+// same labels or annotations map to several objects can let one of them
+// mutate its own copy afterward without the change leaking into the
+// others. A nil m copies to an empty, non-nil map.
+func CopyStringMap(m map[string]string) map[string]string {
+	copied := make(map[string]string, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
+
+// TraceAnnotations returns the annotations an in-cluster broker should apply
+// to every Kubernetes object it creates while handling an OSB operation, on
+// top of BuildAnnotations' build provenance: the correlation ID a triager
+// can grep the broker's log for to find the exact operation that created
+// this object, the operation itself, and the time it was created. Like
+// BuildAnnotations, these are for a human doing cluster archaeology, not
+// for the reconciler or GC to match on -- they keep using labels, which are
+// never touched here.
+func TraceAnnotations(correlationID, operation string) map[string]string {
+	annotations := BuildAnnotations()
+	annotations["service-catalog.k8s.io/correlation-id"] = correlationID
+	annotations["service-catalog.k8s.io/operation"] = operation
+	annotations["service-catalog.k8s.io/created-at"] = time.Now().UTC().Format(time.RFC3339)
+	return annotations
+}
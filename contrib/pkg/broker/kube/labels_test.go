@@ -0,0 +1,76 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommonLabelsIncludesEveryField(t *testing.T) {
+	got := CommonLabels("heketi", "v1.2.3", "service-1", "plan-1", "instance-1", "default")
+	want := map[string]string{
+		"instanceID": "instance-1",
+		"serviceID":  "service-1",
+		"planID":     "plan-1",
+		"broker":     "heketi",
+		"version":    "v1.2.3",
+		"namespace":  "default",
+		"managed-by": "service-catalog",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CommonLabels() = %v, want %v", got, want)
+	}
+}
+
+func TestConfigureOverridesInstanceLabelKeyAndAddsExtraLabels(t *testing.T) {
+	defer Configure("", nil)
+
+	Configure("app.example.com/instance", map[string]string{"team": "storage"})
+
+	if got, want := InstanceLabelKey(), "app.example.com/instance"; got != want {
+		t.Errorf("InstanceLabelKey() = %q, want %q", got, want)
+	}
+	if got, want := InstanceLabelSelector("instance-1"), "app.example.com/instance=instance-1"; got != want {
+		t.Errorf("InstanceLabelSelector() = %q, want %q", got, want)
+	}
+
+	got := CommonLabels("heketi", "v1.2.3", "service-1", "plan-1", "instance-1", "default")
+	want := map[string]string{
+		"app.example.com/instance": "instance-1",
+		"serviceID":                "service-1",
+		"planID":                   "plan-1",
+		"broker":                   "heketi",
+		"version":                  "v1.2.3",
+		"namespace":                "default",
+		"managed-by":               "service-catalog",
+		"team":                     "storage",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CommonLabels() = %v, want %v", got, want)
+	}
+}
+
+func TestConfigureEmptyKeepsDefaultInstanceLabelKey(t *testing.T) {
+	defer Configure("", nil)
+
+	Configure("", nil)
+
+	if got, want := InstanceLabelKey(), DefaultInstanceLabelKey; got != want {
+		t.Errorf("InstanceLabelKey() = %q, want %q", got, want)
+	}
+}
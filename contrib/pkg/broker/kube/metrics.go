@@ -0,0 +1,65 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "service_catalog_broker",
+		Subsystem: "kube_client",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of Kubernetes API calls made by the broker, by the operation that triggered them, the verb, and the resource.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "verb", "resource"})
+
+	apiRequestErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "service_catalog_broker",
+		Subsystem: "kube_client",
+		Name:      "request_errors_total",
+		Help:      "Count of failed Kubernetes API calls made by the broker, by the operation that triggered them, the verb, and the resource.",
+	}, []string{"operation", "verb", "resource"})
+)
+
+func init() {
+	prometheus.MustRegister(apiRequestDuration, apiRequestErrorsTotal)
+}
+
+// TimeAPICall records how long a Kubernetes API call, identified by verb
+// (e.g. "get", "create") and resource (e.g. "pods", "services"), takes on
+// behalf of operation (e.g. "provision", "bind", "deprovision",
+// "reconcile"). It returns a function the caller invokes with the call's
+// result once it returns, so a slow provision can be attributed to the
+// apiserver instead of broker code without threading a stopwatch through
+// every call site by hand:
+//
+//	done := kube.TimeAPICall("provision", "create", "pods")
+//	_, err := client.Core().Pods(namespace).Create(pod)
+//	done(err)
+func TimeAPICall(operation, verb, resource string) func(err error) {
+	start := time.Now()
+	return func(err error) {
+		apiRequestDuration.WithLabelValues(operation, verb, resource).Observe(time.Since(start).Seconds())
+		if err != nil {
+			apiRequestErrorsTotal.WithLabelValues(operation, verb, resource).Inc()
+		}
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func requestCount(operation, verb, resource string) uint64 {
+	var m dto.Metric
+	if err := apiRequestErrorsTotal.WithLabelValues(operation, verb, resource).Write(&m); err != nil {
+		panic(err)
+	}
+	return uint64(m.GetCounter().GetValue())
+}
+
+func requestObservations(operation, verb, resource string) uint64 {
+	var m dto.Metric
+	histogram := apiRequestDuration.WithLabelValues(operation, verb, resource).(prometheus.Histogram)
+	if err := histogram.Write(&m); err != nil {
+		panic(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestTimeAPICallRecordsLatencyOnSuccess(t *testing.T) {
+	before := requestObservations("provision", "create", "test-pods-success")
+
+	done := TimeAPICall("provision", "create", "test-pods-success")
+	done(nil)
+
+	if after := requestObservations("provision", "create", "test-pods-success"); after != before+1 {
+		t.Errorf("observation count = %d, want %d", after, before+1)
+	}
+	if errs := requestCount("provision", "create", "test-pods-success"); errs != 0 {
+		t.Errorf("error count = %d, want 0", errs)
+	}
+}
+
+func TestTimeAPICallRecordsErrorOnFailure(t *testing.T) {
+	before := requestCount("provision", "create", "test-pods-failure")
+
+	done := TimeAPICall("provision", "create", "test-pods-failure")
+	done(errors.New("boom"))
+
+	if after := requestCount("provision", "create", "test-pods-failure"); after != before+1 {
+		t.Errorf("error count = %d, want %d", after, before+1)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// ValidateTargetNamespace checks that namespace is a fit target for a new
+// instance: non-empty, existing, not terminating, and (when allowed is
+// non-empty) present in it. Every provisioner should call this before
+// creating any resource, so a bad namespace fails the request up front
+// instead of leaving a partially created instance behind. allowed being nil
+// or empty means every existing, non-terminating namespace is permitted.
+func ValidateTargetNamespace(client kubernetes.Interface, namespace string, allowed map[string]bool) error {
+	if namespace == "" {
+		return ErrEmptyNamespace{}
+	}
+
+	ns, err := client.Core().Namespaces().Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		return ErrNamespaceNotFound{Namespace: namespace}
+	}
+	if ns.Status.Phase == v1.NamespaceTerminating {
+		return ErrNamespaceTerminating{Namespace: namespace}
+	}
+	if len(allowed) > 0 && !allowed[namespace] {
+		return ErrNamespaceNotAllowed{Namespace: namespace}
+	}
+	return nil
+}
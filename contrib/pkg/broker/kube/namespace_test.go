@@ -0,0 +1,81 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestValidateTargetNamespaceRejectsEmpty(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	if err := ValidateTargetNamespace(client, "", nil); err != (ErrEmptyNamespace{}) {
+		t.Errorf("ValidateTargetNamespace(\"\") = %v, want ErrEmptyNamespace", err)
+	}
+}
+
+func TestValidateTargetNamespaceRejectsMissing(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	err := ValidateTargetNamespace(client, "missing", nil)
+	if _, ok := err.(ErrNamespaceNotFound); !ok {
+		t.Errorf("ValidateTargetNamespace(missing) = %v, want ErrNamespaceNotFound", err)
+	}
+}
+
+func TestValidateTargetNamespaceRejectsTerminating(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "dying"},
+		Status:     v1.NamespaceStatus{Phase: v1.NamespaceTerminating},
+	})
+	err := ValidateTargetNamespace(client, "dying", nil)
+	if _, ok := err.(ErrNamespaceTerminating); !ok {
+		t.Errorf("ValidateTargetNamespace(dying) = %v, want ErrNamespaceTerminating", err)
+	}
+}
+
+func TestValidateTargetNamespaceRejectsNotAllowed(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+	})
+	err := ValidateTargetNamespace(client, "default", map[string]bool{"other": true})
+	if _, ok := err.(ErrNamespaceNotAllowed); !ok {
+		t.Errorf("ValidateTargetNamespace(default) = %v, want ErrNamespaceNotAllowed", err)
+	}
+}
+
+func TestValidateTargetNamespaceAcceptsActiveAllowed(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Status:     v1.NamespaceStatus{Phase: v1.NamespaceActive},
+	})
+	if err := ValidateTargetNamespace(client, "default", map[string]bool{"default": true}); err != nil {
+		t.Errorf("ValidateTargetNamespace(default) = %v, want nil", err)
+	}
+}
+
+func TestValidateTargetNamespaceAcceptsUnrestricted(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Status:     v1.NamespaceStatus{Phase: v1.NamespaceActive},
+	})
+	if err := ValidateTargetNamespace(client, "default", nil); err != nil {
+		t.Errorf("ValidateTargetNamespace(default) = %v, want nil", err)
+	}
+}
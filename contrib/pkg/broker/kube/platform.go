@@ -0,0 +1,58 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import "fmt"
+
+// Platform identifies the cluster flavor a broker is configured to run
+// against, selecting how it exposes an instance outside the cluster.
+type Platform string
+
+const (
+	// PlatformKubernetes is the default: exposure uses NodePort Services
+	// and Ingress objects, which every Kubernetes cluster supports.
+	PlatformKubernetes Platform = "kubernetes"
+
+	// PlatformOpenShift additionally allows exposure via Route objects,
+	// which only exist on OpenShift clusters.
+	PlatformOpenShift Platform = "openshift"
+)
+
+// ParsePlatform validates the value of a broker's --platform flag,
+// defaulting an unset one to PlatformKubernetes.
+func ParsePlatform(s string) (Platform, error) {
+	switch p := Platform(s); p {
+	case "", PlatformKubernetes:
+		return PlatformKubernetes, nil
+	case PlatformOpenShift:
+		return PlatformOpenShift, nil
+	default:
+		return "", fmt.Errorf("platform must be %q or %q, got %q", PlatformKubernetes, PlatformOpenShift, s)
+	}
+}
+
+// ErrPlatformNotConfigured is returned when a request asks for a resource,
+// named by Resource, that only exists on a platform other than the one this
+// broker was started with.
+type ErrPlatformNotConfigured struct {
+	Resource string
+	Required Platform
+}
+
+func (e ErrPlatformNotConfigured) Error() string {
+	return fmt.Sprintf("%s requires the broker to be started with --platform=%s", e.Resource, e.Required)
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import "testing"
+
+func TestParsePlatformDefaultsToKubernetes(t *testing.T) {
+	p, err := ParsePlatform("")
+	if err != nil {
+		t.Fatalf("ParsePlatform: %v", err)
+	}
+	if p != PlatformKubernetes {
+		t.Errorf("platform = %q, want %q", p, PlatformKubernetes)
+	}
+}
+
+func TestParsePlatformAcceptsKnownValues(t *testing.T) {
+	for _, want := range []Platform{PlatformKubernetes, PlatformOpenShift} {
+		got, err := ParsePlatform(string(want))
+		if err != nil {
+			t.Fatalf("ParsePlatform(%q): %v", want, err)
+		}
+		if got != want {
+			t.Errorf("ParsePlatform(%q) = %q, want %q", want, got, want)
+		}
+	}
+}
+
+func TestParsePlatformRejectsUnknownValue(t *testing.T) {
+	if _, err := ParsePlatform("openstack"); err == nil {
+		t.Error("expected an error for an unknown platform")
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// DefaultRetryAttempts is the number of times Retry calls op before giving
+// up, used when a controller isn't configured with its own value.
+const DefaultRetryAttempts = 3
+
+// DefaultRetryBaseDelay is the delay before the first retry, used when a
+// controller isn't configured with its own value. Later retries back off
+// exponentially from it.
+const DefaultRetryBaseDelay = 200 * time.Millisecond
+
+// sleep waits out delay, or returns ctx's error early if ctx is cancelled
+// first. It's a var so tests can make retries instant instead of actually
+// waiting out the backoff.
+var sleep = func(ctx context.Context, delay time.Duration) error {
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// IsRetryable reports whether err is a transient apiserver error worth
+// retrying: a server timeout, a rate limit, an internal error, or a
+// conflicting update. Anything else - Forbidden, Invalid, AlreadyExists,
+// NotFound, and so on - is a caller or state error that a retry can't fix.
+func IsRetryable(err error) bool {
+	return errors.IsServerTimeout(err) ||
+		errors.IsTooManyRequests(err) ||
+		errors.IsInternalError(err) ||
+		errors.IsConflict(err)
+}
+
+// Retry calls op, retrying up to attempts times (so attempts=3 means up to
+// two retries after the initial call) as long as op's error is retryable
+// per IsRetryable, with an exponential backoff starting at baseDelay. label
+// identifies the operation in the log line printed before each retry, e.g.
+// "create mongodb instance pod". It returns the last error op returned, or
+// nil as soon as op succeeds. If ctx is cancelled while waiting out a
+// backoff, Retry stops and returns ctx.Err() instead of retrying further.
+func Retry(ctx context.Context, attempts int, baseDelay time.Duration, label string, op func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	delay := baseDelay
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = op(); err == nil {
+			return nil
+		}
+		if !IsRetryable(err) || attempt == attempts {
+			return err
+		}
+		glog.Warningf("%s: attempt %d/%d failed with a retryable error, retrying in %s: %v", label, attempt, attempts, delay, err)
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			return sleepErr
+		}
+		delay *= 2
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func noopSleep(context.Context, time.Duration) error { return nil }
+
+// newTooManyRequests builds a 429 StatusError; the errors package doesn't
+// export a constructor for one directly.
+func newTooManyRequests(gr schema.GroupResource) error {
+	return errors.NewGenericServerResponse(429, "get", gr, "test", "", 1, false)
+}
+
+func TestIsRetryable(t *testing.T) {
+	gr := schema.GroupResource{Resource: "pods"}
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server timeout", errors.NewServerTimeout(gr, "get", 1), true},
+		{"too many requests", newTooManyRequests(gr), true},
+		{"internal error", errors.NewInternalError(fmt.Errorf("boom")), true},
+		{"conflict", errors.NewConflict(gr, "test", fmt.Errorf("boom")), true},
+		{"forbidden", errors.NewForbidden(gr, "test", fmt.Errorf("boom")), false},
+		{"invalid", errors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "test", nil), false},
+		{"already exists", errors.NewAlreadyExists(gr, "test"), false},
+		{"not found", errors.NewNotFound(gr, "test"), false},
+		{"plain error", fmt.Errorf("boom"), false},
+	}
+	for _, c := range cases {
+		if got := IsRetryable(c.err); got != c.want {
+			t.Errorf("%s: IsRetryable() = %t, want %t", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRetrySucceedsWithoutRetrying(t *testing.T) {
+	defer func(orig func(context.Context, time.Duration) error) { sleep = orig }(sleep)
+	sleep = noopSleep
+
+	calls := 0
+	err := Retry(context.Background(), 3, time.Millisecond, "test", func() error {
+		calls++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRetrySucceedsAfterTransientErrors(t *testing.T) {
+	defer func(orig func(context.Context, time.Duration) error) { sleep = orig }(sleep)
+	sleep = noopSleep
+
+	calls := 0
+	err := Retry(context.Background(), 3, time.Millisecond, "test", func() error {
+		calls++
+		if calls < 3 {
+			return errors.NewConflict(schema.GroupResource{Resource: "pods"}, "test", fmt.Errorf("conflict"))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestRetryGivesUpAfterAttempts(t *testing.T) {
+	defer func(orig func(context.Context, time.Duration) error) { sleep = orig }(sleep)
+	sleep = noopSleep
+
+	calls := 0
+	wantErr := newTooManyRequests(schema.GroupResource{Resource: "pods"})
+	err := Retry(context.Background(), 3, time.Millisecond, "test", func() error {
+		calls++
+		return wantErr
+	})
+	if err != wantErr {
+		t.Errorf("err = %v, want %v", err, wantErr)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestRetryFailsImmediatelyOnNonRetryableError(t *testing.T) {
+	defer func(orig func(context.Context, time.Duration) error) { sleep = orig }(sleep)
+	sleep = func(context.Context, time.Duration) error {
+		t.Fatal("did not expect Retry to sleep before a non-retryable error")
+		return nil
+	}
+
+	calls := 0
+	wantErr := errors.NewAlreadyExists(schema.GroupResource{Resource: "pods"}, "test")
+	err := Retry(context.Background(), 3, time.Millisecond, "test", func() error {
+		calls++
+		return wantErr
+	})
+	if err != wantErr {
+		t.Errorf("err = %v, want %v", err, wantErr)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRetryStopsWhenContextCancelledBeforeBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	wantErr := errors.NewConflict(schema.GroupResource{Resource: "pods"}, "test", fmt.Errorf("conflict"))
+	err := Retry(ctx, 3, time.Millisecond, "test", func() error {
+		calls++
+		return wantErr
+	})
+	if err != context.Canceled {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRetryTreatsZeroOrNegativeAttemptsAsOne(t *testing.T) {
+	defer func(orig func(context.Context, time.Duration) error) { sleep = orig }(sleep)
+	sleep = noopSleep
+
+	calls := 0
+	wantErr := errors.NewConflict(schema.GroupResource{Resource: "pods"}, "test", fmt.Errorf("conflict"))
+	err := Retry(context.Background(), 0, time.Millisecond, "test", func() error {
+		calls++
+		return wantErr
+	})
+	if err != wantErr {
+		t.Errorf("err = %v, want %v", err, wantErr)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
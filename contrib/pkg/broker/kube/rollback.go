@@ -0,0 +1,84 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kube holds small Kubernetes-facing helpers shared across the
+// in-cluster service brokers in contrib/pkg/broker.
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// RollbackTracker accumulates undo actions as a multi-step provisioning
+// operation creates Kubernetes objects, so a failure partway through can
+// tear down everything created so far instead of orphaning it. It is not
+// safe for concurrent use; each provisioning attempt should use its own
+// tracker.
+type RollbackTracker struct {
+	undos []rollbackStep
+}
+
+type rollbackStep struct {
+	name string
+	undo func(ctx context.Context) error
+}
+
+// Add registers undo as a step to run if Run is called instead of Commit.
+// name identifies the step in the log line printed as it's undone, e.g.
+// "delete nginx instance deployment". Steps run in LIFO order, so the most
+// recently added step is undone first, mirroring the dependency order
+// objects were likely created in.
+func (t *RollbackTracker) Add(name string, undo func(ctx context.Context) error) {
+	t.undos = append(t.undos, rollbackStep{name: name, undo: undo})
+}
+
+// Commit discards every step added so far, so a later call to Run is a
+// no-op. Call it once a provisioning attempt has fully succeeded and the
+// objects it created should be kept.
+func (t *RollbackTracker) Commit() {
+	t.undos = nil
+}
+
+// Run undoes every step added so far, most recently added first, and then
+// discards them so a second Run (or a Commit) is a no-op. A step's undo
+// failing doesn't stop the rest from running, since one broken teardown
+// shouldn't orphan everything before it; each failure is logged as it
+// happens and also collected into the returned error. That returned error
+// is for the caller to log alongside the failure that triggered the
+// rollback - it should never replace that original error, since a broken
+// teardown is not why the provisioning attempt failed.
+func (t *RollbackTracker) Run(ctx context.Context) error {
+	undos := t.undos
+	t.undos = nil
+
+	var failures []string
+	for i := len(undos) - 1; i >= 0; i-- {
+		step := undos[i]
+		glog.Infof("rollback: undoing %s", step.name)
+		if err := step.undo(ctx); err != nil {
+			glog.Errorf("rollback: %s: %v", step.name, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", step.name, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("rollback: %d of %d step(s) failed: %s", len(failures), len(undos), strings.Join(failures, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRollbackTrackerRunUndoesInReverseOrder(t *testing.T) {
+	var order []int
+	var tracker RollbackTracker
+	for i := 0; i < 3; i++ {
+		i := i
+		tracker.Add(fmt.Sprintf("step %d", i), func(ctx context.Context) error {
+			order = append(order, i)
+			return nil
+		})
+	}
+
+	if err := tracker.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if want := []int{2, 1, 0}; !reflect.DeepEqual(order, want) {
+		t.Errorf("undo order = %v, want %v", order, want)
+	}
+}
+
+func TestRollbackTrackerRunWithNoStepsIsANoOp(t *testing.T) {
+	var tracker RollbackTracker
+	if err := tracker.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func TestRollbackTrackerRunContinuesAfterPartialFailure(t *testing.T) {
+	var ran []string
+	var tracker RollbackTracker
+	tracker.Add("first", func(ctx context.Context) error {
+		ran = append(ran, "first")
+		return nil
+	})
+	tracker.Add("second", func(ctx context.Context) error {
+		ran = append(ran, "second")
+		return fmt.Errorf("boom")
+	})
+	tracker.Add("third", func(ctx context.Context) error {
+		ran = append(ran, "third")
+		return nil
+	})
+
+	err := tracker.Run(context.Background())
+	if err == nil {
+		t.Fatal("expected Run to report the failed undo step")
+	}
+
+	want := []string{"third", "second", "first"}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran = %v, want %v (a failed undo must not stop the rest)", ran, want)
+	}
+}
+
+func TestRollbackTrackerRunIsSafeToCallTwice(t *testing.T) {
+	calls := 0
+	var tracker RollbackTracker
+	tracker.Add("only", func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+
+	if err := tracker.Run(context.Background()); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	if err := tracker.Run(context.Background()); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (a second Run must not undo again)", calls)
+	}
+}
+
+func TestRollbackTrackerCommitDiscardsSteps(t *testing.T) {
+	calls := 0
+	var tracker RollbackTracker
+	tracker.Add("only", func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+
+	tracker.Commit()
+	if err := tracker.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("calls = %d, want 0 (Commit should discard the step)", calls)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	routeAPIVersion = "route.openshift.io/v1"
+	routeKind       = "Route"
+)
+
+// routeResource describes the Route API to the dynamic client: OpenShift's
+// generated Route clientset isn't vendored here, so a PlatformOpenShift
+// broker talks to it the same way it would talk to any other API this repo
+// doesn't have generated types for.
+var routeResource = metav1.APIResource{Name: "routes", Kind: routeKind, Namespaced: true}
+
+// RouteClient creates, lists, and deletes OpenShift Route objects for a
+// broker configured with PlatformOpenShift. It's implemented by
+// dynamicRouteClient against a real cluster, and by a hand-rolled fake in
+// tests, so callers exercise the same provision/deprovision code paths
+// either way.
+type RouteClient interface {
+	Create(namespace string, route *unstructured.Unstructured) (*unstructured.Unstructured, error)
+	List(namespace string, opts metav1.ListOptions) (*unstructured.UnstructuredList, error)
+	Delete(namespace, name string) error
+	DeleteCollection(namespace string, opts metav1.ListOptions) error
+}
+
+// dynamicRouteClient implements RouteClient against a real cluster's
+// route.openshift.io/v1 API, via client-go's dynamic client rather than a
+// generated OpenShift clientset.
+type dynamicRouteClient struct {
+	client *dynamic.Client
+}
+
+// NewRouteClient builds a RouteClient talking to config's cluster. It
+// doesn't itself verify the Route API group is served; a broker pointed at
+// a plain Kubernetes cluster instead finds out on its first Route call,
+// as a "the server could not find the requested resource" error.
+func NewRouteClient(config *rest.Config) (RouteClient, error) {
+	routeConfig := *config
+	routeConfig.GroupVersion = &schema.GroupVersion{Group: "route.openshift.io", Version: "v1"}
+	routeConfig.APIPath = "/apis"
+	client, err := dynamic.NewClient(&routeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build route client: %v", err)
+	}
+	return &dynamicRouteClient{client: client}, nil
+}
+
+func (r *dynamicRouteClient) Create(namespace string, route *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return r.client.Resource(&routeResource, namespace).Create(route)
+}
+
+func (r *dynamicRouteClient) Delete(namespace, name string) error {
+	return r.client.Resource(&routeResource, namespace).Delete(name, nil)
+}
+
+func (r *dynamicRouteClient) List(namespace string, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	obj, err := r.client.Resource(&routeResource, namespace).List(opts)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := obj.(*unstructured.UnstructuredList)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T listing routes", obj)
+	}
+	return list, nil
+}
+
+func (r *dynamicRouteClient) DeleteCollection(namespace string, opts metav1.ListOptions) error {
+	return r.client.Resource(&routeResource, namespace).DeleteCollection(nil, opts)
+}
+
+// NewRoute builds the unstructured Route object exposing serviceName's port
+// at host, labeled with labels and stamped with annotations. Callers create
+// it via a RouteClient the same way they'd create any other object with a
+// typed clientset.
+func NewRoute(name, namespace, host, serviceName string, port int32, labels, annotations map[string]string) *unstructured.Unstructured {
+	labelsField := make(map[string]interface{}, len(labels))
+	for k, v := range labels {
+		labelsField[k] = v
+	}
+	annotationsField := make(map[string]interface{}, len(annotations))
+	for k, v := range annotations {
+		annotationsField[k] = v
+	}
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": routeAPIVersion,
+			"kind":       routeKind,
+			"metadata": map[string]interface{}{
+				"name":        name,
+				"namespace":   namespace,
+				"labels":      labelsField,
+				"annotations": annotationsField,
+			},
+			"spec": map[string]interface{}{
+				"host": host,
+				"to": map[string]interface{}{
+					"kind": "Service",
+					"name": serviceName,
+				},
+				"port": map[string]interface{}{
+					"targetPort": port,
+				},
+			},
+		},
+	}
+}
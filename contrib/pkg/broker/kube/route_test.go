@@ -0,0 +1,126 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNewRouteSetsHostAndBackend(t *testing.T) {
+	route := NewRoute("myroute", "default", "app.example.com", "app-svc", 8080, map[string]string{"broker": "nginx"}, map[string]string{"service-catalog.k8s.io/correlation-id": "corr-1"})
+
+	if route.GetName() != "myroute" || route.GetNamespace() != "default" {
+		t.Fatalf("unexpected object metadata: %+v", route.Object["metadata"])
+	}
+	if route.GetKind() != routeKind || route.GetAPIVersion() != routeAPIVersion {
+		t.Fatalf("unexpected type meta: kind=%s apiVersion=%s", route.GetKind(), route.GetAPIVersion())
+	}
+	if route.GetLabels()["broker"] != "nginx" {
+		t.Errorf("labels = %v, want broker=nginx", route.GetLabels())
+	}
+	if route.GetAnnotations()["service-catalog.k8s.io/correlation-id"] != "corr-1" {
+		t.Errorf("annotations = %v, want service-catalog.k8s.io/correlation-id=corr-1", route.GetAnnotations())
+	}
+
+	spec, ok := route.Object["spec"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("spec is %T, want map[string]interface{}", route.Object["spec"])
+	}
+	if spec["host"] != "app.example.com" {
+		t.Errorf("spec.host = %v, want app.example.com", spec["host"])
+	}
+	to, ok := spec["to"].(map[string]interface{})
+	if !ok || to["name"] != "app-svc" || to["kind"] != "Service" {
+		t.Errorf("spec.to = %v, want a Service named app-svc", spec["to"])
+	}
+}
+
+// fakeRouteClient is an in-memory RouteClient used in place of a real
+// dynamic client, since no OpenShift cluster (or fake for one) is available
+// in tests.
+type fakeRouteClient struct {
+	routes map[string]*unstructured.Unstructured
+}
+
+func newFakeRouteClient() *fakeRouteClient {
+	return &fakeRouteClient{routes: make(map[string]*unstructured.Unstructured)}
+}
+
+func (f *fakeRouteClient) key(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+func (f *fakeRouteClient) Create(namespace string, route *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	f.routes[f.key(namespace, route.GetName())] = route
+	return route, nil
+}
+
+func (f *fakeRouteClient) List(namespace string, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{}
+	for _, route := range f.routes {
+		if route.GetNamespace() == namespace {
+			list.Items = append(list.Items, *route)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeRouteClient) Delete(namespace, name string) error {
+	key := f.key(namespace, name)
+	if _, ok := f.routes[key]; !ok {
+		return fmt.Errorf("route %s not found", key)
+	}
+	delete(f.routes, key)
+	return nil
+}
+
+func (f *fakeRouteClient) DeleteCollection(namespace string, opts metav1.ListOptions) error {
+	for key, route := range f.routes {
+		if route.GetNamespace() == namespace {
+			delete(f.routes, key)
+		}
+	}
+	return nil
+}
+
+func TestFakeRouteClientRoundTrip(t *testing.T) {
+	var client RouteClient = newFakeRouteClient()
+
+	route := NewRoute("myroute", "default", "app.example.com", "app-svc", 8080, nil, nil)
+	if _, err := client.Create("default", route); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	list, err := client.List("default", metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("len(list.Items) = %d, want 1", len(list.Items))
+	}
+
+	if err := client.Delete("default", "myroute"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := client.Delete("default", "myroute"); err == nil {
+		t.Error("expected an error deleting an already-deleted route")
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ghodss/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// PodSpecOverride holds the subset of a provisioned pod's shape an operator
+// can tune via a --templates-dir YAML file without recompiling the broker.
+// It intentionally doesn't expose the whole PodSpec, so a template can't
+// accidentally clobber the fields a provisioner depends on, such as the
+// instance container's name, image, ports, or volumes.
+type PodSpecOverride struct {
+	// Resources replaces the resource requests/limits of the instance's
+	// main container.
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
+
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	Tolerations  []v1.Toleration   `json:"tolerations,omitempty"`
+
+	// Annotations are merged onto the pod template's own, with these
+	// values winning on a key collision.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// LoadPodSpecOverride reads and parses the YAML template at path. It
+// returns a nil override, and no error, when path doesn't exist, so a
+// caller falls back to its built-in pod shape instead of failing
+// provisioning. Any other read or parse error is returned so CreateController
+// can fail the broker at startup instead of at first provision.
+func LoadPodSpecOverride(path string) (*PodSpecOverride, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pod template %s: %v", path, err)
+	}
+	var override PodSpecOverride
+	if err := yaml.Unmarshal(data, &override); err != nil {
+		return nil, fmt.Errorf("failed to parse pod template %s: %v", path, err)
+	}
+	return &override, nil
+}
+
+// ApplyPodSpecOverride merges a non-nil override onto spec and meta. It's
+// meant to run after the caller has built its normal pod shape, so a
+// template can tune it without the provisioner itself needing to know
+// templates exist. A nil override is a no-op.
+func ApplyPodSpecOverride(spec *v1.PodSpec, meta *metav1.ObjectMeta, override *PodSpecOverride) {
+	if override == nil {
+		return
+	}
+	if override.Resources != nil && len(spec.Containers) > 0 {
+		spec.Containers[0].Resources = *override.Resources
+	}
+	if override.NodeSelector != nil {
+		spec.NodeSelector = override.NodeSelector
+	}
+	if override.Tolerations != nil {
+		spec.Tolerations = override.Tolerations
+	}
+	if len(override.Annotations) > 0 {
+		if meta.Annotations == nil {
+			meta.Annotations = map[string]string{}
+		}
+		for k, v := range override.Annotations {
+			meta.Annotations[k] = v
+		}
+	}
+}
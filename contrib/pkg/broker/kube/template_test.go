@@ -0,0 +1,137 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestLoadPodSpecOverrideReturnsNilWhenFileIsAbsent(t *testing.T) {
+	override, err := LoadPodSpecOverride(filepath.Join(os.TempDir(), "does-not-exist.yaml"))
+	if err != nil {
+		t.Fatalf("LoadPodSpecOverride: %v", err)
+	}
+	if override != nil {
+		t.Errorf("override = %+v, want nil", override)
+	}
+}
+
+func TestLoadPodSpecOverrideRejectsMalformedYAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-template-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pod.yaml")
+	if err := ioutil.WriteFile(path, []byte("not: [valid"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := LoadPodSpecOverride(path); err == nil {
+		t.Fatal("expected an error for malformed YAML")
+	}
+}
+
+func TestLoadPodSpecOverrideParsesFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-template-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pod.yaml")
+	contents := `
+nodeSelector:
+  disktype: ssd
+annotations:
+  team: storage
+resources:
+  requests:
+    cpu: "250m"
+`
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	override, err := LoadPodSpecOverride(path)
+	if err != nil {
+		t.Fatalf("LoadPodSpecOverride: %v", err)
+	}
+	if override == nil {
+		t.Fatal("override = nil, want a parsed override")
+	}
+	if got, want := override.NodeSelector["disktype"], "ssd"; got != want {
+		t.Errorf("NodeSelector[disktype] = %q, want %q", got, want)
+	}
+	if got, want := override.Annotations["team"], "storage"; got != want {
+		t.Errorf("Annotations[team] = %q, want %q", got, want)
+	}
+	if override.Resources == nil {
+		t.Fatal("Resources = nil, want a parsed ResourceRequirements")
+	}
+	if got, want := override.Resources.Requests.Cpu().String(), "250m"; got != want {
+		t.Errorf("Resources.Requests[cpu] = %q, want %q", got, want)
+	}
+}
+
+func TestApplyPodSpecOverrideMergesOntoBuiltInShape(t *testing.T) {
+	spec := &v1.PodSpec{
+		Containers: []v1.Container{{Name: "instance", Image: "example:latest"}},
+	}
+	meta := &metav1.ObjectMeta{Name: "instance-1"}
+
+	override := &PodSpecOverride{
+		NodeSelector: map[string]string{"disktype": "ssd"},
+		Annotations:  map[string]string{"team": "storage"},
+		Resources: &v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("250m")},
+		},
+	}
+	ApplyPodSpecOverride(spec, meta, override)
+
+	if got, want := spec.NodeSelector["disktype"], "ssd"; got != want {
+		t.Errorf("NodeSelector[disktype] = %q, want %q", got, want)
+	}
+	if got, want := meta.Annotations["team"], "storage"; got != want {
+		t.Errorf("Annotations[team] = %q, want %q", got, want)
+	}
+	if got, want := spec.Containers[0].Resources.Requests.Cpu().String(), "250m"; got != want {
+		t.Errorf("Containers[0].Resources.Requests[cpu] = %q, want %q", got, want)
+	}
+	if spec.Containers[0].Name != "instance" {
+		t.Errorf("Containers[0].Name = %q, want unchanged %q", spec.Containers[0].Name, "instance")
+	}
+}
+
+func TestApplyPodSpecOverrideIsNoOpWhenNil(t *testing.T) {
+	spec := &v1.PodSpec{Containers: []v1.Container{{Name: "instance"}}}
+	meta := &metav1.ObjectMeta{Name: "instance-1"}
+
+	ApplyPodSpecOverride(spec, meta, nil)
+
+	if len(spec.Containers) != 1 || spec.Containers[0].Name != "instance" {
+		t.Errorf("spec was mutated by a nil override: %+v", spec)
+	}
+}
@@ -0,0 +1,29 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import "time"
+
+// DefaultProvisionTimeout bounds how long a provision or deprovision
+// operation runs before its context is canceled, used when a controller
+// isn't configured with its own value.
+const DefaultProvisionTimeout = 2 * time.Minute
+
+// DefaultBindTimeout bounds how long a bind or unbind operation runs before
+// its context is canceled, used when a controller isn't configured with its
+// own value.
+const DefaultBindTimeout = 30 * time.Second
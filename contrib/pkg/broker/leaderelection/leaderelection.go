@@ -0,0 +1,185 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package leaderelection lets multiple userbroker replicas run against the
+// same Kubernetes namespace while only one of them serves mutating OSB
+// operations at a time, using a client-go Endpoints lease the same way
+// cmd/controller-manager does.
+package leaderelection
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	internalclientset "k8s.io/kubernetes/pkg/client/clientset_generated/clientset"
+	kubeleaderelection "k8s.io/kubernetes/pkg/client/leaderelection"
+	"k8s.io/kubernetes/pkg/client/leaderelection/resourcelock"
+)
+
+// Elector reports whether the local process currently holds the leader
+// lease. Server handlers consult it before serving a mutating OSB request.
+type Elector interface {
+	IsLeader() bool
+}
+
+// lockNamePrefix, followed by the service name, names the Endpoints object the userbroker replicas contend for. It
+// is scoped by --service, since each --service value runs as an
+// independent broker with its own instance state.
+const lockNamePrefix = "service-catalog-userbroker-"
+
+// Config configures a LeaseElector.
+type Config struct {
+	// KubeconfigPath is resolved the same way as the broker's own client:
+	// in-cluster config first, then this path or $KUBECONFIG.
+	KubeconfigPath string
+
+	// KubeContext selects a context by name from KubeconfigPath, instead of
+	// its current-context. Empty leaves the kubeconfig's own choice in
+	// place; a non-empty value skips the in-cluster configuration and loads
+	// the kubeconfig even when running in-cluster.
+	KubeContext string
+
+	// Namespace is the namespace the Endpoints lock lives in. Typically the
+	// broker's own namespace.
+	Namespace string
+
+	// Service names the broker (--service) so replicas running different
+	// services don't contend for the same lock.
+	Service string
+
+	// Identity distinguishes this replica from others contending for the
+	// same lock, and is recorded as the lease holder. Defaults to "unknown"
+	// if empty.
+	Identity string
+
+	// LeaseDuration, RenewDeadline, and RetryPeriod tune the election the
+	// same way they do for cmd/controller-manager. Zero keeps client-go's
+	// built-in defaults.
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+
+	// OnStartedLeading and OnStoppedLeading, if set, run in addition to the
+	// bookkeeping IsLeader relies on, e.g. to reload state from the
+	// persistence backend on a leadership change.
+	OnStartedLeading func()
+	OnStoppedLeading func()
+}
+
+// LeaseElector contests leadership of an Endpoints lock and reports whether
+// this replica currently holds it. The underlying Lease machinery is the
+// same one cmd/controller-manager relies on and is exercised against a real
+// apiserver there; this package's own tests cover the config validation.
+type LeaseElector struct {
+	elector *kubeleaderelection.LeaderElector
+}
+
+// New builds a LeaseElector from cfg. It does not start contesting
+// leadership; call Run to begin.
+func New(cfg Config) (*LeaseElector, error) {
+	restConfig, err := kube.RestConfig(cfg.KubeconfigPath, cfg.KubeContext)
+	if err != nil {
+		return nil, err
+	}
+	client, err := internalclientset.NewForConfig(rest.AddUserAgent(restConfig, "leader-election"))
+	if err != nil {
+		return nil, err
+	}
+	eventsClient, err := kubernetes.NewForConfig(rest.AddUserAgent(restConfig, "leader-election"))
+	if err != nil {
+		return nil, err
+	}
+
+	identity := cfg.Identity
+	if identity == "" {
+		identity = "unknown"
+	}
+
+	recorder := kube.NewEventRecorder(eventsClient, "userbroker-"+cfg.Service)
+
+	lock := &resourcelock.EndpointsLock{
+		EndpointsMeta: metav1.ObjectMeta{
+			Namespace: cfg.Namespace,
+			Name:      lockNamePrefix + cfg.Service,
+		},
+		Client: client.Core(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity:      identity,
+			EventRecorder: recorder,
+		},
+	}
+
+	le := &LeaseElector{}
+	elector, err := kubeleaderelection.NewLeaderElector(kubeleaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: orDefault(cfg.LeaseDuration, kubeleaderelection.DefaultLeaseDuration),
+		RenewDeadline: orDefault(cfg.RenewDeadline, kubeleaderelection.DefaultRenewDeadline),
+		RetryPeriod:   orDefault(cfg.RetryPeriod, kubeleaderelection.DefaultRetryPeriod),
+		Callbacks: kubeleaderelection.LeaderCallbacks{
+			OnStartedLeading: func(stop <-chan struct{}) {
+				glog.Infof("%s: started leading %s/%s", identity, cfg.Namespace, lock.EndpointsMeta.Name)
+				if cfg.OnStartedLeading != nil {
+					cfg.OnStartedLeading()
+				}
+			},
+			OnStoppedLeading: func() {
+				glog.Infof("%s: stopped leading %s/%s", identity, cfg.Namespace, lock.EndpointsMeta.Name)
+				if cfg.OnStoppedLeading != nil {
+					cfg.OnStoppedLeading()
+				}
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	le.elector = elector
+	return le, nil
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// Run contests leadership until stop is closed. A lost or never-won
+// election is retried rather than treated as fatal, since a userbroker
+// replica that isn't leading still has followers to serve read-only OSB
+// calls; Run keeps re-contesting so this replica can take over if the
+// current leader goes away.
+func (l *LeaseElector) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+			l.elector.Run()
+		}
+	}
+}
+
+// IsLeader reports whether this replica currently holds the lease.
+func (l *LeaseElector) IsLeader() bool {
+	return l.elector.IsLeader()
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leaderelection
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+const fakeKubeconfig = `
+apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://example.invalid:6443
+  name: test-cluster
+contexts:
+- context:
+    cluster: test-cluster
+    user: test-user
+  name: test-context
+current-context: test-context
+users:
+- name: test-user
+  user:
+    token: fake-token
+`
+
+func writeFakeKubeconfig(t *testing.T) string {
+	f, err := ioutil.TempFile("", "kubeconfig")
+	if err != nil {
+		t.Fatalf("failed to create temp kubeconfig: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(fakeKubeconfig); err != nil {
+		t.Fatalf("failed to write temp kubeconfig: %v", err)
+	}
+	return f.Name()
+}
+
+func TestOrDefaultKeepsPositiveValue(t *testing.T) {
+	if got := orDefault(5*time.Second, 10*time.Second); got != 5*time.Second {
+		t.Errorf("orDefault = %v, want 5s", got)
+	}
+}
+
+func TestOrDefaultFallsBackWhenZeroOrNegative(t *testing.T) {
+	if got := orDefault(0, 10*time.Second); got != 10*time.Second {
+		t.Errorf("orDefault(0) = %v, want 10s", got)
+	}
+	if got := orDefault(-time.Second, 10*time.Second); got != 10*time.Second {
+		t.Errorf("orDefault(-1s) = %v, want 10s", got)
+	}
+}
+
+// TestNewRejectsLeaseDurationNotLongerThanRenewDeadline exercises New's
+// config validation. It doesn't touch the network: outside a cluster with
+// no in-cluster config available, New falls back to the temp kubeconfig
+// below, and NewLeaderElector rejects the durations before any API call.
+func TestNewRejectsLeaseDurationNotLongerThanRenewDeadline(t *testing.T) {
+	path := writeFakeKubeconfig(t)
+	defer os.Remove(path)
+
+	_, err := New(Config{
+		KubeconfigPath: path,
+		Namespace:      "default",
+		Service:        "mongodb",
+		LeaseDuration:  5 * time.Second,
+		RenewDeadline:  5 * time.Second,
+		RetryPeriod:    time.Second,
+	})
+	if err == nil {
+		t.Fatal("expected an error when LeaseDuration does not exceed RenewDeadline")
+	}
+}
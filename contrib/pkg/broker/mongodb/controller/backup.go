@@ -0,0 +1,174 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	batchv1 "k8s.io/client-go/pkg/apis/batch/v1"
+	batchv2alpha1 "k8s.io/client-go/pkg/apis/batch/v2alpha1"
+)
+
+const backupImage = "mongo:latest"
+
+// backupVolumeSize is the size requested for the dedicated backup PVC.
+const backupVolumeSize = "1Gi"
+
+// validateCronSchedule performs a light-weight sanity check of a standard
+// five-field cron expression, since the vanilla Kubernetes CronJob API does
+// not validate the schedule string until a Job actually fails to schedule.
+func validateCronSchedule(schedule string) error {
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return fmt.Errorf("expected a 5 field cron expression, got %q", schedule)
+	}
+	return nil
+}
+
+// createBackupCronJobIfRequested creates the backup CronJob and its backing
+// PVC when the request carries a backupSchedule parameter. It returns the
+// name of the created CronJob, or "" if none was requested.
+func (c *mongodbController) createBackupCronJobIfRequested(client kubernetes.Interface, namespace, instanceID string, req *brokerapi.CreateServiceInstanceRequest) (string, error) {
+	v, ok := req.Parameters["backupSchedule"]
+	if !ok {
+		return "", nil
+	}
+	schedule, ok := v.(string)
+	if !ok || schedule == "" {
+		return "", nil
+	}
+
+	if req.PlanID != planPersistent {
+		return "", fmt.Errorf("backupSchedule is only supported on the persistent plan")
+	}
+	if err := validateCronSchedule(schedule); err != nil {
+		return "", fmt.Errorf("invalid backupSchedule: %v", err)
+	}
+
+	backupPVCName := names.InstanceResourceName(brokerName, instanceID, "backup")
+	size, err := resource.ParseQuantity(backupVolumeSize)
+	if err != nil {
+		return "", fmt.Errorf("invalid backup volume size %q: %v", backupVolumeSize, err)
+	}
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupPVCName,
+			Namespace: namespace,
+			Labels:    map[string]string{kube.InstanceLabelKey(): instanceID},
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: size},
+			},
+		},
+	}
+	if _, err := client.Core().PersistentVolumeClaims(namespace).Create(pvc); err != nil {
+		return "", fmt.Errorf("failed to create backup volume claim: %v", err)
+	}
+
+	cronJobName := names.InstanceResourceName(brokerName, instanceID, "backup")
+	cronJob := &batchv2alpha1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cronJobName,
+			Namespace: namespace,
+			Labels:    map[string]string{kube.InstanceLabelKey(): instanceID},
+		},
+		Spec: batchv2alpha1.CronJobSpec{
+			Schedule: schedule,
+			JobTemplate: batchv2alpha1.JobTemplateSpec{
+				Spec: backupJobSpec(instanceID, namespace, backupPVCName),
+			},
+		},
+	}
+	if _, err := client.BatchV2alpha1().CronJobs(namespace).Create(cronJob); err != nil {
+		return "", fmt.Errorf("failed to create backup CronJob: %v", err)
+	}
+	return cronJobName, nil
+}
+
+func backupJobSpec(instanceID, namespace, backupPVCName string) batchv1.JobSpec {
+	return batchv1.JobSpec{
+		Template: v1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{kube.InstanceLabelKey(): instanceID}},
+			Spec: v1.PodSpec{
+				RestartPolicy: v1.RestartPolicyOnFailure,
+				Containers: []v1.Container{
+					{
+						Name:    "mongodump",
+						Image:   backupImage,
+						Command: []string{"mongodump"},
+						Args: []string{
+							fmt.Sprintf("--host=mongodb-%s.%s.svc", instanceID, namespace),
+							"--out=/backup",
+						},
+						VolumeMounts: []v1.VolumeMount{{Name: "backup", MountPath: "/backup"}},
+					},
+				},
+				Volumes: []v1.Volume{
+					{
+						Name: "backup",
+						VolumeSource: v1.VolumeSource{
+							PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: backupPVCName},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// deleteBackupResources removes the CronJob and, unless RetainBackups is
+// set, the backup PVC created for instance.
+func deleteBackupResources(client kubernetes.Interface, instance *mongodbServiceInstance) error {
+	if err := client.BatchV2alpha1().CronJobs(instance.Namespace).Delete(instance.BackupCronJobName, nil); err != nil {
+		return fmt.Errorf("failed to delete backup CronJob: %v", err)
+	}
+	if instance.RetainBackups {
+		return nil
+	}
+	backupPVCName := names.InstanceResourceName(brokerName, instance.ID, "backup")
+	if err := client.Core().PersistentVolumeClaims(instance.Namespace).Delete(backupPVCName, nil); err != nil {
+		return fmt.Errorf("failed to delete backup volume claim: %v", err)
+	}
+	return nil
+}
+
+// lastSuccessfulBackup reads the CronJob's status for the timestamp of its
+// most recently scheduled run, for surfacing through instance status.
+func lastSuccessfulBackup(client kubernetes.Interface, instance *mongodbServiceInstance) (string, error) {
+	if instance.BackupCronJobName == "" {
+		return "", nil
+	}
+	job, err := client.BatchV2alpha1().CronJobs(instance.Namespace).Get(instance.BackupCronJobName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up backup CronJob: %v", err)
+	}
+	if job.Status.LastScheduleTime == nil {
+		return "", nil
+	}
+	return job.Status.LastScheduleTime.String(), nil
+}
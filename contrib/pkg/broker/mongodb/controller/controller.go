@@ -0,0 +1,754 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package controller implements a broker that provisions MongoDB instances
+// as pods in the cluster the broker is running in.
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/reqlog"
+	"github.com/kubernetes-incubator/service-catalog/pkg"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// brokerName identifies this broker in its Kubernetes client's UserAgent.
+const brokerName = "mongodb"
+
+const (
+	serviceID = "a2b7b7d1-8b3f-4b3f-9b3f-1c3d5e7f9a1b"
+
+	planEphemeral  = "e5e5e5e5-5e5e-5e5e-5e5e-5e5e5e5e5e5e"
+	planPersistent = "f6f6f6f6-6f6f-6f6f-6f6f-6f6f6f6f6f6f"
+	planHA         = "07070707-0707-0707-0707-070707070707"
+
+	// planID is retained as an alias of planEphemeral for backwards
+	// compatibility with the original single-plan catalog.
+	planID = planEphemeral
+
+	mongoImage        = "mongo:latest"
+	mongoPort         = 27017
+	dataVolumeName    = "mongo-data"
+	dataMountPath     = "/data/db"
+	defaultVolumeSize = "1Gi"
+)
+
+// defaultNamespace is used when the request does not carry a context
+// profile namespace.
+const defaultNamespace = "default"
+
+// defaultServiceAccount names the service account this controller reports
+// itself running as when Options.ServiceAccount is unset.
+const defaultServiceAccount = "default"
+
+type mongodbServiceInstance struct {
+	ID        string
+	Namespace string
+	PodName   string
+	PlanID    string
+
+	PVCName string
+
+	// BackupCronJobName is set when a backupSchedule parameter was
+	// provided at provision time.
+	BackupCronJobName string
+	RetainBackups     bool
+
+	// StatefulSetName and PDBName are set for the ha plan.
+	StatefulSetName string
+	PDBName         string
+
+	// CreatedAt is when this instance was provisioned, reported through
+	// SnapshotState for age-based metrics and admin output.
+	CreatedAt time.Time
+}
+
+type mongodbController struct {
+	rwMutex sync.RWMutex
+
+	// imagePullSecret, when set, names a secret (in brokerNamespace) to
+	// use for pulling the mongo image on every instance that does not
+	// override it via the imagePullSecret parameter.
+	imagePullSecret string
+
+	// brokerNamespace is the namespace this broker's own pod runs in,
+	// where imagePullSecret is looked up.
+	brokerNamespace string
+
+	// dialHealthChecks enables an active TCP dial to the instance's mongo
+	// port as part of checkInstanceHealth.
+	dialHealthChecks bool
+
+	// allowedNamespaces restricts which namespaces a namespace provision
+	// parameter override may target. Empty means unrestricted.
+	allowedNamespaces map[string]bool
+
+	// defaultNamespace is used for a request whose context carries no
+	// namespace, when allowDefaultNamespace permits it.
+	defaultNamespace string
+
+	// allowDefaultNamespace gates falling back to defaultNamespace for a
+	// request that names no namespace, instead of rejecting it.
+	allowDefaultNamespace bool
+
+	// namespacePerInstance provisions every instance into a dedicated
+	// namespace this controller creates and owns, instead of the requesting
+	// namespace.
+	namespacePerInstance bool
+
+	// serviceAccount is the name of the service account this controller
+	// runs as, used only to name it in a kube.ErrForbidden message when the
+	// Kubernetes API rejects a request as forbidden.
+	serviceAccount string
+
+	// kubeClient is built once, at construction, and reused for every
+	// request instead of dialing a fresh clientset per call.
+	kubeClient kubernetes.Interface
+
+	// recorder publishes Kubernetes Events for instance lifecycle
+	// transitions, so an operator running `kubectl describe` on an
+	// instance's pod sees why it failed without digging through the
+	// broker's own logs.
+	recorder record.EventRecorder
+
+	// retryAttempts and retryBaseDelay configure kube.Retry for the calls
+	// this controller makes against the Kubernetes API.
+	retryAttempts  int
+	retryBaseDelay time.Duration
+
+	// provisionTimeout and bindTimeout bound how long a provision/deprovision
+	// or bind/unbind operation's context runs before it is canceled.
+	provisionTimeout time.Duration
+	bindTimeout      time.Duration
+
+	// podSpecOverride, when non-nil, is applied on top of every instance
+	// pod's shape, loaded once at construction from Options.TemplatesDir.
+	podSpecOverride *kube.PodSpecOverride
+
+	instanceMap map[string]*mongodbServiceInstance
+	healthCache map[string]*instanceHealth
+}
+
+// Options configures the MongoDB broker controller.
+type Options struct {
+	// ImagePullSecret is the name of a secret, in BrokerNamespace, used by
+	// default to pull the mongo image.
+	ImagePullSecret string
+
+	// BrokerNamespace is the namespace this broker's own pod runs in,
+	// where ImagePullSecret is looked up. Defaults to $POD_NAMESPACE, or
+	// "default" if that isn't set either.
+	BrokerNamespace string
+
+	// DialHealthChecks enables an active TCP dial to the instance's mongo
+	// port when reporting instance health.
+	DialHealthChecks bool
+
+	// AllowedNamespaces restricts which namespaces a namespace provision
+	// parameter override may target. Empty means unrestricted.
+	AllowedNamespaces []string
+
+	// DefaultNamespace is used for a request whose context carries no
+	// namespace, when AllowDefaultNamespace permits it. Defaults to
+	// "default".
+	DefaultNamespace string
+
+	// AllowDefaultNamespace lets a request that names no namespace fall
+	// back to DefaultNamespace, instead of being rejected.
+	AllowDefaultNamespace bool
+
+	// ServiceAccount is the name of the service account this broker runs
+	// as. It is used only to name it in an error naming the RBAC a
+	// forbidden request is missing; it is not used to authenticate.
+	// Defaults to "default".
+	ServiceAccount string
+
+	// PreflightDryRun has no effect beyond a one-time warning logged by
+	// CreateController: this broker's Kubernetes client cannot submit a
+	// dry-run create, so no preflight check runs and an admission webhook
+	// rejection still surfaces only on the real create.
+	PreflightDryRun bool
+
+	// NamespacePerInstance provisions every instance's pod into a dedicated
+	// namespace this controller creates and owns, instead of the requesting
+	// namespace, so quota and RBAC scoped to that namespace apply to exactly
+	// one instance. AllowedNamespaces and DefaultNamespace still gate the
+	// requesting namespace the request came in on.
+	NamespacePerInstance bool
+
+	// KubeconfigPath, when set, is used to build the Kubernetes client if
+	// the broker is not running in-cluster, for local development.
+	KubeconfigPath string
+
+	// KubeContext selects a context by name from the kubeconfig at
+	// KubeconfigPath, instead of its current-context. Setting it skips the
+	// in-cluster configuration, so the kubeconfig is loaded even in-cluster.
+	KubeContext string
+
+	// KubeAPIQPS and KubeAPIBurst cap the rate of Kubernetes API calls this
+	// broker's client makes, easing apiserver load in a large cluster.
+	// Zero keeps client-go's built-in defaults.
+	KubeAPIQPS   float32
+	KubeAPIBurst int
+
+	// RetryAttempts is the number of times a Kubernetes API call is
+	// attempted before giving up on a transient error. Defaults to
+	// kube.DefaultRetryAttempts.
+	RetryAttempts int
+
+	// RetryBaseDelay is the delay before the first retry of a Kubernetes
+	// API call that failed with a transient error, doubling on each
+	// subsequent retry. Defaults to kube.DefaultRetryBaseDelay.
+	RetryBaseDelay time.Duration
+
+	// ProvisionTimeout bounds how long a provision or deprovision operation
+	// runs before its context is canceled. Defaults to
+	// kube.DefaultProvisionTimeout.
+	ProvisionTimeout time.Duration
+
+	// BindTimeout bounds how long a bind or unbind operation runs before its
+	// context is canceled. Defaults to kube.DefaultBindTimeout.
+	BindTimeout time.Duration
+
+	// TemplatesDir, when set, is checked for a mongodb-pod.yaml overriding
+	// the resource requests/limits, node selector, tolerations, and
+	// annotations of every instance's pod, without requiring a rebuild of
+	// the broker to tune them. A missing file falls back to the built-in
+	// shape; a malformed one fails CreateController.
+	TemplatesDir string
+}
+
+// CreateController creates an instance of a MongoDB service broker
+// controller, building its Kubernetes client up front so a broken
+// in-cluster config or kubeconfig fails the broker at startup instead of
+// on its first request.
+func CreateController(opts Options) (controller.Controller, error) {
+	client, err := kube.NewClient(opts.KubeconfigPath, kube.ClientOptions{
+		Context:   opts.KubeContext,
+		QPS:       opts.KubeAPIQPS,
+		Burst:     opts.KubeAPIBurst,
+		UserAgent: fmt.Sprintf("%s/%s", brokerName, pkg.VERSION),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[string]bool, len(opts.AllowedNamespaces))
+	for _, ns := range opts.AllowedNamespaces {
+		allowed[ns] = true
+	}
+	defaultNS := opts.DefaultNamespace
+	if defaultNS == "" {
+		defaultNS = defaultNamespace
+	}
+	serviceAccount := opts.ServiceAccount
+	if serviceAccount == "" {
+		serviceAccount = defaultServiceAccount
+	}
+	if opts.PreflightDryRun {
+		kube.WarnPreflightDryRunUnsupported()
+	}
+	retryAttempts := opts.RetryAttempts
+	if retryAttempts <= 0 {
+		retryAttempts = kube.DefaultRetryAttempts
+	}
+	retryBaseDelay := opts.RetryBaseDelay
+	if retryBaseDelay <= 0 {
+		retryBaseDelay = kube.DefaultRetryBaseDelay
+	}
+	provisionTimeout := opts.ProvisionTimeout
+	if provisionTimeout <= 0 {
+		provisionTimeout = kube.DefaultProvisionTimeout
+	}
+	bindTimeout := opts.BindTimeout
+	if bindTimeout <= 0 {
+		bindTimeout = kube.DefaultBindTimeout
+	}
+	var podSpecOverride *kube.PodSpecOverride
+	if opts.TemplatesDir != "" {
+		podSpecOverride, err = kube.LoadPodSpecOverride(filepath.Join(opts.TemplatesDir, "mongodb-pod.yaml"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &mongodbController{
+		imagePullSecret:       opts.ImagePullSecret,
+		brokerNamespace:       kube.PodNamespace(opts.BrokerNamespace),
+		dialHealthChecks:      opts.DialHealthChecks,
+		allowedNamespaces:     allowed,
+		defaultNamespace:      defaultNS,
+		allowDefaultNamespace: opts.AllowDefaultNamespace,
+		namespacePerInstance:  opts.NamespacePerInstance,
+		serviceAccount:        serviceAccount,
+		kubeClient:            client,
+		recorder:              kube.NewEventRecorder(client, brokerName),
+		retryAttempts:         retryAttempts,
+		retryBaseDelay:        retryBaseDelay,
+		provisionTimeout:      provisionTimeout,
+		bindTimeout:           bindTimeout,
+		podSpecOverride:       podSpecOverride,
+		instanceMap:           make(map[string]*mongodbServiceInstance),
+		healthCache:           make(map[string]*instanceHealth),
+	}, nil
+}
+
+func (c *mongodbController) Catalog() (*brokerapi.Catalog, error) {
+	glog.Info("Catalog()")
+	return &brokerapi.Catalog{
+		Services: []*brokerapi.Service{
+			{
+				Name:        "mongodb",
+				ID:          serviceID,
+				Description: "A single-instance MongoDB database",
+				Plans: []brokerapi.ServicePlan{
+					{
+						Name:        "ephemeral",
+						ID:          planEphemeral,
+						Description: "A MongoDB instance with no persistent storage",
+						Free:        true,
+					},
+					{
+						Name:        "persistent",
+						ID:          planPersistent,
+						Description: "A MongoDB instance backed by a PersistentVolumeClaim",
+						Free:        false,
+					},
+					{
+						Name:        "ha",
+						ID:          planHA,
+						Description: "A 3-member MongoDB replica set spread across nodes",
+						Free:        false,
+					},
+				},
+				Bindable: true,
+			},
+		},
+	}, nil
+}
+
+// resolveNamespace determines the effective namespace for a new instance,
+// honoring a namespace provision parameter override, then validates it via
+// kube.ValidateTargetNamespace so a bad namespace (missing, terminating, or
+// outside allowedNamespaces) fails the request before anything is created.
+// A request that names no namespace at all falls back to c.defaultNamespace
+// only when c.allowDefaultNamespace is set; otherwise it is rejected instead
+// of silently landing in a namespace the caller never asked for.
+//
+// When c.namespacePerInstance is set, the namespace resolved above (and
+// validated against c.allowedNamespaces) is only the requesting namespace;
+// the namespace actually returned, and used for the instance, is a
+// dedicated one this controller creates via kube.EnsureInstanceNamespace.
+func (c *mongodbController) resolveNamespace(client kubernetes.Interface, id string, req *brokerapi.CreateServiceInstanceRequest) (string, error) {
+	namespace := req.ContextProfile.Namespace
+
+	if v, ok := req.Parameters["namespace"]; ok {
+		if override, ok := v.(string); ok && override != "" {
+			namespace = override
+		}
+	}
+
+	if namespace == "" {
+		if !c.allowDefaultNamespace {
+			return "", fmt.Errorf("no namespace given in the request, and default namespace is disabled (enable it with --allow-default-namespace)")
+		}
+		namespace = c.defaultNamespace
+	}
+
+	if err := kube.ValidateTargetNamespace(client, namespace, c.allowedNamespaces); err != nil {
+		return "", err
+	}
+
+	if c.namespacePerInstance {
+		instanceNamespace := kube.InstanceNamespaceName(brokerName, id)
+		if err := kube.EnsureInstanceNamespace(client, brokerName, id, instanceNamespace); err != nil {
+			return "", err
+		}
+		return instanceNamespace, nil
+	}
+	return namespace, nil
+}
+
+// resolveImagePullSecret returns the name of the secret to reference on the
+// instance pod, copying a broker-namespace secret into the instance
+// namespace when necessary. It returns an error if the referenced secret
+// cannot be found, so that provisioning fails before the pod is created.
+func (c *mongodbController) resolveImagePullSecret(client kubernetes.Interface, namespace string, req *brokerapi.CreateServiceInstanceRequest, instanceID string) (string, error) {
+	name := c.imagePullSecret
+	if v, ok := req.Parameters["imagePullSecret"]; ok {
+		if s, ok := v.(string); ok && s != "" {
+			name = s
+		}
+	}
+	if name == "" {
+		return "", nil
+	}
+
+	secret, err := client.Core().Secrets(c.brokerNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("image pull secret %q not found in namespace %q: %v", name, c.brokerNamespace, err)
+	}
+	if namespace == c.brokerNamespace {
+		return name, nil
+	}
+
+	copyName := names.InstanceResourceName(name, instanceID, "")
+	copySecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      copyName,
+			Namespace: namespace,
+			Labels:    map[string]string{kube.InstanceLabelKey(): instanceID},
+		},
+		Type: secret.Type,
+		Data: secret.Data,
+	}
+	if _, err := client.Core().Secrets(namespace).Create(copySecret); err != nil {
+		err = kube.TranslateForbidden(err, "create", "secrets", namespace, c.serviceAccount)
+		return "", fmt.Errorf("failed to copy image pull secret %q into namespace %q: %v", name, namespace, err)
+	}
+	return copyName, nil
+}
+
+// newDatabaseInstancePod builds the hardened mongo pod shape shared by every
+// plan, before any plan-specific volume is attached or the operator's
+// podSpecOverride, if any, is applied.
+func newDatabaseInstancePod(podName, namespace, id, pullSecretName string, annotations map[string]string) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        podName,
+			Namespace:   namespace,
+			Labels:      map[string]string{kube.InstanceLabelKey(): id},
+			Annotations: annotations,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:  "mongodb",
+					Image: mongoImage,
+					Ports: []v1.ContainerPort{{ContainerPort: mongoPort}},
+				},
+			},
+		},
+	}
+	if pullSecretName != "" {
+		pod.Spec.ImagePullSecrets = []v1.LocalObjectReference{{Name: pullSecretName}}
+	}
+	harden(&pod.Spec)
+	return pod
+}
+
+func (c *mongodbController) CreateServiceInstance(
+	ctx context.Context,
+	id string,
+	req *brokerapi.CreateServiceInstanceRequest,
+) (*brokerapi.CreateServiceInstanceResponse, error) {
+	reqlog.FromContext(ctx).Infof("CreateServiceInstance()")
+	ctx, cancel := context.WithTimeout(ctx, c.provisionTimeout)
+	defer cancel()
+
+	client := c.kubeClient
+
+	c.rwMutex.RLock()
+	existing, isRetry := c.instanceMap[id]
+	c.rwMutex.RUnlock()
+
+	namespace, err := c.resolveNamespace(client, id, req)
+	if err != nil {
+		return nil, err
+	}
+	if isRetry && existing.Namespace != namespace {
+		return nil, fmt.Errorf("namespace override for instance %s changed on retry (was %q, now %q)", id, existing.Namespace, namespace)
+	}
+
+	if req.PlanID == planHA {
+		return c.createHAInstance(client, namespace, id, req)
+	}
+
+	pullSecretName, err := c.resolveImagePullSecret(client, namespace, req, id)
+	if err != nil {
+		return nil, err
+	}
+
+	podName := names.InstanceResourceName(brokerName, id, "")
+	annotations := kube.TraceAnnotations(reqlog.FromContext(ctx).CorrelationID(), "provision")
+	pod := newDatabaseInstancePod(podName, namespace, id, pullSecretName, annotations)
+	podRef := kube.ObjectRef("Pod", namespace, podName)
+
+	c.recorder.Event(podRef, api.EventTypeNormal, kube.ReasonProvisioning, "Provisioning mongodb instance")
+
+	rollback := &kube.RollbackTracker{}
+
+	var pvcName string
+	if req.PlanID == planPersistent {
+		pvcName = names.InstanceResourceName(brokerName, id, "")
+		if err := createDataVolumeClaim(client, namespace, pvcName, id); err != nil {
+			kube.RecordProvisionFailed(c.recorder, podRef, "mongodb", err)
+			return nil, err
+		}
+		rollback.Add("delete mongodb instance PVC", func(ctx context.Context) error {
+			return client.Core().PersistentVolumeClaims(namespace).Delete(pvcName, nil)
+		})
+		attachDataVolume(pod, pvcName)
+	}
+	kube.ApplyPodSpecOverride(&pod.Spec, &pod.ObjectMeta, c.podSpecOverride)
+
+	createErr := kube.Retry(ctx, c.retryAttempts, c.retryBaseDelay, "create mongodb instance pod", func() error {
+		done := kube.TimeAPICall("provision", "create", "pods")
+		_, err := client.Core().Pods(namespace).Create(pod)
+		err = kube.TranslateForbidden(err, "create", "pods", namespace, c.serviceAccount)
+		done(err)
+		return err
+	})
+	if createErr != nil {
+		if rbErr := rollback.Run(ctx); rbErr != nil {
+			glog.Errorf("rollback: %v", rbErr)
+		}
+		kube.RecordProvisionFailed(c.recorder, podRef, "mongodb", createErr)
+		return nil, fmt.Errorf("failed to create mongodb instance pod: %v", createErr)
+	}
+	rollback.Add("delete mongodb instance pod", func(ctx context.Context) error {
+		return client.Core().Pods(namespace).Delete(podName, nil)
+	})
+
+	if err := diagnoseProvisionFailure(ctx, client, namespace, podName); err != nil {
+		if rbErr := rollback.Run(ctx); rbErr != nil {
+			glog.Errorf("rollback: %v", rbErr)
+		}
+		kube.RecordProvisionFailed(c.recorder, podRef, "mongodb", err)
+		return nil, err
+	}
+
+	backupCronJobName, err := c.createBackupCronJobIfRequested(client, namespace, id, req)
+	if err != nil {
+		if rbErr := rollback.Run(ctx); rbErr != nil {
+			glog.Errorf("rollback: %v", rbErr)
+		}
+		kube.RecordProvisionFailed(c.recorder, podRef, "mongodb", err)
+		return nil, err
+	}
+	rollback.Commit()
+	c.recorder.Event(podRef, api.EventTypeNormal, kube.ReasonProvisioned, "Provisioned mongodb instance")
+
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+
+	c.instanceMap[id] = &mongodbServiceInstance{
+		ID:                id,
+		Namespace:         namespace,
+		PodName:           podName,
+		PlanID:            req.PlanID,
+		PVCName:           pvcName,
+		BackupCronJobName: backupCronJobName,
+		CreatedAt:         time.Now(),
+	}
+	glog.Infof("Created MongoDB Service Instance:\n%v\n", c.instanceMap[id])
+	return &brokerapi.CreateServiceInstanceResponse{}, nil
+}
+
+func (c *mongodbController) UpdateServiceInstance(
+	ctx context.Context,
+	instanceID string,
+	req *brokerapi.UpdateServiceInstanceRequest,
+) (*brokerapi.UpdateServiceInstanceResponse, error) {
+	reqlog.FromContext(ctx).Infof("UpdateServiceInstance()")
+	return nil, errors.New("Unimplemented")
+}
+
+func (c *mongodbController) GetServiceInstanceLastOperation(
+	ctx context.Context,
+	instanceID,
+	serviceID,
+	planID,
+	operation string,
+) (*brokerapi.LastOperationResponse, error) {
+	reqlog.FromContext(ctx).Infof("GetServiceInstanceLastOperation()")
+	health, err := c.healthFor(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if !health.Exists {
+		return nil, kube.ErrNoSuchInstance{InstanceID: instanceID}
+	}
+
+	description := fmt.Sprintf("pod phase: %s, ready: %t, restarts: %d", health.PodPhase, health.Ready, health.RestartCount)
+	// An existing-but-unhealthy pod is still a successfully provisioned
+	// instance; health is reported alongside status, not in place of it.
+	return &brokerapi.LastOperationResponse{State: brokerapi.StateSucceeded, Description: description}, nil
+}
+
+func (c *mongodbController) RemoveServiceInstance(
+	ctx context.Context,
+	instanceID,
+	serviceID,
+	planID string,
+	acceptsIncomplete, force bool,
+) (*brokerapi.DeleteServiceInstanceResponse, error) {
+	reqlog.FromContext(ctx).Infof("RemoveServiceInstance()")
+	tctx, cancel := context.WithTimeout(ctx, c.provisionTimeout)
+	defer cancel()
+
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+
+	instance, ok := c.instanceMap[instanceID]
+	if !ok {
+		return &brokerapi.DeleteServiceInstanceResponse{}, nil
+	}
+
+	client := c.kubeClient
+
+	if c.namespacePerInstance {
+		if err := kube.DeleteInstanceNamespace(tctx, client, instance.Namespace, c.retryBaseDelay); err != nil {
+			return nil, fmt.Errorf("failed to delete mongodb instance namespace: %v", err)
+		}
+		delete(c.instanceMap, instanceID)
+		return &brokerapi.DeleteServiceInstanceResponse{}, nil
+	}
+
+	if instance.PlanID == planHA {
+		if err := deleteHAInstance(client, instance); err != nil {
+			return nil, err
+		}
+		delete(c.instanceMap, instanceID)
+		return &brokerapi.DeleteServiceInstanceResponse{}, nil
+	}
+
+	deleteErr := kube.Retry(tctx, c.retryAttempts, c.retryBaseDelay, "delete mongodb instance pod", func() error {
+		done := kube.TimeAPICall("deprovision", "delete", "pods")
+		err := client.Core().Pods(instance.Namespace).Delete(instance.PodName, nil)
+		done(err)
+		return err
+	})
+	if deleteErr != nil {
+		return nil, fmt.Errorf("failed to delete mongodb instance pod: %v", deleteErr)
+	}
+
+	if instance.BackupCronJobName != "" {
+		if err := deleteBackupResources(client, instance); err != nil {
+			return nil, err
+		}
+	}
+
+	c.recorder.Event(kube.ObjectRef("Pod", instance.Namespace, instance.PodName), api.EventTypeNormal, kube.ReasonDeprovisioned, "Deprovisioned mongodb instance")
+	delete(c.instanceMap, instanceID)
+	return &brokerapi.DeleteServiceInstanceResponse{}, nil
+}
+
+func (c *mongodbController) Bind(
+	ctx context.Context,
+	instanceID,
+	bindingID string,
+	req *brokerapi.BindingRequest,
+) (*brokerapi.CreateServiceBindingResponse, error) {
+	reqlog.FromContext(ctx).Infof("Bind()")
+	ctx, cancel := context.WithTimeout(ctx, c.bindTimeout)
+	defer cancel()
+
+	c.rwMutex.RLock()
+	defer c.rwMutex.RUnlock()
+
+	instance, ok := c.instanceMap[instanceID]
+	if !ok {
+		return nil, kube.ErrNoSuchInstance{InstanceID: instanceID}
+	}
+
+	client := c.kubeClient
+	host, err := kube.ResolveEndpoint(client, "bind", instance.Namespace, instance.PodName, kube.InstanceLabelSelector(instanceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve mongodb instance endpoint: %v", err)
+	}
+
+	dbName := bindDatabaseName(bindingID, req)
+	cred := brokerapi.Credential{
+		"host":     host,
+		"port":     mongoPort,
+		"database": dbName,
+		"uri":      fmt.Sprintf("mongodb://%s:%d/%s", host, mongoPort, dbName),
+	}
+	c.recorder.Event(kube.ObjectRef("Pod", instance.Namespace, instance.PodName), api.EventTypeNormal, kube.ReasonBound, "Bound mongodb instance")
+	return &brokerapi.CreateServiceBindingResponse{Credentials: cred}, nil
+}
+
+// bindDatabaseName returns the name of the database a binding should use:
+// the caller-supplied database parameter, or a name derived from the
+// binding ID so distinct bindings default to distinct databases.
+func bindDatabaseName(bindingID string, req *brokerapi.BindingRequest) string {
+	if v, ok := req.Parameters["database"]; ok {
+		if s, ok := v.(string); ok && s != "" {
+			return s
+		}
+	}
+	return fmt.Sprintf("binding-%s", bindingID)
+}
+
+func (c *mongodbController) UnBind(ctx context.Context, instanceID, bindingID, serviceID, planID string) error {
+	reqlog.FromContext(ctx).Infof("UnBind()")
+	// Bindings are not separately persisted, so there is nothing to clean up.
+	return nil
+}
+
+// CheckReadiness implements controller.ReadinessChecker.
+func (c *mongodbController) CheckReadiness(ctx context.Context) map[string]error {
+	failures := map[string]error{}
+	if err := kube.CheckAPIServerReachable(c.kubeClient); err != nil {
+		failures["kube-api"] = err
+	}
+	return failures
+}
+
+// SnapshotState implements controller.StateReporter.
+func (c *mongodbController) SnapshotState() controller.StateSnapshot {
+	c.rwMutex.RLock()
+	defer c.rwMutex.RUnlock()
+
+	instances := make([]controller.InstanceSnapshot, 0, len(c.instanceMap))
+	for _, instance := range c.instanceMap {
+		instances = append(instances, controller.InstanceSnapshot{
+			ID:        instance.ID,
+			Namespace: instance.Namespace,
+			ServiceID: serviceID,
+			PlanID:    instance.PlanID,
+			CreatedAt: instance.CreatedAt,
+		})
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+
+	return controller.StateSnapshot{
+		Instances: instances,
+		Config: map[string]string{
+			"dialHealthChecks":     strconv.FormatBool(c.dialHealthChecks),
+			"defaultNamespace":     c.defaultNamespace,
+			"namespacePerInstance": strconv.FormatBool(c.namespacePerInstance),
+		},
+	}
+}
@@ -0,0 +1,345 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/pkg/api/v1"
+	ktesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestController(instances ...*mongodbServiceInstance) *mongodbController {
+	c := &mongodbController{instanceMap: make(map[string]*mongodbServiceInstance), brokerNamespace: defaultNamespace, recorder: record.NewFakeRecorder(100)}
+	for _, instance := range instances {
+		c.instanceMap[instance.ID] = instance
+	}
+	return c
+}
+
+func TestResolveNamespaceDefaultsToContextProfile(t *testing.T) {
+	c := newTestController()
+	req := &brokerapi.CreateServiceInstanceRequest{ContextProfile: brokerapi.ContextProfile{Namespace: "team-a"}}
+
+	ns, err := c.resolveNamespace(fake.NewSimpleClientset(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}), "test", req)
+	if err != nil {
+		t.Fatalf("resolveNamespace: %v", err)
+	}
+	if ns != "team-a" {
+		t.Errorf("ns = %q, want %q", ns, "team-a")
+	}
+}
+
+func TestResolveNamespaceDefaultMustExist(t *testing.T) {
+	c := newTestController()
+	req := &brokerapi.CreateServiceInstanceRequest{ContextProfile: brokerapi.ContextProfile{Namespace: "team-a"}}
+
+	if _, err := c.resolveNamespace(fake.NewSimpleClientset(), "test", req); err == nil {
+		t.Fatal("expected an error for a default namespace that does not exist")
+	}
+}
+
+func TestResolveNamespaceOverrideMustExist(t *testing.T) {
+	c := newTestController()
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"namespace": "missing"}}
+
+	if _, err := c.resolveNamespace(fake.NewSimpleClientset(), "test", req); err == nil {
+		t.Fatal("expected an error for a namespace override that does not exist")
+	}
+}
+
+func TestResolveNamespaceOverrideRefusesTerminating(t *testing.T) {
+	c := newTestController()
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "going-away"},
+		Status:     v1.NamespaceStatus{Phase: v1.NamespaceTerminating},
+	}
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"namespace": "going-away"}}
+
+	if _, err := c.resolveNamespace(fake.NewSimpleClientset(ns), "test", req); err == nil {
+		t.Fatal("expected an error for a terminating namespace override")
+	}
+}
+
+func TestResolveNamespaceRejectsMissingNamespaceByDefault(t *testing.T) {
+	c := newTestController()
+	req := &brokerapi.CreateServiceInstanceRequest{}
+
+	if _, err := c.resolveNamespace(fake.NewSimpleClientset(), "test", req); err == nil {
+		t.Fatal("expected an error for a request with no namespace and AllowDefaultNamespace unset")
+	}
+}
+
+func TestResolveNamespaceFallsBackToDefaultNamespaceWhenAllowed(t *testing.T) {
+	c := &mongodbController{allowDefaultNamespace: true, defaultNamespace: "team-default"}
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-default"}}
+	req := &brokerapi.CreateServiceInstanceRequest{}
+
+	got, err := c.resolveNamespace(fake.NewSimpleClientset(ns), "test", req)
+	if err != nil {
+		t.Fatalf("resolveNamespace: %v", err)
+	}
+	if got != "team-default" {
+		t.Errorf("ns = %q, want %q", got, "team-default")
+	}
+}
+
+func TestResolveNamespaceOverrideHonorsAllowList(t *testing.T) {
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}}
+	c := &mongodbController{allowedNamespaces: map[string]bool{"team-a": true}}
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"namespace": "team-b"}}
+
+	if _, err := c.resolveNamespace(fake.NewSimpleClientset(ns), "test", req); err == nil {
+		t.Fatal("expected an error for a namespace override outside allowedNamespaces")
+	}
+}
+
+func TestResolveNamespaceCreatesDedicatedNamespaceWhenNamespacePerInstance(t *testing.T) {
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+	c := &mongodbController{namespacePerInstance: true}
+	req := &brokerapi.CreateServiceInstanceRequest{ContextProfile: brokerapi.ContextProfile{Namespace: "team-a"}}
+
+	got, err := c.resolveNamespace(fake.NewSimpleClientset(ns), "test", req)
+	if err != nil {
+		t.Fatalf("resolveNamespace: %v", err)
+	}
+	if want := "mongodb-test"; got != want {
+		t.Errorf("ns = %q, want %q", got, want)
+	}
+}
+
+func TestResolveNamespaceStillValidatesRequestingNamespaceWhenNamespacePerInstance(t *testing.T) {
+	c := &mongodbController{namespacePerInstance: true, allowedNamespaces: map[string]bool{"team-a": true}}
+	req := &brokerapi.CreateServiceInstanceRequest{ContextProfile: brokerapi.ContextProfile{Namespace: "team-b"}}
+
+	if _, err := c.resolveNamespace(fake.NewSimpleClientset(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}}), "test", req); err == nil {
+		t.Fatal("expected an error for a requesting namespace outside allowedNamespaces even with namespacePerInstance set")
+	}
+}
+
+func TestResolveImagePullSecretCopiesIntoInstanceNamespace(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry-creds", Namespace: defaultNamespace},
+		Data:       map[string][]byte{".dockerconfigjson": []byte("{}")},
+	})
+	c := newTestController()
+	c.imagePullSecret = "registry-creds"
+
+	name, err := c.resolveImagePullSecret(client, "other-namespace", &brokerapi.CreateServiceInstanceRequest{}, "test")
+	if err != nil {
+		t.Fatalf("resolveImagePullSecret: %v", err)
+	}
+	if name != "registry-creds-test" {
+		t.Errorf("name = %q, want %q", name, "registry-creds-test")
+	}
+	if _, err := client.Core().Secrets("other-namespace").Get(name, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the secret to be copied into other-namespace: %v", err)
+	}
+}
+
+func TestResolveImagePullSecretEmptyWhenUnconfigured(t *testing.T) {
+	c := newTestController()
+
+	name, err := c.resolveImagePullSecret(fake.NewSimpleClientset(), defaultNamespace, &brokerapi.CreateServiceInstanceRequest{}, "test")
+	if err != nil {
+		t.Fatalf("resolveImagePullSecret: %v", err)
+	}
+	if name != "" {
+		t.Errorf("name = %q, want empty", name)
+	}
+}
+
+func TestResolveImagePullSecretTranslatesForbiddenCreate(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry-creds", Namespace: defaultNamespace},
+		Data:       map[string][]byte{".dockerconfigjson": []byte("{}")},
+	})
+	client.PrependReactor("create", "secrets", func(ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "registry-creds-test", fmt.Errorf("denied"))
+	})
+	c := &mongodbController{serviceAccount: "mongodb-broker", brokerNamespace: defaultNamespace, imagePullSecret: "registry-creds"}
+
+	_, err := c.resolveImagePullSecret(client, "other-namespace", &brokerapi.CreateServiceInstanceRequest{}, "test")
+	if err == nil {
+		t.Fatal("expected resolveImagePullSecret to fail")
+	}
+	if !strings.Contains(err.Error(), `service account "mongodb-broker" is not permitted to create secrets`) {
+		t.Errorf("err = %v, want it to name the forbidden verb, resource, and service account", err)
+	}
+}
+
+func TestBindReturnsPodIPAsHost(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mongodb-test",
+			Namespace: "default",
+			Labels:    map[string]string{kube.InstanceLabelKey(): "test"},
+		},
+		Status: v1.PodStatus{
+			PodIP:      "10.0.0.5",
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+	c := newTestController(&mongodbServiceInstance{ID: "test", Namespace: "default", PodName: "mongodb-test"})
+	c.kubeClient = fake.NewSimpleClientset(pod)
+
+	resp, err := c.Bind(context.Background(), "test", "binding-1", &brokerapi.BindingRequest{})
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if resp.Credentials["host"] != "10.0.0.5" {
+		t.Errorf("host = %v, want %q", resp.Credentials["host"], "10.0.0.5")
+	}
+	if resp.Credentials["database"] != "binding-binding-1" {
+		t.Errorf("database = %v, want %q", resp.Credentials["database"], "binding-binding-1")
+	}
+}
+
+func TestBindPrefersServiceClusterIPWhenPresent(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "mongodb-test", Namespace: "default"},
+		Spec:       v1.ServiceSpec{ClusterIP: "10.0.0.9"},
+	}
+	c := newTestController(&mongodbServiceInstance{ID: "test", Namespace: "default", PodName: "mongodb-test"})
+	c.kubeClient = fake.NewSimpleClientset(svc)
+
+	resp, err := c.Bind(context.Background(), "test", "binding-1", &brokerapi.BindingRequest{})
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if resp.Credentials["host"] != "10.0.0.9" {
+		t.Errorf("host = %v, want %q", resp.Credentials["host"], "10.0.0.9")
+	}
+}
+
+func TestBindFailsWhenNoEndpointFound(t *testing.T) {
+	c := newTestController(&mongodbServiceInstance{ID: "test", Namespace: "default", PodName: "mongodb-test"})
+	c.kubeClient = fake.NewSimpleClientset()
+
+	if _, err := c.Bind(context.Background(), "test", "binding-1", &brokerapi.BindingRequest{}); err == nil {
+		t.Fatal("expected an error when no Service or pod backs the instance")
+	}
+}
+
+func TestBindHonorsDatabaseParameter(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mongodb-test",
+			Namespace: "default",
+			Labels:    map[string]string{kube.InstanceLabelKey(): "test"},
+		},
+		Status: v1.PodStatus{
+			PodIP:      "10.0.0.5",
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+	c := newTestController(&mongodbServiceInstance{ID: "test", Namespace: "default", PodName: "mongodb-test"})
+	c.kubeClient = fake.NewSimpleClientset(pod)
+
+	req := &brokerapi.BindingRequest{Parameters: map[string]interface{}{"database": "custom"}}
+	resp, err := c.Bind(context.Background(), "test", "binding-1", req)
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if resp.Credentials["database"] != "custom" {
+		t.Errorf("database = %v, want %q", resp.Credentials["database"], "custom")
+	}
+}
+
+func TestBindFailsForUnknownInstance(t *testing.T) {
+	c := newTestController()
+	c.kubeClient = fake.NewSimpleClientset()
+
+	if _, err := c.Bind(context.Background(), "missing", "binding-1", &brokerapi.BindingRequest{}); err == nil {
+		t.Fatal("expected an error for an unknown instance")
+	}
+}
+
+func TestBindFailsWhenPodIsGone(t *testing.T) {
+	c := newTestController(&mongodbServiceInstance{ID: "test", Namespace: "default", PodName: "mongodb-test"})
+	c.kubeClient = fake.NewSimpleClientset()
+
+	if _, err := c.Bind(context.Background(), "test", "binding-1", &brokerapi.BindingRequest{}); err == nil {
+		t.Fatal("expected an error when the instance pod no longer exists")
+	}
+}
+
+func TestRemoveServiceInstanceDeletesPod(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "mongodb-test", Namespace: "default"}}
+	c := newTestController(&mongodbServiceInstance{ID: "test", Namespace: "default", PodName: "mongodb-test"})
+	c.kubeClient = fake.NewSimpleClientset(pod)
+
+	if _, err := c.RemoveServiceInstance(context.Background(), "test", serviceID, planEphemeral, false, false); err != nil {
+		t.Fatalf("RemoveServiceInstance: %v", err)
+	}
+	if _, ok := c.instanceMap["test"]; ok {
+		t.Error("expected the instance to be removed from instanceMap")
+	}
+	if _, err := c.kubeClient.Core().Pods("default").Get("mongodb-test", metav1.GetOptions{}); err == nil {
+		t.Error("expected the instance pod to be deleted")
+	}
+}
+
+func TestRemoveServiceInstanceIgnoresUnknownInstance(t *testing.T) {
+	c := newTestController()
+	c.kubeClient = fake.NewSimpleClientset()
+
+	resp, err := c.RemoveServiceInstance(context.Background(), "missing", serviceID, planEphemeral, false, false)
+	if err != nil {
+		t.Fatalf("RemoveServiceInstance: %v", err)
+	}
+	if resp == nil {
+		t.Error("expected a non-nil response for an already-gone instance")
+	}
+}
+
+func TestNewDatabaseInstancePodCarriesTheGivenAnnotations(t *testing.T) {
+	annotations := kube.TraceAnnotations("correlation-1", "provision")
+
+	pod := newDatabaseInstancePod("mongodb-test", defaultNamespace, "test", "", annotations)
+
+	if !reflect.DeepEqual(pod.Annotations, annotations) {
+		t.Errorf("pod annotations = %+v, want %+v", pod.Annotations, annotations)
+	}
+}
+
+func TestNewDatabaseInstancePodThenApplyPodSpecOverride(t *testing.T) {
+	pod := newDatabaseInstancePod("mongodb-test", defaultNamespace, "test", "", nil)
+	override := &kube.PodSpecOverride{NodeSelector: map[string]string{"disktype": "ssd"}}
+
+	kube.ApplyPodSpecOverride(&pod.Spec, &pod.ObjectMeta, override)
+
+	if got, want := pod.Spec.NodeSelector["disktype"], "ssd"; got != want {
+		t.Errorf("NodeSelector[disktype] = %q, want %q", got, want)
+	}
+	if pod.Spec.Containers[0].Name != "mongodb" {
+		t.Errorf("Containers[0].Name = %q, want unchanged %q", pod.Spec.Containers[0].Name, "mongodb")
+	}
+}
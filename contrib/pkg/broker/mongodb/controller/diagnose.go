@@ -0,0 +1,83 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// provisionDiagnosisTimeout bounds how long CreateServiceInstance waits to
+// distinguish a pod that is merely starting slowly from one that will
+// never come up (bad image, unschedulable, etc).
+const provisionDiagnosisTimeout = 30 * time.Second
+
+const provisionDiagnosisPollInterval = 2 * time.Second
+
+// diagnoseProvisionFailure polls the instance pod for a short window,
+// looking for waiting-container reasons (ErrImagePull, ImagePullBackOff)
+// or a PodScheduled=False condition that indicate the pod will never come
+// up, and returns a descriptive error for those cases. It returns nil once
+// the pod is Running or the poll window elapses without a diagnosable
+// failure, on the assumption that the pod is still starting normally. It
+// returns early if ctx is canceled or hits its deadline first.
+func diagnoseProvisionFailure(ctx context.Context, client kubernetes.Interface, namespace, podName string) error {
+	deadline := time.Now().Add(provisionDiagnosisTimeout)
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("diagnosing instance pod %s/%s: %v", namespace, podName, ctx.Err())
+		default:
+		}
+
+		pod, err := client.Core().Pods(namespace).Get(podName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to look up instance pod %s/%s: %v", namespace, podName, err)
+		}
+
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == "PodScheduled" && cond.Status == "False" {
+				return fmt.Errorf("instance pod %s/%s could not be scheduled: %s", namespace, podName, cond.Message)
+			}
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Waiting == nil {
+				continue
+			}
+			switch cs.State.Waiting.Reason {
+			case "ErrImagePull", "ImagePullBackOff":
+				return fmt.Errorf("instance pod %s/%s failed to pull its image: %s", namespace, podName, cs.State.Waiting.Message)
+			}
+		}
+		if pod.Status.Phase == "Running" {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("diagnosing instance pod %s/%s: %v", namespace, podName, ctx.Err())
+		case <-time.After(provisionDiagnosisPollInterval):
+		}
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestDiagnoseProvisionFailureReturnsNilOncePodIsRunning(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "mongodb-test", Namespace: "default"},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	client := fake.NewSimpleClientset(pod)
+
+	if err := diagnoseProvisionFailure(context.Background(), client, "default", "mongodb-test"); err != nil {
+		t.Fatalf("diagnoseProvisionFailure: %v", err)
+	}
+}
+
+func TestDiagnoseProvisionFailureAbortsPromptlyWhenContextIsCanceled(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "mongodb-test", Namespace: "default"},
+		Status:     v1.PodStatus{Phase: v1.PodPending},
+	}
+	client := fake.NewSimpleClientset(pod)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := diagnoseProvisionFailure(ctx, client, "default", "mongodb-test")
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected an error from a canceled context")
+	}
+	if elapsed >= provisionDiagnosisPollInterval {
+		t.Errorf("diagnoseProvisionFailure took %s, want it to abort promptly instead of waiting out a poll interval", elapsed)
+	}
+}
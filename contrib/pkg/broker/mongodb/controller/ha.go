@@ -0,0 +1,142 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	appsv1beta1 "k8s.io/client-go/pkg/apis/apps/v1beta1"
+	policyv1beta1 "k8s.io/client-go/pkg/apis/policy/v1beta1"
+)
+
+// haReplicas is the fixed size of the replica set created for the ha plan.
+const haReplicas = 3
+
+// haMinAvailable is the minimum number of members the PodDisruptionBudget
+// keeps available, so a node drain can never take down a majority.
+const haMinAvailable = 2
+
+// createHAInstance provisions a 3-member mongo replica set as a
+// StatefulSet, with a PodDisruptionBudget and hostname anti-affinity so
+// members spread across nodes.
+func (c *mongodbController) createHAInstance(client kubernetes.Interface, namespace, id string, req *brokerapi.CreateServiceInstanceRequest) (*brokerapi.CreateServiceInstanceResponse, error) {
+	name := names.InstanceResourceName(brokerName, id, "")
+	labels := map[string]string{kube.InstanceLabelKey(): id}
+	replicas := int32(haReplicas)
+
+	sts := &appsv1beta1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1beta1.StatefulSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{
+							Name:  "mongodb",
+							Image: mongoImage,
+							Ports: []v1.ContainerPort{{ContainerPort: mongoPort}},
+						},
+					},
+					Affinity: &v1.Affinity{
+						PodAntiAffinity: &v1.PodAntiAffinity{
+							PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{
+								{
+									Weight: 100,
+									PodAffinityTerm: v1.PodAffinityTerm{
+										LabelSelector: &metav1.LabelSelector{MatchLabels: labels},
+										TopologyKey:   "kubernetes.io/hostname",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	harden(&sts.Spec.Template.Spec)
+	if _, err := client.Apps().StatefulSets(namespace).Create(sts); err != nil {
+		return nil, fmt.Errorf("failed to create mongodb replica set: %v", err)
+	}
+
+	minAvailable := intstr.FromInt(haMinAvailable)
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector:     &metav1.LabelSelector{MatchLabels: labels},
+		},
+	}
+	if _, err := client.Policy().PodDisruptionBudgets(namespace).Create(pdb); err != nil {
+		return nil, fmt.Errorf("failed to create mongodb PodDisruptionBudget: %v", err)
+	}
+
+	c.rwMutex.Lock()
+	c.instanceMap[id] = &mongodbServiceInstance{
+		ID:              id,
+		Namespace:       namespace,
+		PlanID:          req.PlanID,
+		StatefulSetName: name,
+		PDBName:         name,
+	}
+	c.rwMutex.Unlock()
+
+	glog.Infof("Created HA MongoDB Service Instance:\n%v\n", id)
+	return &brokerapi.CreateServiceInstanceResponse{}, nil
+}
+
+// deleteHAInstance deletes the StatefulSet and PodDisruptionBudget created
+// for an ha-plan instance.
+func deleteHAInstance(client kubernetes.Interface, instance *mongodbServiceInstance) error {
+	if err := client.Apps().StatefulSets(instance.Namespace).Delete(instance.StatefulSetName, nil); err != nil {
+		return fmt.Errorf("failed to delete mongodb replica set: %v", err)
+	}
+	if err := client.Policy().PodDisruptionBudgets(instance.Namespace).Delete(instance.PDBName, nil); err != nil {
+		return fmt.Errorf("failed to delete mongodb PodDisruptionBudget: %v", err)
+	}
+	return nil
+}
+
+// haPDBExists reports whether the ha-plan instance's PodDisruptionBudget is
+// still present, so the reconciler can flag instances whose PDB was
+// deleted out-of-band.
+func haPDBExists(client kubernetes.Interface, instance *mongodbServiceInstance) (bool, error) {
+	_, err := client.Policy().PodDisruptionBudgets(instance.Namespace).Get(instance.PDBName, metav1.GetOptions{})
+	if err == nil {
+		return true, nil
+	}
+	return false, nil
+}
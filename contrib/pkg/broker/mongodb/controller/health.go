@@ -0,0 +1,124 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// healthCacheTTL bounds how often checkInstanceHealth actually hits the
+// API server (and, when dial checks are enabled, the instance itself) for
+// the same instance.
+const healthCacheTTL = 10 * time.Second
+
+// dialTimeout bounds the optional TCP health dial.
+const dialTimeout = 2 * time.Second
+
+// instanceHealth is a structured view of whether an instance's pod is
+// actually serving traffic, not just present.
+type instanceHealth struct {
+	Exists       bool   `json:"exists"`
+	PodPhase     string `json:"podPhase"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restartCount"`
+	Dialable     *bool  `json:"dialable,omitempty"`
+	checkedAt    time.Time
+}
+
+// checkInstanceHealth inspects the instance's pod phase, Ready condition
+// and restart count and, when dialChecks is enabled, attempts a TCP dial
+// to the instance's mongo port through its Service. It is safe to call
+// when the pod no longer exists: it then reports Exists false.
+func checkInstanceHealth(client kubernetes.Interface, namespace, podName string, dialChecks bool) (*instanceHealth, error) {
+	pod, err := client.Core().Pods(namespace).Get(podName, metav1.GetOptions{})
+	if err != nil {
+		return &instanceHealth{Exists: false, checkedAt: time.Now()}, nil
+	}
+
+	health := &instanceHealth{
+		Exists:    true,
+		PodPhase:  string(pod.Status.Phase),
+		checkedAt: time.Now(),
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodReady {
+			health.Ready = cond.Status == v1.ConditionTrue
+		}
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		health.RestartCount += cs.RestartCount
+	}
+
+	if dialChecks && health.Ready {
+		serviceAddr := fmt.Sprintf("%s.%s.svc:%d", podName, namespace, mongoPort)
+		ok := dialTCP(serviceAddr)
+		health.Dialable = &ok
+	}
+
+	return health, nil
+}
+
+func dialTCP(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// cachedHealth returns a cached health result for instanceID if it is
+// still within healthCacheTTL, and reports whether the cache was used.
+func (c *mongodbController) cachedHealth(instanceID string) (*instanceHealth, bool) {
+	c.rwMutex.RLock()
+	defer c.rwMutex.RUnlock()
+	h, ok := c.healthCache[instanceID]
+	if !ok || time.Since(h.checkedAt) > healthCacheTTL {
+		return nil, false
+	}
+	return h, true
+}
+
+func (c *mongodbController) healthFor(instanceID string) (*instanceHealth, error) {
+	if h, ok := c.cachedHealth(instanceID); ok {
+		return h, nil
+	}
+
+	c.rwMutex.RLock()
+	instance, ok := c.instanceMap[instanceID]
+	c.rwMutex.RUnlock()
+	if !ok {
+		return &instanceHealth{Exists: false, checkedAt: time.Now()}, nil
+	}
+
+	client := c.kubeClient
+	h, err := checkInstanceHealth(client, instance.Namespace, instance.PodName, c.dialHealthChecks)
+	if err != nil {
+		return nil, err
+	}
+
+	c.rwMutex.Lock()
+	c.healthCache[instanceID] = h
+	c.rwMutex.Unlock()
+	return h, nil
+}
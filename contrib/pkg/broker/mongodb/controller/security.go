@@ -0,0 +1,57 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import "k8s.io/client-go/pkg/api/v1"
+
+// mongoUID is the UID the official mongo image runs its server process as.
+const mongoUID = 999
+
+// podSecurityContext returns the pod-level security context applied to
+// every instance pod: a non-root run-as UID and a matching fsGroup so the
+// data volume is writable by that UID.
+func podSecurityContext() *v1.PodSecurityContext {
+	uid := int64(mongoUID)
+	nonRoot := true
+	return &v1.PodSecurityContext{
+		RunAsUser:    &uid,
+		RunAsNonRoot: &nonRoot,
+		FSGroup:      &uid,
+	}
+}
+
+// containerSecurityContext returns the container-level hardening applied
+// to the mongodb container: no added capabilities, running as the
+// non-root UID podSecurityContext already sets at the pod level. This
+// vendored client-go's SecurityContext predates AllowPrivilegeEscalation,
+// so RunAsNonRoot/Capabilities are what's available to harden with here.
+func containerSecurityContext() *v1.SecurityContext {
+	nonRoot := true
+	return &v1.SecurityContext{
+		RunAsNonRoot: &nonRoot,
+		Capabilities: &v1.Capabilities{Drop: []v1.Capability{"ALL"}},
+	}
+}
+
+// harden applies the standard security context to a pod's spec and to
+// each of its containers.
+func harden(spec *v1.PodSpec) {
+	spec.SecurityContext = podSecurityContext()
+	for i := range spec.Containers {
+		spec.Containers[i].SecurityContext = containerSecurityContext()
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package names builds Kubernetes object names for an instance's
+// resources, so every in-cluster broker derives them the same way instead
+// of each hand-rolling its own string concatenation.
+package names
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// maxNameLength is the maximum length of a Kubernetes object name.
+const maxNameLength = 63
+
+// hashSuffixLength is how much of the hash InstanceResourceName appends to
+// a truncated name, long enough that two names truncated down to the same
+// prefix still don't collide in practice.
+const hashSuffixLength = 8
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// InstanceResourceName builds the name for one of instanceID's resources:
+// prefix (typically the broker name, e.g. "heketi"), instanceID, and an
+// optional suffix distinguishing more than one resource of the same kind
+// an instance owns (e.g. "admin", "db"), joined with "-". Each part is
+// sanitized to a valid name segment first, since instanceID in particular
+// need not already be one.
+//
+// The result is always a valid, deterministic Kubernetes name: when
+// joining prefix, instanceID, and suffix would run past the 63-character
+// name limit, the name is truncated and given a short hash suffix derived
+// from the untruncated name, so two instanceIDs that would otherwise
+// collide once cut down to length still map to distinct names.
+func InstanceResourceName(prefix, instanceID, suffix string) string {
+	parts := []string{sanitize(prefix), sanitize(instanceID)}
+	if suffix != "" {
+		parts = append(parts, sanitize(suffix))
+	}
+	full := strings.Join(parts, "-")
+	if len(full) <= maxNameLength {
+		return full
+	}
+	truncated := strings.TrimRight(full[:maxNameLength-hashSuffixLength-1], "-")
+	return truncated + "-" + hashSuffix(full)
+}
+
+func sanitize(s string) string {
+	sanitized := invalidNameChars.ReplaceAllString(strings.ToLower(s), "-")
+	sanitized = strings.Trim(sanitized, "-")
+	if sanitized == "" {
+		sanitized = "x"
+	}
+	return sanitized
+}
+
+func hashSuffix(full string) string {
+	sum := sha256.Sum256([]byte(full))
+	return hex.EncodeToString(sum[:])[:hashSuffixLength]
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package names
+
+import (
+	"regexp"
+	"testing"
+	"testing/quick"
+)
+
+var validName = regexp.MustCompile(`^[a-z0-9-]{1,63}$`)
+
+func TestInstanceResourceNameIsAlwaysValid(t *testing.T) {
+	f := func(prefix, instanceID, suffix string) bool {
+		return validName.MatchString(InstanceResourceName(prefix, instanceID, suffix))
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInstanceResourceNameIsDeterministic(t *testing.T) {
+	f := func(prefix, instanceID, suffix string) bool {
+		return InstanceResourceName(prefix, instanceID, suffix) == InstanceResourceName(prefix, instanceID, suffix)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInstanceResourceNameJoinsPrefixInstanceIDAndSuffix(t *testing.T) {
+	got := InstanceResourceName("heketi", "abc-123", "admin")
+	want := "heketi-abc-123-admin"
+	if got != want {
+		t.Errorf("InstanceResourceName() = %q, want %q", got, want)
+	}
+}
+
+func TestInstanceResourceNameOmitsEmptySuffix(t *testing.T) {
+	got := InstanceResourceName("heketi", "abc-123", "")
+	want := "heketi-abc-123"
+	if got != want {
+		t.Errorf("InstanceResourceName() = %q, want %q", got, want)
+	}
+}
+
+func TestInstanceResourceNameSanitizesInstanceID(t *testing.T) {
+	got := InstanceResourceName("heketi", "Not A Valid-ID!!", "")
+	want := "heketi-not-a-valid-id"
+	if got != want {
+		t.Errorf("InstanceResourceName() = %q, want %q", got, want)
+	}
+}
+
+func TestInstanceResourceNameTruncatesWithStableHash(t *testing.T) {
+	longID := "an-extremely-long-instance-identifier-that-blows-well-past-the-name-limit"
+	got := InstanceResourceName("heketi", longID, "admin")
+	if len(got) > 63 {
+		t.Fatalf("name %q is %d characters, want <= 63", got, len(got))
+	}
+	if got != InstanceResourceName("heketi", longID, "admin") {
+		t.Fatal("truncated name is not deterministic")
+	}
+}
+
+func TestInstanceResourceNameDisambiguatesCollidingTruncations(t *testing.T) {
+	base := "01234567890123456789012345678901234567890123456789012345"
+	first := InstanceResourceName("heketi", base+"aaaa", "admin")
+	second := InstanceResourceName("heketi", base+"bbbb", "admin")
+	if first == second {
+		t.Fatalf("distinct instanceIDs truncated to colliding names: %q", first)
+	}
+}
@@ -0,0 +1,397 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// basicAuthPlanID identifies the plan under which an instance requires
+// HTTP basic auth, with a username/password generated and issued per
+// binding rather than shared across every caller.
+const basicAuthPlanID = "c3c3c3c3-3c3c-4c3c-3c3c-3c3c3c3c3c3c"
+
+const (
+	basicAuthVolumeName  = "htpasswd"
+	basicAuthMountPath   = "/etc/nginx/.htpasswd"
+	basicAuthHtpasswdKey = "htpasswd"
+)
+
+// adminCredentialDataKey names the entry in a basic-auth plan instance's
+// secret that holds its shared admin username/password, generated once at
+// creation and handed out by a "role: admin" bind parameter instead of a
+// per-binding credential.
+const adminCredentialDataKey = "admin"
+
+// bindRoleViewer and bindRoleAdmin are the values the "role" bind parameter
+// accepts. bindRoleViewer, the default, gets a per-binding credential that
+// only unlocks the instance itself; bindRoleAdmin gets the shared admin
+// credential, gated behind the broker's --allow-admin-bind flag.
+const (
+	bindRoleViewer = "viewer"
+	bindRoleAdmin  = "admin"
+)
+
+// resolveBindRole extracts the "role" bind parameter, defaulting to
+// bindRoleViewer so a plain Bind request keeps getting a credential scoped
+// to just that binding.
+func resolveBindRole(params map[string]interface{}) (string, error) {
+	v, present := params["role"]
+	if !present {
+		return bindRoleViewer, nil
+	}
+	role, isString := v.(string)
+	if !isString {
+		return "", fmt.Errorf("role must be a string")
+	}
+	switch role {
+	case bindRoleViewer, bindRoleAdmin:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unrecognized role %q, want %q or %q", role, bindRoleViewer, bindRoleAdmin)
+	}
+}
+
+// basicAuthCredentialKeyPrefix precedes a bindingID in the secret data key
+// issueBindCredential stores a viewer binding's credential under.
+const basicAuthCredentialKeyPrefix = "binding-"
+
+// basicAuthRoleKeyPrefix precedes a bindingID in the secret data key
+// issueBindCredential records its granted role under.
+const basicAuthRoleKeyPrefix = "role-"
+
+// isBasicAuthCredentialKey reports whether key holds a username:password
+// pair renderHtpasswd should render a line for, as opposed to bookkeeping
+// such as a role-#### entry.
+func isBasicAuthCredentialKey(key string) bool {
+	return key == adminCredentialDataKey || strings.HasPrefix(key, basicAuthCredentialKeyPrefix)
+}
+
+func basicAuthSecretName(instanceID string) string {
+	return names.InstanceResourceName(brokerName, instanceID, "basic-auth")
+}
+
+// createBasicAuthSecret creates the secret that holds a basic-auth plan
+// instance's admin credential and per-binding credentials, along with the
+// htpasswd file rendered from them. The admin credential is generated
+// immediately, so the secret is never mounted with an empty htpasswd file;
+// per-binding credentials are added later as Bind requests come in.
+func createBasicAuthSecret(client kubernetes.Interface, namespace, instanceID string, labels, annotations map[string]string) (string, error) {
+	name := basicAuthSecretName(instanceID)
+
+	username, err := randomToken(6)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate a basic auth admin username: %v", err)
+	}
+	password, err := randomToken(18)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate a basic auth admin password: %v", err)
+	}
+
+	data := map[string][]byte{adminCredentialDataKey: []byte(joinBasicAuthCredential(username, password))}
+	data[basicAuthHtpasswdKey] = []byte(renderHtpasswd(data))
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Data: data,
+	}
+	if _, err := client.Core().Secrets(namespace).Create(secret); err != nil {
+		return "", fmt.Errorf("failed to create nginx basic auth secret: %v", err)
+	}
+	return name, nil
+}
+
+// readAdminCredential returns secretName's shared admin username/password,
+// generated once by createBasicAuthSecret rather than issued per binding.
+func readAdminCredential(client kubernetes.Interface, namespace, secretName string) (username, password string, err error) {
+	secret, err := client.Core().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to look up nginx basic auth secret: %v", err)
+	}
+	username, password = splitBasicAuthCredential(string(secret.Data[adminCredentialDataKey]))
+	return username, password, nil
+}
+
+// basicAuthCredentialDataKey names the entry in the instance's basic-auth
+// secret that holds a given viewer binding's "username:password", so it
+// can be revoked independently of other bindings on unbind.
+func basicAuthCredentialDataKey(bindingID string) string {
+	return basicAuthCredentialKeyPrefix + bindingID
+}
+
+// basicAuthRoleDataKey names the entry in the instance's basic-auth secret
+// that records which role bindingID was granted, so a Bind call that omits
+// or changes the role parameter on a later, idempotent retry still gets
+// back the same credential shape, and Unbind knows whether the binding
+// held the shared admin credential.
+func basicAuthRoleDataKey(bindingID string) string {
+	return basicAuthRoleKeyPrefix + bindingID
+}
+
+// issueBindCredential returns the role granted to bindingID along with its
+// username/password. A bindingID that already has a recorded role keeps
+// it regardless of the role argument, so a repeated bind request is
+// idempotent instead of silently downgrading or upgrading an existing
+// binding. A fresh bindRoleAdmin binding is issued the secret's shared
+// admin credential; a fresh bindRoleViewer binding is issued, and stored
+// as, a credential of its own, and the instance's htpasswd file is
+// re-rendered to include it. nginx's auth_basic_user_file is read fresh on
+// every request, so no separate reload step is needed once the mounted
+// secret refreshes.
+func issueBindCredential(client kubernetes.Interface, namespace, secretName, bindingID, role string) (grantedRole, username, password string, err error) {
+	secret, err := client.Core().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to look up nginx basic auth secret: %v", err)
+	}
+
+	roleKey := basicAuthRoleDataKey(bindingID)
+	if recorded, ok := secret.Data[roleKey]; ok {
+		role = string(recorded)
+	}
+
+	if role == bindRoleAdmin {
+		username, password = splitBasicAuthCredential(string(secret.Data[adminCredentialDataKey]))
+		if _, ok := secret.Data[roleKey]; ok {
+			return bindRoleAdmin, username, password, nil
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[roleKey] = []byte(bindRoleAdmin)
+		if _, err := client.Core().Secrets(namespace).Update(secret); err != nil {
+			return "", "", "", fmt.Errorf("failed to record nginx admin binding: %v", err)
+		}
+		return bindRoleAdmin, username, password, nil
+	}
+
+	credKey := basicAuthCredentialDataKey(bindingID)
+	if existing, ok := secret.Data[credKey]; ok {
+		username, password = splitBasicAuthCredential(string(existing))
+		return bindRoleViewer, username, password, nil
+	}
+
+	username, err = randomToken(6)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to generate a basic auth username: %v", err)
+	}
+	password, err = randomToken(18)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to generate a basic auth password: %v", err)
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[credKey] = []byte(joinBasicAuthCredential(username, password))
+	secret.Data[roleKey] = []byte(bindRoleViewer)
+	secret.Data[basicAuthHtpasswdKey] = []byte(renderHtpasswd(secret.Data))
+
+	if _, err := client.Core().Secrets(namespace).Update(secret); err != nil {
+		return "", "", "", fmt.Errorf("failed to store nginx basic auth credential: %v", err)
+	}
+	return bindRoleViewer, username, password, nil
+}
+
+// resolveRotateAdminPassword extracts the "rotateAdminPassword" update
+// parameter, defaulting to false so an update that doesn't mention it
+// leaves the admin credential alone.
+func resolveRotateAdminPassword(params map[string]interface{}) (bool, error) {
+	v, present := params["rotateAdminPassword"]
+	if !present {
+		return false, nil
+	}
+	rotate, isBool := v.(bool)
+	if !isBool {
+		return false, fmt.Errorf("rotateAdminPassword must be a boolean")
+	}
+	return rotate, nil
+}
+
+// rotateAdminCredential replaces secretName's shared admin username/password
+// with a freshly generated one and re-renders its htpasswd file, so the old
+// password stops authenticating as soon as the updated secret is mounted.
+// Every other per-binding credential is left untouched. Because
+// issueBindCredential always reads the admin credential back out of the
+// secret rather than storing its own copy, an admin-role binding that binds
+// again after a rotation is handed the new credential automatically,
+// without needing its own record updated.
+func rotateAdminCredential(client kubernetes.Interface, namespace, secretName string) (username, password string, err error) {
+	secret, err := client.Core().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to look up nginx basic auth secret: %v", err)
+	}
+
+	username, err = randomToken(6)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate a basic auth admin username: %v", err)
+	}
+	password, err = randomToken(18)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate a basic auth admin password: %v", err)
+	}
+
+	secret.Data[adminCredentialDataKey] = []byte(joinBasicAuthCredential(username, password))
+	secret.Data[basicAuthHtpasswdKey] = []byte(renderHtpasswd(secret.Data))
+
+	if _, err := client.Core().Secrets(namespace).Update(secret); err != nil {
+		return "", "", fmt.Errorf("failed to rotate nginx basic auth admin credential: %v", err)
+	}
+	return username, password, nil
+}
+
+// revokeBasicAuthCredential removes bindingID's credential and recorded
+// role from the instance's basic-auth secret and re-renders its htpasswd
+// file. If bindingID held the admin role and rotateAdmin is true, the
+// shared admin credential is rotated too, invalidating it for every other
+// admin binding on the instance — the operator's opt-in response to an
+// admin credential that may have leaked. Revoking an unknown or
+// already-revoked binding is not an error.
+func revokeBasicAuthCredential(client kubernetes.Interface, namespace, secretName, bindingID string, rotateAdmin bool) error {
+	secret, err := client.Core().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up nginx basic auth secret: %v", err)
+	}
+
+	roleKey := basicAuthRoleDataKey(bindingID)
+	role, hadRole := secret.Data[roleKey]
+	credKey := basicAuthCredentialDataKey(bindingID)
+	_, hadCred := secret.Data[credKey]
+	if !hadRole && !hadCred {
+		return nil
+	}
+	delete(secret.Data, roleKey)
+	delete(secret.Data, credKey)
+
+	if hadRole && string(role) == bindRoleAdmin && rotateAdmin {
+		username, err := randomToken(6)
+		if err != nil {
+			return fmt.Errorf("failed to generate a basic auth admin username: %v", err)
+		}
+		password, err := randomToken(18)
+		if err != nil {
+			return fmt.Errorf("failed to generate a basic auth admin password: %v", err)
+		}
+		secret.Data[adminCredentialDataKey] = []byte(joinBasicAuthCredential(username, password))
+	}
+	secret.Data[basicAuthHtpasswdKey] = []byte(renderHtpasswd(secret.Data))
+
+	if _, err := client.Core().Secrets(namespace).Update(secret); err != nil {
+		return fmt.Errorf("failed to revoke nginx basic auth credential: %v", err)
+	}
+	return nil
+}
+
+func joinBasicAuthCredential(username, password string) string {
+	return username + ":" + password
+}
+
+func splitBasicAuthCredential(raw string) (username, password string) {
+	parts := strings.SplitN(raw, ":", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// randomToken returns a random URL-safe token derived from n random bytes.
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// renderHtpasswd renders the admin credential and every binding credential
+// currently in data into an
+// htpasswd file using nginx's {SHA} format, which
+// ngx_http_auth_basic_module supports directly without relying on libc's
+// crypt(), keeping the hash portable across images. Entries are sorted by
+// binding data key so the rendered file is deterministic regardless of map
+// iteration order.
+func renderHtpasswd(data map[string][]byte) string {
+	var dataKeys []string
+	for k := range data {
+		if !isBasicAuthCredentialKey(k) {
+			continue
+		}
+		dataKeys = append(dataKeys, k)
+	}
+	sort.Strings(dataKeys)
+
+	var lines []string
+	for _, k := range dataKeys {
+		username, password := splitBasicAuthCredential(string(data[k]))
+		sum := sha1.Sum([]byte(password))
+		lines = append(lines, fmt.Sprintf("%s:{SHA}%s", username, base64.StdEncoding.EncodeToString(sum[:])))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// basicAuthServerConf renders the nginx server block that requires basic
+// auth for all requests, checked against the htpasswd file attachBasicAuth
+// mounts at basicAuthMountPath, listening on port. It's generated, not user
+// supplied, so a custom nginxConf and the basic-auth plan can't be combined.
+func basicAuthServerConf(port int32) string {
+	return fmt.Sprintf(`server {
+    listen %d;
+
+    auth_basic           "Restricted";
+    auth_basic_user_file %s;
+
+    location / {
+        root  /usr/share/nginx/html;
+        index index.html;
+    }
+}
+`, port, basicAuthMountPath)
+}
+
+// attachBasicAuth mounts an instance's htpasswd secret into the pod.
+func attachBasicAuth(spec *v1.PodSpec, secretName string) {
+	spec.Volumes = append(spec.Volumes, v1.Volume{
+		Name: basicAuthVolumeName,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: secretName},
+		},
+	})
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts,
+			v1.VolumeMount{Name: basicAuthVolumeName, MountPath: basicAuthMountPath, SubPath: basicAuthHtpasswdKey, ReadOnly: true},
+		)
+	}
+}
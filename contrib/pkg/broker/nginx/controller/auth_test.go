@@ -0,0 +1,358 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBasicAuthCredentialRoundTrip(t *testing.T) {
+	raw := joinBasicAuthCredential("alice", "s3cr3t")
+	username, password := splitBasicAuthCredential(raw)
+	if username != "alice" || password != "s3cr3t" {
+		t.Errorf("splitBasicAuthCredential(%q) = (%q, %q), want (alice, s3cr3t)", raw, username, password)
+	}
+}
+
+func TestSplitBasicAuthCredentialRejectsMalformedInput(t *testing.T) {
+	username, password := splitBasicAuthCredential("not-a-credential")
+	if username != "" || password != "" {
+		t.Errorf("splitBasicAuthCredential(malformed) = (%q, %q), want (\"\", \"\")", username, password)
+	}
+}
+
+func TestRenderHtpasswdIsDeterministic(t *testing.T) {
+	data := map[string][]byte{
+		basicAuthHtpasswdKey:            []byte("stale"),
+		basicAuthCredentialDataKey("b"): []byte(joinBasicAuthCredential("bob", "bpass")),
+		basicAuthCredentialDataKey("a"): []byte(joinBasicAuthCredential("alice", "apass")),
+	}
+
+	first := renderHtpasswd(data)
+	second := renderHtpasswd(data)
+	if first != second {
+		t.Errorf("renderHtpasswd is not deterministic: %q != %q", first, second)
+	}
+
+	lines := strings.Split(first, "\n")
+	if len(lines) != 2 {
+		t.Fatalf("renderHtpasswd produced %d lines, want 2: %q", len(lines), first)
+	}
+	if !strings.HasPrefix(lines[0], "alice:{SHA}") || !strings.HasPrefix(lines[1], "bob:{SHA}") {
+		t.Errorf("renderHtpasswd = %q, want alice before bob, both in {SHA} format", first)
+	}
+}
+
+func TestRenderHtpasswdOmitsItsOwnKey(t *testing.T) {
+	data := map[string][]byte{basicAuthHtpasswdKey: []byte("previous contents")}
+	if got := renderHtpasswd(data); got != "" {
+		t.Errorf("renderHtpasswd with no bindings = %q, want empty", got)
+	}
+}
+
+func TestResolveBindRoleDefaultsToViewer(t *testing.T) {
+	role, err := resolveBindRole(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("resolveBindRole: %v", err)
+	}
+	if role != bindRoleViewer {
+		t.Errorf("role = %q, want %q", role, bindRoleViewer)
+	}
+}
+
+func TestResolveBindRoleHonorsAdmin(t *testing.T) {
+	role, err := resolveBindRole(map[string]interface{}{"role": "admin"})
+	if err != nil {
+		t.Fatalf("resolveBindRole: %v", err)
+	}
+	if role != bindRoleAdmin {
+		t.Errorf("role = %q, want %q", role, bindRoleAdmin)
+	}
+}
+
+func TestResolveBindRoleRejectsUnknownValue(t *testing.T) {
+	if _, err := resolveBindRole(map[string]interface{}{"role": "superadmin"}); err == nil {
+		t.Fatal("expected an error for an unrecognized role")
+	}
+}
+
+func TestIssueBindCredentialIssuesDistinctViewerCredentials(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	name, err := createBasicAuthSecret(client, "default", "test", nil, nil)
+	if err != nil {
+		t.Fatalf("createBasicAuthSecret: %v", err)
+	}
+
+	role, aliceUser, alicePass, err := issueBindCredential(client, "default", name, "alice-binding", bindRoleViewer)
+	if err != nil {
+		t.Fatalf("issueBindCredential: %v", err)
+	}
+	if role != bindRoleViewer {
+		t.Errorf("role = %q, want %q", role, bindRoleViewer)
+	}
+
+	_, bobUser, bobPass, err := issueBindCredential(client, "default", name, "bob-binding", bindRoleViewer)
+	if err != nil {
+		t.Fatalf("issueBindCredential: %v", err)
+	}
+	if aliceUser == bobUser || alicePass == bobPass {
+		t.Error("expected distinct credentials for distinct bindings")
+	}
+
+	againUser, againPass := aliceUser, alicePass
+	role, aliceUser, alicePass, err = issueBindCredential(client, "default", name, "alice-binding", bindRoleViewer)
+	if err != nil {
+		t.Fatalf("issueBindCredential: %v", err)
+	}
+	if role != bindRoleViewer || aliceUser != againUser || alicePass != againPass {
+		t.Error("expected a repeated bind to return the same credential")
+	}
+}
+
+func TestIssueBindCredentialAdminReturnsSharedCredentialAndRecordsRole(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	name, err := createBasicAuthSecret(client, "default", "test", nil, nil)
+	if err != nil {
+		t.Fatalf("createBasicAuthSecret: %v", err)
+	}
+	adminUser, adminPass, err := readAdminCredential(client, "default", name)
+	if err != nil {
+		t.Fatalf("readAdminCredential: %v", err)
+	}
+
+	role, username, password, err := issueBindCredential(client, "default", name, "admin-binding", bindRoleAdmin)
+	if err != nil {
+		t.Fatalf("issueBindCredential: %v", err)
+	}
+	if role != bindRoleAdmin || username != adminUser || password != adminPass {
+		t.Errorf("issueBindCredential = (%q, %q, %q), want the shared admin credential", role, username, password)
+	}
+
+	secret, err := client.Core().Secrets("default").Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to look up secret: %v", err)
+	}
+	if string(secret.Data[basicAuthRoleDataKey("admin-binding")]) != bindRoleAdmin {
+		t.Error("expected the admin role to be recorded for admin-binding")
+	}
+}
+
+func TestIssueBindCredentialRecordedRoleWinsOverArgument(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	name, err := createBasicAuthSecret(client, "default", "test", nil, nil)
+	if err != nil {
+		t.Fatalf("createBasicAuthSecret: %v", err)
+	}
+
+	if _, _, _, err := issueBindCredential(client, "default", name, "admin-binding", bindRoleAdmin); err != nil {
+		t.Fatalf("issueBindCredential: %v", err)
+	}
+
+	role, _, _, err := issueBindCredential(client, "default", name, "admin-binding", bindRoleViewer)
+	if err != nil {
+		t.Fatalf("issueBindCredential: %v", err)
+	}
+	if role != bindRoleAdmin {
+		t.Errorf("role = %q, want the previously recorded %q", role, bindRoleAdmin)
+	}
+}
+
+func TestRevokeBasicAuthCredentialRemovesViewerBinding(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	name, err := createBasicAuthSecret(client, "default", "test", nil, nil)
+	if err != nil {
+		t.Fatalf("createBasicAuthSecret: %v", err)
+	}
+	if _, _, _, err := issueBindCredential(client, "default", name, "alice-binding", bindRoleViewer); err != nil {
+		t.Fatalf("issueBindCredential: %v", err)
+	}
+
+	if err := revokeBasicAuthCredential(client, "default", name, "alice-binding", false); err != nil {
+		t.Fatalf("revokeBasicAuthCredential: %v", err)
+	}
+
+	secret, err := client.Core().Secrets("default").Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to look up secret: %v", err)
+	}
+	if _, ok := secret.Data[basicAuthCredentialDataKey("alice-binding")]; ok {
+		t.Error("expected alice-binding's credential to be removed")
+	}
+	if _, ok := secret.Data[basicAuthRoleDataKey("alice-binding")]; ok {
+		t.Error("expected alice-binding's role record to be removed")
+	}
+}
+
+func TestRevokeBasicAuthCredentialRotatesAdminWhenRequested(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	name, err := createBasicAuthSecret(client, "default", "test", nil, nil)
+	if err != nil {
+		t.Fatalf("createBasicAuthSecret: %v", err)
+	}
+	originalUser, originalPass, err := readAdminCredential(client, "default", name)
+	if err != nil {
+		t.Fatalf("readAdminCredential: %v", err)
+	}
+	if _, _, _, err := issueBindCredential(client, "default", name, "admin-binding", bindRoleAdmin); err != nil {
+		t.Fatalf("issueBindCredential: %v", err)
+	}
+
+	if err := revokeBasicAuthCredential(client, "default", name, "admin-binding", true); err != nil {
+		t.Fatalf("revokeBasicAuthCredential: %v", err)
+	}
+
+	rotatedUser, rotatedPass, err := readAdminCredential(client, "default", name)
+	if err != nil {
+		t.Fatalf("readAdminCredential: %v", err)
+	}
+	if rotatedUser == originalUser && rotatedPass == originalPass {
+		t.Error("expected the admin credential to be rotated")
+	}
+}
+
+func TestRevokeBasicAuthCredentialLeavesAdminWhenNotRequested(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	name, err := createBasicAuthSecret(client, "default", "test", nil, nil)
+	if err != nil {
+		t.Fatalf("createBasicAuthSecret: %v", err)
+	}
+	originalUser, originalPass, err := readAdminCredential(client, "default", name)
+	if err != nil {
+		t.Fatalf("readAdminCredential: %v", err)
+	}
+	if _, _, _, err := issueBindCredential(client, "default", name, "admin-binding", bindRoleAdmin); err != nil {
+		t.Fatalf("issueBindCredential: %v", err)
+	}
+
+	if err := revokeBasicAuthCredential(client, "default", name, "admin-binding", false); err != nil {
+		t.Fatalf("revokeBasicAuthCredential: %v", err)
+	}
+
+	sameUser, samePass, err := readAdminCredential(client, "default", name)
+	if err != nil {
+		t.Fatalf("readAdminCredential: %v", err)
+	}
+	if sameUser != originalUser || samePass != originalPass {
+		t.Error("expected the admin credential to be left alone")
+	}
+}
+
+func TestResolveRotateAdminPasswordAbsent(t *testing.T) {
+	rotate, err := resolveRotateAdminPassword(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("resolveRotateAdminPassword: %v", err)
+	}
+	if rotate {
+		t.Error("expected rotate=false when rotateAdminPassword is not set")
+	}
+}
+
+func TestResolveRotateAdminPasswordRejectsNonBool(t *testing.T) {
+	if _, err := resolveRotateAdminPassword(map[string]interface{}{"rotateAdminPassword": "yes"}); err == nil {
+		t.Fatal("expected an error for a non-boolean rotateAdminPassword")
+	}
+}
+
+func TestRotateAdminCredentialIssuesNewCredentialAndInvalidatesOldOne(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	name, err := createBasicAuthSecret(client, "default", "test", nil, nil)
+	if err != nil {
+		t.Fatalf("createBasicAuthSecret: %v", err)
+	}
+	originalUser, originalPass, err := readAdminCredential(client, "default", name)
+	if err != nil {
+		t.Fatalf("readAdminCredential: %v", err)
+	}
+
+	newUser, newPass, err := rotateAdminCredential(client, "default", name)
+	if err != nil {
+		t.Fatalf("rotateAdminCredential: %v", err)
+	}
+	if newUser == originalUser && newPass == originalPass {
+		t.Error("expected a freshly generated admin credential")
+	}
+
+	rotatedUser, rotatedPass, err := readAdminCredential(client, "default", name)
+	if err != nil {
+		t.Fatalf("readAdminCredential: %v", err)
+	}
+	if rotatedUser != newUser || rotatedPass != newPass {
+		t.Errorf("readAdminCredential = (%q, %q), want the rotated (%q, %q)", rotatedUser, rotatedPass, newUser, newPass)
+	}
+
+	secret, err := client.Core().Secrets("default").Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to look up secret: %v", err)
+	}
+	htpasswd := string(secret.Data[basicAuthHtpasswdKey])
+	if strings.Contains(htpasswd, originalUser+":") {
+		t.Error("expected the old admin credential to no longer appear in htpasswd")
+	}
+}
+
+func TestRotateAdminCredentialLeavesViewerBindingsIntact(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	name, err := createBasicAuthSecret(client, "default", "test", nil, nil)
+	if err != nil {
+		t.Fatalf("createBasicAuthSecret: %v", err)
+	}
+	_, aliceUser, alicePass, err := issueBindCredential(client, "default", name, "alice-binding", bindRoleViewer)
+	if err != nil {
+		t.Fatalf("issueBindCredential: %v", err)
+	}
+
+	if _, _, err := rotateAdminCredential(client, "default", name); err != nil {
+		t.Fatalf("rotateAdminCredential: %v", err)
+	}
+
+	role, user, pass, err := issueBindCredential(client, "default", name, "alice-binding", bindRoleViewer)
+	if err != nil {
+		t.Fatalf("issueBindCredential: %v", err)
+	}
+	if role != bindRoleViewer || user != aliceUser || pass != alicePass {
+		t.Error("expected alice-binding's viewer credential to survive an admin rotation")
+	}
+}
+
+func TestCreateBasicAuthSecretPopulatesAdminCredentialAndHtpasswd(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	name, err := createBasicAuthSecret(client, "default", "test", nil, nil)
+	if err != nil {
+		t.Fatalf("createBasicAuthSecret: %v", err)
+	}
+
+	username, password, err := readAdminCredential(client, "default", name)
+	if err != nil {
+		t.Fatalf("readAdminCredential: %v", err)
+	}
+	if username == "" || password == "" {
+		t.Errorf("readAdminCredential = (%q, %q), want a generated admin username and password", username, password)
+	}
+
+	secret, err := client.Core().Secrets("default").Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to look up created secret: %v", err)
+	}
+	if len(secret.Data[basicAuthHtpasswdKey]) == 0 {
+		t.Error("expected the htpasswd file to be rendered from the admin credential, not left empty")
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	autoscalingv1 "k8s.io/client-go/pkg/apis/autoscaling/v1"
+)
+
+// autoscalePlanID identifies the plan under which an instance's replica
+// count is managed by a HorizontalPodAutoscaler instead of the fixed
+// "replicas" provision/update parameter.
+const autoscalePlanID = "d4d4d4d4-4d4d-4d4d-4d4d-4d4d4d4d4d4d"
+
+const (
+	defaultMinReplicas                = 1
+	defaultMaxReplicas                = 10
+	defaultTargetCPUUtilization int32 = 80
+)
+
+// autoscaleParams is the min/max replicas and target CPU utilization an
+// autoscale plan instance's HorizontalPodAutoscaler is created with.
+type autoscaleParams struct {
+	MinReplicas                    int32
+	MaxReplicas                    int32
+	TargetCPUUtilizationPercentage int32
+}
+
+// resolveAutoscaleParams reads the "minReplicas"/"maxReplicas"/
+// "targetCPUUtilizationPercentage" provision/update parameters, applying the
+// defaults an unset parameter is left at.
+func resolveAutoscaleParams(params map[string]interface{}) (autoscaleParams, error) {
+	result := autoscaleParams{
+		MinReplicas:                    defaultMinReplicas,
+		MaxReplicas:                    defaultMaxReplicas,
+		TargetCPUUtilizationPercentage: defaultTargetCPUUtilization,
+	}
+
+	whole := func(name string) (int32, bool, error) {
+		v, present := params[name]
+		if !present {
+			return 0, false, nil
+		}
+		n, isNumber := v.(float64)
+		if !isNumber || n != float64(int32(n)) {
+			return 0, false, fmt.Errorf("%s must be a whole number", name)
+		}
+		return int32(n), true, nil
+	}
+
+	if n, ok, err := whole("minReplicas"); err != nil {
+		return autoscaleParams{}, err
+	} else if ok {
+		result.MinReplicas = n
+	}
+	if n, ok, err := whole("maxReplicas"); err != nil {
+		return autoscaleParams{}, err
+	} else if ok {
+		result.MaxReplicas = n
+	}
+	if n, ok, err := whole("targetCPUUtilizationPercentage"); err != nil {
+		return autoscaleParams{}, err
+	} else if ok {
+		result.TargetCPUUtilizationPercentage = n
+	}
+
+	if result.MinReplicas < 1 {
+		return autoscaleParams{}, fmt.Errorf("minReplicas must be at least 1")
+	}
+	if result.MaxReplicas < result.MinReplicas {
+		return autoscaleParams{}, fmt.Errorf("maxReplicas must be at least minReplicas")
+	}
+	if result.TargetCPUUtilizationPercentage < 1 || result.TargetCPUUtilizationPercentage > 100 {
+		return autoscaleParams{}, fmt.Errorf("targetCPUUtilizationPercentage must be between 1 and 100")
+	}
+	return result, nil
+}
+
+// autoscalerName names the HorizontalPodAutoscaler that manages an autoscale
+// plan instance's replica count. It shares the Deployment's name, since a
+// namespace has at most one HPA per instance and the two are always looked
+// up together.
+func autoscalerName(instanceID string) string {
+	return deploymentName(instanceID)
+}
+
+// createNginXAutoscaler creates the HorizontalPodAutoscaler that targets
+// instanceID's Deployment.
+func createNginXAutoscaler(client kubernetes.Interface, namespace, instanceID string, params autoscaleParams, labels, annotations map[string]string) (string, error) {
+	targetCPU := params.TargetCPUUtilizationPercentage
+	minReplicas := params.MinReplicas
+	hpa := &autoscalingv1.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        autoscalerName(instanceID),
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{
+				Kind:       "Deployment",
+				Name:       deploymentName(instanceID),
+				APIVersion: "apps/v1beta1",
+			},
+			MinReplicas:                    &minReplicas,
+			MaxReplicas:                    params.MaxReplicas,
+			TargetCPUUtilizationPercentage: &targetCPU,
+		},
+	}
+	if _, err := client.Autoscaling().HorizontalPodAutoscalers(namespace).Create(hpa); err != nil {
+		return "", fmt.Errorf("failed to create nginx instance autoscaler: %v", err)
+	}
+	return hpa.Name, nil
+}
+
+// nginxReplicaStatus is the current/desired replica counts surfaced in an
+// instance's status, read from its Deployment and, for an autoscale plan
+// instance, its HorizontalPodAutoscaler.
+type nginxReplicaStatus struct {
+	CurrentReplicas int32
+	DesiredReplicas int32
+	MinReplicas     int32
+	MaxReplicas     int32
+}
+
+// readNginXReplicaStatus reads instance's current replica counts from its
+// Deployment, and, when it has one, its HorizontalPodAutoscaler's min/max
+// bounds. A missing HPA is not an error: it just means the instance isn't on
+// the autoscale plan.
+func readNginXReplicaStatus(client kubernetes.Interface, namespace string, instance *nginxServiceInstance) (*nginxReplicaStatus, error) {
+	deployment, err := client.Apps().Deployments(namespace).Get(instance.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up nginx instance deployment: %v", err)
+	}
+
+	status := &nginxReplicaStatus{
+		CurrentReplicas: deployment.Status.AvailableReplicas,
+		DesiredReplicas: *deployment.Spec.Replicas,
+	}
+
+	if instance.AutoscalerName == "" {
+		return status, nil
+	}
+	hpa, err := client.Autoscaling().HorizontalPodAutoscalers(namespace).Get(instance.AutoscalerName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up nginx instance autoscaler: %v", err)
+	}
+	if hpa.Spec.MinReplicas != nil {
+		status.MinReplicas = *hpa.Spec.MinReplicas
+	}
+	status.MaxReplicas = hpa.Spec.MaxReplicas
+	if hpa.Status.DesiredReplicas != 0 {
+		status.DesiredReplicas = hpa.Status.DesiredReplicas
+	}
+	return status, nil
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+)
+
+func TestResolveAutoscaleParamsDefaults(t *testing.T) {
+	params, err := resolveAutoscaleParams(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("resolveAutoscaleParams: %v", err)
+	}
+	if params.MinReplicas != defaultMinReplicas || params.MaxReplicas != defaultMaxReplicas ||
+		params.TargetCPUUtilizationPercentage != defaultTargetCPUUtilization {
+		t.Errorf("params = %+v, want the defaults", params)
+	}
+}
+
+func TestResolveAutoscaleParamsOverrides(t *testing.T) {
+	params, err := resolveAutoscaleParams(map[string]interface{}{
+		"minReplicas":                    float64(2),
+		"maxReplicas":                    float64(5),
+		"targetCPUUtilizationPercentage": float64(50),
+	})
+	if err != nil {
+		t.Fatalf("resolveAutoscaleParams: %v", err)
+	}
+	if params.MinReplicas != 2 || params.MaxReplicas != 5 || params.TargetCPUUtilizationPercentage != 50 {
+		t.Errorf("params = %+v, want {2 5 50}", params)
+	}
+}
+
+func TestResolveAutoscaleParamsRejectsNonWholeNumber(t *testing.T) {
+	if _, err := resolveAutoscaleParams(map[string]interface{}{"minReplicas": float64(1.5)}); err == nil {
+		t.Fatal("expected an error for a non-whole minReplicas value")
+	}
+}
+
+func TestResolveAutoscaleParamsRejectsMinBelowOne(t *testing.T) {
+	if _, err := resolveAutoscaleParams(map[string]interface{}{"minReplicas": float64(0)}); err == nil {
+		t.Fatal("expected an error for a minReplicas value below 1")
+	}
+}
+
+func TestResolveAutoscaleParamsRejectsMaxBelowMin(t *testing.T) {
+	if _, err := resolveAutoscaleParams(map[string]interface{}{"minReplicas": float64(5), "maxReplicas": float64(3)}); err == nil {
+		t.Fatal("expected an error when maxReplicas is below minReplicas")
+	}
+}
+
+func TestResolveAutoscaleParamsRejectsTargetOutOfRange(t *testing.T) {
+	if _, err := resolveAutoscaleParams(map[string]interface{}{"targetCPUUtilizationPercentage": float64(0)}); err == nil {
+		t.Fatal("expected an error for a targetCPUUtilizationPercentage below 1")
+	}
+	if _, err := resolveAutoscaleParams(map[string]interface{}{"targetCPUUtilizationPercentage": float64(101)}); err == nil {
+		t.Fatal("expected an error for a targetCPUUtilizationPercentage above 100")
+	}
+}
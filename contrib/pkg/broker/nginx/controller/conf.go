@@ -0,0 +1,158 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	confVolumeName = "conf"
+	confMountPath  = "/etc/nginx/conf.d/default.conf"
+	confKey        = "default.conf"
+
+	// confHashAnnotation records the fingerprint of the nginx.conf a pod
+	// template was created against, for the same reason contentHashAnnotation
+	// does: the PodSpec doesn't change when only the ConfigMap's contents do,
+	// so without it a conf-only update wouldn't roll the Deployment.
+	confHashAnnotation = "nginx.service-catalog.k8s.io/conf-hash"
+)
+
+// forbiddenConfDirectives are nginx.conf directives that reach outside the
+// sandbox a provisioned instance is meant to run in, either by loading
+// arbitrary code into the worker process or by changing who it runs as.
+var forbiddenConfDirectives = []string{"load_module", "user"}
+
+// resolveConf validates the optional "nginxConf" provision/update
+// parameter. ok is false when the caller didn't set one, in which case the
+// image's stock default.conf is left in place.
+func resolveConf(params map[string]interface{}) (conf string, ok bool, err error) {
+	v, present := params["nginxConf"]
+	if !present {
+		return "", false, nil
+	}
+	conf, isString := v.(string)
+	if !isString {
+		return "", false, fmt.Errorf("nginxConf must be a string")
+	}
+	if err := validateNginXConf(conf); err != nil {
+		return "", false, err
+	}
+	return conf, true, nil
+}
+
+// validateNginXConf runs a syntax sanity check over a user-supplied
+// nginx.conf: it must be non-empty, its braces must balance, and it must
+// not use a directive from forbiddenConfDirectives. This catches obviously
+// broken or dangerous input before it's ever rolled out to a pod; it is not
+// a substitute for the readiness wait that follows a rollout, which is what
+// catches a config that's well-formed but still crashes nginx.
+func validateNginXConf(conf string) error {
+	if strings.TrimSpace(conf) == "" {
+		return fmt.Errorf("nginxConf must not be empty")
+	}
+	if open, close := strings.Count(conf, "{"), strings.Count(conf, "}"); open != close {
+		return fmt.Errorf("nginxConf has unbalanced braces (%d \"{\" vs %d \"}\")", open, close)
+	}
+	for _, line := range strings.Split(conf, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		directive := strings.TrimSuffix(fields[0], ";")
+		for _, forbidden := range forbiddenConfDirectives {
+			if directive == forbidden {
+				return fmt.Errorf("nginxConf must not use the %q directive", forbidden)
+			}
+		}
+	}
+	return nil
+}
+
+func confConfigMapName(instanceID string) string {
+	return names.InstanceResourceName(brokerName, instanceID, "conf")
+}
+
+// createConfConfigMap stores conf as instanceID's nginx.conf ConfigMap,
+// returning its name and a fingerprint of its contents.
+func createConfConfigMap(client kubernetes.Interface, namespace, instanceID, conf string, labels, annotations map[string]string) (name, hash string, err error) {
+	name = confConfigMapName(instanceID)
+	data := map[string]string{confKey: conf}
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Data: data,
+	}
+	if _, err := client.Core().ConfigMaps(namespace).Create(cm); err != nil {
+		return "", "", fmt.Errorf("failed to create nginx conf config map: %v", err)
+	}
+	return name, contentHash(data), nil
+}
+
+// updateConfConfigMap re-renders conf into instanceID's existing nginx.conf
+// ConfigMap, returning the new fingerprint.
+func updateConfConfigMap(client kubernetes.Interface, namespace, instanceID, conf string) (string, error) {
+	name := confConfigMapName(instanceID)
+	cm, err := client.Core().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up nginx conf config map: %v", err)
+	}
+	cm.Data = map[string]string{confKey: conf}
+
+	if _, err := client.Core().ConfigMaps(namespace).Update(cm); err != nil {
+		return "", fmt.Errorf("failed to update nginx conf config map: %v", err)
+	}
+	return contentHash(cm.Data), nil
+}
+
+// setConfHashAnnotation stamps a pod template with hash, so that changing
+// only the conf ConfigMap's contents still triggers a rollout.
+func setConfHashAnnotation(template *v1.PodTemplateSpec, hash string) {
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[confHashAnnotation] = hash
+}
+
+// attachConf mounts an instance's nginx.conf ConfigMap over the container's
+// default server block, leaving the rest of /etc/nginx/conf.d untouched.
+func attachConf(spec *v1.PodSpec, configMapName string) {
+	spec.Volumes = append(spec.Volumes, v1.Volume{
+		Name: confVolumeName,
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: configMapName},
+			},
+		},
+	})
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts,
+			v1.VolumeMount{Name: confVolumeName, MountPath: confMountPath, SubPath: confKey},
+		)
+	}
+}
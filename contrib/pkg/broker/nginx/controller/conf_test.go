@@ -0,0 +1,125 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+)
+
+func TestValidateNginXConf(t *testing.T) {
+	cases := []struct {
+		name    string
+		conf    string
+		wantErr bool
+	}{
+		{
+			name:    "valid server block",
+			conf:    "server {\n    listen 80;\n    location / {\n        return 200;\n    }\n}",
+			wantErr: false,
+		},
+		{
+			name:    "empty",
+			conf:    "",
+			wantErr: true,
+		},
+		{
+			name:    "blank",
+			conf:    "   \n\t  ",
+			wantErr: true,
+		},
+		{
+			name:    "unbalanced braces missing close",
+			conf:    "server {\n    listen 80;",
+			wantErr: true,
+		},
+		{
+			name:    "unbalanced braces extra close",
+			conf:    "server {\n    listen 80;\n}\n}",
+			wantErr: true,
+		},
+		{
+			name:    "forbidden load_module directive",
+			conf:    "load_module modules/ngx_stream_module.so;\nserver {\n    listen 80;\n}",
+			wantErr: true,
+		},
+		{
+			name:    "forbidden user directive",
+			conf:    "user root;\nserver {\n    listen 80;\n}",
+			wantErr: true,
+		},
+		{
+			name:    "comment mentioning a forbidden directive is allowed",
+			conf:    "# load_module isn't allowed here\nserver {\n    listen 80;\n}",
+			wantErr: false,
+		},
+		{
+			name:    "directive name as a substring of another token is allowed",
+			conf:    "server {\n    listen 80;\n    user_agent_directive off;\n}",
+			wantErr: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateNginXConf(c.conf)
+			if c.wantErr && err == nil {
+				t.Errorf("validateNginXConf(%q) = nil, want an error", c.conf)
+			}
+			if !c.wantErr && err != nil {
+				t.Errorf("validateNginXConf(%q) = %v, want nil", c.conf, err)
+			}
+		})
+	}
+}
+
+func TestResolveConfAbsent(t *testing.T) {
+	conf, ok, err := resolveConf(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("resolveConf: %v", err)
+	}
+	if ok {
+		t.Error("expected ok=false when nginxConf is not set")
+	}
+	if conf != "" {
+		t.Errorf("conf = %q, want empty", conf)
+	}
+}
+
+func TestResolveConfValid(t *testing.T) {
+	conf, ok, err := resolveConf(map[string]interface{}{"nginxConf": "server {\n    listen 80;\n}"})
+	if err != nil {
+		t.Fatalf("resolveConf: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected ok=true when nginxConf is set")
+	}
+	if conf != "server {\n    listen 80;\n}" {
+		t.Errorf("conf = %q, want the input conf back", conf)
+	}
+}
+
+func TestResolveConfRejectsNonString(t *testing.T) {
+	if _, _, err := resolveConf(map[string]interface{}{"nginxConf": 5}); err == nil {
+		t.Fatal("expected an error for a non-string nginxConf")
+	}
+}
+
+func TestResolveConfRejectsInvalidConf(t *testing.T) {
+	if _, _, err := resolveConf(map[string]interface{}{"nginxConf": "server {"}); err == nil {
+		t.Fatal("expected an error for an unbalanced nginxConf")
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	contentVolumeName = "content"
+	contentMountPath  = "/usr/share/nginx/html"
+
+	// defaultMaxContentSize keeps a generated content ConfigMap safely
+	// under Kubernetes' 1MiB ConfigMap size cap.
+	defaultMaxContentSize = 900 * 1024
+
+	// contentHashAnnotation records the fingerprint of the content
+	// ConfigMap a pod template was created against. The Deployment's
+	// PodSpec never changes when only the ConfigMap's contents do, so
+	// without this annotation a content-only update would leave the
+	// running pod serving the old content.
+	contentHashAnnotation = "nginx.service-catalog.k8s.io/content-hash"
+)
+
+// resolveContent extracts the "indexHtml" and "files" provision/update
+// parameters into a flat filename-to-content map. ok is false when neither
+// parameter is set, in which case provisioning leaves the stock nginx
+// welcome page in place. The combined size of the returned files is
+// checked against maxContentSize to stay clear of the ConfigMap size cap.
+func resolveContent(params map[string]interface{}, maxContentSize int) (files map[string]string, ok bool, err error) {
+	_, hasIndexHTML := params["indexHtml"]
+	_, hasFiles := params["files"]
+	if !hasIndexHTML && !hasFiles {
+		return nil, false, nil
+	}
+
+	files = map[string]string{}
+	if hasIndexHTML {
+		indexHTML, isString := params["indexHtml"].(string)
+		if !isString {
+			return nil, false, fmt.Errorf("indexHtml must be a string")
+		}
+		files["index.html"] = indexHTML
+	}
+
+	if hasFiles {
+		raw, isMap := params["files"].(map[string]interface{})
+		if !isMap {
+			return nil, false, fmt.Errorf("files must be a map of filename to content")
+		}
+		for name, v := range raw {
+			content, isString := v.(string)
+			if !isString {
+				return nil, false, fmt.Errorf("files[%q] must be a string", name)
+			}
+			if err := validateContentFilename(name); err != nil {
+				return nil, false, err
+			}
+			files[name] = content
+		}
+	}
+
+	total := 0
+	for name, content := range files {
+		total += len(name) + len(content)
+	}
+	if total > maxContentSize {
+		return nil, false, fmt.Errorf("total content size %d exceeds the %d byte limit", total, maxContentSize)
+	}
+	return files, true, nil
+}
+
+// validateContentFilename rejects a files key that isn't a plain filename,
+// since it becomes a ConfigMap data key and, ultimately, a file name under
+// contentMountPath.
+func validateContentFilename(name string) error {
+	if name == "" || name != path.Base(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid content filename %q", name)
+	}
+	return nil
+}
+
+func contentConfigMapName(instanceID string) string {
+	return names.InstanceResourceName(brokerName, instanceID, "content")
+}
+
+// createContentConfigMap stores files as instanceID's content ConfigMap,
+// returning its name and a fingerprint of its contents.
+func createContentConfigMap(client kubernetes.Interface, namespace, instanceID string, files map[string]string, labels, annotations map[string]string) (name, hash string, err error) {
+	name = contentConfigMapName(instanceID)
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Data: files,
+	}
+	if _, err := client.Core().ConfigMaps(namespace).Create(cm); err != nil {
+		return "", "", fmt.Errorf("failed to create nginx content config map: %v", err)
+	}
+	return name, contentHash(files), nil
+}
+
+// updateContentConfigMap re-renders files into instanceID's existing
+// content ConfigMap, returning the new fingerprint.
+func updateContentConfigMap(client kubernetes.Interface, namespace, instanceID string, files map[string]string) (string, error) {
+	name := contentConfigMapName(instanceID)
+	cm, err := client.Core().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up nginx content config map: %v", err)
+	}
+	cm.Data = files
+
+	if _, err := client.Core().ConfigMaps(namespace).Update(cm); err != nil {
+		return "", fmt.Errorf("failed to update nginx content config map: %v", err)
+	}
+	return contentHash(files), nil
+}
+
+// contentHash fingerprints files for use in contentHashAnnotation.
+func contentHash(files map[string]string) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(files[name]))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// setContentHashAnnotation stamps a pod template with hash, so that
+// changing only the content ConfigMap's contents still triggers a rollout.
+func setContentHashAnnotation(template *v1.PodTemplateSpec, hash string) {
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[contentHashAnnotation] = hash
+}
+
+// attachContent mounts an instance's content ConfigMap over nginx's static
+// content directory.
+func attachContent(spec *v1.PodSpec, configMapName string) {
+	spec.Volumes = append(spec.Volumes, v1.Volume{
+		Name: contentVolumeName,
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: configMapName},
+			},
+		},
+	})
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts,
+			v1.VolumeMount{Name: contentVolumeName, MountPath: contentMountPath},
+		)
+	}
+}
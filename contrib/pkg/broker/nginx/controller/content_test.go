@@ -0,0 +1,128 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+)
+
+func TestResolveContentAbsent(t *testing.T) {
+	files, ok, err := resolveContent(map[string]interface{}{}, defaultMaxContentSize)
+	if err != nil {
+		t.Fatalf("resolveContent: %v", err)
+	}
+	if ok {
+		t.Error("expected ok=false when neither indexHtml nor files is set")
+	}
+	if files != nil {
+		t.Errorf("files = %v, want nil", files)
+	}
+}
+
+func TestResolveContentIndexHTMLOnly(t *testing.T) {
+	files, ok, err := resolveContent(map[string]interface{}{"indexHtml": "<html></html>"}, defaultMaxContentSize)
+	if err != nil {
+		t.Fatalf("resolveContent: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected ok=true when indexHtml is set")
+	}
+	if files["index.html"] != "<html></html>" {
+		t.Errorf("files[index.html] = %q, want <html></html>", files["index.html"])
+	}
+}
+
+func TestResolveContentFilesOnly(t *testing.T) {
+	files, ok, err := resolveContent(map[string]interface{}{
+		"files": map[string]interface{}{"style.css": "body {}"},
+	}, defaultMaxContentSize)
+	if err != nil {
+		t.Fatalf("resolveContent: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected ok=true when files is set")
+	}
+	if files["style.css"] != "body {}" {
+		t.Errorf("files[style.css] = %q, want body {}", files["style.css"])
+	}
+}
+
+func TestResolveContentCombined(t *testing.T) {
+	files, ok, err := resolveContent(map[string]interface{}{
+		"indexHtml": "<html></html>",
+		"files":     map[string]interface{}{"style.css": "body {}"},
+	}, defaultMaxContentSize)
+	if err != nil {
+		t.Fatalf("resolveContent: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected ok=true when indexHtml and files are set")
+	}
+	if len(files) != 2 {
+		t.Errorf("len(files) = %d, want 2", len(files))
+	}
+}
+
+func TestResolveContentRejectsNonStringIndexHTML(t *testing.T) {
+	if _, _, err := resolveContent(map[string]interface{}{"indexHtml": 5}, defaultMaxContentSize); err == nil {
+		t.Fatal("expected an error for a non-string indexHtml")
+	}
+}
+
+func TestResolveContentRejectsNonMapFiles(t *testing.T) {
+	if _, _, err := resolveContent(map[string]interface{}{"files": "not a map"}, defaultMaxContentSize); err == nil {
+		t.Fatal("expected an error for a non-map files")
+	}
+}
+
+func TestResolveContentRejectsNonStringFileContent(t *testing.T) {
+	if _, _, err := resolveContent(map[string]interface{}{
+		"files": map[string]interface{}{"style.css": 5},
+	}, defaultMaxContentSize); err == nil {
+		t.Fatal("expected an error for a non-string file content")
+	}
+}
+
+func TestResolveContentRejectsPathTraversal(t *testing.T) {
+	if _, _, err := resolveContent(map[string]interface{}{
+		"files": map[string]interface{}{"../etc/passwd": "x"},
+	}, defaultMaxContentSize); err == nil {
+		t.Fatal("expected an error for a path traversal filename")
+	}
+}
+
+func TestResolveContentRejectsEmptyFilename(t *testing.T) {
+	if _, _, err := resolveContent(map[string]interface{}{
+		"files": map[string]interface{}{"": "x"},
+	}, defaultMaxContentSize); err == nil {
+		t.Fatal("expected an error for an empty filename")
+	}
+}
+
+func TestResolveContentRejectsDotPrefixedFilename(t *testing.T) {
+	if _, _, err := resolveContent(map[string]interface{}{
+		"files": map[string]interface{}{".hidden": "x"},
+	}, defaultMaxContentSize); err == nil {
+		t.Fatal("expected an error for a dot-prefixed filename")
+	}
+}
+
+func TestResolveContentRejectsOversizedContent(t *testing.T) {
+	if _, _, err := resolveContent(map[string]interface{}{"indexHtml": "abcdef"}, 3); err == nil {
+		t.Fatal("expected an error when content exceeds maxContentSize")
+	}
+}
@@ -0,0 +1,1806 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package controller implements a broker that provisions nginx, a simple
+// web server, as a Deployment in the cluster the broker is running in. It
+// exists mainly as a lightweight demo service alongside the mongodb and
+// heketi brokers.
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/reqlog"
+	"github.com/kubernetes-incubator/service-catalog/pkg"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/pkg/api"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// brokerName identifies this broker in the "broker" label applied to every
+// Kubernetes object it creates.
+const brokerName = "nginx"
+
+const (
+	serviceID = "f9f9f9f9-9f9f-4f9f-9f9f-9f9f9f9f9f9f"
+	planID    = "a1a1a1a1-1a1a-1a1a-1a1a-1a1a1a1a1a1a"
+
+	nginxPort = 80
+
+	nginxContainerName = "nginx"
+	nginxPortName      = "nginx"
+)
+
+const defaultNamespace = "default"
+
+// defaultServiceAccount names the service account this controller reports
+// itself running as when Options.ServiceAccount is unset.
+const defaultServiceAccount = "default"
+
+type nginxServiceInstance struct {
+	ID                   string
+	Namespace            string
+	DeploymentName       string
+	ServiceName          string
+	IngressName          string
+	IngressHost          string
+
+	// RouteName is set instead of IngressName when this broker is running
+	// with Options.Platform set to kube.PlatformOpenShift, naming the Route
+	// created in place of an Ingress. IngressHost still holds its host.
+	RouteName string
+
+	ContentConfigMapName string
+	ConfConfigMapName    string
+
+	// TLSSecretName and TLSConfConfigMapName are set for an https plan
+	// instance; TLSExpiry then holds its generated certificate's expiry.
+	TLSSecretName        string
+	TLSConfConfigMapName string
+	TLSExpiry            time.Time
+
+	// BasicAuthSecretName is set for a basic-auth plan instance. It never
+	// holds a binding's actual username/password: those live only in the
+	// secret it names, read back out by issueBindCredential on Bind.
+	BasicAuthSecretName string
+
+	// ConfIsBasicAuthDefault is true when ConfConfigMapName holds the server
+	// block UpdateServiceInstance generated to enforce auth for a switch
+	// into the basic-auth plan, rather than a caller's own nginxConf. It's
+	// cleaned up on a later switch away from basic-auth; a caller-supplied
+	// nginxConf is left alone.
+	ConfIsBasicAuthDefault bool
+
+	// AutoscalerName is set for an autoscale plan instance, naming the
+	// HorizontalPodAutoscaler that manages its replica count in place of the
+	// "replicas" provision/update parameter.
+	AutoscalerName string
+
+	// Image is the resolved image, including tag, this instance's
+	// Deployment was created with: the broker's configured default,
+	// overridden by the imageTag provision parameter when given.
+	Image string
+
+	// GitRepo, GitRef, and GitSecretName are set for an instance provisioned
+	// with the gitRepo parameter: content is cloned into the instance by an
+	// init container rather than served from ContentConfigMapName.
+	GitRepo       string
+	GitRef        string
+	GitSecretName string
+
+	// ExposeNodePort is true when the instance's Service was created with
+	// expose: nodeport rather than the default ClusterIP type; NodePort
+	// then holds the node port it was assigned.
+	ExposeNodePort bool
+	NodePort       int32
+
+	// LogSidecar is true when the instance was provisioned with
+	// logSidecar: true, and so runs a second, logSidecarContainerName
+	// container tailing its access log.
+	LogSidecar bool
+
+	// Phase is the instance's most recently derived rollout phase, one of
+	// phaseCreatingResources, "rolling out (n/m available)", phaseReady, or
+	// a failedPhasePrefix-prefixed failure. GetServiceInstanceLastOperation
+	// refreshes it from the Deployment's live status while it isn't yet
+	// terminal, and answers straight from it once it is.
+	Phase string
+
+	// Conditions is this instance's Kubernetes-style status conditions, kept
+	// alongside Phase for tooling that wants to key off a stable set of
+	// condition types instead of parsing the free-form phase strings.
+	// Reported through SnapshotState.
+	Conditions []controller.Condition
+
+	// Port is the container port nginx listens on, set from the port
+	// provision/update parameter and defaulting to nginxPort.
+	Port int32
+
+	// NetworkPolicyName names the NetworkPolicy restricting ingress to
+	// this instance's pods, created when the allowedNamespaces provision
+	// or update parameter is set. Empty when no NetworkPolicy exists.
+	NetworkPolicyName string
+
+	// Upstream is the in-cluster (or, with --allow-external-proxy,
+	// external) URL this instance reverse-proxies to, set from the
+	// proxyPass provision/update parameter. Empty when the instance
+	// serves static content instead.
+	Upstream string
+
+	// ConfIsProxyDefault is true when ConfConfigMapName holds the server
+	// block generated for Upstream rather than a caller's own nginxConf,
+	// mirroring ConfIsBasicAuthDefault.
+	ConfIsProxyDefault bool
+
+	// CreatedAt is when this instance was provisioned, reported through
+	// SnapshotState for age-based metrics and admin output.
+	CreatedAt time.Time
+
+	// Bindings tracks which bindingIDs currently have a live binding
+	// against this instance, so SnapshotState can report a binding count.
+	Bindings map[string]struct{}
+}
+
+type nginxController struct {
+	rwMutex     sync.RWMutex
+	instanceMap map[string]*nginxServiceInstance
+
+	// maxContentSize bounds the combined size of the indexHtml/files
+	// provision and update parameters.
+	maxContentSize int
+
+	// readinessTimeout bounds how long CreateServiceInstance waits, in
+	// synchronous mode, for a freshly created instance to become available.
+	readinessTimeout time.Duration
+
+	// nginxImage is the image, including tag, run for instances that don't
+	// override it with the imageTag provision parameter. Defaults to
+	// defaultNginxImage.
+	nginxImage string
+
+	// imagePullSecret is the name of a secret, in brokerNamespace, used by
+	// default to pull the nginx image.
+	imagePullSecret string
+
+	// brokerNamespace is the namespace this broker's own pod runs in,
+	// where imagePullSecret is looked up.
+	brokerNamespace string
+
+	// allowAdminBind lets a Bind request opt into receiving the shared
+	// basic-auth admin credential, via a "role: admin" bind parameter,
+	// instead of a per-binding credential.
+	allowAdminBind bool
+
+	// rotateOnAdminUnbind rotates a basic-auth plan instance's shared admin
+	// credential whenever an admin-role binding is unbound, invalidating
+	// it for every other admin binding on the instance.
+	rotateOnAdminUnbind bool
+
+	// keepFailedInstances skips rollback of a failed provisioning attempt's
+	// Kubernetes objects, leaving them in place for debugging instead of
+	// tearing them down.
+	keepFailedInstances bool
+
+	// gitImage is the image, including a git binary, run as the init
+	// container that clones a gitRepo provision parameter into an
+	// instance's content volume. Defaults to defaultGitImage.
+	gitImage string
+
+	// logSidecarImage is the image run as the second container that tails
+	// an instance's access log when it's provisioned with logSidecar: true.
+	// Defaults to defaultLogSidecarImage.
+	logSidecarImage string
+
+	// allowExternalProxy lets the proxyPass provision/update parameter
+	// name an upstream outside the cluster, instead of requiring an
+	// in-cluster service address.
+	allowExternalProxy bool
+
+	// allowedNamespaces restricts which namespaces an instance may be
+	// provisioned into. Empty means unrestricted.
+	allowedNamespaces map[string]bool
+
+	// defaultNamespace is used for a request whose context carries no
+	// namespace, when allowDefaultNamespace permits it.
+	defaultNamespace string
+
+	// allowDefaultNamespace gates falling back to defaultNamespace for a
+	// request that names no namespace, instead of rejecting it.
+	allowDefaultNamespace bool
+
+	// namespacePerInstance provisions every instance into a dedicated
+	// namespace this controller creates and owns, instead of the requesting
+	// namespace.
+	namespacePerInstance bool
+
+	// serviceAccount is the name of the service account this controller
+	// runs as, used only to name it in a kube.ErrForbidden message when the
+	// Kubernetes API rejects a request as forbidden.
+	serviceAccount string
+
+	// kubeClient is built once, at construction, and reused for every
+	// request instead of dialing a fresh clientset per call.
+	kubeClient kubernetes.Interface
+
+	// platform is kube.PlatformOpenShift when Options.Platform requested
+	// it, switching ingressHost exposure from an Ingress to a Route.
+	// Defaults to kube.PlatformKubernetes.
+	platform kube.Platform
+
+	// routeClient talks to the Route API and is non-nil only when platform
+	// is kube.PlatformOpenShift.
+	routeClient kube.RouteClient
+
+	// recorder publishes Kubernetes Events for instance lifecycle
+	// transitions, so an operator running `kubectl describe` on an
+	// instance's Deployment sees why it failed without digging through
+	// the broker's own logs.
+	recorder record.EventRecorder
+
+	// retryAttempts and retryBaseDelay configure kube.Retry for the calls
+	// this controller makes against the Kubernetes API.
+	retryAttempts  int
+	retryBaseDelay time.Duration
+
+	// provisionTimeout and bindTimeout bound how long a provision/deprovision
+	// or bind/unbind operation's context runs before it is canceled.
+	provisionTimeout time.Duration
+	bindTimeout      time.Duration
+
+	// podSpecOverride, when non-nil, is applied on top of every instance
+	// Deployment's pod template, loaded once at construction from
+	// Options.TemplatesDir.
+	podSpecOverride *kube.PodSpecOverride
+
+	// podLister, when non-nil, is read instead of listing Pods directly
+	// against the API for every pod status lookup. It's backed by a shared
+	// informer started at construction, unless Options.DisableInformers
+	// opted out of it.
+	podLister v1listers.PodLister
+}
+
+// Options carries the operator-configurable behavior of the nginx broker,
+// set from command-line flags by its caller.
+type Options struct {
+	// MaxContentSize bounds the combined size, in bytes, of the indexHtml
+	// and files provision/update parameters. Defaults to
+	// defaultMaxContentSize.
+	MaxContentSize int
+
+	// ReadinessTimeout bounds how long a synchronous CreateServiceInstance
+	// waits for the new instance to become available. Defaults to
+	// defaultReadinessTimeout.
+	ReadinessTimeout time.Duration
+
+	// NginxImage is the image, including tag, run for instances that don't
+	// override it with the imageTag provision parameter. Defaults to
+	// defaultNginxImage.
+	NginxImage string
+
+	// ImagePullSecret is the name of a secret, in BrokerNamespace, used by
+	// default to pull the nginx image.
+	ImagePullSecret string
+
+	// BrokerNamespace is the namespace this broker's own pod runs in,
+	// where ImagePullSecret is looked up. Defaults to $POD_NAMESPACE, or
+	// "default" if that isn't set either.
+	BrokerNamespace string
+
+	// AllowAdminBind lets a Bind request receive the shared basic-auth
+	// admin credential via a "role: admin" bind parameter, instead of a
+	// per-binding credential.
+	AllowAdminBind bool
+
+	// RotateOnAdminUnbind rotates a basic-auth plan instance's shared admin
+	// credential whenever an admin-role binding is unbound, invalidating
+	// it for every other admin binding on the instance.
+	RotateOnAdminUnbind bool
+
+	// KeepFailedInstances skips rollback of a failed provisioning attempt's
+	// Kubernetes objects, leaving them in place for debugging instead of
+	// tearing them down.
+	KeepFailedInstances bool
+
+	// GitImage is the image, including a git binary, run as the init
+	// container that clones a gitRepo provision parameter into an
+	// instance's content volume. Defaults to defaultGitImage.
+	GitImage string
+
+	// LogSidecarImage is the image run as the second container that tails
+	// an instance's access log when it's provisioned with logSidecar: true.
+	// Defaults to defaultLogSidecarImage.
+	LogSidecarImage string
+
+	// AllowExternalProxy lets the proxyPass provision/update parameter
+	// name an upstream outside the cluster, instead of requiring an
+	// in-cluster service address.
+	AllowExternalProxy bool
+
+	// AllowedNamespaces restricts which namespaces an instance may be
+	// provisioned into. Empty means unrestricted.
+	AllowedNamespaces []string
+
+	// DefaultNamespace is used for a request whose context carries no
+	// namespace, when AllowDefaultNamespace permits it. Defaults to
+	// "default".
+	DefaultNamespace string
+
+	// AllowDefaultNamespace lets a request that names no namespace fall
+	// back to DefaultNamespace, instead of being rejected.
+	AllowDefaultNamespace bool
+
+	// ServiceAccount is the name of the service account this broker runs
+	// as. It is used only to name it in an error naming the RBAC a
+	// forbidden request is missing; it is not used to authenticate.
+	// Defaults to "default".
+	ServiceAccount string
+
+	// PreflightDryRun has no effect beyond a one-time warning logged by
+	// CreateController: this broker's Kubernetes client cannot submit a
+	// dry-run create, so no preflight check runs and an admission webhook
+	// rejection still surfaces only on the real create.
+	PreflightDryRun bool
+
+	// NamespacePerInstance provisions every instance's Deployment into a
+	// dedicated namespace this controller creates and owns, instead of the
+	// requesting namespace, so quota and RBAC scoped to that namespace
+	// apply to exactly one instance. AllowedNamespaces and DefaultNamespace
+	// still gate the requesting namespace the request came in on.
+	NamespacePerInstance bool
+
+	// Platform is "kubernetes" (the default) or "openshift". On
+	// "openshift", an instance's ingressHost parameter creates a Route
+	// instead of an Ingress, since OpenShift clusters don't run an Ingress
+	// controller by default.
+	Platform string
+
+	// KubeconfigPath, when set, is used to build the Kubernetes client if
+	// the broker is not running in-cluster, for local development.
+	KubeconfigPath string
+
+	// KubeContext selects a context by name from the kubeconfig at
+	// KubeconfigPath, instead of its current-context. Setting it skips the
+	// in-cluster configuration, so the kubeconfig is loaded even in-cluster.
+	KubeContext string
+
+	// KubeAPIQPS and KubeAPIBurst cap the rate of Kubernetes API calls this
+	// broker's client makes, easing apiserver load in a large cluster.
+	// Zero keeps client-go's built-in defaults.
+	KubeAPIQPS   float32
+	KubeAPIBurst int
+
+	// RetryAttempts is the number of times a Kubernetes API call is
+	// attempted before giving up on a transient error. Defaults to
+	// kube.DefaultRetryAttempts.
+	RetryAttempts int
+
+	// RetryBaseDelay is the delay before the first retry of a Kubernetes
+	// API call that failed with a transient error, doubling on each
+	// subsequent retry. Defaults to kube.DefaultRetryBaseDelay.
+	RetryBaseDelay time.Duration
+
+	// ProvisionTimeout bounds how long a provision or deprovision operation
+	// runs before its context is canceled. Defaults to
+	// kube.DefaultProvisionTimeout.
+	ProvisionTimeout time.Duration
+
+	// BindTimeout bounds how long a bind or unbind operation runs before its
+	// context is canceled. Defaults to kube.DefaultBindTimeout.
+	BindTimeout time.Duration
+
+	// TemplatesDir, when set, is checked for an nginx-pod.yaml overriding
+	// the resource requests/limits, node selector, tolerations, and
+	// annotations of every instance's pod, without requiring a rebuild of
+	// the broker to tune them. A missing file falls back to the built-in
+	// shape; a malformed one fails CreateController.
+	TemplatesDir string
+
+	// DisableInformers falls back to a direct Pods List for every pod
+	// status lookup, instead of a shared informer's cache. Useful on a
+	// tiny cluster where the informer's List-and-Watch isn't worth the
+	// extra apiserver connection.
+	DisableInformers bool
+}
+
+// CreateController creates an instance of an nginx service broker
+// controller, building its Kubernetes client up front so a broken
+// in-cluster config or kubeconfig fails the broker at startup instead of
+// on its first request.
+func CreateController(opts Options) (controller.Controller, error) {
+	client, err := kube.NewClient(opts.KubeconfigPath, kube.ClientOptions{
+		Context:   opts.KubeContext,
+		QPS:       opts.KubeAPIQPS,
+		Burst:     opts.KubeAPIBurst,
+		UserAgent: fmt.Sprintf("%s/%s", brokerName, pkg.VERSION),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	allowedNamespaces := make(map[string]bool, len(opts.AllowedNamespaces))
+	for _, ns := range opts.AllowedNamespaces {
+		allowedNamespaces[ns] = true
+	}
+	defaultNS := opts.DefaultNamespace
+	if defaultNS == "" {
+		defaultNS = defaultNamespace
+	}
+	serviceAccount := opts.ServiceAccount
+	if serviceAccount == "" {
+		serviceAccount = defaultServiceAccount
+	}
+	if opts.PreflightDryRun {
+		kube.WarnPreflightDryRunUnsupported()
+	}
+	platform, err := kube.ParsePlatform(opts.Platform)
+	if err != nil {
+		return nil, err
+	}
+	var routeClient kube.RouteClient
+	if platform == kube.PlatformOpenShift {
+		restConfig, err := kube.RestConfig(opts.KubeconfigPath, opts.KubeContext)
+		if err != nil {
+			return nil, err
+		}
+		routeClient, err = kube.NewRouteClient(restConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	maxContentSize := opts.MaxContentSize
+	if maxContentSize <= 0 {
+		maxContentSize = defaultMaxContentSize
+	}
+	readinessTimeout := opts.ReadinessTimeout
+	if readinessTimeout <= 0 {
+		readinessTimeout = defaultReadinessTimeout
+	}
+	image := opts.NginxImage
+	if image == "" {
+		image = defaultNginxImage
+	}
+	gitImage := opts.GitImage
+	if gitImage == "" {
+		gitImage = defaultGitImage
+	}
+	logSidecarImage := opts.LogSidecarImage
+	if logSidecarImage == "" {
+		logSidecarImage = defaultLogSidecarImage
+	}
+	retryAttempts := opts.RetryAttempts
+	if retryAttempts <= 0 {
+		retryAttempts = kube.DefaultRetryAttempts
+	}
+	retryBaseDelay := opts.RetryBaseDelay
+	if retryBaseDelay <= 0 {
+		retryBaseDelay = kube.DefaultRetryBaseDelay
+	}
+	provisionTimeout := opts.ProvisionTimeout
+	if provisionTimeout <= 0 {
+		provisionTimeout = kube.DefaultProvisionTimeout
+	}
+	bindTimeout := opts.BindTimeout
+	if bindTimeout <= 0 {
+		bindTimeout = kube.DefaultBindTimeout
+	}
+	var podSpecOverride *kube.PodSpecOverride
+	if opts.TemplatesDir != "" {
+		podSpecOverride, err = kube.LoadPodSpecOverride(filepath.Join(opts.TemplatesDir, "nginx-pod.yaml"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	var podLister v1listers.PodLister
+	if !opts.DisableInformers {
+		podLister, err = kube.NewPodInformer(client, wait.NeverStop)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &nginxController{
+		instanceMap:           make(map[string]*nginxServiceInstance),
+		maxContentSize:        maxContentSize,
+		readinessTimeout:      readinessTimeout,
+		nginxImage:            image,
+		imagePullSecret:       opts.ImagePullSecret,
+		brokerNamespace:       kube.PodNamespace(opts.BrokerNamespace),
+		allowAdminBind:        opts.AllowAdminBind,
+		rotateOnAdminUnbind:   opts.RotateOnAdminUnbind,
+		keepFailedInstances:   opts.KeepFailedInstances,
+		gitImage:              gitImage,
+		logSidecarImage:       logSidecarImage,
+		allowExternalProxy:    opts.AllowExternalProxy,
+		allowedNamespaces:     allowedNamespaces,
+		defaultNamespace:      defaultNS,
+		allowDefaultNamespace: opts.AllowDefaultNamespace,
+		namespacePerInstance:  opts.NamespacePerInstance,
+		serviceAccount:        serviceAccount,
+		kubeClient:            client,
+		platform:              platform,
+		routeClient:           routeClient,
+		recorder:              kube.NewEventRecorder(client, brokerName),
+		retryAttempts:         retryAttempts,
+		retryBaseDelay:        retryBaseDelay,
+		provisionTimeout:      provisionTimeout,
+		bindTimeout:           bindTimeout,
+		podSpecOverride:       podSpecOverride,
+		podLister:             podLister,
+	}, nil
+}
+
+// resolveImagePullSecret returns the name of the secret to reference on the
+// instance's Deployment, copying a broker-namespace secret into the
+// instance namespace when necessary. It returns an error if the referenced
+// secret cannot be found, so that provisioning fails before the Deployment
+// is created rather than leaving it stuck ImagePullBackOff. brokerPullSecret
+// is the broker-wide default (the --image-pull-secret flag); an
+// imagePullSecret provision parameter overrides it for one instance.
+func resolveImagePullSecret(client kubernetes.Interface, namespace, brokerPullSecret, brokerNamespace, serviceAccount string, req *brokerapi.CreateServiceInstanceRequest, instanceID string) (string, error) {
+	name := brokerPullSecret
+	if v, ok := req.Parameters["imagePullSecret"]; ok {
+		if s, ok := v.(string); ok && s != "" {
+			name = s
+		}
+	}
+	if name == "" {
+		return "", nil
+	}
+
+	secret, err := client.Core().Secrets(brokerNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("image pull secret %q not found in namespace %q: %v", name, brokerNamespace, err)
+	}
+	if namespace == brokerNamespace {
+		return name, nil
+	}
+
+	copyName := names.InstanceResourceName(name, instanceID, "")
+	copySecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      copyName,
+			Namespace: namespace,
+			Labels:    map[string]string{kube.InstanceLabelKey(): instanceID},
+		},
+		Type: secret.Type,
+		Data: secret.Data,
+	}
+	if _, err := client.Core().Secrets(namespace).Create(copySecret); err != nil {
+		err = kube.TranslateForbidden(err, "create", "secrets", namespace, serviceAccount)
+		return "", fmt.Errorf("failed to copy image pull secret %q into namespace %q: %v", name, namespace, err)
+	}
+	return copyName, nil
+}
+
+func (c *nginxController) Catalog() (*brokerapi.Catalog, error) {
+	glog.Info("Catalog()")
+	return &brokerapi.Catalog{
+		Services: []*brokerapi.Service{
+			{
+				Name:        "nginx",
+				ID:          serviceID,
+				Description: "A simple nginx web server",
+				Plans: []brokerapi.ServicePlan{
+					{
+						Name:        "default",
+						ID:          planID,
+						Description: "A single nginx instance with ephemeral storage (50m CPU / 64Mi memory request, 200m CPU / 128Mi memory limit)",
+						Free:        true,
+					},
+					{
+						Name:        "https",
+						ID:          httpsPlanID,
+						Description: "A single nginx instance terminating TLS with a generated self-signed certificate (50m CPU / 64Mi memory request, 200m CPU / 128Mi memory limit)",
+						Free:        true,
+					},
+					{
+						Name:        "basic-auth",
+						ID:          basicAuthPlanID,
+						Description: "A single nginx instance requiring HTTP basic auth, with a username/password generated per binding (50m CPU / 64Mi memory request, 200m CPU / 128Mi memory limit)",
+						Free:        true,
+					},
+					{
+						Name:        "autoscale",
+						ID:          autoscalePlanID,
+						Description: "An nginx instance scaled by a HorizontalPodAutoscaler instead of a fixed replica count (50m CPU / 64Mi memory request, 200m CPU / 128Mi memory limit per pod)",
+						Free:        true,
+					},
+				},
+				Bindable: true,
+			},
+		},
+	}, nil
+}
+
+// planKind maps a plan ID to the short name UpdateServiceInstance uses to
+// detect a plan switch, so it doesn't have to compare every plan ID
+// pairwise.
+func planKind(id string) string {
+	switch id {
+	case httpsPlanID:
+		return "https"
+	case basicAuthPlanID:
+		return "basic-auth"
+	case autoscalePlanID:
+		return "autoscale"
+	default:
+		return "default"
+	}
+}
+
+// instancePlanKind infers instance's current plan from the resources it
+// was provisioned with, rather than storing the plan ID redundantly.
+func instancePlanKind(instance *nginxServiceInstance) string {
+	switch {
+	case instance.TLSSecretName != "":
+		return "https"
+	case instance.BasicAuthSecretName != "":
+		return "basic-auth"
+	case instance.AutoscalerName != "":
+		return "autoscale"
+	default:
+		return "default"
+	}
+}
+
+// removeVolumeAndMounts strips spec's volumes named in names, and every
+// container's VolumeMounts referencing them, when a plan switch drops the
+// resource a volume was mounted from.
+func removeVolumeAndMounts(spec *v1.PodSpec, names ...string) {
+	drop := make(map[string]bool, len(names))
+	for _, name := range names {
+		drop[name] = true
+	}
+
+	var volumes []v1.Volume
+	for _, vol := range spec.Volumes {
+		if !drop[vol.Name] {
+			volumes = append(volumes, vol)
+		}
+	}
+	spec.Volumes = volumes
+
+	for i := range spec.Containers {
+		var mounts []v1.VolumeMount
+		for _, m := range spec.Containers[i].VolumeMounts {
+			if !drop[m.Name] {
+				mounts = append(mounts, m)
+			}
+		}
+		spec.Containers[i].VolumeMounts = mounts
+	}
+}
+
+func namespaceOf(req *brokerapi.CreateServiceInstanceRequest) string {
+	if req.ContextProfile.Namespace != "" {
+		return req.ContextProfile.Namespace
+	}
+	return defaultNamespace
+}
+
+// resolveNamespace determines the effective namespace for a new instance
+// from its request context, falling back to c.defaultNamespace only when
+// c.allowDefaultNamespace permits it; otherwise a request naming no
+// namespace is rejected instead of silently landing in one the caller
+// never asked for.
+func (c *nginxController) resolveNamespace(req *brokerapi.CreateServiceInstanceRequest) (string, error) {
+	if req.ContextProfile.Namespace != "" {
+		return req.ContextProfile.Namespace, nil
+	}
+	if !c.allowDefaultNamespace {
+		return "", fmt.Errorf("no namespace given in the request context, and default namespace is disabled (enable it with --allow-default-namespace)")
+	}
+	return c.defaultNamespace, nil
+}
+
+// resolveInstanceNamespace validates requestingNamespace against
+// c.allowedNamespaces, then returns it unchanged, along with
+// c.allowedNamespaces for doNginXProvision to re-validate against.
+// When c.namespacePerInstance is set, it instead creates (or reuses, on
+// retry) a dedicated namespace for id via kube.EnsureInstanceNamespace and
+// returns that, with a nil allow-list: the dedicated namespace was just
+// created by this broker, not named by the request, so it isn't (and
+// doesn't need to be) in c.allowedNamespaces.
+func (c *nginxController) resolveInstanceNamespace(client kubernetes.Interface, id, requestingNamespace string) (string, map[string]bool, error) {
+	if err := kube.ValidateTargetNamespace(client, requestingNamespace, c.allowedNamespaces); err != nil {
+		return "", nil, err
+	}
+	if !c.namespacePerInstance {
+		return requestingNamespace, c.allowedNamespaces, nil
+	}
+	instanceNamespace := kube.InstanceNamespaceName(brokerName, id)
+	if err := kube.EnsureInstanceNamespace(client, brokerName, id, instanceNamespace); err != nil {
+		return "", nil, err
+	}
+	return instanceNamespace, nil, nil
+}
+
+func (c *nginxController) CreateServiceInstance(
+	ctx context.Context,
+	id string,
+	req *brokerapi.CreateServiceInstanceRequest,
+) (*brokerapi.CreateServiceInstanceResponse, error) {
+	reqlog.FromContext(ctx).Infof("CreateServiceInstance()")
+	ctx, cancel := context.WithTimeout(ctx, c.provisionTimeout)
+	defer cancel()
+	client := c.kubeClient
+	namespace, err := c.resolveNamespace(req)
+	if err != nil {
+		return nil, err
+	}
+	namespace, allowedNamespaces, err := c.resolveInstanceNamespace(client, id, namespace)
+	if err != nil {
+		return nil, err
+	}
+	req.ContextProfile.Namespace = namespace
+
+	c.recorder.Event(kube.ObjectRef("Namespace", namespace, namespace), api.EventTypeNormal, kube.ReasonProvisioning, "Provisioning nginx instance")
+
+	rollback := &kube.RollbackTracker{}
+	instance, err := doNginXProvision(ctx, client, id, req, c.maxContentSize, c.nginxImage, c.imagePullSecret, c.brokerNamespace, c.gitImage, c.logSidecarImage, c.serviceAccount, c.allowExternalProxy, allowedNamespaces, c.retryAttempts, c.retryBaseDelay, c.podSpecOverride, c.platform, c.routeClient, c.podLister, rollback)
+	if err != nil {
+		if !c.keepFailedInstances {
+			if rbErr := rollback.Run(ctx); rbErr != nil {
+				glog.Errorf("rollback: %v", rbErr)
+			}
+		}
+		kube.RecordProvisionFailed(c.recorder, kube.ObjectRef("Namespace", namespace, namespace), "nginx", err)
+		return nil, fmt.Errorf("failed to provision nginx instance: %v", err)
+	}
+
+	instance.Phase = phaseCreatingResources
+	setInstanceCondition(instance, controller.ConditionProvisioned, controller.ConditionFalse, "Provisioning", "")
+	setInstanceCondition(instance, controller.ConditionReady, controller.ConditionFalse, "Provisioning", "")
+	c.rwMutex.Lock()
+	c.instanceMap[id] = instance
+	c.rwMutex.Unlock()
+	glog.Infof("Created nginx Service Instance:\n%v\n", instance)
+
+	if req.AcceptsIncomplete {
+		rollback.Commit()
+		c.recorder.Event(c.instanceEventRef(instance), api.EventTypeNormal, kube.ReasonProvisioned, "Provisioned nginx instance")
+		return &brokerapi.CreateServiceInstanceResponse{Operation: "provision"}, nil
+	}
+	if err := waitForNginXAvailable(ctx, client, c.podLister, instance.Namespace, id, c.readinessTimeout); err != nil {
+		if !c.keepFailedInstances {
+			if rbErr := rollback.Run(ctx); rbErr != nil {
+				glog.Errorf("rollback: %v", rbErr)
+			}
+			c.rwMutex.Lock()
+			delete(c.instanceMap, id)
+			c.rwMutex.Unlock()
+		}
+		instance.Phase = fmt.Sprintf("%s%v)", failedPhasePrefix, err)
+		setInstanceCondition(instance, controller.ConditionProvisioned, controller.ConditionFalse, "Failed", err.Error())
+		setInstanceCondition(instance, controller.ConditionReady, controller.ConditionFalse, "Failed", err.Error())
+		setInstanceCondition(instance, controller.ConditionDegraded, controller.ConditionTrue, "Failed", err.Error())
+		kube.RecordProvisionFailed(c.recorder, c.instanceEventRef(instance), "nginx", err)
+		return nil, fmt.Errorf("failed to provision nginx instance: %v", err)
+	}
+	rollback.Commit()
+	instance.Phase = phaseReady
+	setInstanceCondition(instance, controller.ConditionProvisioned, controller.ConditionTrue, "Provisioned", "")
+	setInstanceCondition(instance, controller.ConditionReady, controller.ConditionTrue, "Ready", "")
+	setInstanceCondition(instance, controller.ConditionDegraded, controller.ConditionFalse, "Ready", "")
+	c.recorder.Event(c.instanceEventRef(instance), api.EventTypeNormal, kube.ReasonProvisioned, "Provisioned nginx instance")
+	return &brokerapi.CreateServiceInstanceResponse{}, nil
+}
+
+// setInstanceCondition applies a Provisioned/Ready/Degraded/DeletionPending
+// transition to instance.Conditions.
+func setInstanceCondition(instance *nginxServiceInstance, condType string, status controller.ConditionStatus, reason, message string) {
+	instance.Conditions = controller.SetCondition(instance.Conditions, controller.Condition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: time.Now(),
+	})
+}
+
+// instanceEventRef returns the object an Event about instance should be
+// attached to: its Deployment, since doNginXProvision always creates one
+// before returning successfully.
+func (c *nginxController) instanceEventRef(instance *nginxServiceInstance) *v1.ObjectReference {
+	return kube.ObjectRef("Deployment", instance.Namespace, instance.DeploymentName)
+}
+
+// doNginXProvision creates the Kubernetes objects backing a new nginx
+// instance: its Deployment and, depending on req, a content/conf
+// ConfigMap, a git-clone init container, a TLS secret and conf, a
+// basic-auth secret, a log sidecar, a Service, an autoscaler, and an
+// Ingress (or, on platform kube.PlatformOpenShift, a Route). It registers
+// an undo for each object with rollback as soon as it's created, so the
+// caller can tear everything down on a later failure instead of leaving it
+// orphaned; it does not itself decide whether to roll back, since that's
+// the keep-failed-instances policy of the caller. defaultImage,
+// brokerPullSecret, gitImage, and logSidecarImage are the broker's
+// configured defaults, each overridable by a provision parameter.
+// routeClient is non-nil exactly when platform is kube.PlatformOpenShift.
+func doNginXProvision(
+	ctx context.Context,
+	client kubernetes.Interface,
+	id string,
+	req *brokerapi.CreateServiceInstanceRequest,
+	maxContentSize int,
+	defaultImage, brokerPullSecret, brokerNamespace, gitImage, logSidecarImage, serviceAccount string,
+	allowExternalProxy bool,
+	allowedNamespaces map[string]bool,
+	retryAttempts int,
+	retryBaseDelay time.Duration,
+	podSpecOverride *kube.PodSpecOverride,
+	platform kube.Platform,
+	routeClient kube.RouteClient,
+	podLister v1listers.PodLister,
+	rollback *kube.RollbackTracker,
+) (*nginxServiceInstance, error) {
+	namespace := namespaceOf(req)
+	if err := kube.ValidateTargetNamespace(client, namespace, allowedNamespaces); err != nil {
+		return nil, err
+	}
+
+	replicas, ok, err := replicasParam(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		replicas = 1
+	}
+
+	host, err := parseIngressHost(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if host != "" {
+		if err := checkIngressHostAvailable(client, host); err != nil {
+			return nil, err
+		}
+	}
+
+	expose, err := resolveExposeParams(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if expose.NodePort != 0 && !expose.Exposed {
+		return nil, fmt.Errorf("nodePort requires \"expose\": \"nodeport\"")
+	}
+
+	logSidecar, err := resolveLogSidecarParam(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	files, hasContent, err := resolveContent(req.Parameters, maxContentSize)
+	if err != nil {
+		return nil, err
+	}
+
+	gitOpts, hasGitContent, err := resolveGitContent(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if hasGitContent {
+		if hasContent {
+			return nil, fmt.Errorf("gitRepo cannot be combined with indexHtml/files")
+		}
+		if gitOpts.Secret != "" {
+			if err := checkGitSecretExists(client, namespace, gitOpts.Secret); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	conf, hasConf, err := resolveConf(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	port, hasPort, err := resolvePortParam(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPort {
+		port = nginxPort
+	}
+
+	proxyPass, hasProxyPass, err := resolveProxyPass(req.Parameters, allowExternalProxy)
+	if err != nil {
+		return nil, err
+	}
+	if hasProxyPass {
+		if hasContent {
+			return nil, fmt.Errorf("proxyPass cannot be combined with indexHtml/files")
+		}
+		if hasGitContent {
+			return nil, fmt.Errorf("proxyPass cannot be combined with gitRepo")
+		}
+		if hasConf {
+			return nil, fmt.Errorf("proxyPass cannot be combined with a custom nginxConf")
+		}
+		conf, hasConf = proxyServerConf(port, proxyPass), true
+	}
+
+	networkPolicyPeers, hasAllowedNamespaces, err := resolveAllowedNamespaces(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	https := req.PlanID == httpsPlanID
+	if https && port == httpsPort {
+		return nil, fmt.Errorf("port must not be %d: that port is reserved for the https plan's TLS listener", httpsPort)
+	}
+	basicAuth := req.PlanID == basicAuthPlanID
+	if basicAuth {
+		if hasConf {
+			if hasProxyPass {
+				return nil, fmt.Errorf("proxyPass cannot be combined with the basic-auth plan")
+			}
+			return nil, fmt.Errorf("a custom nginxConf cannot be combined with the basic-auth plan")
+		}
+		conf, hasConf = basicAuthServerConf(port), true
+	}
+
+	autoscale := req.PlanID == autoscalePlanID
+	var autoscaleOpts autoscaleParams
+	if autoscale {
+		if ok {
+			return nil, fmt.Errorf("a fixed \"replicas\" parameter cannot be combined with the autoscale plan")
+		}
+		autoscaleOpts, err = resolveAutoscaleParams(req.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		replicas = autoscaleOpts.MinReplicas
+	}
+
+	image, _, err := resolveImage(defaultImage, req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	pullSecretName, err := resolveImagePullSecret(client, namespace, brokerPullSecret, brokerNamespace, serviceAccount, req, id)
+	if err != nil {
+		return nil, err
+	}
+	if pullSecretName != "" && namespace != brokerNamespace {
+		rollback.Add("delete nginx image pull secret copy", func(ctx context.Context) error {
+			return client.Core().Secrets(namespace).Delete(pullSecretName, nil)
+		})
+	}
+
+	labels := kube.CommonLabels(brokerName, pkg.VERSION, serviceID, req.PlanID, id, namespace)
+	annotations := kube.TraceAnnotations(reqlog.FromContext(ctx).CorrelationID(), "provision")
+	deployment := newNginXInstanceResources(deploymentName(id), namespace, id, labels, annotations, replicas, &nginxDeploymentOptions{
+		Image:           image,
+		ImagePullSecret: pullSecretName,
+		Port:            port,
+	})
+
+	var contentConfigMapName string
+	if hasContent {
+		name, hash, err := createContentConfigMap(client, namespace, id, files, labels, annotations)
+		if err != nil {
+			return nil, err
+		}
+		contentConfigMapName = name
+		rollback.Add("delete nginx content configmap", func(ctx context.Context) error {
+			return client.Core().ConfigMaps(namespace).Delete(name, nil)
+		})
+		attachContent(&deployment.Spec.Template.Spec, name)
+		setContentHashAnnotation(&deployment.Spec.Template, hash)
+	}
+
+	if hasGitContent {
+		attachGitContent(&deployment.Spec.Template.Spec, gitOpts, gitImage)
+	}
+
+	var confConfigMapName string
+	if hasConf {
+		name, hash, err := createConfConfigMap(client, namespace, id, conf, labels, annotations)
+		if err != nil {
+			return nil, err
+		}
+		confConfigMapName = name
+		rollback.Add("delete nginx conf configmap", func(ctx context.Context) error {
+			return client.Core().ConfigMaps(namespace).Delete(name, nil)
+		})
+		attachConf(&deployment.Spec.Template.Spec, name)
+		setConfHashAnnotation(&deployment.Spec.Template, hash)
+	}
+
+	var tlsSecretName, tlsConfConfigMapName string
+	var tlsExpiry time.Time
+	if https {
+		confName, err := createTLSConfConfigMap(client, namespace, id, labels, annotations)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfConfigMapName = confName
+		rollback.Add("delete nginx TLS conf configmap", func(ctx context.Context) error {
+			return client.Core().ConfigMaps(namespace).Delete(confName, nil)
+		})
+
+		secretName, cert, err := createTLSSecret(client, namespace, id, tlsDNSNames(namespace, serviceName(id), host), labels, annotations)
+		if err != nil {
+			return nil, err
+		}
+		tlsSecretName = secretName
+		tlsExpiry = cert.Cert.NotAfter
+		rollback.Add("delete nginx TLS secret", func(ctx context.Context) error {
+			return client.Core().Secrets(namespace).Delete(secretName, nil)
+		})
+		attachTLS(&deployment.Spec.Template.Spec, secretName, confName)
+	}
+
+	var basicAuthSecretName string
+	if basicAuth {
+		name, err := createBasicAuthSecret(client, namespace, id, labels, annotations)
+		if err != nil {
+			return nil, err
+		}
+		basicAuthSecretName = name
+		rollback.Add("delete nginx basic-auth secret", func(ctx context.Context) error {
+			return client.Core().Secrets(namespace).Delete(name, nil)
+		})
+		attachBasicAuth(&deployment.Spec.Template.Spec, name)
+	}
+
+	if logSidecar {
+		attachLogSidecar(&deployment.Spec.Template.Spec, logSidecarImage)
+	}
+	kube.ApplyPodSpecOverride(&deployment.Spec.Template.Spec, &deployment.Spec.Template.ObjectMeta, podSpecOverride)
+
+	createErr := kube.Retry(ctx, retryAttempts, retryBaseDelay, "create nginx instance deployment", func() error {
+		_, err := client.Apps().Deployments(namespace).Create(deployment)
+		return kube.TranslateForbidden(err, "create", "deployments", namespace, serviceAccount)
+	})
+	if createErr != nil {
+		return nil, fmt.Errorf("failed to create nginx instance deployment: %v", createErr)
+	}
+	rollback.Add("delete nginx instance deployment", func(ctx context.Context) error {
+		return client.Apps().Deployments(namespace).Delete(deployment.Name, nil)
+	})
+
+	if hasConf {
+		if err := waitForNginXConfReady(ctx, client, podLister, namespace, id); err != nil {
+			return nil, err
+		}
+	}
+
+	svcName, nodePort, err := createNginXService(client, namespace, id, port, https, expose, labels, annotations)
+	if err != nil {
+		return nil, err
+	}
+	rollback.Add("delete nginx instance service", func(ctx context.Context) error {
+		return client.Core().Services(namespace).Delete(svcName, nil)
+	})
+
+	var networkPolicyName string
+	if hasAllowedNamespaces {
+		name, err := createNginXNetworkPolicy(client, namespace, id, port, networkPolicyPeers, labels, annotations)
+		if err != nil {
+			return nil, err
+		}
+		networkPolicyName = name
+		rollback.Add("delete nginx network policy", func(ctx context.Context) error {
+			return client.Networking().NetworkPolicies(namespace).Delete(name, nil)
+		})
+	}
+
+	var autoscalerName string
+	if autoscale {
+		name, err := createNginXAutoscaler(client, namespace, id, autoscaleOpts, labels, annotations)
+		if err != nil {
+			return nil, err
+		}
+		autoscalerName = name
+		rollback.Add("delete nginx autoscaler", func(ctx context.Context) error {
+			return client.Autoscaling().HorizontalPodAutoscalers(namespace).Delete(name, nil)
+		})
+	}
+
+	instance := &nginxServiceInstance{
+		ID:                     id,
+		Namespace:              namespace,
+		DeploymentName:         deployment.Name,
+		ServiceName:            svcName,
+		ContentConfigMapName:   contentConfigMapName,
+		ConfConfigMapName:      confConfigMapName,
+		ConfIsBasicAuthDefault: basicAuth,
+		ConfIsProxyDefault:     hasProxyPass,
+		Upstream:               proxyPass,
+		TLSSecretName:          tlsSecretName,
+		TLSConfConfigMapName:   tlsConfConfigMapName,
+		TLSExpiry:              tlsExpiry,
+		BasicAuthSecretName:    basicAuthSecretName,
+		AutoscalerName:         autoscalerName,
+		Image:                  image,
+		ExposeNodePort:         expose.Exposed,
+		NodePort:               nodePort,
+		LogSidecar:             logSidecar,
+		Port:                   port,
+		NetworkPolicyName:      networkPolicyName,
+		CreatedAt:              time.Now(),
+		Bindings:               make(map[string]struct{}),
+	}
+
+	if hasGitContent {
+		instance.GitRepo = gitOpts.Repo
+		instance.GitRef = gitOpts.Ref
+		instance.GitSecretName = gitOpts.Secret
+	}
+
+	if host != "" && platform == kube.PlatformOpenShift {
+		routeName := names.InstanceResourceName(brokerName, id, "route")
+		route := kube.NewRoute(routeName, namespace, host, svcName, port, labels, annotations)
+		if _, err := routeClient.Create(namespace, route); err != nil {
+			return nil, fmt.Errorf("failed to create nginx route: %v", err)
+		}
+		rollback.Add("delete nginx route", func(ctx context.Context) error {
+			return routeClient.Delete(namespace, routeName)
+		})
+		instance.RouteName = routeName
+		instance.IngressHost = host
+	} else if host != "" {
+		ingName, err := createNginXIngress(client, namespace, id, svcName, host, port, labels, annotations)
+		if err != nil {
+			return nil, err
+		}
+		rollback.Add("delete nginx ingress", func(ctx context.Context) error {
+			return client.Extensions().Ingresses(namespace).Delete(ingName, nil)
+		})
+		instance.IngressName = ingName
+		instance.IngressHost = host
+	}
+
+	return instance, nil
+}
+
+// GetServiceInstanceLastOperation reports instance's rollout phase, so an
+// asynchronous ("accepts_incomplete") provision or update can be polled to
+// completion. It refreshes the phase from the Deployment's live status via
+// deriveNginXPhase while instance hasn't reached a phaseIsTerminal phase,
+// recording the result back onto instance so a later call, once terminal,
+// can answer without touching the API server again.
+func (c *nginxController) GetServiceInstanceLastOperation(
+	ctx context.Context,
+	instanceID,
+	serviceID,
+	planID,
+	operation string,
+) (*brokerapi.LastOperationResponse, error) {
+	reqlog.FromContext(ctx).Infof("GetServiceInstanceLastOperation()")
+	c.rwMutex.RLock()
+	instance, ok := c.instanceMap[instanceID]
+	c.rwMutex.RUnlock()
+	if !ok {
+		return nil, kube.ErrNoSuchInstance{InstanceID: instanceID}
+	}
+
+	if phaseIsTerminal(instance.Phase) {
+		return &brokerapi.LastOperationResponse{State: phaseState(instance.Phase), Description: instance.Phase}, nil
+	}
+
+	client := c.kubeClient
+	state, phase := deriveNginXPhase(client, c.podLister, instance.Namespace, instance)
+	instance.Phase = phase
+
+	description := phase
+	if state == brokerapi.StateSucceeded {
+		description = replicaStatusDescription(client, instance)
+		setInstanceCondition(instance, controller.ConditionProvisioned, controller.ConditionTrue, "Provisioned", "")
+		setInstanceCondition(instance, controller.ConditionReady, controller.ConditionTrue, "Ready", description)
+	} else if state == brokerapi.StateFailed {
+		setInstanceCondition(instance, controller.ConditionProvisioned, controller.ConditionFalse, "Failed", description)
+		setInstanceCondition(instance, controller.ConditionReady, controller.ConditionFalse, "Failed", description)
+		setInstanceCondition(instance, controller.ConditionDegraded, controller.ConditionTrue, "Failed", description)
+	}
+	return &brokerapi.LastOperationResponse{State: state, Description: description}, nil
+}
+
+// replicaStatusDescription best-effort reads instance's current replica
+// status for inclusion in a successful LastOperationResponse. A failure
+// reading it is folded into the description text rather than propagated,
+// since it's the pod readiness check above that determines success.
+func replicaStatusDescription(client kubernetes.Interface, instance *nginxServiceInstance) string {
+	status, err := readNginXReplicaStatus(client, instance.Namespace, instance)
+	if err != nil {
+		return fmt.Sprintf("(failed to read replica status: %v)", err)
+	}
+	description := fmt.Sprintf("replicas: %d/%d", status.CurrentReplicas, status.DesiredReplicas)
+	if instance.AutoscalerName != "" {
+		description = fmt.Sprintf("replicas: %d/%d (autoscaled between %d and %d)",
+			status.CurrentReplicas, status.DesiredReplicas, status.MinReplicas, status.MaxReplicas)
+	}
+	if instance.ExposeNodePort {
+		description = fmt.Sprintf("%s, exposed at %s", description, nodePortHintURL(instance.NodePort))
+	}
+	if instance.NetworkPolicyName != "" {
+		description = fmt.Sprintf("%s, access restricted by NetworkPolicy %s (no effect without an enforcing CNI)", description, instance.NetworkPolicyName)
+	}
+	return description
+}
+
+func (c *nginxController) RemoveServiceInstance(
+	ctx context.Context,
+	instanceID,
+	serviceID,
+	planID string,
+	acceptsIncomplete, force bool,
+) (*brokerapi.DeleteServiceInstanceResponse, error) {
+	reqlog.FromContext(ctx).Infof("RemoveServiceInstance()")
+	dctx, cancel := context.WithTimeout(ctx, c.provisionTimeout)
+	defer cancel()
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+
+	instance, ok := c.instanceMap[instanceID]
+	if !ok {
+		return &brokerapi.DeleteServiceInstanceResponse{}, nil
+	}
+
+	setInstanceCondition(instance, controller.ConditionDeletionPending, controller.ConditionTrue, "Deprovisioning", "")
+
+	client := c.kubeClient
+	if c.namespacePerInstance {
+		if err := kube.DeleteInstanceNamespace(dctx, client, instance.Namespace, c.retryBaseDelay); err != nil {
+			return nil, fmt.Errorf("failed to delete nginx instance namespace: %v", err)
+		}
+	} else if _, err := doNginXDeprovision(dctx, client, c.routeClient, instance, false); err != nil {
+		return nil, err
+	}
+	c.recorder.Event(c.instanceEventRef(instance), api.EventTypeNormal, kube.ReasonDeprovisioned, "Deprovisioned nginx instance")
+	delete(c.instanceMap, instanceID)
+	return &brokerapi.DeleteServiceInstanceResponse{}, nil
+}
+
+// UpdateServiceInstance applies a replica count, image tag, content, conf,
+// or gitRef change to an instance, named by the
+// "replicas"/"imageTag"/"indexHtml"/"files"/"nginxConf"/"gitRef" update
+// parameters, and/or a switch to a different plan, named by a "plan_id"
+// that differs from the instance's current one. A content, conf, or gitRef
+// change regenerates the corresponding ConfigMap, or re-points the
+// git-clone init container, and re-stamps the Deployment's pod template so
+// the resulting rollout picks it up; ConfigMap contents alone don't trigger
+// a restart. A plan switch adds or removes the resources the target and
+// former plans need (an autoscaler, a TLS secret and Service port, or a
+// basic-auth secret and its enforcing conf), on top of whatever else is
+// requested in the same call. An "expose"/"nodePort" pair switches the
+// instance's Service between ClusterIP and NodePort, or moves an already
+// exposed instance to a different node port, mutating that same Service
+// rather than creating a second one. As with CreateServiceInstance, a
+// resource created for the switch isn't rolled back if a later step fails;
+// the instance record itself is only updated once the Deployment (and, for
+// an https or exposure switch, Service) update succeeds, so the caller's
+// stored state never gets ahead of what's actually running. It is a no-op,
+// successful update if nothing changed.
+func (c *nginxController) UpdateServiceInstance(ctx context.Context, instanceID string, req *brokerapi.UpdateServiceInstanceRequest) (*brokerapi.UpdateServiceInstanceResponse, error) {
+	reqlog.FromContext(ctx).Infof("UpdateServiceInstance()")
+	ctx, cancel := context.WithTimeout(ctx, c.provisionTimeout)
+	defer cancel()
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+
+	instance, ok := c.instanceMap[instanceID]
+	if !ok {
+		return nil, kube.ErrNoSuchInstance{InstanceID: instanceID}
+	}
+
+	currentPlan := instancePlanKind(instance)
+	targetPlan := currentPlan
+	if req.PlanID != "" {
+		targetPlan = planKind(req.PlanID)
+	}
+	switchingPlan := targetPlan != currentPlan
+	toAutoscale := targetPlan == "autoscale" && currentPlan != "autoscale"
+	fromAutoscale := currentPlan == "autoscale" && targetPlan != "autoscale"
+	toHTTPS := targetPlan == "https" && currentPlan != "https"
+	fromHTTPS := currentPlan == "https" && targetPlan != "https"
+	toBasicAuth := targetPlan == "basic-auth" && currentPlan != "basic-auth"
+	fromBasicAuth := currentPlan == "basic-auth" && targetPlan != "basic-auth"
+
+	replicas, hasReplicas, err := replicasParam(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if toAutoscale && hasReplicas {
+		return nil, fmt.Errorf("the autoscale plan manages replicas itself: don't set a \"replicas\" parameter")
+	}
+
+	files, hasContent, err := resolveContent(req.Parameters, c.maxContentSize)
+	if err != nil {
+		return nil, err
+	}
+	if hasContent && instance.ContentConfigMapName == "" {
+		return nil, fmt.Errorf("instance %s was not provisioned with content and cannot be updated with it", instanceID)
+	}
+
+	conf, hasConf, err := resolveConf(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if hasConf && instance.ConfConfigMapName == "" {
+		return nil, fmt.Errorf("instance %s was not provisioned with a custom nginxConf and cannot be updated with one", instanceID)
+	}
+	if (hasConf || (instance.ConfConfigMapName != "" && !instance.ConfIsBasicAuthDefault)) && toBasicAuth {
+		return nil, fmt.Errorf("a custom nginxConf cannot be combined with the basic-auth plan")
+	}
+
+	port, hasPort, err := resolvePortParam(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if hasPort && port == httpsPort && targetPlan == "https" {
+		return nil, fmt.Errorf("port must not be %d: that port is reserved for the https plan's TLS listener", httpsPort)
+	}
+	effectivePort := instance.Port
+	if hasPort {
+		effectivePort = port
+	}
+
+	proxyPass, hasProxyPass, err := resolveProxyPass(req.Parameters, c.allowExternalProxy)
+	if err != nil {
+		return nil, err
+	}
+	if hasProxyPass && instance.Upstream == "" {
+		return nil, fmt.Errorf("instance %s was not provisioned with a proxyPass and cannot be updated with one", instanceID)
+	}
+	if hasProxyPass && toBasicAuth {
+		return nil, fmt.Errorf("proxyPass cannot be combined with the basic-auth plan")
+	}
+
+	allowedNamespaces, hasAllowedNamespaces, err := resolveAllowedNamespaces(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	gitRef, hasGitRef, err := resolveGitRefUpdate(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if hasGitRef && instance.GitRepo == "" {
+		return nil, fmt.Errorf("instance %s was not provisioned with a gitRepo and cannot be updated with a gitRef", instanceID)
+	}
+
+	image, hasImage, err := resolveImage(instance.Image, req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	expose, err := resolveExposeParams(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	switchingExpose := expose.ExposeSet && expose.Exposed != instance.ExposeNodePort
+	exposedAfter := instance.ExposeNodePort
+	if expose.ExposeSet {
+		exposedAfter = expose.Exposed
+	}
+	movingNodePort := expose.NodePort != 0 && exposedAfter && expose.NodePort != instance.NodePort
+	if expose.NodePort != 0 && !exposedAfter {
+		return nil, fmt.Errorf("nodePort requires \"expose\": \"nodeport\"")
+	}
+
+	var autoscaleOpts autoscaleParams
+	if toAutoscale {
+		autoscaleOpts, err = resolveAutoscaleParams(req.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		replicas, hasReplicas = autoscaleOpts.MinReplicas, true
+	}
+
+	rotateAdminPassword, err := resolveRotateAdminPassword(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if rotateAdminPassword && instance.BasicAuthSecretName == "" {
+		return nil, fmt.Errorf("instance %s is not on the basic-auth plan and has no admin credential to rotate", instanceID)
+	}
+
+	if !hasReplicas && !hasContent && !hasConf && !hasGitRef && !hasImage && !hasPort && !hasAllowedNamespaces &&
+		!hasProxyPass && !rotateAdminPassword && !switchingPlan && !switchingExpose && !movingNodePort {
+		return &brokerapi.UpdateServiceInstanceResponse{}, nil
+	}
+
+	client := c.kubeClient
+	deployment, err := client.Apps().Deployments(instance.Namespace).Get(instance.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up nginx instance deployment: %v", err)
+	}
+
+	if fromAutoscale {
+		if err := client.Autoscaling().HorizontalPodAutoscalers(instance.Namespace).Delete(instance.AutoscalerName, nil); err != nil {
+			return nil, fmt.Errorf("failed to delete nginx instance autoscaler: %v", err)
+		}
+		if !hasReplicas {
+			replicas, hasReplicas = *deployment.Spec.Replicas, true
+		}
+	}
+
+	if hasReplicas {
+		deployment.Spec.Replicas = &replicas
+	}
+	if hasImage {
+		for i := range deployment.Spec.Template.Spec.Containers {
+			if deployment.Spec.Template.Spec.Containers[i].Name == nginxContainerName {
+				deployment.Spec.Template.Spec.Containers[i].Image = image
+			}
+		}
+	}
+
+	if hasPort {
+		for i := range deployment.Spec.Template.Spec.Containers {
+			if deployment.Spec.Template.Spec.Containers[i].Name == nginxContainerName {
+				deployment.Spec.Template.Spec.Containers[i].Ports = []v1.ContainerPort{{Name: nginxPortName, ContainerPort: port}}
+				deployment.Spec.Template.Spec.Containers[i].ReadinessProbe = nginxProbe(port, 1)
+				deployment.Spec.Template.Spec.Containers[i].LivenessProbe = nginxProbe(port, 10)
+			}
+		}
+	}
+
+	if hasContent {
+		hash, err := updateContentConfigMap(client, instance.Namespace, instance.ID, files)
+		if err != nil {
+			return nil, err
+		}
+		setContentHashAnnotation(&deployment.Spec.Template, hash)
+	}
+
+	if hasConf {
+		hash, err := updateConfConfigMap(client, instance.Namespace, instance.ID, conf)
+		if err != nil {
+			return nil, err
+		}
+		setConfHashAnnotation(&deployment.Spec.Template, hash)
+	}
+
+	stayingBasicAuth := currentPlan == "basic-auth" && targetPlan == "basic-auth"
+	if hasPort && stayingBasicAuth && instance.ConfIsBasicAuthDefault {
+		hash, err := updateConfConfigMap(client, instance.Namespace, instance.ID, basicAuthServerConf(effectivePort))
+		if err != nil {
+			return nil, err
+		}
+		setConfHashAnnotation(&deployment.Spec.Template, hash)
+	}
+
+	if hasProxyPass {
+		hash, err := updateConfConfigMap(client, instance.Namespace, instance.ID, proxyServerConf(effectivePort, proxyPass))
+		if err != nil {
+			return nil, err
+		}
+		setConfHashAnnotation(&deployment.Spec.Template, hash)
+	} else if hasPort && instance.ConfIsProxyDefault {
+		hash, err := updateConfConfigMap(client, instance.Namespace, instance.ID, proxyServerConf(effectivePort, instance.Upstream))
+		if err != nil {
+			return nil, err
+		}
+		setConfHashAnnotation(&deployment.Spec.Template, hash)
+	}
+
+	if hasGitRef {
+		setGitRefRollout(&deployment.Spec.Template, gitContentParams{Repo: instance.GitRepo, Ref: gitRef, Secret: instance.GitSecretName})
+	}
+
+	if fromHTTPS {
+		removeVolumeAndMounts(&deployment.Spec.Template.Spec, tlsVolumeName, tlsConfVolumeName)
+		if err := client.Core().Secrets(instance.Namespace).Delete(instance.TLSSecretName, nil); err != nil {
+			return nil, fmt.Errorf("failed to delete nginx instance tls secret: %v", err)
+		}
+		if err := client.Core().ConfigMaps(instance.Namespace).Delete(instance.TLSConfConfigMapName, nil); err != nil {
+			return nil, fmt.Errorf("failed to delete nginx instance tls conf config map: %v", err)
+		}
+	}
+	if fromBasicAuth {
+		removeVolumeAndMounts(&deployment.Spec.Template.Spec, basicAuthVolumeName)
+		if err := client.Core().Secrets(instance.Namespace).Delete(instance.BasicAuthSecretName, nil); err != nil {
+			return nil, fmt.Errorf("failed to delete nginx instance basic auth secret: %v", err)
+		}
+		if instance.ConfIsBasicAuthDefault {
+			if err := client.Core().ConfigMaps(instance.Namespace).Delete(instance.ConfConfigMapName, nil); err != nil {
+				return nil, fmt.Errorf("failed to delete nginx instance conf config map: %v", err)
+			}
+			removeVolumeAndMounts(&deployment.Spec.Template.Spec, confVolumeName)
+		}
+	}
+
+	if rotateAdminPassword && !fromBasicAuth {
+		if _, _, err := rotateAdminCredential(client, instance.Namespace, instance.BasicAuthSecretName); err != nil {
+			return nil, err
+		}
+		glog.Infof("Rotated nginx instance %s basic auth admin credential", instanceID)
+	}
+
+	annotations := kube.TraceAnnotations(reqlog.FromContext(ctx).CorrelationID(), "update")
+
+	var newTLSSecretName, newTLSConfConfigMapName string
+	var newTLSExpiry time.Time
+	if toHTTPS {
+		confName, err := createTLSConfConfigMap(client, instance.Namespace, instance.ID, deployment.Labels, annotations)
+		if err != nil {
+			return nil, err
+		}
+		secretName, cert, err := createTLSSecret(client, instance.Namespace, instance.ID, tlsDNSNames(instance.Namespace, instance.ServiceName, instance.IngressHost), deployment.Labels, annotations)
+		if err != nil {
+			return nil, err
+		}
+		newTLSSecretName, newTLSConfConfigMapName, newTLSExpiry = secretName, confName, cert.Cert.NotAfter
+		attachTLS(&deployment.Spec.Template.Spec, secretName, confName)
+	}
+
+	var newBasicAuthSecretName string
+	var newConfConfigMapName string
+	if toBasicAuth {
+		name, err := createBasicAuthSecret(client, instance.Namespace, instance.ID, deployment.Labels, annotations)
+		if err != nil {
+			return nil, err
+		}
+		newBasicAuthSecretName = name
+		attachBasicAuth(&deployment.Spec.Template.Spec, name)
+
+		if instance.ConfConfigMapName == "" {
+			confName, hash, err := createConfConfigMap(client, instance.Namespace, instance.ID, basicAuthServerConf(effectivePort), deployment.Labels, annotations)
+			if err != nil {
+				return nil, err
+			}
+			newConfConfigMapName = confName
+			attachConf(&deployment.Spec.Template.Spec, confName)
+			setConfHashAnnotation(&deployment.Spec.Template, hash)
+		}
+	}
+
+	var newNodePort int32
+	if toHTTPS || fromHTTPS || switchingExpose || movingNodePort || hasPort {
+		svc, err := client.Core().Services(instance.Namespace).Get(instance.ServiceName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up nginx instance service: %v", err)
+		}
+		setServiceHTTPSPort(svc, targetPlan == "https")
+		if hasPort {
+			setServiceHTTPPort(svc, port)
+		}
+		if switchingExpose || movingNodePort {
+			setServiceNodePort(svc, exposedAfter, expose.NodePort)
+		}
+		updated, err := client.Core().Services(instance.Namespace).Update(svc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update nginx instance service: %v", err)
+		}
+		newNodePort = nodePortOf(updated)
+	}
+
+	var newNetworkPolicyName string
+	if instance.NetworkPolicyName != "" && (hasAllowedNamespaces || hasPort) {
+		np, err := client.Networking().NetworkPolicies(instance.Namespace).Get(instance.NetworkPolicyName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up nginx instance network policy: %v", err)
+		}
+		peers := np.Spec.Ingress[0].From
+		if hasAllowedNamespaces {
+			peers = allowedNamespaces
+		}
+		setNetworkPolicyPeers(np, effectivePort, peers)
+		if _, err := client.Networking().NetworkPolicies(instance.Namespace).Update(np); err != nil {
+			return nil, fmt.Errorf("failed to update nginx instance network policy: %v", err)
+		}
+	} else if instance.NetworkPolicyName == "" && hasAllowedNamespaces {
+		name, err := createNginXNetworkPolicy(client, instance.Namespace, instance.ID, effectivePort, allowedNamespaces, deployment.Labels, annotations)
+		if err != nil {
+			return nil, err
+		}
+		newNetworkPolicyName = name
+	}
+
+	if _, err := client.Apps().Deployments(instance.Namespace).Update(deployment); err != nil {
+		return nil, fmt.Errorf("failed to update nginx instance deployment: %v", err)
+	}
+
+	if fromAutoscale {
+		instance.AutoscalerName = ""
+	}
+	if toAutoscale {
+		name, err := createNginXAutoscaler(client, instance.Namespace, instance.ID, autoscaleOpts, deployment.Labels, annotations)
+		if err != nil {
+			return nil, err
+		}
+		instance.AutoscalerName = name
+	}
+	if hasImage {
+		instance.Image = image
+	}
+	if hasPort {
+		instance.Port = port
+	}
+	if hasProxyPass {
+		instance.Upstream = proxyPass
+	}
+	if newNetworkPolicyName != "" {
+		instance.NetworkPolicyName = newNetworkPolicyName
+	}
+	if hasGitRef {
+		instance.GitRef = gitRef
+	}
+	if fromHTTPS {
+		instance.TLSSecretName, instance.TLSConfConfigMapName = "", ""
+		instance.TLSExpiry = time.Time{}
+	}
+	if toHTTPS {
+		instance.TLSSecretName, instance.TLSConfConfigMapName, instance.TLSExpiry = newTLSSecretName, newTLSConfConfigMapName, newTLSExpiry
+	}
+	if fromBasicAuth {
+		instance.BasicAuthSecretName = ""
+		if instance.ConfIsBasicAuthDefault {
+			instance.ConfConfigMapName, instance.ConfIsBasicAuthDefault = "", false
+		}
+	}
+	if toBasicAuth {
+		instance.BasicAuthSecretName = newBasicAuthSecretName
+		if newConfConfigMapName != "" {
+			instance.ConfConfigMapName, instance.ConfIsBasicAuthDefault = newConfConfigMapName, true
+		}
+	}
+	if switchingExpose {
+		instance.ExposeNodePort = expose.Exposed
+	}
+	if switchingExpose || movingNodePort {
+		instance.NodePort = newNodePort
+	}
+
+	if hasConf {
+		if err := waitForNginXConfReady(ctx, client, c.podLister, instance.Namespace, instance.ID); err != nil {
+			return nil, err
+		}
+	}
+	instance.Phase = phaseCreatingResources
+	return &brokerapi.UpdateServiceInstanceResponse{}, nil
+}
+
+func (c *nginxController) Bind(
+	ctx context.Context,
+	instanceID,
+	bindingID string,
+	req *brokerapi.BindingRequest,
+) (*brokerapi.CreateServiceBindingResponse, error) {
+	reqlog.FromContext(ctx).Infof("Bind()")
+	ctx, cancel := context.WithTimeout(ctx, c.bindTimeout)
+	defer cancel()
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+
+	instance, ok := c.instanceMap[instanceID]
+	if !ok {
+		return nil, kube.ErrNoSuchInstance{InstanceID: instanceID}
+	}
+
+	client := c.kubeClient
+
+	cred := brokerapi.Credential{
+		"url": nginxServiceURL(instance.Namespace, instance.ServiceName, instance.Port),
+	}
+	if instance.IngressHost != "" {
+		cred["externalUrl"] = fmt.Sprintf("http://%s", instance.IngressHost)
+	}
+	if instance.ExposeNodePort {
+		cred["nodePort"] = instance.NodePort
+		cred["nodePortUrl"] = nodePortHintURL(instance.NodePort)
+	}
+	if instance.Upstream != "" {
+		cred["upstream"] = instance.Upstream
+	}
+	if instance.TLSSecretName != "" {
+		secret, err := client.Core().Secrets(instance.Namespace).Get(instance.TLSSecretName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up nginx instance tls secret: %v", err)
+		}
+		cred["url"] = nginxServiceHTTPSURL(instance.Namespace, instance.ServiceName)
+		cred["caCertificate"] = string(secret.Data[v1.TLSCertKey])
+	}
+	role, err := resolveBindRole(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if role == bindRoleAdmin && !c.allowAdminBind {
+		return nil, fmt.Errorf("role: admin bind parameter requires the broker to be started with --allow-admin-bind")
+	}
+
+	if instance.BasicAuthSecretName != "" {
+		grantedRole, username, password, err := issueBindCredential(client, instance.Namespace, instance.BasicAuthSecretName, bindingID, role)
+		if err != nil {
+			return nil, fmt.Errorf("failed to issue nginx instance basic auth credential: %v", err)
+		}
+		cred["role"] = grantedRole
+		cred["username"] = username
+		cred["password"] = password
+	} else if role == bindRoleAdmin {
+		return nil, fmt.Errorf("role: admin bind parameter requires the basic-auth plan")
+	}
+	instance.Bindings[bindingID] = struct{}{}
+	c.recorder.Event(c.instanceEventRef(instance), api.EventTypeNormal, kube.ReasonBound, "Bound nginx instance")
+	return &brokerapi.CreateServiceBindingResponse{Credentials: cred}, nil
+}
+
+func (c *nginxController) UnBind(ctx context.Context, instanceID, bindingID, serviceID, planID string) error {
+	reqlog.FromContext(ctx).Infof("UnBind()")
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+
+	instance, ok := c.instanceMap[instanceID]
+	if !ok {
+		return nil
+	}
+	delete(instance.Bindings, bindingID)
+	if instance.BasicAuthSecretName == "" {
+		return nil
+	}
+	client := c.kubeClient
+	if err := revokeBasicAuthCredential(client, instance.Namespace, instance.BasicAuthSecretName, bindingID, c.rotateOnAdminUnbind); err != nil {
+		return err
+	}
+	c.recorder.Event(c.instanceEventRef(instance), api.EventTypeNormal, kube.ReasonUnbound, "Unbound nginx instance")
+	return nil
+}
+
+// CheckReadiness implements controller.ReadinessChecker.
+func (c *nginxController) CheckReadiness(ctx context.Context) map[string]error {
+	failures := map[string]error{}
+	if err := kube.CheckAPIServerReachable(c.kubeClient); err != nil {
+		failures["kube-api"] = err
+	}
+	return failures
+}
+
+// SnapshotState implements controller.StateReporter.
+func (c *nginxController) SnapshotState() controller.StateSnapshot {
+	c.rwMutex.RLock()
+	defer c.rwMutex.RUnlock()
+
+	instances := make([]controller.InstanceSnapshot, 0, len(c.instanceMap))
+	for _, instance := range c.instanceMap {
+		instances = append(instances, controller.InstanceSnapshot{
+			ID:           instance.ID,
+			Namespace:    instance.Namespace,
+			ServiceID:    serviceID,
+			Phase:        instance.Phase,
+			Conditions:   instance.Conditions,
+			CreatedAt:    instance.CreatedAt,
+			BindingCount: len(instance.Bindings),
+		})
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+
+	return controller.StateSnapshot{
+		Instances: instances,
+		Config: map[string]string{
+			"allowAdminBind":      strconv.FormatBool(c.allowAdminBind),
+			"rotateOnAdminUnbind": strconv.FormatBool(c.rotateOnAdminUnbind),
+			"keepFailedInstances": strconv.FormatBool(c.keepFailedInstances),
+		},
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestResolveNamespaceDefaultsToContextProfile(t *testing.T) {
+	c := &nginxController{}
+	req := &brokerapi.CreateServiceInstanceRequest{ContextProfile: brokerapi.ContextProfile{Namespace: "team-a"}}
+
+	ns, err := c.resolveNamespace(req)
+	if err != nil {
+		t.Fatalf("resolveNamespace: %v", err)
+	}
+	if ns != "team-a" {
+		t.Errorf("ns = %q, want %q", ns, "team-a")
+	}
+}
+
+func TestResolveNamespaceRejectsMissingNamespaceByDefault(t *testing.T) {
+	c := &nginxController{}
+
+	if _, err := c.resolveNamespace(&brokerapi.CreateServiceInstanceRequest{}); err == nil {
+		t.Fatal("expected an error for a request with no namespace and allowDefaultNamespace unset")
+	}
+}
+
+func TestResolveNamespaceFallsBackToDefaultNamespaceWhenAllowed(t *testing.T) {
+	c := &nginxController{allowDefaultNamespace: true, defaultNamespace: "team-default"}
+
+	ns, err := c.resolveNamespace(&brokerapi.CreateServiceInstanceRequest{})
+	if err != nil {
+		t.Fatalf("resolveNamespace: %v", err)
+	}
+	if ns != "team-default" {
+		t.Errorf("ns = %q, want %q", ns, "team-default")
+	}
+}
+
+func TestResolveInstanceNamespacePassesThroughByDefault(t *testing.T) {
+	c := &nginxController{allowedNamespaces: map[string]bool{"team-a": true}}
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+
+	got, allowed, err := c.resolveInstanceNamespace(fake.NewSimpleClientset(ns), "test", "team-a")
+	if err != nil {
+		t.Fatalf("resolveInstanceNamespace: %v", err)
+	}
+	if got != "team-a" {
+		t.Errorf("ns = %q, want %q", got, "team-a")
+	}
+	if !allowed["team-a"] {
+		t.Errorf("allowedNamespaces = %v, want to still contain %q", allowed, "team-a")
+	}
+}
+
+func TestResolveInstanceNamespaceRejectsDisallowedRequestingNamespace(t *testing.T) {
+	c := &nginxController{allowedNamespaces: map[string]bool{"team-a": true}}
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}}
+
+	if _, _, err := c.resolveInstanceNamespace(fake.NewSimpleClientset(ns), "test", "team-b"); err == nil {
+		t.Fatal("expected an error for a requesting namespace outside allowedNamespaces")
+	}
+}
+
+func TestResolveInstanceNamespaceCreatesDedicatedNamespaceWhenNamespacePerInstance(t *testing.T) {
+	c := &nginxController{namespacePerInstance: true, allowedNamespaces: map[string]bool{"team-a": true}}
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+
+	got, allowed, err := c.resolveInstanceNamespace(fake.NewSimpleClientset(ns), "test", "team-a")
+	if err != nil {
+		t.Fatalf("resolveInstanceNamespace: %v", err)
+	}
+	if want := "nginx-test"; got != want {
+		t.Errorf("ns = %q, want %q", got, want)
+	}
+	if allowed != nil {
+		t.Errorf("allowedNamespaces = %v, want nil", allowed)
+	}
+}
+
+func TestPlanKind(t *testing.T) {
+	cases := map[string]string{
+		httpsPlanID:     "https",
+		basicAuthPlanID: "basic-auth",
+		autoscalePlanID: "autoscale",
+		planID:          "default",
+		"":              "default",
+	}
+	for id, want := range cases {
+		if got := planKind(id); got != want {
+			t.Errorf("planKind(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestInstancePlanKind(t *testing.T) {
+	cases := []struct {
+		name     string
+		instance *nginxServiceInstance
+		want     string
+	}{
+		{"default", &nginxServiceInstance{}, "default"},
+		{"https", &nginxServiceInstance{TLSSecretName: "tls"}, "https"},
+		{"basic-auth", &nginxServiceInstance{BasicAuthSecretName: "auth"}, "basic-auth"},
+		{"autoscale", &nginxServiceInstance{AutoscalerName: "hpa"}, "autoscale"},
+	}
+	for _, c := range cases {
+		if got := instancePlanKind(c.instance); got != c.want {
+			t.Errorf("%s: instancePlanKind() = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRemoveVolumeAndMounts(t *testing.T) {
+	spec := &v1.PodSpec{
+		Volumes: []v1.Volume{{Name: "content"}, {Name: "tls"}},
+		Containers: []v1.Container{
+			{
+				Name: nginxContainerName,
+				VolumeMounts: []v1.VolumeMount{
+					{Name: "content", MountPath: "/usr/share/nginx/html"},
+					{Name: "tls", MountPath: "/etc/nginx/tls"},
+				},
+			},
+		},
+	}
+
+	removeVolumeAndMounts(spec, "tls")
+
+	if len(spec.Volumes) != 1 || spec.Volumes[0].Name != "content" {
+		t.Errorf("Volumes = %+v, want only \"content\" left", spec.Volumes)
+	}
+	mounts := spec.Containers[0].VolumeMounts
+	if len(mounts) != 1 || mounts[0].Name != "content" {
+		t.Errorf("VolumeMounts = %+v, want only \"content\" left", mounts)
+	}
+}
+
+func TestRemoveVolumeAndMountsIgnoresUnknownNames(t *testing.T) {
+	spec := &v1.PodSpec{Volumes: []v1.Volume{{Name: "content"}}}
+
+	removeVolumeAndMounts(spec, "does-not-exist")
+
+	if len(spec.Volumes) != 1 || spec.Volumes[0].Name != "content" {
+		t.Errorf("Volumes = %+v, want it left untouched", spec.Volumes)
+	}
+}
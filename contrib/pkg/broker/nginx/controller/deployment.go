@@ -0,0 +1,169 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/pkg/api/v1"
+	appsv1beta1 "k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// maxReplicas bounds the replicas provision/update parameter, so a single
+// instance can't be used to exhaust cluster capacity.
+const maxReplicas = 10
+
+// nginxResources is the CPU/memory sizing applied to every plan's container,
+// so an instance is no longer BestEffort. nginx's own footprint barely
+// varies with plan (https and basic-auth just add a static config/secret
+// mount), so a single fixed size is used everywhere rather than a
+// per-plan tier.
+var nginxResources = v1.ResourceRequirements{
+	Requests: v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("50m"),
+		v1.ResourceMemory: resource.MustParse("64Mi"),
+	},
+	Limits: v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("200m"),
+		v1.ResourceMemory: resource.MustParse("128Mi"),
+	},
+}
+
+// replicasParam extracts and validates the replicas parameter shared by
+// provision and update requests. ok is false when the caller didn't set one,
+// in which case the default of 1 replica applies.
+func replicasParam(params map[string]interface{}) (replicas int32, ok bool, err error) {
+	v, present := params["replicas"]
+	if !present {
+		return 0, false, nil
+	}
+	n, isNumber := v.(float64)
+	if !isNumber || n != float64(int32(n)) {
+		return 0, false, fmt.Errorf("replicas must be a whole number")
+	}
+	replicas = int32(n)
+	if replicas < 1 || replicas > maxReplicas {
+		return 0, false, fmt.Errorf("replicas must be between 1 and %d", maxReplicas)
+	}
+	return replicas, true, nil
+}
+
+// resolvePortParam validates the optional "port" provision/update
+// parameter, the container port nginx listens on in place of the default
+// nginxPort. ok is false when the caller didn't set one, in which case the
+// default (at provision time) or the instance's already-recorded port (at
+// update time) applies. The full 1-65535 range is accepted, since nginx
+// instances run as the container's default user rather than a hardened,
+// non-root one that would need a privileged port excluded.
+func resolvePortParam(params map[string]interface{}) (port int32, ok bool, err error) {
+	v, present := params["port"]
+	if !present {
+		return 0, false, nil
+	}
+	n, isNumber := v.(float64)
+	if !isNumber || n != float64(int32(n)) {
+		return 0, false, fmt.Errorf("port must be a whole number")
+	}
+	port = int32(n)
+	if port < 1 || port > 65535 {
+		return 0, false, fmt.Errorf("port must be between 1 and 65535")
+	}
+	return port, true, nil
+}
+
+// nginxDeploymentOptions bundles the settings that determine an nginx
+// instance's Deployment shape: the image to run and an optional pull
+// secret. Resolving both up front and passing them into
+// newNginXInstanceResources, rather than mutating the Deployment it
+// returns, keeps that constructor a pure function of its inputs.
+type nginxDeploymentOptions struct {
+	Image           string
+	ImagePullSecret string
+
+	// Port is the container port nginx listens on, defaulting to nginxPort.
+	Port int32
+}
+
+// newNginXInstanceResources builds the Deployment backing an nginx instance.
+func newNginXInstanceResources(name, namespace, instanceID string, labels, annotations map[string]string, replicas int32, opts *nginxDeploymentOptions) *appsv1beta1.Deployment {
+	selector := map[string]string{kube.InstanceLabelKey(): instanceID}
+
+	podSpec := v1.PodSpec{
+		Containers: []v1.Container{
+			{
+				Name:           nginxContainerName,
+				Image:          opts.Image,
+				Ports:          []v1.ContainerPort{{Name: nginxPortName, ContainerPort: opts.Port}},
+				Resources:      nginxResources,
+				ReadinessProbe: nginxProbe(opts.Port, 1),
+				LivenessProbe:  nginxProbe(opts.Port, 10),
+			},
+		},
+	}
+	if opts.ImagePullSecret != "" {
+		podSpec.ImagePullSecrets = []v1.LocalObjectReference{{Name: opts.ImagePullSecret}}
+	}
+
+	return &appsv1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: appsv1beta1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: selector},
+			Template: v1.PodTemplateSpec{
+				// The pod template gets its own copy of annotations, since
+				// setContentHashAnnotation and setConfHashAnnotation (and
+				// any operator podSpecOverride) add to it afterward, and it
+				// must not mutate the Deployment's own copy in the process.
+				ObjectMeta: metav1.ObjectMeta{Labels: labels, Annotations: kube.CopyStringMap(annotations)},
+				Spec:       podSpec,
+			},
+		},
+	}
+}
+
+// nginxProbe builds a GET / probe against the container port, used for both
+// the readiness and liveness probes. initialDelaySeconds is kept short for
+// readiness so a healthy pod is marked Ready promptly, and longer for
+// liveness so a slow-starting nginx isn't restarted before it gets a chance
+// to come up.
+func nginxProbe(port, initialDelaySeconds int32) *v1.Probe {
+	return &v1.Probe{
+		Handler: v1.Handler{
+			HTTPGet: &v1.HTTPGetAction{
+				Path: "/",
+				Port: intstr.FromInt(int(port)),
+			},
+		},
+		InitialDelaySeconds: initialDelaySeconds,
+		PeriodSeconds:       10,
+	}
+}
+
+func deploymentName(instanceID string) string {
+	return names.InstanceResourceName(brokerName, instanceID, "")
+}
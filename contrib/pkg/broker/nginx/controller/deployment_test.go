@@ -0,0 +1,169 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+)
+
+func TestReplicasParamAbsent(t *testing.T) {
+	replicas, ok, err := replicasParam(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("replicasParam: %v", err)
+	}
+	if ok {
+		t.Error("expected ok=false when replicas is not set")
+	}
+	if replicas != 0 {
+		t.Errorf("replicas = %d, want 0", replicas)
+	}
+}
+
+func TestReplicasParamValid(t *testing.T) {
+	replicas, ok, err := replicasParam(map[string]interface{}{"replicas": float64(3)})
+	if err != nil {
+		t.Fatalf("replicasParam: %v", err)
+	}
+	if !ok {
+		t.Error("expected ok=true when replicas is set")
+	}
+	if replicas != 3 {
+		t.Errorf("replicas = %d, want 3", replicas)
+	}
+}
+
+func TestReplicasParamRejectsNonWholeNumber(t *testing.T) {
+	if _, _, err := replicasParam(map[string]interface{}{"replicas": float64(2.5)}); err == nil {
+		t.Fatal("expected an error for a non-whole replicas value")
+	}
+}
+
+func TestReplicasParamRejectsOutOfRange(t *testing.T) {
+	if _, _, err := replicasParam(map[string]interface{}{"replicas": float64(0)}); err == nil {
+		t.Fatal("expected an error for a replicas value below the minimum")
+	}
+	if _, _, err := replicasParam(map[string]interface{}{"replicas": float64(maxReplicas + 1)}); err == nil {
+		t.Fatal("expected an error for a replicas value above the maximum")
+	}
+}
+
+func TestResolvePortParamAbsent(t *testing.T) {
+	port, ok, err := resolvePortParam(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("resolvePortParam: %v", err)
+	}
+	if ok {
+		t.Error("expected ok=false when port is not set")
+	}
+	if port != 0 {
+		t.Errorf("port = %d, want 0", port)
+	}
+}
+
+func TestResolvePortParamValid(t *testing.T) {
+	port, ok, err := resolvePortParam(map[string]interface{}{"port": float64(8080)})
+	if err != nil {
+		t.Fatalf("resolvePortParam: %v", err)
+	}
+	if !ok {
+		t.Error("expected ok=true when port is set")
+	}
+	if port != 8080 {
+		t.Errorf("port = %d, want 8080", port)
+	}
+}
+
+func TestResolvePortParamRejectsNonWholeNumber(t *testing.T) {
+	if _, _, err := resolvePortParam(map[string]interface{}{"port": float64(80.5)}); err == nil {
+		t.Fatal("expected an error for a non-whole port value")
+	}
+}
+
+func TestResolvePortParamRejectsOutOfRange(t *testing.T) {
+	if _, _, err := resolvePortParam(map[string]interface{}{"port": float64(0)}); err == nil {
+		t.Fatal("expected an error for a port value below the minimum")
+	}
+	if _, _, err := resolvePortParam(map[string]interface{}{"port": float64(65536)}); err == nil {
+		t.Fatal("expected an error for a port value above the maximum")
+	}
+}
+
+func TestNewNginXInstanceResourcesUsesResolvedImage(t *testing.T) {
+	deployment := newNginXInstanceResources("nginx-test", "default", "test", nil, nil, 1, &nginxDeploymentOptions{
+		Image: "nginx:1.17",
+		Port:  nginxPort,
+	})
+
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if container.Image != "nginx:1.17" {
+		t.Errorf("image = %q, want %q", container.Image, "nginx:1.17")
+	}
+	if len(deployment.Spec.Template.Spec.ImagePullSecrets) != 0 {
+		t.Error("expected no image pull secrets when ImagePullSecret is unset")
+	}
+}
+
+func TestNewNginXInstanceResourcesSetsImagePullSecret(t *testing.T) {
+	deployment := newNginXInstanceResources("nginx-test", "default", "test", nil, nil, 1, &nginxDeploymentOptions{
+		Image:           "nginx:latest",
+		ImagePullSecret: "registry-creds",
+		Port:            nginxPort,
+	})
+
+	secrets := deployment.Spec.Template.Spec.ImagePullSecrets
+	if len(secrets) != 1 || secrets[0].Name != "registry-creds" {
+		t.Errorf("ImagePullSecrets = %v, want [{registry-creds}]", secrets)
+	}
+}
+
+func TestNewNginXInstanceResourcesUsesCustomPort(t *testing.T) {
+	deployment := newNginXInstanceResources("nginx-test", "default", "test", nil, nil, 1, &nginxDeploymentOptions{
+		Image: "nginx:1.17",
+		Port:  8080,
+	})
+
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if len(container.Ports) != 1 || container.Ports[0].ContainerPort != 8080 {
+		t.Errorf("container ports = %v, want a single port of 8080", container.Ports)
+	}
+	if container.ReadinessProbe.HTTPGet.Port.IntValue() != 8080 {
+		t.Errorf("readiness probe port = %d, want 8080", container.ReadinessProbe.HTTPGet.Port.IntValue())
+	}
+	if container.LivenessProbe.HTTPGet.Port.IntValue() != 8080 {
+		t.Errorf("liveness probe port = %d, want 8080", container.LivenessProbe.HTTPGet.Port.IntValue())
+	}
+}
+
+func TestNewNginXInstanceResourcesUsesConfiguredInstanceLabelKey(t *testing.T) {
+	defer kube.Configure("", nil)
+	kube.Configure("app.example.com/instance", nil)
+
+	deployment := newNginXInstanceResources("nginx-test", "default", "test", nil, nil, 1, &nginxDeploymentOptions{
+		Image: "nginx:1.17",
+		Port:  nginxPort,
+	})
+
+	selector := deployment.Spec.Selector.MatchLabels
+	if got, want := selector["app.example.com/instance"], "test"; got != want {
+		t.Errorf("selector[app.example.com/instance] = %q, want %q", got, want)
+	}
+	if _, ok := selector[kube.DefaultInstanceLabelKey]; ok {
+		t.Errorf("selector unexpectedly still keyed by default label %q", kube.DefaultInstanceLabelKey)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/waitutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// deletionWaitTimeout bounds how long doNginXDeprovision waits for the
+// Deployment and Service it just deleted to actually disappear, so a
+// deprovision that reports complete doesn't leave a caller free to
+// immediately re-provision into a namespace whose old Service is still
+// terminating.
+const deletionWaitTimeout = 30 * time.Second
+
+// nginxResourceKind is one Kubernetes resource type doNginXDeprovision knows
+// how to clean up: list matches its instance's label selector, for a
+// dry-run report, and deleteAll removes them for real.
+type nginxResourceKind struct {
+	name      string
+	list      func() (int, error)
+	deleteAll func() error
+}
+
+// nginxResourceKinds returns every resource kind the nginx controller may
+// have created for instance, matched by its instanceID and serviceID
+// labels. Matching on both, rather than instanceID alone, keeps this from
+// ever touching another broker's resources in a garbage-collection or
+// recovery pass over a shared namespace. routeClient is non-nil only when
+// the controller is running with platform kube.PlatformOpenShift, in which
+// case Routes are included alongside every other kind.
+func nginxResourceKinds(client kubernetes.Interface, routeClient kube.RouteClient, instance *nginxServiceInstance) []nginxResourceKind {
+	selector := metav1.ListOptions{LabelSelector: instanceResourceSelector(instance)}
+	ns := instance.Namespace
+
+	kinds := []nginxResourceKind{
+		{
+			name: "deployments",
+			list: func() (int, error) {
+				list, err := client.Apps().Deployments(ns).List(selector)
+				return len(list.Items), err
+			},
+			deleteAll: func() error { return client.Apps().Deployments(ns).DeleteCollection(nil, selector) },
+		},
+		{
+			name: "services",
+			list: func() (int, error) {
+				list, err := client.Core().Services(ns).List(selector)
+				return len(list.Items), err
+			},
+			deleteAll: func() error { return client.Core().Services(ns).DeleteCollection(nil, selector) },
+		},
+		{
+			name: "ingresses",
+			list: func() (int, error) {
+				list, err := client.Extensions().Ingresses(ns).List(selector)
+				return len(list.Items), err
+			},
+			deleteAll: func() error { return client.Extensions().Ingresses(ns).DeleteCollection(nil, selector) },
+		},
+		{
+			name: "configmaps",
+			list: func() (int, error) {
+				list, err := client.Core().ConfigMaps(ns).List(selector)
+				return len(list.Items), err
+			},
+			deleteAll: func() error { return client.Core().ConfigMaps(ns).DeleteCollection(nil, selector) },
+		},
+		{
+			name: "secrets",
+			list: func() (int, error) {
+				list, err := client.Core().Secrets(ns).List(selector)
+				return len(list.Items), err
+			},
+			deleteAll: func() error { return client.Core().Secrets(ns).DeleteCollection(nil, selector) },
+		},
+		{
+			name: "horizontalpodautoscalers",
+			list: func() (int, error) {
+				list, err := client.Autoscaling().HorizontalPodAutoscalers(ns).List(selector)
+				return len(list.Items), err
+			},
+			deleteAll: func() error {
+				return client.Autoscaling().HorizontalPodAutoscalers(ns).DeleteCollection(nil, selector)
+			},
+		},
+		{
+			name: "networkpolicies",
+			list: func() (int, error) {
+				list, err := client.Networking().NetworkPolicies(ns).List(selector)
+				return len(list.Items), err
+			},
+			deleteAll: func() error { return client.Networking().NetworkPolicies(ns).DeleteCollection(nil, selector) },
+		},
+	}
+
+	if routeClient != nil {
+		kinds = append(kinds, nginxResourceKind{
+			name: "routes",
+			list: func() (int, error) {
+				list, err := routeClient.List(ns, selector)
+				if err != nil {
+					return 0, err
+				}
+				return len(list.Items), nil
+			},
+			deleteAll: func() error { return routeClient.DeleteCollection(ns, selector) },
+		})
+	}
+
+	return kinds
+}
+
+// instanceResourceSelector matches every resource nginxResourceKinds may
+// have created for instance: its instanceID label plus this broker's
+// serviceID, so a garbage-collection or recovery pass over a shared
+// namespace never touches another broker's resources.
+func instanceResourceSelector(instance *nginxServiceInstance) string {
+	return fmt.Sprintf("%s,serviceID=%s", kube.InstanceLabelSelector(instance.ID), serviceID)
+}
+
+// doNginXDeprovision deletes every Kubernetes resource labeled with
+// instance's ID and this broker's serviceID, across every resource type the
+// controller may have created for it. Every kind is attempted regardless of
+// earlier failures, and the caller gets back an aggregate of every error
+// encountered; it succeeds only when every kind was deleted or already
+// absent. It returns the names of the kinds that had at least one matching
+// resource, for logging. routeClient is non-nil only when the controller is
+// running with platform kube.PlatformOpenShift.
+//
+// When dryRun is true, nothing is deleted: the same label selectors are
+// used to list what's present instead, so a caller can report what a real
+// deprovision would remove without performing it.
+//
+// Once every kind is deleted, it blocks until the instance's Deployment and
+// Service are actually gone, using waitutil.ForDeletion, so a caller never
+// reports the deprovision complete while a Service that could collide with
+// a subsequent provision is still terminating. It returns early if ctx is
+// canceled or hits its deadline first.
+func doNginXDeprovision(ctx context.Context, client kubernetes.Interface, routeClient kube.RouteClient, instance *nginxServiceInstance, dryRun bool) ([]string, error) {
+	var present []string
+	var failures []string
+
+	for _, kind := range nginxResourceKinds(client, routeClient, instance) {
+		if dryRun {
+			n, err := kind.list()
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", kind.name, err))
+				continue
+			}
+			if n > 0 {
+				present = append(present, kind.name)
+			}
+			continue
+		}
+		n, listErr := kind.list()
+		if err := kind.deleteAll(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", kind.name, err))
+			continue
+		}
+		if listErr == nil && n > 0 {
+			present = append(present, kind.name)
+		}
+	}
+
+	if len(failures) > 0 {
+		return present, fmt.Errorf("failed to delete nginx instance resources: %s", strings.Join(failures, "; "))
+	}
+
+	if !dryRun {
+		selector := instanceResourceSelector(instance)
+		kinds := []waitutil.Kind{waitutil.Deployments, waitutil.Services}
+		if err := waitutil.ForDeletion(ctx, client, instance.Namespace, selector, kinds, deletionWaitTimeout); err != nil {
+			return present, fmt.Errorf("waiting for nginx instance resources to finish deleting: %v", err)
+		}
+	}
+	return present, nil
+}
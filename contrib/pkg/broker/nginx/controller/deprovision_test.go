@@ -0,0 +1,148 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	appsv1beta1 "k8s.io/client-go/pkg/apis/apps/v1beta1"
+	autoscalingv1 "k8s.io/client-go/pkg/apis/autoscaling/v1"
+	extensionsv1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	networkingv1 "k8s.io/client-go/pkg/apis/networking/v1"
+	ktesting "k8s.io/client-go/testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// failDeleteCollection makes client return err for every DeleteCollection
+// against resource, simulating the API server rejecting one particular kind
+// of object.
+func failDeleteCollection(client *fake.Clientset, resource string, err error) {
+	client.PrependReactor("delete-collection", resource, func(ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, err
+	})
+}
+
+func seededNginXFixtures(instanceID string) []runtime.Object {
+	labels := map[string]string{"instanceID": instanceID, "serviceID": serviceID}
+	return []runtime.Object{
+		&appsv1beta1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "d", Namespace: "default", Labels: labels}},
+		&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "s", Namespace: "default", Labels: labels}},
+		&extensionsv1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "i", Namespace: "default", Labels: labels}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "default", Labels: labels}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "sec", Namespace: "default", Labels: labels}},
+		&autoscalingv1.HorizontalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: "hpa", Namespace: "default", Labels: labels}},
+		&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "np", Namespace: "default", Labels: labels}},
+	}
+}
+
+func TestDoNginXDeprovisionDeletesEveryResourceKind(t *testing.T) {
+	instance := &nginxServiceInstance{ID: "test", Namespace: "default"}
+	client := fake.NewSimpleClientset(seededNginXFixtures(instance.ID)...)
+
+	present, err := doNginXDeprovision(context.Background(), client, nil, instance, false)
+	if err != nil {
+		t.Fatalf("doNginXDeprovision: %v", err)
+	}
+	if len(present) != 7 {
+		t.Errorf("present = %v, want all 7 resource kinds", present)
+	}
+
+	for _, kind := range nginxResourceKinds(client, nil, instance) {
+		n, err := kind.list()
+		if err != nil {
+			t.Fatalf("%s: %v", kind.name, err)
+		}
+		if n != 0 {
+			t.Errorf("%s: %d resources remain after deprovision", kind.name, n)
+		}
+	}
+}
+
+func TestDoNginXDeprovisionSucceedsWhenAlreadyAbsent(t *testing.T) {
+	instance := &nginxServiceInstance{ID: "test", Namespace: "default"}
+	client := fake.NewSimpleClientset()
+
+	present, err := doNginXDeprovision(context.Background(), client, nil, instance, false)
+	if err != nil {
+		t.Fatalf("doNginXDeprovision: %v", err)
+	}
+	if len(present) != 0 {
+		t.Errorf("present = %v, want none", present)
+	}
+}
+
+func TestDoNginXDeprovisionDryRunReportsWithoutDeleting(t *testing.T) {
+	instance := &nginxServiceInstance{ID: "test", Namespace: "default"}
+	client := fake.NewSimpleClientset(seededNginXFixtures(instance.ID)...)
+
+	present, err := doNginXDeprovision(context.Background(), client, nil, instance, true)
+	if err != nil {
+		t.Fatalf("doNginXDeprovision: %v", err)
+	}
+	if len(present) != 7 {
+		t.Errorf("present = %v, want all 7 resource kinds", present)
+	}
+
+	for _, kind := range nginxResourceKinds(client, nil, instance) {
+		n, err := kind.list()
+		if err != nil {
+			t.Fatalf("%s: %v", kind.name, err)
+		}
+		if n != 1 {
+			t.Errorf("%s: %d resources remain, dry run should not have deleted anything", kind.name, n)
+		}
+	}
+}
+
+func TestDoNginXDeprovisionFailsWhenAResourceNeverFinishesDeleting(t *testing.T) {
+	instance := &nginxServiceInstance{ID: "test", Namespace: "default"}
+	client := fake.NewSimpleClientset(seededNginXFixtures(instance.ID)...)
+	// Accept the delete-collection call but don't actually remove the
+	// Deployment, simulating one still terminating behind the scenes.
+	client.PrependReactor("delete-collection", "deployments", func(ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := doNginXDeprovision(ctx, client, nil, instance, false); err == nil {
+		t.Fatal("expected doNginXDeprovision to fail waiting for the lingering deployment")
+	}
+}
+
+func TestDoNginXDeprovisionAggregatesErrorsAcrossKinds(t *testing.T) {
+	instance := &nginxServiceInstance{ID: "test", Namespace: "default"}
+	client := fake.NewSimpleClientset()
+	failDeleteCollection(client, "deployments", fmt.Errorf("boom"))
+	failDeleteCollection(client, "services", fmt.Errorf("boom"))
+
+	_, err := doNginXDeprovision(context.Background(), client, nil, instance, false)
+	if err == nil {
+		t.Fatal("expected an aggregate error")
+	}
+	if !strings.Contains(err.Error(), "deployments") || !strings.Contains(err.Error(), "services") {
+		t.Errorf("error = %q, want it to mention both failing kinds", err.Error())
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// currentNginXPodName resolves the pod currently backing an instance's
+// Service by reading the Service's Endpoints, rather than assuming a fixed
+// pod name. This is necessary because the instance runs as a Deployment: its
+// pod is replaced, with a freshly generated name, on every restart or
+// rollout. Once a Service has published Endpoints, this is preferred over
+// listing Pods directly, since an Endpoint only exists for a pod that has
+// already passed its readiness check.
+func currentNginXPodName(client kubernetes.Interface, namespace, serviceName string) (string, error) {
+	endpoints, err := client.Core().Endpoints(namespace).Get(serviceName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, subset := range endpoints.Subsets {
+		for _, addr := range subset.Addresses {
+			if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" {
+				return addr.TargetRef.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no ready pod behind service %s", serviceName)
+}
+
+// errNoNginXPod is returned by getNginXPodIP when instanceID has no pod that
+// could serve traffic, so callers can tell "not up yet" apart from a
+// transport failure talking to a pod that does exist.
+type errNoNginXPod struct {
+	instanceID string
+}
+
+func (e errNoNginXPod) Error() string {
+	return fmt.Sprintf("no running nginx pod found for instance %s", e.instanceID)
+}
+
+// getNginXPodIP resolves instanceID's nginx pod address directly against the
+// Pods API, for callers that need an address before its Service has
+// published any Endpoints. It selects the "nginx" container by name and its
+// "nginx" port by name, rather than assuming either is
+// Containers[0]/Ports[0], since a service-mesh sidecar can be injected ahead
+// of the nginx container. Only Running pods are considered, and a Ready one
+// is preferred when more than one replica is up, so a pod that's mid-startup
+// or unhealthy isn't returned ahead of one already serving traffic.
+func getNginXPodIP(client kubernetes.Interface, namespace, instanceID string) (ip string, port int32, err error) {
+	pods, err := client.Core().Pods(namespace).List(metav1.ListOptions{LabelSelector: kube.InstanceLabelSelector(instanceID)})
+	if err != nil {
+		return "", 0, err
+	}
+
+	var candidate *v1.Pod
+	for i, pod := range pods.Items {
+		if pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		if candidate == nil {
+			candidate = &pods.Items[i]
+		}
+		if podReady(&pods.Items[i]) {
+			candidate = &pods.Items[i]
+			break
+		}
+	}
+	if candidate == nil {
+		return "", 0, errNoNginXPod{instanceID: instanceID}
+	}
+
+	for _, container := range candidate.Spec.Containers {
+		if container.Name != nginxContainerName {
+			continue
+		}
+		for _, p := range container.Ports {
+			if p.Name == nginxPortName {
+				return candidate.Status.PodIP, p.ContainerPort, nil
+			}
+		}
+	}
+	return "", 0, errNoNginXPod{instanceID: instanceID}
+}
+
+func podReady(pod *v1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
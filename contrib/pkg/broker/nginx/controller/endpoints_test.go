@@ -0,0 +1,126 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestGetNginXPodIPNoPodsReturnsTypedError(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	if _, _, err := getNginXPodIP(client, "default", "test"); err == nil {
+		t.Fatal("expected an error with no pods")
+	} else if _, ok := err.(errNoNginXPod); !ok {
+		t.Errorf("expected errNoNginXPod, got %T: %v", err, err)
+	}
+}
+
+func TestGetNginXPodIPSkipsSidecarAndSelectsNginXContainer(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx-test-abcde",
+			Namespace: "default",
+			Labels:    map[string]string{"instanceID": "test"},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{Name: "istio-proxy", Ports: []v1.ContainerPort{{Name: "http-proxy", ContainerPort: 15001}}},
+				{Name: nginxContainerName, Ports: []v1.ContainerPort{{Name: nginxPortName, ContainerPort: nginxPort}}},
+			},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning, PodIP: "10.1.2.3"},
+	}
+	client := fake.NewSimpleClientset(pod)
+
+	ip, port, err := getNginXPodIP(client, "default", "test")
+	if err != nil {
+		t.Fatalf("getNginXPodIP: %v", err)
+	}
+	if ip != "10.1.2.3" || port != nginxPort {
+		t.Errorf("expected 10.1.2.3:%d, got %s:%d", nginxPort, ip, port)
+	}
+}
+
+func TestGetNginXPodIPSkipsTerminatedPods(t *testing.T) {
+	dead := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx-test-old",
+			Namespace: "default",
+			Labels:    map[string]string{"instanceID": "test"},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: nginxContainerName, Ports: []v1.ContainerPort{{Name: nginxPortName, ContainerPort: nginxPort}}}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodFailed, PodIP: "10.1.2.4"},
+	}
+	client := fake.NewSimpleClientset(dead)
+
+	if _, _, err := getNginXPodIP(client, "default", "test"); err == nil {
+		t.Fatal("expected an error when the only matching pod has exited")
+	}
+}
+
+func TestGetNginXPodIPPrefersReadyPodAmongReplicas(t *testing.T) {
+	notReady := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx-test-a",
+			Namespace: "default",
+			Labels:    map[string]string{"instanceID": "test"},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: nginxContainerName, Ports: []v1.ContainerPort{{Name: nginxPortName, ContainerPort: nginxPort}}}},
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodRunning,
+			PodIP: "10.1.2.5",
+			Conditions: []v1.PodCondition{
+				{Type: v1.PodReady, Status: v1.ConditionFalse},
+			},
+		},
+	}
+	ready := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx-test-b",
+			Namespace: "default",
+			Labels:    map[string]string{"instanceID": "test"},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: nginxContainerName, Ports: []v1.ContainerPort{{Name: nginxPortName, ContainerPort: nginxPort}}}},
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodRunning,
+			PodIP: "10.1.2.6",
+			Conditions: []v1.PodCondition{
+				{Type: v1.PodReady, Status: v1.ConditionTrue},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(notReady, ready)
+
+	ip, _, err := getNginXPodIP(client, "default", "test")
+	if err != nil {
+		t.Fatalf("getNginXPodIP: %v", err)
+	}
+	if ip != "10.1.2.6" {
+		t.Errorf("expected the ready pod's address 10.1.2.6, got %s", ip)
+	}
+}
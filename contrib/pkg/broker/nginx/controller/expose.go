@@ -0,0 +1,123 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// nodePortRangeMin and nodePortRangeMax bound the nodePort provision/update
+// parameter to the range the Kubernetes API server allocates NodePort
+// services from by default, so a bad value is rejected here with a clear
+// message instead of surfacing as an opaque API server error. A cluster
+// configured with a different --service-node-port-range still enforces its
+// own bounds; this is just a friendlier first check.
+const (
+	nodePortRangeMin = 30000
+	nodePortRangeMax = 32767
+)
+
+// exposeParams is what resolveExposeParams parses from the expose and
+// nodePort provision/update parameters.
+type exposeParams struct {
+	// Exposed is true when the caller passed expose: nodeport, requesting a
+	// NodePort Service instead of the default ClusterIP one.
+	Exposed bool
+	// ExposeSet is true when the caller passed an expose parameter at all,
+	// clusterip or nodeport, so UpdateServiceInstance can tell an explicit
+	// switch from an update that doesn't mention exposure.
+	ExposeSet bool
+	// NodePort is the specific node port requested via the nodePort
+	// parameter, or 0 to let the API server assign one.
+	NodePort int32
+}
+
+// resolveExposeParams extracts and validates the expose and nodePort
+// provision/update parameters shared by create and update requests.
+func resolveExposeParams(params map[string]interface{}) (exposeParams, error) {
+	var out exposeParams
+
+	if v, present := params["expose"]; present {
+		expose, isString := v.(string)
+		if !isString {
+			return exposeParams{}, fmt.Errorf("expose must be a string")
+		}
+		switch expose {
+		case "clusterip":
+		case "nodeport":
+			out.Exposed = true
+		default:
+			return exposeParams{}, fmt.Errorf("expose must be \"clusterip\" or \"nodeport\"")
+		}
+		out.ExposeSet = true
+	}
+
+	if v, present := params["nodePort"]; present {
+		n, isNumber := v.(float64)
+		if !isNumber || n != float64(int32(n)) {
+			return exposeParams{}, fmt.Errorf("nodePort must be a whole number")
+		}
+		if n < nodePortRangeMin || n > nodePortRangeMax {
+			return exposeParams{}, fmt.Errorf("nodePort must be between %d and %d", nodePortRangeMin, nodePortRangeMax)
+		}
+		out.NodePort = int32(n)
+	}
+
+	return out, nil
+}
+
+// setServiceNodePort switches svc between a NodePort and a ClusterIP
+// Service, applying nodePort to its "http" port when exposed and clearing
+// any previously assigned node ports otherwise. It leaves nodePort at 0,
+// letting the API server pick one, when the caller didn't request a
+// specific value.
+func setServiceNodePort(svc *v1.Service, exposed bool, nodePort int32) {
+	if !exposed {
+		svc.Spec.Type = v1.ServiceTypeClusterIP
+		for i := range svc.Spec.Ports {
+			svc.Spec.Ports[i].NodePort = 0
+		}
+		return
+	}
+	svc.Spec.Type = v1.ServiceTypeNodePort
+	for i := range svc.Spec.Ports {
+		if svc.Spec.Ports[i].Name == "http" {
+			svc.Spec.Ports[i].NodePort = nodePort
+		}
+	}
+}
+
+// nodePortOf returns the node port assigned to svc's "http" port, or 0 if
+// svc isn't a NodePort Service.
+func nodePortOf(svc *v1.Service) int32 {
+	for _, p := range svc.Spec.Ports {
+		if p.Name == "http" {
+			return p.NodePort
+		}
+	}
+	return 0
+}
+
+// nodePortHintURL is a best-effort URL for reaching an instance from
+// outside the cluster via its NodePort: the caller is expected to
+// substitute a real node address for <node-ip>, since the broker has no
+// reliable way to learn one from inside the cluster.
+func nodePortHintURL(nodePort int32) string {
+	return fmt.Sprintf("http://<node-ip>:%d", nodePort)
+}
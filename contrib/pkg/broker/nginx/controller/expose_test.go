@@ -0,0 +1,125 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestResolveExposeParamsAbsentDefaultsToClusterIP(t *testing.T) {
+	expose, err := resolveExposeParams(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("resolveExposeParams: %v", err)
+	}
+	if expose.Exposed || expose.ExposeSet || expose.NodePort != 0 {
+		t.Errorf("expose = %+v, want the zero value", expose)
+	}
+}
+
+func TestResolveExposeParamsHonorsNodeport(t *testing.T) {
+	expose, err := resolveExposeParams(map[string]interface{}{"expose": "nodeport"})
+	if err != nil {
+		t.Fatalf("resolveExposeParams: %v", err)
+	}
+	if !expose.Exposed || !expose.ExposeSet {
+		t.Errorf("expose = %+v, want Exposed and ExposeSet", expose)
+	}
+}
+
+func TestResolveExposeParamsHonorsClusterIP(t *testing.T) {
+	expose, err := resolveExposeParams(map[string]interface{}{"expose": "clusterip"})
+	if err != nil {
+		t.Fatalf("resolveExposeParams: %v", err)
+	}
+	if expose.Exposed || !expose.ExposeSet {
+		t.Errorf("expose = %+v, want ExposeSet but not Exposed", expose)
+	}
+}
+
+func TestResolveExposeParamsRejectsUnknownValue(t *testing.T) {
+	if _, err := resolveExposeParams(map[string]interface{}{"expose": "loadbalancer"}); err == nil {
+		t.Fatal("expected an error for an unrecognized expose value")
+	}
+}
+
+func TestResolveExposeParamsHonorsNodePort(t *testing.T) {
+	expose, err := resolveExposeParams(map[string]interface{}{"expose": "nodeport", "nodePort": float64(30080)})
+	if err != nil {
+		t.Fatalf("resolveExposeParams: %v", err)
+	}
+	if expose.NodePort != 30080 {
+		t.Errorf("NodePort = %d, want 30080", expose.NodePort)
+	}
+}
+
+func TestResolveExposeParamsRejectsNodePortOutOfRange(t *testing.T) {
+	if _, err := resolveExposeParams(map[string]interface{}{"nodePort": float64(1024)}); err == nil {
+		t.Fatal("expected an error for a nodePort below the allocatable range")
+	}
+}
+
+func TestResolveExposeParamsRejectsNonWholeNodePort(t *testing.T) {
+	if _, err := resolveExposeParams(map[string]interface{}{"nodePort": float64(30080.5)}); err == nil {
+		t.Fatal("expected an error for a non-whole nodePort value")
+	}
+}
+
+func testServiceWithHTTPPort() *v1.Service {
+	return &v1.Service{
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{
+				{Name: "http", Port: nginxPort, TargetPort: intstr.FromInt(nginxPort)},
+			},
+		},
+	}
+}
+
+func TestSetServiceNodePortExposes(t *testing.T) {
+	svc := testServiceWithHTTPPort()
+
+	setServiceNodePort(svc, true, 30080)
+
+	if svc.Spec.Type != v1.ServiceTypeNodePort {
+		t.Errorf("Type = %v, want NodePort", svc.Spec.Type)
+	}
+	if svc.Spec.Ports[0].NodePort != 30080 {
+		t.Errorf("NodePort = %d, want 30080", svc.Spec.Ports[0].NodePort)
+	}
+}
+
+func TestSetServiceNodePortRetracts(t *testing.T) {
+	svc := testServiceWithHTTPPort()
+	setServiceNodePort(svc, true, 30080)
+
+	setServiceNodePort(svc, false, 0)
+
+	if svc.Spec.Type != v1.ServiceTypeClusterIP {
+		t.Errorf("Type = %v, want ClusterIP", svc.Spec.Type)
+	}
+	if svc.Spec.Ports[0].NodePort != 0 {
+		t.Errorf("NodePort = %d, want 0", svc.Spec.Ports[0].NodePort)
+	}
+}
+
+func TestNodePortOfReturnsZeroWhenNotExposed(t *testing.T) {
+	if got := nodePortOf(testServiceWithHTTPPort()); got != 0 {
+		t.Errorf("nodePortOf() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	gitInitContainerName = "git-clone"
+	gitSecretVolumeName  = "git-ssh-key"
+	gitSecretMountPath   = "/etc/git-secret"
+)
+
+// defaultGitImage is the image, including a git binary, run as the init
+// container that clones a gitRepo provision parameter into the content
+// volume. It's a broker-wide setting rather than a provision parameter,
+// since it's an implementation detail of how the clone happens rather than
+// something a caller needs to vary per instance.
+const defaultGitImage = "alpine/git:2.36"
+
+// defaultGitRef is the branch cloned when the gitRef provision/update
+// parameter is not given.
+const defaultGitRef = "master"
+
+// gitContentParams is the resolved gitRepo/gitRef/gitSecret
+// provision/update parameters describing a git-backed content source.
+type gitContentParams struct {
+	Repo   string
+	Ref    string
+	Secret string
+}
+
+// resolveGitContent extracts the "gitRepo"/"gitRef"/"gitSecret"
+// provision/update parameters. ok is false when gitRepo isn't set, in which
+// case the caller falls back to its other content sources.
+func resolveGitContent(params map[string]interface{}) (gitContentParams, bool, error) {
+	repo, isString := params["gitRepo"].(string)
+	if !isString || repo == "" {
+		return gitContentParams{}, false, nil
+	}
+
+	secret, _ := params["gitSecret"].(string)
+	if err := validateGitRepoURL(repo, secret != ""); err != nil {
+		return gitContentParams{}, false, err
+	}
+
+	ref := defaultGitRef
+	if v, present := params["gitRef"]; present {
+		s, isString := v.(string)
+		if !isString || s == "" {
+			return gitContentParams{}, false, fmt.Errorf("gitRef must be a non-empty string")
+		}
+		ref = s
+	}
+
+	return gitContentParams{Repo: repo, Ref: ref, Secret: secret}, true, nil
+}
+
+// resolveGitRefUpdate extracts the "gitRef" update parameter, for
+// re-triggering an existing git-backed instance's clone against a new ref.
+// ok is false when gitRef isn't set.
+func resolveGitRefUpdate(params map[string]interface{}) (string, bool, error) {
+	v, present := params["gitRef"]
+	if !present {
+		return "", false, nil
+	}
+	ref, isString := v.(string)
+	if !isString || ref == "" {
+		return "", false, fmt.Errorf("gitRef must be a non-empty string")
+	}
+	return ref, true, nil
+}
+
+// validateGitRepoURL requires an https:// URL by default. A gitSecret
+// naming a deploy-key secret opts an instance into cloning over ssh
+// instead, since that secret is what makes an ssh clone authenticate.
+func validateGitRepoURL(repo string, hasSecret bool) error {
+	if strings.HasPrefix(repo, "https://") {
+		return nil
+	}
+	if !hasSecret {
+		return fmt.Errorf("gitRepo must use https:// unless a gitSecret is supplied for ssh access")
+	}
+	if strings.HasPrefix(repo, "ssh://") || strings.Contains(repo, "@") {
+		return nil
+	}
+	return fmt.Errorf("gitRepo %q is not a valid https or ssh URL", repo)
+}
+
+// checkGitSecretExists confirms secretName, an existing deploy-key secret
+// the caller is expected to have already created, is present in namespace,
+// so provisioning fails fast rather than leaving the init container stuck
+// unable to mount it.
+func checkGitSecretExists(client kubernetes.Interface, namespace, secretName string) error {
+	if _, err := client.Core().Secrets(namespace).Get(secretName, metav1.GetOptions{}); err != nil {
+		return fmt.Errorf("gitSecret %q not found in namespace %q: %v", secretName, namespace, err)
+	}
+	return nil
+}
+
+// gitCloneCommand builds the init container command that shallow-clones
+// opts.Ref of opts.Repo into contentMountPath.
+func gitCloneCommand(opts gitContentParams) []string {
+	return []string{"git", "clone", "--branch", opts.Ref, "--single-branch", "--depth", "1", opts.Repo, contentMountPath}
+}
+
+// attachGitContent adds the emptyDir volume nginx serves from and the init
+// container that clones opts into it, replacing the ConfigMap-backed
+// content volume attachContent would otherwise mount at the same path. When
+// opts.Secret names a deploy-key secret, it's mounted into the init
+// container and referenced via GIT_SSH_COMMAND so the clone authenticates.
+// gitImage is the broker's configured image to run the clone in.
+func attachGitContent(spec *v1.PodSpec, opts gitContentParams, gitImage string) {
+	spec.Volumes = append(spec.Volumes, v1.Volume{
+		Name:         contentVolumeName,
+		VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+	})
+
+	initContainer := v1.Container{
+		Name:                     gitInitContainerName,
+		Image:                    gitImage,
+		Command:                  gitCloneCommand(opts),
+		VolumeMounts:             []v1.VolumeMount{{Name: contentVolumeName, MountPath: contentMountPath}},
+		TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
+	}
+
+	if opts.Secret != "" {
+		mode := int32(0400)
+		spec.Volumes = append(spec.Volumes, v1.Volume{
+			Name: gitSecretVolumeName,
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{SecretName: opts.Secret, DefaultMode: &mode},
+			},
+		})
+		initContainer.VolumeMounts = append(initContainer.VolumeMounts,
+			v1.VolumeMount{Name: gitSecretVolumeName, MountPath: gitSecretMountPath, ReadOnly: true},
+		)
+		initContainer.Env = []v1.EnvVar{
+			{Name: "GIT_SSH_COMMAND", Value: fmt.Sprintf("ssh -i %s/%s -o StrictHostKeyChecking=no", gitSecretMountPath, v1.SSHAuthPrivateKey)},
+		}
+	}
+
+	spec.InitContainers = append(spec.InitContainers, initContainer)
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts,
+			v1.VolumeMount{Name: contentVolumeName, MountPath: contentMountPath, ReadOnly: true},
+		)
+	}
+}
+
+// setGitRefRollout updates template's init container to clone opts.Ref,
+// so an UpdateServiceInstance call that only changes gitRef still changes
+// the pod template and triggers a rollout.
+func setGitRefRollout(template *v1.PodTemplateSpec, opts gitContentParams) {
+	for i := range template.Spec.InitContainers {
+		if template.Spec.InitContainers[i].Name == gitInitContainerName {
+			template.Spec.InitContainers[i].Command = gitCloneCommand(opts)
+			return
+		}
+	}
+}
+
+// gitCloneFailure inspects pod's init container statuses for a non-zero
+// exit, returning the clone's termination message when found. A pod stuck
+// on an unauthenticated or unreachable gitRepo never reaches Running, so
+// this is checked alongside terminalPodFailure's own container checks.
+func gitCloneFailure(pod *v1.Pod) error {
+	for _, cs := range pod.Status.InitContainerStatuses {
+		if cs.Name != gitInitContainerName {
+			continue
+		}
+		if cs.State.Terminated != nil && cs.State.Terminated.ExitCode != 0 {
+			return fmt.Errorf("nginx instance's git-clone init container in pod %s/%s failed: %s",
+				pod.Namespace, pod.Name, cs.State.Terminated.Message)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestResolveGitContentAbsentReturnsNotOK(t *testing.T) {
+	_, ok, err := resolveGitContent(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("resolveGitContent: %v", err)
+	}
+	if ok {
+		t.Error("expected ok=false when gitRepo isn't set")
+	}
+}
+
+func TestResolveGitContentDefaultsRef(t *testing.T) {
+	opts, ok, err := resolveGitContent(map[string]interface{}{"gitRepo": "https://example.com/site.git"})
+	if err != nil {
+		t.Fatalf("resolveGitContent: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected ok=true")
+	}
+	if opts.Ref != defaultGitRef {
+		t.Errorf("Ref = %q, want %q", opts.Ref, defaultGitRef)
+	}
+}
+
+func TestResolveGitContentHonorsGitRef(t *testing.T) {
+	opts, ok, err := resolveGitContent(map[string]interface{}{
+		"gitRepo": "https://example.com/site.git",
+		"gitRef":  "release",
+	})
+	if err != nil {
+		t.Fatalf("resolveGitContent: %v", err)
+	}
+	if !ok || opts.Ref != "release" {
+		t.Errorf("opts = %+v, ok=%v, want Ref=release", opts, ok)
+	}
+}
+
+func TestResolveGitContentRejectsEmptyGitRef(t *testing.T) {
+	_, _, err := resolveGitContent(map[string]interface{}{
+		"gitRepo": "https://example.com/site.git",
+		"gitRef":  "",
+	})
+	if err == nil {
+		t.Fatal("expected an error for an empty gitRef")
+	}
+}
+
+func TestResolveGitContentRejectsSSHWithoutSecret(t *testing.T) {
+	_, _, err := resolveGitContent(map[string]interface{}{"gitRepo": "git@example.com:org/site.git"})
+	if err == nil {
+		t.Fatal("expected an error for an ssh gitRepo without a gitSecret")
+	}
+}
+
+func TestResolveGitContentAllowsSSHWithSecret(t *testing.T) {
+	opts, ok, err := resolveGitContent(map[string]interface{}{
+		"gitRepo":   "git@example.com:org/site.git",
+		"gitSecret": "deploy-key",
+	})
+	if err != nil {
+		t.Fatalf("resolveGitContent: %v", err)
+	}
+	if !ok || opts.Secret != "deploy-key" {
+		t.Errorf("opts = %+v, ok=%v, want Secret=deploy-key", opts, ok)
+	}
+}
+
+func TestGitCloneCommand(t *testing.T) {
+	got := gitCloneCommand(gitContentParams{Repo: "https://example.com/site.git", Ref: "release"})
+	want := []string{"git", "clone", "--branch", "release", "--single-branch", "--depth", "1", "https://example.com/site.git", contentMountPath}
+	if len(got) != len(want) {
+		t.Fatalf("gitCloneCommand = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("gitCloneCommand = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestGitCloneFailureReturnsTerminationMessage(t *testing.T) {
+	pod := &v1.Pod{
+		Status: v1.PodStatus{
+			InitContainerStatuses: []v1.ContainerStatus{
+				{
+					Name: gitInitContainerName,
+					State: v1.ContainerState{
+						Terminated: &v1.ContainerStateTerminated{ExitCode: 1, Message: "authentication failed"},
+					},
+				},
+			},
+		},
+	}
+	err := gitCloneFailure(pod)
+	if err == nil {
+		t.Fatal("expected an error for a failed git-clone init container")
+	}
+}
+
+func TestGitCloneFailureIgnoresSuccessfulClone(t *testing.T) {
+	pod := &v1.Pod{
+		Status: v1.PodStatus{
+			InitContainerStatuses: []v1.ContainerStatus{
+				{
+					Name:  gitInitContainerName,
+					State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 0}},
+				},
+			},
+		},
+	}
+	if err := gitCloneFailure(pod); err != nil {
+		t.Errorf("gitCloneFailure = %v, want nil", err)
+	}
+}
+
+func TestCheckGitSecretExists(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "deploy-key", Namespace: "default"},
+	})
+	if err := checkGitSecretExists(client, "default", "deploy-key"); err != nil {
+		t.Errorf("checkGitSecretExists: %v", err)
+	}
+	if err := checkGitSecretExists(client, "default", "missing"); err == nil {
+		t.Error("expected an error for a missing gitSecret")
+	}
+}
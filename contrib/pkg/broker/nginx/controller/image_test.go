@@ -0,0 +1,120 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestResolveImageDefaultsToBrokerImage(t *testing.T) {
+	image, changed, err := resolveImage(defaultNginxImage, nil)
+	if err != nil {
+		t.Fatalf("resolveImage: %v", err)
+	}
+	if image != defaultNginxImage {
+		t.Errorf("image = %q, want %q", image, defaultNginxImage)
+	}
+	if changed {
+		t.Error("changed = true, want false when imageTag isn't set")
+	}
+}
+
+func TestResolveImageHonorsImageTagParameter(t *testing.T) {
+	image, changed, err := resolveImage("nginx:1.17", map[string]interface{}{"imageTag": "latest"})
+	if err != nil {
+		t.Fatalf("resolveImage: %v", err)
+	}
+	if image != "nginx:latest" {
+		t.Errorf("image = %q, want %q", image, "nginx:latest")
+	}
+	if !changed {
+		t.Error("changed = false, want true when imageTag is set")
+	}
+}
+
+func TestResolveImageRejectsInvalidImageTag(t *testing.T) {
+	params := map[string]interface{}{"imageTag": "not/a-tag:9"}
+	if _, _, err := resolveImage(defaultNginxImage, params); err == nil {
+		t.Fatal("expected an error for an imageTag containing a repository or digest separator")
+	}
+}
+
+func TestResolveImagePullSecretEmptyWhenUnconfigured(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	name, err := resolveImagePullSecret(client, defaultNamespace, "", defaultNamespace, defaultServiceAccount, &brokerapi.CreateServiceInstanceRequest{}, "test")
+	if err != nil {
+		t.Fatalf("resolveImagePullSecret: %v", err)
+	}
+	if name != "" {
+		t.Errorf("name = %q, want empty", name)
+	}
+}
+
+func TestResolveImagePullSecretDefaultsToBrokerSecret(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry-creds", Namespace: defaultNamespace},
+	})
+
+	name, err := resolveImagePullSecret(client, defaultNamespace, "registry-creds", defaultNamespace, defaultServiceAccount, &brokerapi.CreateServiceInstanceRequest{}, "test")
+	if err != nil {
+		t.Fatalf("resolveImagePullSecret: %v", err)
+	}
+	if name != "registry-creds" {
+		t.Errorf("name = %q, want %q", name, "registry-creds")
+	}
+}
+
+func TestResolveImagePullSecretCopiesIntoInstanceNamespace(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry-creds", Namespace: defaultNamespace},
+		Data:       map[string][]byte{".dockerconfigjson": []byte("{}")},
+	})
+
+	name, err := resolveImagePullSecret(client, "other-namespace", "registry-creds", defaultNamespace, defaultServiceAccount, &brokerapi.CreateServiceInstanceRequest{}, "test")
+	if err != nil {
+		t.Fatalf("resolveImagePullSecret: %v", err)
+	}
+	if name != "registry-creds-test" {
+		t.Errorf("name = %q, want %q", name, "registry-creds-test")
+	}
+
+	if _, err := client.Core().Secrets("other-namespace").Get(name, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the secret to be copied into other-namespace: %v", err)
+	}
+}
+
+func TestResolveImagePullSecretHonorsParameterOverride(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "override-creds", Namespace: defaultNamespace},
+	})
+
+	req := &brokerapi.CreateServiceInstanceRequest{Parameters: map[string]interface{}{"imagePullSecret": "override-creds"}}
+	name, err := resolveImagePullSecret(client, defaultNamespace, "", defaultNamespace, defaultServiceAccount, req, "test")
+	if err != nil {
+		t.Fatalf("resolveImagePullSecret: %v", err)
+	}
+	if name != "override-creds" {
+		t.Errorf("name = %q, want %q", name, "override-creds")
+	}
+}
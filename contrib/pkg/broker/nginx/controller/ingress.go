@@ -0,0 +1,106 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	extensionsv1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+func ingressName(instanceID string) string {
+	return names.InstanceResourceName(brokerName, instanceID, "")
+}
+
+// parseIngressHost validates the optional "ingressHost" provision
+// parameter, returning "" when the caller didn't set one, in which case no
+// Ingress is created for the instance.
+func parseIngressHost(params map[string]interface{}) (string, error) {
+	v, ok := params["ingressHost"]
+	if !ok {
+		return "", nil
+	}
+	host, ok := v.(string)
+	if !ok || host == "" {
+		return "", fmt.Errorf("ingressHost must be a non-empty string")
+	}
+	if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+		return "", fmt.Errorf("invalid ingressHost %q: %s", host, strings.Join(errs, "; "))
+	}
+	return host, nil
+}
+
+// checkIngressHostAvailable refuses a host already claimed by another
+// nginx instance's Ingress, since two Ingresses routing the same host
+// would leave routing to chance.
+func checkIngressHostAvailable(client kubernetes.Interface, host string) error {
+	ingresses, err := client.Extensions().Ingresses(metav1.NamespaceAll).List(metav1.ListOptions{LabelSelector: "broker=" + brokerName})
+	if err != nil {
+		return fmt.Errorf("failed to list existing nginx ingresses: %v", err)
+	}
+	for _, ing := range ingresses.Items {
+		for _, rule := range ing.Spec.Rules {
+			if rule.Host == host {
+				return fmt.Errorf("ingressHost %q is already in use", host)
+			}
+		}
+	}
+	return nil
+}
+
+// createNginXIngress creates an Ingress routing host to serviceName's port.
+func createNginXIngress(client kubernetes.Interface, namespace, instanceID, serviceName, host string, port int32, labels, annotations map[string]string) (string, error) {
+	name := ingressName(instanceID)
+	ing := &extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: extensionsv1beta1.IngressSpec{
+			Rules: []extensionsv1beta1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: serviceName,
+										ServicePort: intstr.FromInt(int(port)),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if _, err := client.Extensions().Ingresses(namespace).Create(ing); err != nil {
+		return "", fmt.Errorf("failed to create nginx ingress: %v", err)
+	}
+	return name, nil
+}
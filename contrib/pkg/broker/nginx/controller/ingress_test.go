@@ -0,0 +1,83 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	extensionsv1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+func TestParseIngressHostAbsent(t *testing.T) {
+	host, err := parseIngressHost(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("parseIngressHost: %v", err)
+	}
+	if host != "" {
+		t.Errorf("host = %q, want empty", host)
+	}
+}
+
+func TestParseIngressHostValid(t *testing.T) {
+	host, err := parseIngressHost(map[string]interface{}{"ingressHost": "nginx.example.com"})
+	if err != nil {
+		t.Fatalf("parseIngressHost: %v", err)
+	}
+	if host != "nginx.example.com" {
+		t.Errorf("host = %q, want %q", host, "nginx.example.com")
+	}
+}
+
+func TestParseIngressHostRejectsInvalidDNSName(t *testing.T) {
+	if _, err := parseIngressHost(map[string]interface{}{"ingressHost": "not a valid host!"}); err == nil {
+		t.Fatal("expected an error for an invalid ingressHost")
+	}
+}
+
+func TestParseIngressHostRejectsEmptyString(t *testing.T) {
+	if _, err := parseIngressHost(map[string]interface{}{"ingressHost": ""}); err == nil {
+		t.Fatal("expected an error for an empty ingressHost")
+	}
+}
+
+func TestCheckIngressHostAvailableAllowsUnclaimedHost(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	if err := checkIngressHostAvailable(client, "nginx.example.com"); err != nil {
+		t.Errorf("checkIngressHostAvailable: %v", err)
+	}
+}
+
+func TestCheckIngressHostAvailableRejectsDuplicateHost(t *testing.T) {
+	existing := &extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx-other",
+			Namespace: "default",
+			Labels:    map[string]string{"broker": brokerName},
+		},
+		Spec: extensionsv1beta1.IngressSpec{
+			Rules: []extensionsv1beta1.IngressRule{{Host: "nginx.example.com"}},
+		},
+	}
+	client := fake.NewSimpleClientset(existing)
+
+	if err := checkIngressHostAvailable(client, "nginx.example.com"); err == nil {
+		t.Fatal("expected an error for a host already claimed by another instance")
+	}
+}
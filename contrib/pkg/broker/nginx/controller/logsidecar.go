@@ -0,0 +1,78 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	logSidecarContainerName = "access-logs"
+	logVolumeName           = "access-logs"
+	logVolumeMountPath      = "/var/log/nginx"
+	accessLogFile           = logVolumeMountPath + "/access.log"
+)
+
+// defaultLogSidecarImage runs the container that tails the shared access
+// log volume to its own stdout. It's a broker-wide setting rather than a
+// provision parameter, since it's an implementation detail of how the
+// tailing happens rather than something a caller needs to vary per
+// instance.
+const defaultLogSidecarImage = "busybox:1.35"
+
+// resolveLogSidecarParam extracts the "logSidecar" provision parameter.
+func resolveLogSidecarParam(params map[string]interface{}) (bool, error) {
+	v, present := params["logSidecar"]
+	if !present {
+		return false, nil
+	}
+	enabled, isBool := v.(bool)
+	if !isBool {
+		return false, fmt.Errorf("logSidecar must be a boolean")
+	}
+	return enabled, nil
+}
+
+// attachLogSidecar mounts an emptyDir volume at logVolumeMountPath into
+// every existing container, shadowing the stock nginx image's symlink from
+// access.log to /dev/stdout with a real directory nginx writes its access
+// log into, and adds a second container that tails that file to its own
+// stdout so `kubectl logs -c access-logs` works without shelling into the
+// nginx container. logSidecarImage is the broker's configured image to run
+// it in.
+func attachLogSidecar(spec *v1.PodSpec, logSidecarImage string) {
+	spec.Volumes = append(spec.Volumes, v1.Volume{
+		Name:         logVolumeName,
+		VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+	})
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts,
+			v1.VolumeMount{Name: logVolumeName, MountPath: logVolumeMountPath},
+		)
+	}
+
+	spec.Containers = append(spec.Containers, v1.Container{
+		Name:    logSidecarContainerName,
+		Image:   logSidecarImage,
+		Command: []string{"sh", "-c", fmt.Sprintf("tail -F %s", accessLogFile)},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: logVolumeName, MountPath: logVolumeMountPath, ReadOnly: true},
+		},
+	})
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestResolveLogSidecarParamAbsent(t *testing.T) {
+	enabled, err := resolveLogSidecarParam(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("resolveLogSidecarParam: %v", err)
+	}
+	if enabled {
+		t.Error("expected enabled=false when logSidecar is not set")
+	}
+}
+
+func TestResolveLogSidecarParamEnabled(t *testing.T) {
+	enabled, err := resolveLogSidecarParam(map[string]interface{}{"logSidecar": true})
+	if err != nil {
+		t.Fatalf("resolveLogSidecarParam: %v", err)
+	}
+	if !enabled {
+		t.Error("expected enabled=true when logSidecar is true")
+	}
+}
+
+func TestResolveLogSidecarParamRejectsNonBool(t *testing.T) {
+	if _, err := resolveLogSidecarParam(map[string]interface{}{"logSidecar": "true"}); err == nil {
+		t.Fatal("expected an error for a non-boolean logSidecar value")
+	}
+}
+
+func TestAttachLogSidecarAddsSecondContainer(t *testing.T) {
+	spec := &v1.PodSpec{
+		Containers: []v1.Container{{Name: nginxContainerName}},
+	}
+
+	attachLogSidecar(spec, "busybox:1.35")
+
+	if len(spec.Containers) != 2 {
+		t.Fatalf("Containers = %d, want 2", len(spec.Containers))
+	}
+	if spec.Containers[0].Name != nginxContainerName {
+		t.Errorf("Containers[0].Name = %q, want %q", spec.Containers[0].Name, nginxContainerName)
+	}
+	if spec.Containers[1].Name != logSidecarContainerName {
+		t.Errorf("Containers[1].Name = %q, want %q", spec.Containers[1].Name, logSidecarContainerName)
+	}
+
+	for i, c := range spec.Containers {
+		found := false
+		for _, m := range c.VolumeMounts {
+			if m.Name == logVolumeName && m.MountPath == logVolumeMountPath {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Containers[%d] is missing the %s volume mount", i, logVolumeName)
+		}
+	}
+
+	foundVolume := false
+	for _, vol := range spec.Volumes {
+		if vol.Name == logVolumeName && vol.EmptyDir != nil {
+			foundVolume = true
+		}
+	}
+	if !foundVolume {
+		t.Error("expected an emptyDir volume named " + logVolumeName)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	networkingv1 "k8s.io/client-go/pkg/apis/networking/v1"
+)
+
+func networkPolicyName(instanceID string) string {
+	return names.InstanceResourceName(brokerName, instanceID, "")
+}
+
+// resolveAllowedNamespaces validates the optional "allowedNamespaces"
+// provision/update parameter: a non-empty list whose entries are either a
+// plain namespace name or a label selector object of the form
+// {"matchLabels": {...}}. A namespace name is matched against the "name"
+// label operators conventionally set on Namespace objects for exactly
+// this purpose; a label selector is matched against Namespace labels
+// directly. ok is false when the caller didn't set the parameter, in
+// which case no NetworkPolicy is created or changed.
+func resolveAllowedNamespaces(params map[string]interface{}) (peers []networkingv1.NetworkPolicyPeer, ok bool, err error) {
+	v, present := params["allowedNamespaces"]
+	if !present {
+		return nil, false, nil
+	}
+	list, isList := v.([]interface{})
+	if !isList || len(list) == 0 {
+		return nil, false, fmt.Errorf("allowedNamespaces must be a non-empty list of namespace names or label selectors")
+	}
+
+	for _, entry := range list {
+		switch e := entry.(type) {
+		case string:
+			if errs := validation.IsDNS1123Label(e); len(errs) > 0 {
+				return nil, false, fmt.Errorf("invalid namespace name %q in allowedNamespaces: %s", e, strings.Join(errs, "; "))
+			}
+			peers = append(peers, networkingv1.NetworkPolicyPeer{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"name": e}},
+			})
+		case map[string]interface{}:
+			matchLabels, err := parseMatchLabels(e)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid label selector in allowedNamespaces: %v", err)
+			}
+			peers = append(peers, networkingv1.NetworkPolicyPeer{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: matchLabels},
+			})
+		default:
+			return nil, false, fmt.Errorf("allowedNamespaces entries must be a namespace name string or a label selector object")
+		}
+	}
+	return peers, true, nil
+}
+
+// parseMatchLabels reads the "matchLabels" field of an allowedNamespaces
+// label selector entry.
+func parseMatchLabels(selector map[string]interface{}) (map[string]string, error) {
+	raw, present := selector["matchLabels"]
+	if !present {
+		return nil, fmt.Errorf("label selector must set matchLabels")
+	}
+	m, isMap := raw.(map[string]interface{})
+	if !isMap || len(m) == 0 {
+		return nil, fmt.Errorf("matchLabels must be a non-empty object")
+	}
+	matchLabels := make(map[string]string, len(m))
+	for k, v := range m {
+		s, isString := v.(string)
+		if !isString {
+			return nil, fmt.Errorf("matchLabels value for %q must be a string", k)
+		}
+		matchLabels[k] = s
+	}
+	return matchLabels, nil
+}
+
+// createNginXNetworkPolicy creates a NetworkPolicy isolating an instance's
+// pods, permitting ingress on port only from peers. On a cluster whose CNI
+// doesn't enforce NetworkPolicy, the object is created but has no effect;
+// GetServiceInstanceLastOperation's description says so, since an operator
+// relying on it for isolation needs to know it isn't guaranteed.
+func createNginXNetworkPolicy(client kubernetes.Interface, namespace, instanceID string, port int32, peers []networkingv1.NetworkPolicyPeer, labels, annotations map[string]string) (string, error) {
+	name := networkPolicyName(instanceID)
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{kube.InstanceLabelKey(): instanceID}},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{networkPolicyIngressRule(port, peers)},
+		},
+	}
+	if _, err := client.Networking().NetworkPolicies(namespace).Create(np); err != nil {
+		return "", fmt.Errorf("failed to create nginx network policy: %v", err)
+	}
+	return name, nil
+}
+
+// setNetworkPolicyPeers replaces np's single ingress rule with one
+// permitting ingress on port from peers, so an UpdateServiceInstance call
+// that changes allowedNamespaces or the port parameter can re-derive the
+// same NetworkPolicy createNginXNetworkPolicy builds at provision time.
+func setNetworkPolicyPeers(np *networkingv1.NetworkPolicy, port int32, peers []networkingv1.NetworkPolicyPeer) {
+	np.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{networkPolicyIngressRule(port, peers)}
+}
+
+func networkPolicyIngressRule(port int32, peers []networkingv1.NetworkPolicyPeer) networkingv1.NetworkPolicyIngressRule {
+	portRef := intstr.FromInt(int(port))
+	return networkingv1.NetworkPolicyIngressRule{
+		Ports: []networkingv1.NetworkPolicyPort{{Port: &portRef}},
+		From:  peers,
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResolveAllowedNamespacesAbsent(t *testing.T) {
+	peers, ok, err := resolveAllowedNamespaces(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("resolveAllowedNamespaces: %v", err)
+	}
+	if ok {
+		t.Error("expected ok=false when allowedNamespaces is not set")
+	}
+	if peers != nil {
+		t.Errorf("peers = %v, want nil", peers)
+	}
+}
+
+func TestResolveAllowedNamespacesRejectsEmptyList(t *testing.T) {
+	if _, _, err := resolveAllowedNamespaces(map[string]interface{}{"allowedNamespaces": []interface{}{}}); err == nil {
+		t.Fatal("expected an error for an empty allowedNamespaces list")
+	}
+}
+
+func TestResolveAllowedNamespacesRejectsNonList(t *testing.T) {
+	if _, _, err := resolveAllowedNamespaces(map[string]interface{}{"allowedNamespaces": "team-a"}); err == nil {
+		t.Fatal("expected an error for a non-list allowedNamespaces value")
+	}
+}
+
+func TestResolveAllowedNamespacesAcceptsNamespaceNames(t *testing.T) {
+	peers, ok, err := resolveAllowedNamespaces(map[string]interface{}{"allowedNamespaces": []interface{}{"team-a", "team-b"}})
+	if err != nil {
+		t.Fatalf("resolveAllowedNamespaces: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected ok=true when allowedNamespaces is set")
+	}
+	if len(peers) != 2 {
+		t.Fatalf("len(peers) = %d, want 2", len(peers))
+	}
+	if peers[0].NamespaceSelector.MatchLabels["name"] != "team-a" {
+		t.Errorf("peers[0] namespace selector = %v, want name=team-a", peers[0].NamespaceSelector.MatchLabels)
+	}
+	if peers[1].NamespaceSelector.MatchLabels["name"] != "team-b" {
+		t.Errorf("peers[1] namespace selector = %v, want name=team-b", peers[1].NamespaceSelector.MatchLabels)
+	}
+}
+
+func TestResolveAllowedNamespacesRejectsInvalidNamespaceName(t *testing.T) {
+	if _, _, err := resolveAllowedNamespaces(map[string]interface{}{"allowedNamespaces": []interface{}{"Not Valid!"}}); err == nil {
+		t.Fatal("expected an error for an invalid namespace name")
+	}
+}
+
+func TestResolveAllowedNamespacesAcceptsLabelSelector(t *testing.T) {
+	peers, ok, err := resolveAllowedNamespaces(map[string]interface{}{
+		"allowedNamespaces": []interface{}{
+			map[string]interface{}{"matchLabels": map[string]interface{}{"team": "a"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("resolveAllowedNamespaces: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected ok=true when allowedNamespaces is set")
+	}
+	if len(peers) != 1 || peers[0].NamespaceSelector.MatchLabels["team"] != "a" {
+		t.Errorf("peers = %v, want a namespace selector matching team=a", peers)
+	}
+}
+
+func TestResolveAllowedNamespacesRejectsMalformedSelector(t *testing.T) {
+	cases := []interface{}{
+		map[string]interface{}{},
+		map[string]interface{}{"matchLabels": "not a map"},
+		map[string]interface{}{"matchLabels": map[string]interface{}{}},
+		map[string]interface{}{"matchLabels": map[string]interface{}{"team": 1}},
+		42,
+	}
+	for _, entry := range cases {
+		if _, _, err := resolveAllowedNamespaces(map[string]interface{}{"allowedNamespaces": []interface{}{entry}}); err == nil {
+			t.Errorf("expected an error for malformed entry %v", entry)
+		}
+	}
+}
+
+func TestCreateNginXNetworkPolicySelectsInstancePods(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	peers, _, err := resolveAllowedNamespaces(map[string]interface{}{"allowedNamespaces": []interface{}{"team-a"}})
+	if err != nil {
+		t.Fatalf("resolveAllowedNamespaces: %v", err)
+	}
+
+	name, err := createNginXNetworkPolicy(client, "default", "test", nginxPort, peers, map[string]string{"instanceID": "test"}, nil)
+	if err != nil {
+		t.Fatalf("createNginXNetworkPolicy: %v", err)
+	}
+
+	np, err := client.Networking().NetworkPolicies("default").Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get network policy: %v", err)
+	}
+	if np.Spec.PodSelector.MatchLabels["instanceID"] != "test" {
+		t.Errorf("pod selector = %v, want instanceID=test", np.Spec.PodSelector.MatchLabels)
+	}
+	if len(np.Spec.Ingress) != 1 || len(np.Spec.Ingress[0].From) != 1 {
+		t.Fatalf("ingress rules = %v, want a single rule with one peer", np.Spec.Ingress)
+	}
+	if np.Spec.Ingress[0].Ports[0].Port.IntValue() != nginxPort {
+		t.Errorf("ingress port = %v, want %d", np.Spec.Ingress[0].Ports[0].Port, nginxPort)
+	}
+}
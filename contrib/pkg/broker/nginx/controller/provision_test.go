@@ -0,0 +1,383 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/pkg"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ktesting "k8s.io/client-go/testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// newTestClientset returns a fake clientset seeded with defaultNamespace,
+// so doNginXProvision's namespace validation doesn't reject every test.
+func newTestClientset(objects ...runtime.Object) *fake.Clientset {
+	return fake.NewSimpleClientset(append([]runtime.Object{
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: defaultNamespace}},
+	}, objects...)...)
+}
+
+// failCreate makes client return err for every Create against resource,
+// simulating the API server rejecting one particular kind of object.
+func failCreate(client *fake.Clientset, resource string, err error) {
+	client.PrependReactor("create", resource, func(ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, err
+	})
+}
+
+func TestDoNginXProvisionRollsBackWhateverWasCreatedOnFailure(t *testing.T) {
+	const id = "test"
+
+	cases := []struct {
+		name     string
+		resource string
+		req      *brokerapi.CreateServiceInstanceRequest
+	}{
+		{"deployment", "deployments", &brokerapi.CreateServiceInstanceRequest{PlanID: planID}},
+		{"service", "services", &brokerapi.CreateServiceInstanceRequest{PlanID: planID}},
+		{"contentconfigmap", "configmaps", &brokerapi.CreateServiceInstanceRequest{PlanID: planID, Parameters: map[string]interface{}{"indexHtml": "hi"}}},
+		{"tlssecret", "secrets", &brokerapi.CreateServiceInstanceRequest{PlanID: httpsPlanID}},
+		{"basicauthsecret", "secrets", &brokerapi.CreateServiceInstanceRequest{PlanID: basicAuthPlanID}},
+		{"autoscaler", "horizontalpodautoscalers", &brokerapi.CreateServiceInstanceRequest{PlanID: autoscalePlanID}},
+		{"ingress", "ingresses", &brokerapi.CreateServiceInstanceRequest{PlanID: planID, Parameters: map[string]interface{}{"ingressHost": "nginx.example.com"}}},
+		{"networkpolicy", "networkpolicies", &brokerapi.CreateServiceInstanceRequest{PlanID: planID, Parameters: map[string]interface{}{"allowedNamespaces": []interface{}{"team-a"}}}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newTestClientset()
+			failCreate(client, tc.resource, fmt.Errorf("induced failure"))
+			rollback := &kube.RollbackTracker{}
+
+			if _, err := doNginXProvision(context.Background(), client, id, tc.req, defaultMaxContentSize, defaultNginxImage, "", defaultNamespace, defaultGitImage, defaultLogSidecarImage, defaultServiceAccount, false, nil, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, nil, rollback); err == nil {
+				t.Fatal("expected doNginXProvision to fail")
+			}
+
+			if err := rollback.Run(context.Background()); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			labelSelector := metav1.ListOptions{LabelSelector: "instanceID=" + id}
+			deployments, err := client.Apps().Deployments(defaultNamespace).List(labelSelector)
+			if err != nil {
+				t.Fatalf("List deployments: %v", err)
+			}
+			if len(deployments.Items) != 0 {
+				t.Errorf("%d deployments survived rollback, want 0", len(deployments.Items))
+			}
+			services, err := client.Core().Services(defaultNamespace).List(labelSelector)
+			if err != nil {
+				t.Fatalf("List services: %v", err)
+			}
+			if len(services.Items) != 0 {
+				t.Errorf("%d services survived rollback, want 0", len(services.Items))
+			}
+			configMaps, err := client.Core().ConfigMaps(defaultNamespace).List(labelSelector)
+			if err != nil {
+				t.Fatalf("List configmaps: %v", err)
+			}
+			if len(configMaps.Items) != 0 {
+				t.Errorf("%d configmaps survived rollback, want 0", len(configMaps.Items))
+			}
+			secrets, err := client.Core().Secrets(defaultNamespace).List(labelSelector)
+			if err != nil {
+				t.Fatalf("List secrets: %v", err)
+			}
+			if len(secrets.Items) != 0 {
+				t.Errorf("%d secrets survived rollback, want 0", len(secrets.Items))
+			}
+			autoscalers, err := client.Autoscaling().HorizontalPodAutoscalers(defaultNamespace).List(labelSelector)
+			if err != nil {
+				t.Fatalf("List autoscalers: %v", err)
+			}
+			if len(autoscalers.Items) != 0 {
+				t.Errorf("%d autoscalers survived rollback, want 0", len(autoscalers.Items))
+			}
+			ingresses, err := client.Extensions().Ingresses(defaultNamespace).List(labelSelector)
+			if err != nil {
+				t.Fatalf("List ingresses: %v", err)
+			}
+			if len(ingresses.Items) != 0 {
+				t.Errorf("%d ingresses survived rollback, want 0", len(ingresses.Items))
+			}
+			networkPolicies, err := client.Networking().NetworkPolicies(defaultNamespace).List(labelSelector)
+			if err != nil {
+				t.Fatalf("List network policies: %v", err)
+			}
+			if len(networkPolicies.Items) != 0 {
+				t.Errorf("%d network policies survived rollback, want 0", len(networkPolicies.Items))
+			}
+		})
+	}
+}
+
+func TestDoNginXProvisionTranslatesForbiddenDeploymentCreate(t *testing.T) {
+	const id = "test"
+
+	client := newTestClientset()
+	failCreate(client, "deployments", apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, id, fmt.Errorf("denied")))
+	rollback := &kube.RollbackTracker{}
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: planID}
+
+	_, err := doNginXProvision(context.Background(), client, id, req, defaultMaxContentSize, defaultNginxImage, "", defaultNamespace, defaultGitImage, defaultLogSidecarImage, defaultServiceAccount, false, nil, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, nil, rollback)
+	if err == nil {
+		t.Fatal("expected doNginXProvision to fail")
+	}
+	if !strings.Contains(err.Error(), "not permitted to create deployments") {
+		t.Errorf("err = %v, want it to name the forbidden verb and resource", err)
+	}
+}
+
+func TestDoNginXProvisionRejectsHTTPSPlanOnPort443(t *testing.T) {
+	client := newTestClientset()
+	rollback := &kube.RollbackTracker{}
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: httpsPlanID, Parameters: map[string]interface{}{"port": float64(443)}}
+
+	if _, err := doNginXProvision(context.Background(), client, "test", req, defaultMaxContentSize, defaultNginxImage, "", defaultNamespace, defaultGitImage, defaultLogSidecarImage, defaultServiceAccount, false, nil, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, nil, rollback); err == nil {
+		t.Fatal("expected doNginXProvision to reject a port of 443 on the https plan")
+	}
+}
+
+func TestDoNginXProvisionUsesCustomPort(t *testing.T) {
+	client := newTestClientset()
+	rollback := &kube.RollbackTracker{}
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: planID, Parameters: map[string]interface{}{"port": float64(8080)}}
+
+	instance, err := doNginXProvision(context.Background(), client, "test", req, defaultMaxContentSize, defaultNginxImage, "", defaultNamespace, defaultGitImage, defaultLogSidecarImage, defaultServiceAccount, false, nil, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, nil, rollback)
+	if err != nil {
+		t.Fatalf("doNginXProvision: %v", err)
+	}
+	if instance.Port != 8080 {
+		t.Errorf("instance.Port = %d, want 8080", instance.Port)
+	}
+
+	svc, err := client.Core().Services(instance.Namespace).Get(instance.ServiceName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get service: %v", err)
+	}
+	found := false
+	for _, p := range svc.Spec.Ports {
+		if p.Name == "http" {
+			found = true
+			if p.Port != 8080 {
+				t.Errorf("service http port = %d, want 8080", p.Port)
+			}
+		}
+	}
+	if !found {
+		t.Error("service has no http port")
+	}
+}
+
+func TestDoNginXProvisionLabelsEveryResource(t *testing.T) {
+	client := newTestClientset()
+	rollback := &kube.RollbackTracker{}
+	req := &brokerapi.CreateServiceInstanceRequest{
+		PlanID: autoscalePlanID,
+		Parameters: map[string]interface{}{
+			"indexHtml":   "hi",
+			"ingressHost": "nginx.example.com",
+		},
+	}
+
+	instance, err := doNginXProvision(context.Background(), client, "test", req, defaultMaxContentSize, defaultNginxImage, "", defaultNamespace, defaultGitImage, defaultLogSidecarImage, defaultServiceAccount, false, nil, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, nil, rollback)
+	if err != nil {
+		t.Fatalf("doNginXProvision: %v", err)
+	}
+
+	want := kube.CommonLabels(brokerName, pkg.VERSION, serviceID, req.PlanID, instance.ID, instance.Namespace)
+
+	deployment, err := client.Apps().Deployments(instance.Namespace).Get(instance.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get deployment: %v", err)
+	}
+	if !reflect.DeepEqual(deployment.Labels, want) {
+		t.Errorf("deployment labels = %+v, want %+v", deployment.Labels, want)
+	}
+
+	svc, err := client.Core().Services(instance.Namespace).Get(instance.ServiceName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get service: %v", err)
+	}
+	if !reflect.DeepEqual(svc.Labels, want) {
+		t.Errorf("service labels = %+v, want %+v", svc.Labels, want)
+	}
+
+	ingressName := ingressName(instance.ID)
+	ingress, err := client.Extensions().Ingresses(instance.Namespace).Get(ingressName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get ingress: %v", err)
+	}
+	if !reflect.DeepEqual(ingress.Labels, want) {
+		t.Errorf("ingress labels = %+v, want %+v", ingress.Labels, want)
+	}
+
+	configMap, err := client.Core().ConfigMaps(instance.Namespace).Get(instance.ContentConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get configmap: %v", err)
+	}
+	if !reflect.DeepEqual(configMap.Labels, want) {
+		t.Errorf("configmap labels = %+v, want %+v", configMap.Labels, want)
+	}
+
+	autoscaler, err := client.Autoscaling().HorizontalPodAutoscalers(instance.Namespace).Get(instance.AutoscalerName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get autoscaler: %v", err)
+	}
+	if !reflect.DeepEqual(autoscaler.Labels, want) {
+		t.Errorf("autoscaler labels = %+v, want %+v", autoscaler.Labels, want)
+	}
+}
+
+func TestDoNginXProvisionCreatesNetworkPolicyWhenAllowedNamespacesSet(t *testing.T) {
+	client := newTestClientset()
+	rollback := &kube.RollbackTracker{}
+	req := &brokerapi.CreateServiceInstanceRequest{
+		PlanID:     planID,
+		Parameters: map[string]interface{}{"allowedNamespaces": []interface{}{"team-a"}},
+	}
+
+	instance, err := doNginXProvision(context.Background(), client, "test", req, defaultMaxContentSize, defaultNginxImage, "", defaultNamespace, defaultGitImage, defaultLogSidecarImage, defaultServiceAccount, false, nil, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, nil, rollback)
+	if err != nil {
+		t.Fatalf("doNginXProvision: %v", err)
+	}
+	if instance.NetworkPolicyName == "" {
+		t.Fatal("expected a NetworkPolicy to be created")
+	}
+
+	np, err := client.Networking().NetworkPolicies(instance.Namespace).Get(instance.NetworkPolicyName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get network policy: %v", err)
+	}
+	if len(np.Spec.Ingress) != 1 || len(np.Spec.Ingress[0].From) != 1 {
+		t.Fatalf("ingress rules = %v, want a single rule with one peer", np.Spec.Ingress)
+	}
+	if np.Spec.Ingress[0].From[0].NamespaceSelector.MatchLabels["name"] != "team-a" {
+		t.Errorf("peer = %v, want name=team-a", np.Spec.Ingress[0].From[0].NamespaceSelector.MatchLabels)
+	}
+}
+
+func TestDoNginXProvisionUsesProxyPass(t *testing.T) {
+	client := newTestClientset()
+	rollback := &kube.RollbackTracker{}
+	req := &brokerapi.CreateServiceInstanceRequest{
+		PlanID:     planID,
+		Parameters: map[string]interface{}{"proxyPass": "http://backend.default.svc.cluster.local"},
+	}
+
+	instance, err := doNginXProvision(context.Background(), client, "test", req, defaultMaxContentSize, defaultNginxImage, "", defaultNamespace, defaultGitImage, defaultLogSidecarImage, defaultServiceAccount, false, nil, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, nil, rollback)
+	if err != nil {
+		t.Fatalf("doNginXProvision: %v", err)
+	}
+	if instance.Upstream != "http://backend.default.svc.cluster.local" {
+		t.Errorf("instance.Upstream = %q, want http://backend.default.svc.cluster.local", instance.Upstream)
+	}
+	if instance.ConfConfigMapName == "" {
+		t.Fatal("expected a conf config map to be created for the proxy server block")
+	}
+
+	cm, err := client.Core().ConfigMaps(instance.Namespace).Get(instance.ConfConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get conf config map: %v", err)
+	}
+	if !strings.Contains(cm.Data[confKey], "proxy_pass http://backend.default.svc.cluster.local;") {
+		t.Errorf("conf = %q, want it to proxy_pass to the upstream", cm.Data[confKey])
+	}
+}
+
+func TestDoNginXProvisionRejectsProxyPassWithIndexHTML(t *testing.T) {
+	client := newTestClientset()
+	rollback := &kube.RollbackTracker{}
+	req := &brokerapi.CreateServiceInstanceRequest{
+		PlanID: planID,
+		Parameters: map[string]interface{}{
+			"proxyPass": "http://backend.default.svc.cluster.local",
+			"indexHtml": "hi",
+		},
+	}
+
+	if _, err := doNginXProvision(context.Background(), client, "test", req, defaultMaxContentSize, defaultNginxImage, "", defaultNamespace, defaultGitImage, defaultLogSidecarImage, defaultServiceAccount, false, nil, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, nil, rollback); err == nil {
+		t.Fatal("expected doNginXProvision to reject proxyPass combined with indexHtml")
+	}
+}
+
+func TestDoNginXProvisionRejectsExternalProxyPassByDefault(t *testing.T) {
+	client := newTestClientset()
+	rollback := &kube.RollbackTracker{}
+	req := &brokerapi.CreateServiceInstanceRequest{
+		PlanID:     planID,
+		Parameters: map[string]interface{}{"proxyPass": "http://example.com"},
+	}
+
+	if _, err := doNginXProvision(context.Background(), client, "test", req, defaultMaxContentSize, defaultNginxImage, "", defaultNamespace, defaultGitImage, defaultLogSidecarImage, defaultServiceAccount, false, nil, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, nil, rollback); err == nil {
+		t.Fatal("expected doNginXProvision to reject an external proxyPass without --allow-external-proxy")
+	}
+}
+
+func TestDoNginXProvisionSucceedsWithoutRegisteringSpuriousRollback(t *testing.T) {
+	client := newTestClientset()
+	rollback := &kube.RollbackTracker{}
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: planID}
+
+	instance, err := doNginXProvision(context.Background(), client, "test", req, defaultMaxContentSize, defaultNginxImage, "", defaultNamespace, defaultGitImage, defaultLogSidecarImage, defaultServiceAccount, false, nil, 1, time.Millisecond, nil, kube.PlatformKubernetes, nil, nil, rollback)
+	if err != nil {
+		t.Fatalf("doNginXProvision: %v", err)
+	}
+
+	if err := rollback.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if _, err := client.Apps().Deployments(instance.Namespace).Get(instance.DeploymentName, metav1.GetOptions{}); err == nil {
+		t.Error("deployment survived a rollback that should have undone a successful provision")
+	}
+}
+
+func TestDoNginXProvisionAppliesPodSpecOverride(t *testing.T) {
+	client := newTestClientset()
+	rollback := &kube.RollbackTracker{}
+	req := &brokerapi.CreateServiceInstanceRequest{PlanID: planID}
+	override := &kube.PodSpecOverride{NodeSelector: map[string]string{"disktype": "ssd"}}
+
+	instance, err := doNginXProvision(context.Background(), client, "test", req, defaultMaxContentSize, defaultNginxImage, "", defaultNamespace, defaultGitImage, defaultLogSidecarImage, defaultServiceAccount, false, nil, 1, time.Millisecond, override, kube.PlatformKubernetes, nil, nil, rollback)
+	if err != nil {
+		t.Fatalf("doNginXProvision: %v", err)
+	}
+
+	deployment, err := client.Apps().Deployments(instance.Namespace).Get(instance.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get deployment: %v", err)
+	}
+	if got, want := deployment.Spec.Template.Spec.NodeSelector["disktype"], "ssd"; got != want {
+		t.Errorf("NodeSelector[disktype] = %q, want %q", got, want)
+	}
+}
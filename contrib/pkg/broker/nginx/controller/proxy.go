@@ -0,0 +1,95 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// resolveProxyPass validates the optional "proxyPass" provision/update
+// parameter: an http(s) URL nginx should reverse-proxy to instead of
+// serving static content. ok is false when the caller didn't set it. Unless
+// allowExternal is set (the broker's --allow-external-proxy flag), the
+// URL's host must be an in-cluster service address, so a demo instance
+// can't be pointed at an arbitrary external endpoint by default.
+func resolveProxyPass(params map[string]interface{}, allowExternal bool) (proxyPass string, ok bool, err error) {
+	v, present := params["proxyPass"]
+	if !present {
+		return "", false, nil
+	}
+	raw, isString := v.(string)
+	if !isString || raw == "" {
+		return "", false, fmt.Errorf("proxyPass must be a non-empty string")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false, fmt.Errorf("proxyPass is not a valid URL: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false, fmt.Errorf("proxyPass must be an http:// or https:// URL")
+	}
+	if u.Hostname() == "" {
+		return "", false, fmt.Errorf("proxyPass must include a host")
+	}
+	if !allowExternal && !isInClusterHost(u.Hostname()) {
+		return "", false, fmt.Errorf("proxyPass %q is not an in-cluster service address; start the broker with --allow-external-proxy to permit an external upstream", raw)
+	}
+	return raw, true, nil
+}
+
+// isInClusterHost reports whether host is a Kubernetes Service DNS name:
+// "service", "service.namespace", "service.namespace.svc", or
+// "service.namespace.svc.cluster.local". Anything else - an IP, an external
+// hostname - is treated as leaving the cluster.
+func isInClusterHost(host string) bool {
+	host = strings.TrimSuffix(host, ".svc.cluster.local")
+	host = strings.TrimSuffix(host, ".svc")
+	labels := strings.Split(host, ".")
+	if len(labels) > 2 {
+		return false
+	}
+	for _, label := range labels {
+		if errs := validation.IsDNS1123Label(label); len(errs) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// proxyServerConf renders the nginx server block that reverse-proxies every
+// request to upstream, listening on port. Like basicAuthServerConf, it's
+// generated rather than user supplied, so it's rendered through the same
+// custom-conf ConfigMap plumbing a caller's own nginxConf uses.
+func proxyServerConf(port int32, upstream string) string {
+	return fmt.Sprintf(`server {
+    listen %d;
+
+    location / {
+        proxy_pass %s;
+        proxy_set_header Host $host;
+        proxy_set_header X-Real-IP $remote_addr;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+        proxy_set_header X-Forwarded-Proto $scheme;
+    }
+}
+`, port, upstream)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveProxyPassAbsent(t *testing.T) {
+	proxyPass, ok, err := resolveProxyPass(map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatalf("resolveProxyPass: %v", err)
+	}
+	if ok {
+		t.Error("expected ok=false when proxyPass is not set")
+	}
+	if proxyPass != "" {
+		t.Errorf("proxyPass = %q, want empty", proxyPass)
+	}
+}
+
+func TestResolveProxyPassAcceptsInClusterAddress(t *testing.T) {
+	cases := []string{
+		"http://backend",
+		"http://backend.default",
+		"https://backend.default.svc.cluster.local:8443/api",
+	}
+	for _, raw := range cases {
+		proxyPass, ok, err := resolveProxyPass(map[string]interface{}{"proxyPass": raw}, false)
+		if err != nil {
+			t.Errorf("resolveProxyPass(%q): %v", raw, err)
+			continue
+		}
+		if !ok || proxyPass != raw {
+			t.Errorf("resolveProxyPass(%q) = %q, %v, want %q, true", raw, proxyPass, ok, raw)
+		}
+	}
+}
+
+func TestResolveProxyPassRejectsExternalAddressByDefault(t *testing.T) {
+	if _, _, err := resolveProxyPass(map[string]interface{}{"proxyPass": "http://example.com"}, false); err == nil {
+		t.Fatal("expected an error for an external proxyPass without --allow-external-proxy")
+	}
+}
+
+func TestResolveProxyPassAcceptsExternalAddressWhenAllowed(t *testing.T) {
+	proxyPass, ok, err := resolveProxyPass(map[string]interface{}{"proxyPass": "http://example.com"}, true)
+	if err != nil {
+		t.Fatalf("resolveProxyPass: %v", err)
+	}
+	if !ok || proxyPass != "http://example.com" {
+		t.Errorf("proxyPass = %q, %v, want http://example.com, true", proxyPass, ok)
+	}
+}
+
+func TestResolveProxyPassRejectsNonString(t *testing.T) {
+	if _, _, err := resolveProxyPass(map[string]interface{}{"proxyPass": 5}, false); err == nil {
+		t.Fatal("expected an error for a non-string proxyPass")
+	}
+}
+
+func TestResolveProxyPassRejectsBadScheme(t *testing.T) {
+	if _, _, err := resolveProxyPass(map[string]interface{}{"proxyPass": "ftp://backend"}, true); err == nil {
+		t.Fatal("expected an error for a non-http(s) scheme")
+	}
+}
+
+func TestResolveProxyPassRejectsMalformedURL(t *testing.T) {
+	if _, _, err := resolveProxyPass(map[string]interface{}{"proxyPass": "://not-a-url"}, true); err == nil {
+		t.Fatal("expected an error for a malformed URL")
+	}
+}
+
+func TestProxyServerConfIncludesUpstream(t *testing.T) {
+	conf := proxyServerConf(8080, "http://backend.default.svc.cluster.local")
+	if !strings.Contains(conf, "listen 8080;") {
+		t.Errorf("conf = %q, want it to listen on 8080", conf)
+	}
+	if !strings.Contains(conf, "proxy_pass http://backend.default.svc.cluster.local;") {
+		t.Errorf("conf = %q, want it to proxy_pass to the upstream", conf)
+	}
+	if err := validateNginXConf(conf); err != nil {
+		t.Errorf("validateNginXConf(proxyServerConf(...)) = %v, want nil", err)
+	}
+}
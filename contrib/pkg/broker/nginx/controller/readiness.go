@@ -0,0 +1,180 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+
+	"k8s.io/client-go/kubernetes"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// confReadinessTimeout bounds how long waitForNginXConfReady waits for a
+// freshly rolled out nginxConf to prove it doesn't crash-loop the container.
+const confReadinessTimeout = 30 * time.Second
+
+const confReadinessPollInterval = 2 * time.Second
+
+// logExcerptBytes caps how much of a crash-looping container's log is
+// included in the error waitForNginXConfReady returns.
+const logExcerptBytes = 2000
+
+// waitForNginXConfReady polls instanceID's pod for confReadinessTimeout,
+// failing with an excerpt of the nginx container's log the moment it sees
+// CrashLoopBackOff, since that's the surest sign the config just rolled out
+// is the reason the container won't start. It returns nil once the pod
+// reaches Running or the poll window elapses without a crash loop, on the
+// assumption that a healthy config just needs more time to come up. It
+// returns early if ctx is canceled or hits its deadline first.
+func waitForNginXConfReady(ctx context.Context, client kubernetes.Interface, podLister v1listers.PodLister, namespace, instanceID string) error {
+	deadline := time.Now().Add(confReadinessTimeout)
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for nginx config in pod for instance %s to become ready: %v", instanceID, ctx.Err())
+		default:
+		}
+
+		pod, err := findNginXPod(client, podLister, namespace, instanceID)
+		if err == nil {
+			for _, cs := range pod.Status.ContainerStatuses {
+				if cs.State.Waiting != nil && cs.State.Waiting.Reason == "CrashLoopBackOff" {
+					return fmt.Errorf("nginx container in pod %s/%s is crash-looping, likely due to an invalid nginxConf: %s",
+						namespace, pod.Name, nginxContainerLogExcerpt(client, namespace, pod.Name))
+				}
+			}
+			if pod.Status.Phase == v1.PodRunning {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for nginx config in pod for instance %s to become ready: %v", instanceID, ctx.Err())
+		case <-time.After(confReadinessPollInterval):
+		}
+	}
+}
+
+// findNginXPod resolves instanceID's pod directly against the Pods API,
+// rather than through its Service's Endpoints, since a crash-looping pod
+// never becomes a ready Endpoint.
+func findNginXPod(client kubernetes.Interface, podLister v1listers.PodLister, namespace, instanceID string) (*v1.Pod, error) {
+	pods, err := kube.ListInstancePods(client, podLister, namespace, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	for i, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		return &pods[i], nil
+	}
+	return nil, fmt.Errorf("no running nginx pod found for instance %s", instanceID)
+}
+
+// defaultReadinessTimeout bounds how long CreateServiceInstance waits, in
+// synchronous mode, for a freshly created instance to start serving traffic
+// before reporting failure. Options.ReadinessTimeout overrides it.
+const defaultReadinessTimeout = 60 * time.Second
+
+const availabilityPollInterval = 2 * time.Second
+
+// terminalPodFailure inspects pod's container statuses for a waiting reason
+// that will never resolve on its own, so waitForNginXAvailable can fail fast
+// instead of waiting out the full timeout.
+func terminalPodFailure(client kubernetes.Interface, namespace string, pod *v1.Pod) error {
+	if err := gitCloneFailure(pod); err != nil {
+		return err
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting == nil {
+			continue
+		}
+		switch cs.State.Waiting.Reason {
+		case "ImagePullBackOff", "ErrImagePull":
+			return fmt.Errorf("nginx container in pod %s/%s cannot pull its image: %s", namespace, pod.Name, cs.State.Waiting.Message)
+		case "CrashLoopBackOff":
+			return fmt.Errorf("nginx container in pod %s/%s is crash-looping: %s",
+				namespace, pod.Name, nginxContainerLogExcerpt(client, namespace, pod.Name))
+		}
+	}
+	return nil
+}
+
+// waitForNginXAvailable polls instanceID's pod for timeout, failing fast on
+// a terminalPodFailure and succeeding as soon as the pod reaches the Running
+// phase with its Ready condition true, so CreateServiceInstance doesn't
+// report success before the instance can actually serve traffic. It returns
+// early if ctx is canceled or hits its deadline first.
+func waitForNginXAvailable(ctx context.Context, client kubernetes.Interface, podLister v1listers.PodLister, namespace, instanceID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for nginx instance %s to become available: %v", instanceID, ctx.Err())
+		default:
+		}
+
+		pod, err := findNginXPod(client, podLister, namespace, instanceID)
+		if err == nil {
+			if err := terminalPodFailure(client, namespace, pod); err != nil {
+				return err
+			}
+			if pod.Status.Phase == v1.PodRunning && podReady(pod) {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("nginx instance %s did not become ready within %s", instanceID, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for nginx instance %s to become available: %v", instanceID, ctx.Err())
+		case <-time.After(availabilityPollInterval):
+		}
+	}
+}
+
+// nginxContainerLogExcerpt best-effort fetches the tail of the nginx
+// container's previous run for inclusion in a crash-loop error. A failure
+// reading the log is folded into the excerpt text rather than propagated,
+// since the crash loop itself is the more important error to surface.
+func nginxContainerLogExcerpt(client kubernetes.Interface, namespace, podName string) string {
+	stream, err := client.Core().Pods(namespace).GetLogs(podName, &v1.PodLogOptions{Container: "nginx", Previous: true}).Stream()
+	if err != nil {
+		return fmt.Sprintf("(failed to read container log: %v)", err)
+	}
+	defer stream.Close()
+
+	log, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return fmt.Sprintf("(failed to read container log: %v)", err)
+	}
+	if len(log) > logExcerptBytes {
+		log = log[len(log)-logExcerptBytes:]
+	}
+	return string(log)
+}
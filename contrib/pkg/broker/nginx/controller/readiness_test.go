@@ -0,0 +1,61 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWaitForNginXConfReadyAbortsPromptlyWhenContextIsCanceled(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := waitForNginXConfReady(ctx, client, nil, "default", "nginx-test")
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected an error from a canceled context")
+	}
+	if elapsed >= confReadinessPollInterval {
+		t.Errorf("waitForNginXConfReady took %s, want it to abort promptly instead of waiting out a poll interval", elapsed)
+	}
+}
+
+func TestWaitForNginXAvailableAbortsPromptlyWhenContextIsCanceled(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := waitForNginXAvailable(ctx, client, nil, "default", "nginx-test", defaultReadinessTimeout)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected an error from a canceled context")
+	}
+	if elapsed >= availabilityPollInterval {
+		t.Errorf("waitForNginXAvailable took %s, want it to abort promptly instead of waiting out a poll interval", elapsed)
+	}
+}
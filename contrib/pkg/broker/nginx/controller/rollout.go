@@ -0,0 +1,92 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	v1listers "k8s.io/client-go/listers/core/v1"
+)
+
+// phaseCreatingResources and phaseReady are the fixed rollout phases; the
+// in-progress and failed phases are formatted with the detail that makes
+// them useful, so they're built by deriveNginXPhase rather than declared
+// here.
+const (
+	phaseCreatingResources = "creating resources"
+	phaseReady             = "ready"
+)
+
+// failedPhasePrefix marks a phase string as terminal-failed, so
+// GetServiceInstanceLastOperation can recognize one it cached earlier
+// without re-deriving it.
+const failedPhasePrefix = "failed ("
+
+// deriveNginXPhase reads instance's Deployment status and pod conditions to
+// produce a kubectl-rollout-style phase: phaseCreatingResources before
+// the Deployment controller has observed its latest spec, "rolling out
+// (n/m available)" while replicas catch up to it, phaseReady once every
+// desired replica is available, and a failedPhasePrefix-prefixed phase the
+// moment a pod hits a terminalPodFailure. The returned state mirrors the
+// phase: StateInProgress for the first two, StateSucceeded for the third,
+// StateFailed for the last.
+func deriveNginXPhase(client kubernetes.Interface, podLister v1listers.PodLister, namespace string, instance *nginxServiceInstance) (string, string) {
+	deployment, err := client.Apps().Deployments(namespace).Get(instance.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return brokerapi.StateInProgress, phaseCreatingResources
+	}
+
+	if pod, err := findNginXPod(client, podLister, namespace, instance.ID); err == nil {
+		if failure := terminalPodFailure(client, namespace, pod); failure != nil {
+			return brokerapi.StateFailed, fmt.Sprintf("%s%v)", failedPhasePrefix, failure)
+		}
+	}
+
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return brokerapi.StateInProgress, phaseCreatingResources
+	}
+
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	if deployment.Status.UpdatedReplicas < desired || deployment.Status.AvailableReplicas < desired {
+		return brokerapi.StateInProgress, fmt.Sprintf("rolling out (%d/%d available)", deployment.Status.AvailableReplicas, desired)
+	}
+	return brokerapi.StateSucceeded, phaseReady
+}
+
+// phaseIsTerminal reports whether phase is one GetServiceInstanceLastOperation
+// can answer straight from the instance record instead of re-deriving it,
+// since neither a ready nor a failed rollout reverses itself on its own.
+func phaseIsTerminal(phase string) bool {
+	return phase == phaseReady || strings.HasPrefix(phase, failedPhasePrefix)
+}
+
+// phaseState maps a terminal phase back to the last-operation state it was
+// derived from.
+func phaseState(phase string) string {
+	if phase == phaseReady {
+		return brokerapi.StateSucceeded
+	}
+	return brokerapi.StateFailed
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	appsv1beta1 "k8s.io/client-go/pkg/apis/apps/v1beta1"
+
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func newRolloutTestDeployment(generation, observedGeneration int64, replicas, updatedReplicas, availableReplicas int32) *appsv1beta1.Deployment {
+	return &appsv1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "nginx-test",
+			Namespace:  "default",
+			Generation: generation,
+			Labels:     map[string]string{"instanceID": "test"},
+		},
+		Spec: appsv1beta1.DeploymentSpec{
+			Replicas: &replicas,
+		},
+		Status: appsv1beta1.DeploymentStatus{
+			ObservedGeneration: observedGeneration,
+			UpdatedReplicas:    updatedReplicas,
+			AvailableReplicas:  availableReplicas,
+		},
+	}
+}
+
+func TestDeriveNginXPhaseMissingDeploymentIsCreatingResources(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	instance := &nginxServiceInstance{ID: "test", Namespace: "default", DeploymentName: "nginx-test"}
+
+	state, phase := deriveNginXPhase(client, nil, "default", instance)
+	if state != brokerapi.StateInProgress || phase != phaseCreatingResources {
+		t.Errorf("deriveNginXPhase = (%v, %q), want (%v, %q)", state, phase, brokerapi.StateInProgress, phaseCreatingResources)
+	}
+}
+
+func TestDeriveNginXPhaseUnobservedSpecIsCreatingResources(t *testing.T) {
+	deployment := newRolloutTestDeployment(2, 1, 3, 0, 0)
+	client := fake.NewSimpleClientset(deployment)
+	instance := &nginxServiceInstance{ID: "test", Namespace: "default", DeploymentName: "nginx-test"}
+
+	state, phase := deriveNginXPhase(client, nil, "default", instance)
+	if state != brokerapi.StateInProgress || phase != phaseCreatingResources {
+		t.Errorf("deriveNginXPhase = (%v, %q), want (%v, %q)", state, phase, brokerapi.StateInProgress, phaseCreatingResources)
+	}
+}
+
+func TestDeriveNginXPhaseMidRolloutReportsAvailableCount(t *testing.T) {
+	deployment := newRolloutTestDeployment(1, 1, 3, 3, 2)
+	client := fake.NewSimpleClientset(deployment)
+	instance := &nginxServiceInstance{ID: "test", Namespace: "default", DeploymentName: "nginx-test"}
+
+	state, phase := deriveNginXPhase(client, nil, "default", instance)
+	if state != brokerapi.StateInProgress || phase != "rolling out (2/3 available)" {
+		t.Errorf("deriveNginXPhase = (%v, %q), want (%v, %q)", state, phase, brokerapi.StateInProgress, "rolling out (2/3 available)")
+	}
+}
+
+func TestDeriveNginXPhaseAllAvailableIsReady(t *testing.T) {
+	deployment := newRolloutTestDeployment(1, 1, 3, 3, 3)
+	client := fake.NewSimpleClientset(deployment)
+	instance := &nginxServiceInstance{ID: "test", Namespace: "default", DeploymentName: "nginx-test"}
+
+	state, phase := deriveNginXPhase(client, nil, "default", instance)
+	if state != brokerapi.StateSucceeded || phase != phaseReady {
+		t.Errorf("deriveNginXPhase = (%v, %q), want (%v, %q)", state, phase, brokerapi.StateSucceeded, phaseReady)
+	}
+}
+
+func TestDeriveNginXPhaseTerminalPodFailureIsFailed(t *testing.T) {
+	deployment := newRolloutTestDeployment(1, 1, 1, 0, 0)
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx-test-abcde",
+			Namespace: "default",
+			Labels:    map[string]string{"instanceID": "test"},
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodPending,
+			ContainerStatuses: []v1.ContainerStatus{
+				{
+					Name: nginxContainerName,
+					State: v1.ContainerState{
+						Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "image not found"},
+					},
+				},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(deployment, pod)
+	instance := &nginxServiceInstance{ID: "test", Namespace: "default", DeploymentName: "nginx-test"}
+
+	state, phase := deriveNginXPhase(client, nil, "default", instance)
+	if state != brokerapi.StateFailed {
+		t.Errorf("state = %v, want %v", state, brokerapi.StateFailed)
+	}
+	if !phaseIsTerminal(phase) || phaseState(phase) != brokerapi.StateFailed {
+		t.Errorf("phase %q does not round-trip as a terminal failure", phase)
+	}
+	if phase == phaseCreatingResources || phase == phaseReady {
+		t.Errorf("phase = %q, want a failure detail", phase)
+	}
+}
+
+func TestPhaseIsTerminal(t *testing.T) {
+	if phaseIsTerminal(phaseCreatingResources) || phaseIsTerminal("rolling out (1/2 available)") {
+		t.Error("an in-progress phase must not be reported as terminal")
+	}
+	if !phaseIsTerminal(phaseReady) {
+		t.Error("phaseReady must be reported as terminal")
+	}
+	if !phaseIsTerminal(failedPhasePrefix + "induced failure)") {
+		t.Error("a failed phase must be reported as terminal")
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// fakeRouteClient is an in-memory kube.RouteClient, used in tests in place
+// of a real OpenShift cluster.
+type fakeRouteClient struct {
+	routes map[string]*unstructured.Unstructured
+}
+
+func newFakeRouteClient() *fakeRouteClient {
+	return &fakeRouteClient{routes: make(map[string]*unstructured.Unstructured)}
+}
+
+func (f *fakeRouteClient) Create(namespace string, route *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	f.routes[namespace+"/"+route.GetName()] = route
+	return route, nil
+}
+
+func (f *fakeRouteClient) List(namespace string, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{}
+	for _, route := range f.routes {
+		if route.GetNamespace() == namespace {
+			list.Items = append(list.Items, *route)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeRouteClient) Delete(namespace, name string) error {
+	key := namespace + "/" + name
+	if _, ok := f.routes[key]; !ok {
+		return fmt.Errorf("route %s not found", key)
+	}
+	delete(f.routes, key)
+	return nil
+}
+
+func (f *fakeRouteClient) DeleteCollection(namespace string, opts metav1.ListOptions) error {
+	for key, route := range f.routes {
+		if route.GetNamespace() == namespace {
+			delete(f.routes, key)
+		}
+	}
+	return nil
+}
+
+func TestDoNginXProvisionCreatesRouteOnOpenShift(t *testing.T) {
+	client := newTestClientset()
+	routeClient := newFakeRouteClient()
+	rollback := &kube.RollbackTracker{}
+	req := &brokerapi.CreateServiceInstanceRequest{
+		PlanID:     planID,
+		Parameters: map[string]interface{}{"ingressHost": "nginx.example.com"},
+	}
+
+	instance, err := doNginXProvision(context.Background(), client, "test", req, defaultMaxContentSize, defaultNginxImage, "", defaultNamespace, defaultGitImage, defaultLogSidecarImage, defaultServiceAccount, false, nil, 1, time.Millisecond, nil, kube.PlatformOpenShift, routeClient, nil, rollback)
+	if err != nil {
+		t.Fatalf("doNginXProvision: %v", err)
+	}
+
+	if instance.RouteName == "" {
+		t.Fatal("expected instance.RouteName to be set")
+	}
+	if instance.IngressName != "" {
+		t.Errorf("instance.IngressName = %q, want empty on OpenShift", instance.IngressName)
+	}
+	if instance.IngressHost != "nginx.example.com" {
+		t.Errorf("instance.IngressHost = %q, want nginx.example.com", instance.IngressHost)
+	}
+
+	list, err := routeClient.List(instance.Namespace, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("len(list.Items) = %d, want 1", len(list.Items))
+	}
+}
+
+// failingRouteClient is a kube.RouteClient whose Create always fails,
+// simulating the Route API rejecting the object.
+type failingRouteClient struct {
+	*fakeRouteClient
+}
+
+func (f *failingRouteClient) Create(namespace string, route *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return nil, fmt.Errorf("induced failure")
+}
+
+func TestDoNginXProvisionFailsWhenRouteCreateFails(t *testing.T) {
+	client := newTestClientset()
+	routeClient := &failingRouteClient{newFakeRouteClient()}
+	rollback := &kube.RollbackTracker{}
+	req := &brokerapi.CreateServiceInstanceRequest{
+		PlanID:     planID,
+		Parameters: map[string]interface{}{"ingressHost": "nginx.example.com"},
+	}
+
+	if _, err := doNginXProvision(context.Background(), client, "test", req, defaultMaxContentSize, defaultNginxImage, "", defaultNamespace, defaultGitImage, defaultLogSidecarImage, defaultServiceAccount, false, nil, 1, time.Millisecond, nil, kube.PlatformOpenShift, routeClient, nil, rollback); err == nil {
+		t.Fatal("expected doNginXProvision to fail")
+	}
+}
+
+func TestNginxResourceKindsIncludesRoutesOnOpenShift(t *testing.T) {
+	instance := &nginxServiceInstance{ID: "test", Namespace: "default"}
+	client := newTestClientset()
+	routeClient := newFakeRouteClient()
+	route := kube.NewRoute("r", "default", "nginx.example.com", "svc", nginxPort, map[string]string{"instanceID": instance.ID, "serviceID": serviceID}, nil)
+	if _, err := routeClient.Create("default", route); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	present, err := doNginXDeprovision(context.Background(), client, routeClient, instance, false)
+	if err != nil {
+		t.Fatalf("doNginXDeprovision: %v", err)
+	}
+
+	found := false
+	for _, kind := range present {
+		if kind == "routes" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("present = %v, want it to include routes", present)
+	}
+
+	list, err := routeClient.List("default", metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("%d routes remain after deprovision", len(list.Items))
+	}
+}
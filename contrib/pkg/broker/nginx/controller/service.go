@@ -0,0 +1,115 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func serviceName(instanceID string) string {
+	return names.InstanceResourceName(brokerName, instanceID, "")
+}
+
+// createNginXService creates the Service fronting an instance's Deployment,
+// so binding and clients can address the instance by a stable DNS name
+// rather than a pod IP that changes across restarts. An https plan
+// instance also gets a 443 port for its TLS-terminating server block, and
+// an instance provisioned with expose: nodeport gets a NodePort Service
+// instead of the default ClusterIP one. assignedNodePort is the node port
+// the API server allocated, which may differ from a requested one of 0,
+// and is 0 when the instance isn't exposed via NodePort.
+func createNginXService(client kubernetes.Interface, namespace, instanceID string, port int32, https bool, expose exposeParams, labels, annotations map[string]string) (name string, assignedNodePort int32, err error) {
+	name = serviceName(instanceID)
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: v1.ServiceSpec{
+			Selector: map[string]string{kube.InstanceLabelKey(): instanceID},
+			Ports: []v1.ServicePort{
+				{
+					Name:       "http",
+					Port:       port,
+					TargetPort: intstr.FromInt(int(port)),
+				},
+			},
+		},
+	}
+	setServiceHTTPSPort(svc, https)
+	setServiceNodePort(svc, expose.Exposed, expose.NodePort)
+	created, err := client.Core().Services(namespace).Create(svc)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create nginx service: %v", err)
+	}
+	return name, nodePortOf(created), nil
+}
+
+// setServiceHTTPSPort adds or removes svc's 443 port, so an
+// UpdateServiceInstance call that switches an instance into or out of the
+// https plan can re-derive the same port list createNginXService builds at
+// provision time.
+func setServiceHTTPSPort(svc *v1.Service, https bool) {
+	var ports []v1.ServicePort
+	for _, p := range svc.Spec.Ports {
+		if p.Name != "https" {
+			ports = append(ports, p)
+		}
+	}
+	if https {
+		ports = append(ports, v1.ServicePort{
+			Name:       "https",
+			Port:       httpsPort,
+			TargetPort: intstr.FromInt(httpsPort),
+		})
+	}
+	svc.Spec.Ports = ports
+}
+
+// setServiceHTTPPort re-points svc's "http" port and targetPort at port, so
+// an UpdateServiceInstance call that changes the port parameter can re-derive
+// the same Service createNginXService builds at provision time.
+func setServiceHTTPPort(svc *v1.Service, port int32) {
+	for i := range svc.Spec.Ports {
+		if svc.Spec.Ports[i].Name == "http" {
+			svc.Spec.Ports[i].Port = port
+			svc.Spec.Ports[i].TargetPort = intstr.FromInt(int(port))
+		}
+	}
+}
+
+// nginxServiceURL is the cluster-internal DNS address of an instance's
+// Service.
+func nginxServiceURL(namespace, serviceName string, port int32) string {
+	return fmt.Sprintf("http://%s.%s.svc:%d", serviceName, namespace, port)
+}
+
+// nginxServiceHTTPSURL is the cluster-internal HTTPS address of an https
+// plan instance's Service.
+func nginxServiceHTTPSURL(namespace, serviceName string) string {
+	return fmt.Sprintf("https://%s.%s.svc:%d", serviceName, namespace, httpsPort)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/names"
+
+	"k8s.io/client-go/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// httpsPlanID identifies the plan under which an instance terminates TLS
+// with a generated self-signed certificate, in addition to serving plain
+// HTTP.
+const httpsPlanID = "b2b2b2b2-2b2b-4b2b-2b2b-2b2b2b2b2b2b"
+
+const httpsPort = 443
+
+const (
+	tlsVolumeName = "tls"
+	tlsMountPath  = "/etc/nginx/tls"
+
+	tlsConfVolumeName = "tls-conf"
+	tlsConfMountPath  = "/etc/nginx/conf.d/tls.conf"
+	tlsConfKey        = "tls.conf"
+)
+
+func tlsSecretName(instanceID string) string {
+	return names.InstanceResourceName(brokerName, instanceID, "tls")
+}
+
+func tlsConfConfigMapName(instanceID string) string {
+	return names.InstanceResourceName(brokerName, instanceID, "tls-conf")
+}
+
+// tlsDNSNames lists the DNS names an https plan instance's certificate
+// should be valid for: its Service's cluster DNS names, and its
+// ingressHost, when it has one.
+func tlsDNSNames(namespace, svcName, ingressHost string) []string {
+	names := []string{
+		svcName,
+		fmt.Sprintf("%s.%s", svcName, namespace),
+		fmt.Sprintf("%s.%s.svc", svcName, namespace),
+	}
+	if ingressHost != "" {
+		names = append(names, ingressHost)
+	}
+	return names
+}
+
+// createTLSSecret generates a self-signed certificate valid for dnsNames
+// and stores it as instanceID's TLS secret.
+func createTLSSecret(client kubernetes.Interface, namespace, instanceID string, dnsNames []string, labels, annotations map[string]string) (name string, cert *kube.SelfSignedCert, err error) {
+	cert, err = kube.NewSelfSignedCert(dnsNames[0], dnsNames)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate a self-signed certificate for nginx instance: %v", err)
+	}
+
+	name = tlsSecretName(instanceID)
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Type: v1.SecretTypeTLS,
+		Data: map[string][]byte{
+			v1.TLSCertKey:       cert.CertPEM,
+			v1.TLSPrivateKeyKey: cert.KeyPEM,
+		},
+	}
+	if _, err := client.Core().Secrets(namespace).Create(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to create nginx tls secret: %v", err)
+	}
+	return name, cert, nil
+}
+
+// tlsServerConf renders the nginx server block that terminates TLS with the
+// certificate attachTLS mounts at tlsMountPath. It's generated, not user
+// supplied, so it lives in its own ConfigMap and file rather than sharing
+// nginxConf's default.conf.
+func tlsServerConf() string {
+	return fmt.Sprintf(`server {
+    listen %d ssl;
+    ssl_certificate %s/%s;
+    ssl_certificate_key %s/%s;
+
+    location / {
+        root  /usr/share/nginx/html;
+        index index.html;
+    }
+}
+`, httpsPort, tlsMountPath, v1.TLSCertKey, tlsMountPath, v1.TLSPrivateKeyKey)
+}
+
+// createTLSConfConfigMap stores the generated TLS server block as
+// instanceID's tls conf ConfigMap.
+func createTLSConfConfigMap(client kubernetes.Interface, namespace, instanceID string, labels, annotations map[string]string) (string, error) {
+	name := tlsConfConfigMapName(instanceID)
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Data: map[string]string{tlsConfKey: tlsServerConf()},
+	}
+	if _, err := client.Core().ConfigMaps(namespace).Create(cm); err != nil {
+		return "", fmt.Errorf("failed to create nginx tls conf config map: %v", err)
+	}
+	return name, nil
+}
+
+// attachTLS mounts an instance's TLS secret and its generated TLS server
+// block ConfigMap into the pod.
+func attachTLS(spec *v1.PodSpec, secretName, confConfigMapName string) {
+	spec.Volumes = append(spec.Volumes,
+		v1.Volume{
+			Name: tlsVolumeName,
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{SecretName: secretName},
+			},
+		},
+		v1.Volume{
+			Name: tlsConfVolumeName,
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{Name: confConfigMapName},
+				},
+			},
+		},
+	)
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts,
+			v1.VolumeMount{Name: tlsVolumeName, MountPath: tlsMountPath, ReadOnly: true},
+			v1.VolumeMount{Name: tlsConfVolumeName, MountPath: tlsConfMountPath, SubPath: tlsConfKey},
+		)
+	}
+}
@@ -0,0 +1,38 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTLSDNSNamesWithoutIngressHost(t *testing.T) {
+	names := tlsDNSNames("default", "nginx-test", "")
+	want := []string{"nginx-test", "nginx-test.default", "nginx-test.default.svc"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("tlsDNSNames = %v, want %v", names, want)
+	}
+}
+
+func TestTLSDNSNamesWithIngressHost(t *testing.T) {
+	names := tlsDNSNames("default", "nginx-test", "test.example.com")
+	want := []string{"nginx-test", "nginx-test.default", "nginx-test.default.svc", "test.example.com"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("tlsDNSNames = %v, want %v", names, want)
+	}
+}
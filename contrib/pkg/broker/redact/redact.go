@@ -0,0 +1,67 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package redact provides logging-only wrapper types for values that must
+// never appear verbatim in a log line -- most importantly the credential
+// maps handed back from Bind. It exists purely for call sites that format
+// a value with %v/%s/%+v or json.Marshal it for a log message; the actual
+// OSB response bodies sent to platforms must keep using brokerapi.Credential
+// directly, never a redact type, or the platform would receive "***"
+// instead of the real secret.
+package redact
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Credential wraps a credential-shaped map so that formatting it -- with
+// fmt's %v/%s/%+v verbs or json.Marshal -- never prints a value, only the
+// key names, so a log line can still show which fields a credential set
+// without leaking what they were set to.
+type Credential map[string]interface{}
+
+// String implements fmt.Stringer.
+func (c Credential) String() string {
+	if c == nil {
+		return "redact.Credential(nil)"
+	}
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := "map["
+	for i, k := range keys {
+		if i > 0 {
+			out += " "
+		}
+		out += k + ":***"
+	}
+	out += "]"
+	return out
+}
+
+// MarshalJSON implements json.Marshaler, so a redact.Credential accidentally
+// passed to a JSON-based log encoder is redacted the same way as one
+// formatted with fmt.
+func (c Credential) MarshalJSON() ([]byte, error) {
+	redacted := make(map[string]string, len(c))
+	for k := range c {
+		redacted[k] = "***"
+	}
+	return json.Marshal(redacted)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package reqlog attaches a per-request correlation ID and a small set of
+// key/value fields (instanceID, bindingID, serviceID, operation, ...) to
+// every log line an OSB request emits, so its sub-steps can be picked out
+// of a shared broker log by grepping for one ID. It logs through glog, so
+// verbosity is still controlled the usual way with -v and -logtostderr.
+package reqlog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/satori/go.uuid"
+)
+
+type contextKey struct{}
+
+// Logger emits log lines prefixed with a correlation ID and any fields
+// attached with With. The zero value, which FromContext falls back to, logs
+// with no fields and no correlation ID; New constructs one that has an ID.
+type Logger struct {
+	fields []string // rendered "key=value" pairs, correlation_id first
+}
+
+// package-level indirection so tests can capture emitted lines instead of
+// asserting against glog's own output.
+var (
+	infoSink    = glog.Info
+	warningSink = glog.Warning
+	errorSink   = glog.Error
+)
+
+// New creates a Logger for a new request, generating a fresh correlation
+// ID. fields are an optional, alternating list of key/value pairs, e.g.
+// New("operation", "provision").
+func New(fields ...string) *Logger {
+	return (&Logger{fields: []string{"correlation_id", uuid.NewV4().String()}}).With(fields...)
+}
+
+// With returns a copy of l with additional key/value fields appended, for
+// attaching details -- such as an instanceID parsed from the request path
+// -- that aren't known until partway through handling the request.
+func (l *Logger) With(fields ...string) *Logger {
+	next := &Logger{fields: append(append([]string{}, l.fields...), fields...)}
+	return next
+}
+
+// CorrelationID returns the correlation ID New generated for l, or the
+// empty string for a Logger that was never constructed with New (such as
+// FromContext's fallback), so a caller stamping a created resource can omit
+// the annotation rather than write a meaningless empty one.
+func (l *Logger) CorrelationID() string {
+	for i := 0; i+1 < len(l.fields); i += 2 {
+		if l.fields[i] == "correlation_id" {
+			return l.fields[i+1]
+		}
+	}
+	return ""
+}
+
+func (l *Logger) prefix() string {
+	parts := make([]string, 0, len(l.fields)/2)
+	for i := 0; i+1 < len(l.fields); i += 2 {
+		parts = append(parts, l.fields[i]+"="+l.fields[i+1])
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// Infof logs a formatted informational message with l's fields prefixed.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	infoSink(l.prefix() + " " + fmt.Sprintf(format, args...))
+}
+
+// Warningf logs a formatted warning message with l's fields prefixed.
+func (l *Logger) Warningf(format string, args ...interface{}) {
+	warningSink(l.prefix() + " " + fmt.Sprintf(format, args...))
+}
+
+// Errorf logs a formatted error message with l's fields prefixed.
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	errorSink(l.prefix() + " " + fmt.Sprintf(format, args...))
+}
+
+// NewContext returns a copy of ctx carrying l, retrievable with FromContext.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the Logger attached to ctx by NewContext, or a fresh
+// one with no correlation ID if none was attached.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return &Logger{}
+}
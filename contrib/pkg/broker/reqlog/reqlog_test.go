@@ -0,0 +1,108 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reqlog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// captureSinks swaps infoSink/errorSink so lines can be asserted on instead
+// of going to glog's own output, restoring them when the caller's test
+// returns.
+func captureSinks() (lines *[]string, restore func()) {
+	var captured []string
+	origInfo, origWarning, origError := infoSink, warningSink, errorSink
+	infoSink = func(args ...interface{}) { captured = append(captured, fmt.Sprint(args...)) }
+	warningSink = func(args ...interface{}) { captured = append(captured, fmt.Sprint(args...)) }
+	errorSink = func(args ...interface{}) { captured = append(captured, fmt.Sprint(args...)) }
+	return &captured, func() { infoSink, warningSink, errorSink = origInfo, origWarning, origError }
+}
+
+func TestLoggerAttachesCorrelationIDToEveryLine(t *testing.T) {
+	lines, restore := captureSinks()
+	defer restore()
+
+	l := New("operation", "provision")
+	l.Infof("starting")
+	l = l.With("instanceID", "instance-1")
+	l.Infof("created deployment")
+	l.Errorf("failed to create service: %v", "boom")
+
+	if len(*lines) != 3 {
+		t.Fatalf("expected 3 log lines, got %d: %v", len(*lines), *lines)
+	}
+
+	id := correlationID(l)
+	for _, line := range *lines {
+		if !strings.Contains(line, "correlation_id="+id) {
+			t.Errorf("expected line %q to contain correlation_id=%s", line, id)
+		}
+	}
+
+	if !strings.Contains((*lines)[1], "instanceID=instance-1") {
+		t.Errorf("expected field added via With to appear on subsequent lines, got %q", (*lines)[1])
+	}
+	if strings.Contains((*lines)[0], "instanceID=instance-1") {
+		t.Errorf("did not expect a field added via With to appear on lines logged before it, got %q", (*lines)[0])
+	}
+}
+
+func TestWarningfLogsWithLsFieldsPrefixed(t *testing.T) {
+	lines, restore := captureSinks()
+	defer restore()
+
+	New("operation", "provision").Warningf("running long")
+
+	if len(*lines) != 1 || !strings.Contains((*lines)[0], "operation=provision") {
+		t.Errorf("expected a warning line with operation=provision, got %v", *lines)
+	}
+}
+
+func TestFromContextRoundTripsThroughNewContext(t *testing.T) {
+	lines, restore := captureSinks()
+	defer restore()
+
+	l := New()
+	ctx := NewContext(context.Background(), l)
+
+	FromContext(ctx).Infof("hello")
+
+	if len(*lines) != 1 || !strings.Contains((*lines)[0], "correlation_id="+correlationID(l)) {
+		t.Errorf("expected the logger retrieved from context to carry the original correlation ID, got %v", *lines)
+	}
+}
+
+func TestFromContextWithNoLoggerAttachedDoesNotPanic(t *testing.T) {
+	_, restore := captureSinks()
+	defer restore()
+
+	FromContext(context.Background()).Infof("hello")
+}
+
+// correlationID extracts the correlation_id field value New attached, for
+// assertions.
+func correlationID(l *Logger) string {
+	for i := 0; i+1 < len(l.fields); i += 2 {
+		if l.fields[i] == "correlation_id" {
+			return l.fields[i+1]
+		}
+	}
+	return ""
+}
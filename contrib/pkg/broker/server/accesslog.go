@@ -0,0 +1,91 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// AccessLogSampler decides whether one incoming request should produce an
+// access log line, given its method and URL path (never its query
+// string). The default sampler logs everything except the health check
+// endpoints, which are polled far more often than any OSB traffic and add
+// little value logged on every hit.
+type AccessLogSampler func(method, path string) bool
+
+func defaultAccessLogSampler(method, path string) bool {
+	return path != "/healthz" && path != "/readyz"
+}
+
+// accessLogSink is package-level indirection so a test can capture emitted
+// lines instead of asserting against glog's own output.
+var accessLogSink = glog.Info
+
+// accessLogRecorder wraps an http.ResponseWriter to capture the status
+// code and response size written through it, neither of which is
+// otherwise observable once the wrapped handler returns.
+type accessLogRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (r *accessLogRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *accessLogRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.size += n
+	return n, err
+}
+
+// accessLog wraps next with HTTP-level access logging: method, path (the
+// instance/binding IDs baked into it, but never its query string, which is
+// redacted wholesale rather than filtered field by field), the requesting
+// identity, status code, response size, and latency. sampler decides which
+// requests are logged; a nil sampler uses defaultAccessLogSampler. Request
+// bodies are never logged.
+func accessLog(sampler AccessLogSampler, next http.Handler) http.Handler {
+	if sampler == nil {
+		sampler = defaultAccessLogSampler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if !sampler(r.Method, path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &accessLogRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		accessLogSink(fmt.Sprintf(
+			"[method=%s path=%s identity=%s status=%d size=%d latency=%s]",
+			r.Method, path, requestIdentity(r), rec.status, rec.size, time.Since(start).Round(time.Millisecond),
+		))
+	})
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+)
+
+// captureAccessLog swaps accessLogSink so lines can be asserted on instead
+// of going to glog's own output, restoring it when the caller's test
+// returns.
+func captureAccessLog() (lines *[]string, restore func()) {
+	var captured []string
+	orig := accessLogSink
+	accessLogSink = func(args ...interface{}) {
+		var line string
+		for _, a := range args {
+			if s, ok := a.(string); ok {
+				line += s
+			}
+		}
+		captured = append(captured, line)
+	}
+	return &captured, func() { accessLogSink = orig }
+}
+
+func TestAccessLogRecordsMethodPathStatusAndSize(t *testing.T) {
+	lines, restore := captureAccessLog()
+	defer restore()
+
+	handler := createHandler(&Controller{
+		t: t,
+		catalog: func() (*brokerapi.Catalog, error) {
+			return &brokerapi.Catalog{}, nil
+		},
+	}, nil, nil, "", nil, nil)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/v2/catalog", nil)
+	req.SetBasicAuth("platform-x", "unused")
+	handler.ServeHTTP(rr, req)
+
+	if len(*lines) != 1 {
+		t.Fatalf("expected 1 access log line, got %d: %v", len(*lines), *lines)
+	}
+	line := (*lines)[0]
+	for _, want := range []string{"method=GET", "path=/v2/catalog", "identity=platform-x", "status=200"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected access log line to contain %q, got %q", want, line)
+		}
+	}
+}
+
+func TestAccessLogRedactsQueryParameters(t *testing.T) {
+	lines, restore := captureAccessLog()
+	defer restore()
+
+	handler := createHandler(&Controller{
+		t: t,
+		removeServiceInstance: func(id string) (*brokerapi.DeleteServiceInstanceResponse, error) {
+			return &brokerapi.DeleteServiceInstanceResponse{}, nil
+		},
+	}, nil, nil, "", nil, nil)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("DELETE", "/v2/service_instances/instance-1?service_id=secret-service&plan_id=secret-plan", nil)
+	handler.ServeHTTP(rr, req)
+
+	if len(*lines) != 1 {
+		t.Fatalf("expected 1 access log line, got %d: %v", len(*lines), *lines)
+	}
+	if strings.Contains((*lines)[0], "secret") {
+		t.Errorf("expected query parameters to be redacted, got %q", (*lines)[0])
+	}
+	if !strings.Contains((*lines)[0], "path=/v2/service_instances/instance-1") {
+		t.Errorf("expected the instance ID to remain in the logged path, got %q", (*lines)[0])
+	}
+}
+
+func TestAccessLogExcludesHealthChecksByDefault(t *testing.T) {
+	lines, restore := captureAccessLog()
+	defer restore()
+
+	handler := createHandler(&Controller{t: t}, nil, nil, "", nil, nil)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/readyz", nil))
+
+	if len(*lines) != 0 {
+		t.Errorf("expected no access log lines for health checks, got %v", *lines)
+	}
+}
+
+func TestAccessLogHonorsACustomSampler(t *testing.T) {
+	lines, restore := captureAccessLog()
+	defer restore()
+
+	s := newServer(&Controller{
+		t: t,
+		catalog: func() (*brokerapi.Catalog, error) {
+			return &brokerapi.Catalog{}, nil
+		},
+	}, nil, nil, "", nil, nil)
+	s.accessLogSampler = func(method, path string) bool { return false }
+	handler := s.routes()
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v2/catalog", nil))
+
+	if len(*lines) != 0 {
+		t.Errorf("expected a sampler returning false to suppress every line, got %v", *lines)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+	"github.com/kubernetes-incubator/service-catalog/pkg/util"
+)
+
+// adminAuthError is returned when a request to an admin endpoint doesn't
+// carry a valid bearer token.
+type adminAuthError struct{}
+
+func (adminAuthError) Error() string {
+	return "missing or invalid admin bearer token"
+}
+
+// adminAuth wraps next so it is only served to a request bearing s.adminToken
+// as an "Authorization: Bearer <token>" header, compared in constant time.
+// An empty s.adminToken -- the default -- rejects every request, since no
+// caller can present a token that was never configured.
+func (s *server) adminAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "Bearer "
+		auth := r.Header.Get("Authorization")
+		if s.adminToken == "" || !strings.HasPrefix(auth, prefix) ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, prefix)), []byte(s.adminToken)) != 1 {
+			util.WriteErrorResponse(w, http.StatusUnauthorized, adminAuthError{})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// adminState serves a sanitized snapshot of the controller's in-memory
+// state, for debugging a running broker without attaching a debugger. A
+// Controller that doesn't implement controller.StateReporter reports an
+// empty snapshot.
+func (s *server) adminState(w http.ResponseWriter, r *http.Request) {
+	reporter, ok := s.controller.(controller.StateReporter)
+	if !ok {
+		util.WriteResponse(w, http.StatusOK, controller.StateSnapshot{})
+		return
+	}
+	util.WriteResponse(w, http.StatusOK, reporter.SnapshotState())
+}
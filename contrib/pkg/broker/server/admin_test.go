@@ -0,0 +1,112 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+)
+
+// stateReportingController wraps Controller with a SnapshotState
+// implementation, so a test can drive the /admin/state path without a real
+// controller and its instance map.
+type stateReportingController struct {
+	Controller
+
+	snapshotState func() controller.StateSnapshot
+}
+
+var _ controller.StateReporter = &stateReportingController{}
+
+func (c *stateReportingController) SnapshotState() controller.StateSnapshot {
+	return c.snapshotState()
+}
+
+func TestAdminStateRejectsARequestWithNoAdminTokenConfigured(t *testing.T) {
+	handler := createHandler(&Controller{t: t}, nil, nil, "", nil, nil)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin/state", nil)
+	req.Header.Set("Authorization", "Bearer anything")
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Code = %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAdminStateRejectsAMissingOrWrongToken(t *testing.T) {
+	handler := createHandler(&Controller{t: t}, nil, nil, "s3cr3t", nil, nil)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/admin/state", nil),
+		func() *http.Request {
+			r := httptest.NewRequest("GET", "/admin/state", nil)
+			r.Header.Set("Authorization", "Bearer wrong")
+			return r
+		}(),
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Code = %d, want %d", rr.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestAdminStateServesTheSnapshotWithAValidToken(t *testing.T) {
+	handler := createHandler(&stateReportingController{
+		Controller: Controller{t: t},
+		snapshotState: func() controller.StateSnapshot {
+			return controller.StateSnapshot{
+				Instances: []controller.InstanceSnapshot{{ID: "instance-1", Namespace: "default"}},
+				Config:    map[string]string{"skipPVCDelete": "false"},
+			}
+		},
+	}, nil, nil, "s3cr3t", nil, nil)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin/state", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Code = %d, want %d", rr.Code, http.StatusOK)
+	}
+	for _, want := range []string{"instance-1", "default", "skipPVCDelete"} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("expected response body to contain %q, got %q", want, rr.Body.String())
+		}
+	}
+}
+
+func TestAdminStateReportsAnEmptySnapshotWhenControllerHasNoState(t *testing.T) {
+	handler := createHandler(&Controller{t: t}, nil, nil, "s3cr3t", nil, nil)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin/state", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Code = %d, want %d", rr.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+	"github.com/kubernetes-incubator/service-catalog/pkg/util"
+)
+
+// healthz always reports 200: it answers as soon as this process is up and
+// serving, independent of whether its dependencies are reachable. Use
+// readyz to gate traffic on those.
+func (s *server) healthz(w http.ResponseWriter, r *http.Request) {
+	util.WriteResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz runs the controller's readiness checks, when it implements
+// controller.ReadinessChecker, and reports 503 with the failing checks'
+// names and errors if any failed. A Controller that doesn't implement the
+// interface is always ready.
+func (s *server) readyz(w http.ResponseWriter, r *http.Request) {
+	rc, ok := s.controller.(controller.ReadinessChecker)
+	if !ok {
+		util.WriteResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	}
+
+	failures := map[string]string{}
+	for name, err := range rc.CheckReadiness(r.Context()) {
+		failures[name] = err.Error()
+	}
+	if len(failures) > 0 {
+		util.WriteResponse(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":   "not ready",
+			"failures": failures,
+		})
+		return
+	}
+	util.WriteResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
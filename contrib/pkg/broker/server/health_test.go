@@ -0,0 +1,100 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+)
+
+// readinessCheckingController wraps Controller with a CheckReadiness
+// implementation, so a test can drive the /readyz path without a real
+// controller and its dependencies.
+type readinessCheckingController struct {
+	Controller
+
+	checkReadiness func(ctx context.Context) map[string]error
+}
+
+var _ controller.ReadinessChecker = &readinessCheckingController{}
+
+func (c *readinessCheckingController) CheckReadiness(ctx context.Context) map[string]error {
+	return c.checkReadiness(ctx)
+}
+
+func TestHealthzAlwaysReturns200(t *testing.T) {
+	handler := createHandler(&Controller{t: t}, nil, nil, "", nil, nil)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Code = %d, want %d", rr.Code, http.StatusOK)
+	}
+}
+
+func TestReadyzReturns200WhenControllerDoesNotImplementReadinessChecker(t *testing.T) {
+	handler := createHandler(&Controller{t: t}, nil, nil, "", nil, nil)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Code = %d, want %d", rr.Code, http.StatusOK)
+	}
+}
+
+func TestReadyzReturns200WhenAllChecksPass(t *testing.T) {
+	handler := createHandler(&readinessCheckingController{
+		Controller: Controller{t: t},
+		checkReadiness: func(ctx context.Context) map[string]error {
+			return map[string]error{}
+		},
+	}, nil, nil, "", nil, nil)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Code = %d, want %d", rr.Code, http.StatusOK)
+	}
+}
+
+func TestReadyzReturns503WhenACheckFails(t *testing.T) {
+	handler := createHandler(&readinessCheckingController{
+		Controller: Controller{t: t},
+		checkReadiness: func(ctx context.Context) map[string]error {
+			return map[string]error{"kube-api": errors.New("connection refused")}
+		},
+	}, nil, nil, "", nil, nil)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Code = %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+	if !strings.Contains(rr.Body.String(), "kube-api") {
+		t.Errorf("expected response body to mention the failing check, got %q", rr.Body.String())
+	}
+}
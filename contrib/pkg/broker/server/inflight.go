@@ -0,0 +1,108 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/waitutil"
+)
+
+// realClock is the waitutil.Clock every production inFlightOps uses; tests
+// substitute one that can be driven forward without sleeping.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// inFlightOp records one OSB operation currently being served.
+type inFlightOp struct {
+	Operation  string
+	InstanceID string
+	ServiceID  string
+	Start      time.Time
+}
+
+// inFlightOps tracks every OSB operation currently being served, keyed by
+// an opaque token, so the slow-operation watchdog has something to list
+// without adding any bookkeeping to the OSB handlers beyond a start/end
+// pair around the controller call.
+type inFlightOps struct {
+	clock waitutil.Clock
+
+	mu          sync.Mutex
+	next        uint64
+	ops         map[uint64]inFlightOp
+	lastSuccess time.Time
+}
+
+// newInFlightOps creates an inFlightOps. A nil clock uses realClock.
+func newInFlightOps(clock waitutil.Clock) *inFlightOps {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &inFlightOps{clock: clock, ops: make(map[uint64]inFlightOp)}
+}
+
+// start records the beginning of an OSB operation and returns a token to
+// pass to end once it completes.
+func (t *inFlightOps) start(operation, instanceID, serviceID string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.next++
+	token := t.next
+	t.ops[token] = inFlightOp{
+		Operation:  operation,
+		InstanceID: instanceID,
+		ServiceID:  serviceID,
+		Start:      t.clock.Now(),
+	}
+	return token
+}
+
+// end stops tracking the operation identified by token. err is the error
+// the operation finished with, if any; a nil err records this as the
+// current lastSuccess time.
+func (t *inFlightOps) end(token uint64, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.ops, token)
+	if err == nil {
+		t.lastSuccess = t.clock.Now()
+	}
+}
+
+// snapshot returns a copy of every operation currently in flight, safe to
+// inspect after mu is released.
+func (t *inFlightOps) snapshot() []inFlightOp {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ops := make([]inFlightOp, 0, len(t.ops))
+	for _, op := range t.ops {
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// lastSuccessTime returns the clock time at which an operation most
+// recently ended with a nil error, or the zero Time if none has yet.
+func (t *inFlightOps) lastSuccessTime() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lastSuccess
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+)
+
+// instanceCollector is a prometheus.Collector that reads a controller's
+// StateSnapshot fresh on every scrape, rather than being pushed to on
+// every operation like Metrics' instances/bindings gauges. That keeps its
+// per-instance age and binding-count series inherently bounded by however
+// many instances the controller currently tracks, and means a deleted
+// instance simply stops appearing at the next scrape instead of needing
+// its series explicitly deleted.
+type instanceCollector struct {
+	reporter     controller.StateReporter
+	age          *prometheus.Desc
+	bindingCount *prometheus.Desc
+}
+
+// newInstanceCollector creates an instanceCollector reading reporter's
+// state at scrape time.
+func newInstanceCollector(reporter controller.StateReporter) *instanceCollector {
+	labels := []string{"instance_id", "service_id", "phase"}
+	return &instanceCollector{
+		reporter: reporter,
+		age: prometheus.NewDesc(
+			prometheus.BuildFQName("service_catalog_broker", "osb", "instance_age_seconds"),
+			"Age of a service instance in seconds, by instance ID, serviceID, and phase.",
+			labels, nil,
+		),
+		bindingCount: prometheus.NewDesc(
+			prometheus.BuildFQName("service_catalog_broker", "osb", "instance_binding_count"),
+			"Count of live bindings against a service instance, by instance ID, serviceID, and phase.",
+			labels, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *instanceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.age
+	ch <- c.bindingCount
+}
+
+// Collect implements prometheus.Collector.
+func (c *instanceCollector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	for _, instance := range c.reporter.SnapshotState().Instances {
+		var age float64
+		if !instance.CreatedAt.IsZero() {
+			age = now.Sub(instance.CreatedAt).Seconds()
+		}
+		ch <- prometheus.MustNewConstMetric(c.age, prometheus.GaugeValue, age, instance.ID, instance.ServiceID, instance.Phase)
+		ch <- prometheus.MustNewConstMetric(c.bindingCount, prometheus.GaugeValue, float64(instance.BindingCount), instance.ID, instance.ServiceID, instance.Phase)
+	}
+}
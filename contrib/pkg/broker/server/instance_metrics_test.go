@@ -0,0 +1,118 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestInstanceCollectorReportsAgeAndBindingCountPerInstance(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(newInstanceCollector(&stateReportingController{
+		snapshotState: func() controller.StateSnapshot {
+			return controller.StateSnapshot{
+				Instances: []controller.InstanceSnapshot{
+					{ID: "instance-1", ServiceID: "test-service", Phase: "Ready", CreatedAt: time.Now().Add(-90 * time.Second), BindingCount: 2},
+					{ID: "instance-2", ServiceID: "test-service", Phase: "Provisioning", CreatedAt: time.Now(), BindingCount: 0},
+				},
+			}
+		},
+	}))
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned error: %v", err)
+	}
+
+	var sawAge, sawBindingCount int
+	for _, mf := range families {
+		switch mf.GetName() {
+		case "service_catalog_broker_osb_instance_age_seconds":
+			sawAge = len(mf.GetMetric())
+			for _, m := range mf.GetMetric() {
+				if labelValue(m, "instance_id") == "instance-1" && m.GetGauge().GetValue() < 60 {
+					t.Errorf("instance-1 age = %v, want at least 60s", m.GetGauge().GetValue())
+				}
+			}
+		case "service_catalog_broker_osb_instance_binding_count":
+			sawBindingCount = len(mf.GetMetric())
+			for _, m := range mf.GetMetric() {
+				if labelValue(m, "instance_id") == "instance-1" && m.GetGauge().GetValue() != 2 {
+					t.Errorf("instance-1 binding count = %v, want 2", m.GetGauge().GetValue())
+				}
+			}
+		}
+	}
+	if sawAge != 2 {
+		t.Errorf("instance_age_seconds series = %d, want 2", sawAge)
+	}
+	if sawBindingCount != 2 {
+		t.Errorf("instance_binding_count series = %d, want 2", sawBindingCount)
+	}
+}
+
+func TestInstanceCollectorDropsDeletedInstancesOnNextScrape(t *testing.T) {
+	instances := []controller.InstanceSnapshot{{ID: "instance-1", ServiceID: "test-service", Phase: "Ready"}}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(newInstanceCollector(&stateReportingController{
+		snapshotState: func() controller.StateSnapshot {
+			return controller.StateSnapshot{Instances: instances}
+		},
+	}))
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned error: %v", err)
+	}
+	if n := seriesCount(families, "service_catalog_broker_osb_instance_age_seconds"); n != 1 {
+		t.Fatalf("series before deletion = %d, want 1", n)
+	}
+
+	instances = nil
+
+	families, err = reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned error: %v", err)
+	}
+	if n := seriesCount(families, "service_catalog_broker_osb_instance_age_seconds"); n != 0 {
+		t.Errorf("series after deletion = %d, want 0", n)
+	}
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+func seriesCount(families []*dto.MetricFamily, name string) int {
+	for _, mf := range families {
+		if mf.GetName() == name {
+			return len(mf.GetMetric())
+		}
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/pkg"
+)
+
+// Metrics holds the collectors this server uses to instrument every OSB
+// operation it serves: a counter of requests by operation, serviceID, and
+// outcome, a counter of failures by operation, serviceID, and cause (per
+// kube.ClassifyError), a latency histogram by operation and serviceID, and
+// gauges tracking how many instances and bindings are currently live, by
+// serviceID, a counter of slow-operation watchdog sightings by operation
+// and serviceID, and a build_info gauge carrying the running binary's
+// version, git commit, and build date. Its collectors are registered
+// against an injectable prometheus.Registerer, rather than the global
+// default one, so a test can build its own registry, drive some operations
+// through the controller, and scrape back only what it caused.
+type Metrics struct {
+	requestsTotal       *prometheus.CounterVec
+	errorsTotal         *prometheus.CounterVec
+	requestDuration     *prometheus.HistogramVec
+	instances           *prometheus.GaugeVec
+	bindings            *prometheus.GaugeVec
+	slowOperationsTotal *prometheus.CounterVec
+	buildInfo           *prometheus.GaugeVec
+}
+
+// NewMetrics creates a Metrics and registers its collectors against reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "service_catalog_broker",
+			Subsystem: "osb",
+			Name:      "requests_total",
+			Help:      "Count of OSB operations served, by operation, serviceID, and outcome.",
+		}, []string{"operation", "service_id", "outcome"}),
+		errorsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "service_catalog_broker",
+			Subsystem: "osb",
+			Name:      "errors_total",
+			Help:      "Count of OSB operations that failed, by operation, serviceID, and cause.",
+		}, []string{"operation", "service_id", "cause"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "service_catalog_broker",
+			Subsystem: "osb",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of OSB operations served, by operation and serviceID.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "service_id"}),
+		instances: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "service_catalog_broker",
+			Subsystem: "osb",
+			Name:      "instances",
+			Help:      "Count of service instances currently provisioned, by serviceID.",
+		}, []string{"service_id"}),
+		bindings: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "service_catalog_broker",
+			Subsystem: "osb",
+			Name:      "bindings",
+			Help:      "Count of service bindings currently created, by serviceID.",
+		}, []string{"service_id"}),
+		slowOperationsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "service_catalog_broker",
+			Subsystem: "osb",
+			Name:      "slow_operations_total",
+			Help:      "Count of times the slow-operation watchdog found an operation still running past --slow-operation-threshold, by operation and serviceID.",
+		}, []string{"operation", "service_id"}),
+		buildInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "service_catalog_broker",
+			Name:      "build_info",
+			Help:      "Always 1. Carries the running binary's version, git commit, and build date as labels, so they can be joined against other metrics or alerted on across a fleet running mixed builds.",
+		}, []string{"version", "git_commit", "build_date"}),
+	}
+	reg.MustRegister(m.requestsTotal, m.errorsTotal, m.requestDuration, m.instances, m.bindings, m.slowOperationsTotal, m.buildInfo)
+	m.buildInfo.WithLabelValues(pkg.VERSION, pkg.GitCommit, pkg.BuildDate).Set(1)
+	return m
+}
+
+// defaultMetrics is registered against the global default registry, so a
+// production broker's /metrics endpoint reports OSB operations without its
+// main needing to build and thread a Metrics through by hand.
+var defaultMetrics = NewMetrics(prometheus.DefaultRegisterer)
+
+// record observes the outcome and latency of one dispatched OSB operation,
+// identified by operation (e.g. "catalog", "provision", "update",
+// "deprovision", "bind", "unbind", "last_operation") and serviceID.
+func (m *Metrics) record(operation, serviceID string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		m.errorsTotal.WithLabelValues(operation, serviceID, kube.ClassifyError(err)).Inc()
+	}
+	m.requestsTotal.WithLabelValues(operation, serviceID, outcome).Inc()
+	m.requestDuration.WithLabelValues(operation, serviceID).Observe(time.Since(start).Seconds())
+}
+
+func (m *Metrics) instanceProvisioned(serviceID string)   { m.instances.WithLabelValues(serviceID).Inc() }
+func (m *Metrics) instanceDeprovisioned(serviceID string) { m.instances.WithLabelValues(serviceID).Dec() }
+func (m *Metrics) bindingCreated(serviceID string)        { m.bindings.WithLabelValues(serviceID).Inc() }
+func (m *Metrics) bindingRemoved(serviceID string)        { m.bindings.WithLabelValues(serviceID).Dec() }
+
+// slowOperationDetected records one watchdog sighting of an operation still
+// running past --slow-operation-threshold.
+func (m *Metrics) slowOperationDetected(operation, serviceID string) {
+	m.slowOperationsTotal.WithLabelValues(operation, serviceID).Inc()
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func requestCount(m *Metrics, operation, serviceID, outcome string) uint64 {
+	var metric dto.Metric
+	if err := m.requestsTotal.WithLabelValues(operation, serviceID, outcome).Write(&metric); err != nil {
+		panic(err)
+	}
+	return uint64(metric.GetCounter().GetValue())
+}
+
+func errorCount(m *Metrics, operation, serviceID, cause string) uint64 {
+	var metric dto.Metric
+	if err := m.errorsTotal.WithLabelValues(operation, serviceID, cause).Write(&metric); err != nil {
+		panic(err)
+	}
+	return uint64(metric.GetCounter().GetValue())
+}
+
+func gaugeValue(g *prometheus.GaugeVec, serviceID string) float64 {
+	var metric dto.Metric
+	if err := g.WithLabelValues(serviceID).Write(&metric); err != nil {
+		panic(err)
+	}
+	return metric.GetGauge().GetValue()
+}
+
+func TestMetricsRecordsRequestsAndGaugesAcrossAProvisionAndBindFlow(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics := NewMetrics(reg)
+
+	handler := createHandler(&Controller{
+		t: t,
+		createServiceInstance: func(id string, req *brokerapi.CreateServiceInstanceRequest) (*brokerapi.CreateServiceInstanceResponse, error) {
+			return &brokerapi.CreateServiceInstanceResponse{}, nil
+		},
+		bind: func(instanceID, bindingID string, req *brokerapi.BindingRequest) (*brokerapi.CreateServiceBindingResponse, error) {
+			return &brokerapi.CreateServiceBindingResponse{}, nil
+		},
+		unBind: func(instanceID, bindingID string) error {
+			return nil
+		},
+		removeServiceInstance: func(id string) (*brokerapi.DeleteServiceInstanceResponse, error) {
+			return &brokerapi.DeleteServiceInstanceResponse{}, nil
+		},
+	}, nil, metrics, "", nil, nil)
+
+	body := `{"service_id": "test-service"}`
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/v2/service_instances/instance-1", strings.NewReader(body)))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/v2/service_instances/instance-1/service_bindings/binding-1", strings.NewReader(body)))
+
+	if got := requestCount(metrics, "provision", "test-service", "success"); got != 1 {
+		t.Errorf("provision success count = %d, want 1", got)
+	}
+	if got := requestCount(metrics, "bind", "test-service", "success"); got != 1 {
+		t.Errorf("bind success count = %d, want 1", got)
+	}
+	if got := gaugeValue(metrics.instances, "test-service"); got != 1 {
+		t.Errorf("instances gauge = %v, want 1", got)
+	}
+	if got := gaugeValue(metrics.bindings, "test-service"); got != 1 {
+		t.Errorf("bindings gauge = %v, want 1", got)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/v2/service_instances/instance-1/service_bindings/binding-1?service_id=test-service", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/v2/service_instances/instance-1?service_id=test-service", nil))
+
+	if got := gaugeValue(metrics.instances, "test-service"); got != 0 {
+		t.Errorf("instances gauge after deprovision = %v, want 0", got)
+	}
+	if got := gaugeValue(metrics.bindings, "test-service"); got != 0 {
+		t.Errorf("bindings gauge after unbind = %v, want 0", got)
+	}
+}
+
+func TestNewMetricsSetsBuildInfoFromTheRunningBinary(t *testing.T) {
+	origVersion, origCommit, origDate := pkg.VERSION, pkg.GitCommit, pkg.BuildDate
+	pkg.VERSION, pkg.GitCommit, pkg.BuildDate = "v1.2.3", "abc1234", "2017-11-01T00:00:00Z"
+	defer func() { pkg.VERSION, pkg.GitCommit, pkg.BuildDate = origVersion, origCommit, origDate }()
+
+	reg := prometheus.NewRegistry()
+	metrics := NewMetrics(reg)
+
+	var metric dto.Metric
+	if err := metrics.buildInfo.WithLabelValues(pkg.VERSION, pkg.GitCommit, pkg.BuildDate).Write(&metric); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got := metric.GetGauge().GetValue(); got != 1 {
+		t.Errorf("build_info value = %v, want 1", got)
+	}
+}
+
+func TestMetricsRecordsErrorOutcome(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics := NewMetrics(reg)
+
+	handler := createHandler(&Controller{
+		t: t,
+		createServiceInstance: func(id string, req *brokerapi.CreateServiceInstanceRequest) (*brokerapi.CreateServiceInstanceResponse, error) {
+			return nil, errors.New("induced failure")
+		},
+	}, nil, metrics, "", nil, nil)
+
+	body := `{"service_id": "test-service"}`
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/v2/service_instances/instance-1", strings.NewReader(body)))
+
+	if got := requestCount(metrics, "provision", "test-service", "error"); got != 1 {
+		t.Errorf("provision error count = %d, want 1", got)
+	}
+	if got := errorCount(metrics, "provision", "test-service", "internal"); got != 1 {
+		t.Errorf("provision error count for cause %q = %d, want 1", "internal", got)
+	}
+	if got := gaugeValue(metrics.instances, "test-service"); got != 0 {
+		t.Errorf("instances gauge after failed provision = %v, want 0", got)
+	}
+}
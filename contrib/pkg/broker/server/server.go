@@ -23,44 +23,246 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/audit"
 	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/leaderelection"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/reqlog"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/trace"
+	"github.com/kubernetes-incubator/service-catalog/pkg"
 	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
 	"github.com/kubernetes-incubator/service-catalog/pkg/util"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type server struct {
 	controller controller.Controller
+
+	// elector, if non-nil, gates the mutating OSB operations on this
+	// replica currently holding the leader lease. A nil elector means
+	// leader election is disabled and every replica serves every request.
+	elector leaderelection.Elector
+
+	metrics *Metrics
+
+	// adminToken gates the admin endpoints, /admin/state and /admin/status.
+	// An empty adminToken -- the default -- leaves them permanently
+	// unauthenticated and unreachable rather than open.
+	adminToken string
+
+	// auditLog records every OSB operation this server serves, for
+	// compliance. A nil auditLog -- the default -- records nothing.
+	auditLog *audit.Writer
+
+	// tracer starts a root span for every OSB operation this server
+	// serves, letting a controller nest child spans for its kube calls
+	// and wait phases underneath it. A nil tracer -- the default -- makes
+	// tracing a no-op.
+	tracer *trace.Tracer
+
+	// startTime records when this server was created, for the uptime
+	// reported by /admin/status.
+	startTime time.Time
+
+	// inFlight tracks every OSB operation currently being served, so the
+	// slow-operation watchdog has something to inspect. It's never nil.
+	inFlight *inFlightOps
+
+	// accessLogSampler decides which requests get an access log line. A
+	// nil accessLogSampler -- the default -- uses defaultAccessLogSampler.
+	accessLogSampler AccessLogSampler
 }
 
-// CreateHandler creates Broker HTTP handler based on an implementation
-// of a controller.Controller interface.
-func createHandler(c controller.Controller) http.Handler {
-	s := server{
+// newServer builds a server from the same inputs createHandler and Run
+// accept. It's split out from createHandler so Run can also start the
+// slow-operation watchdog against the exact server instance backing the
+// handler it serves, instead of a separate one.
+func newServer(c controller.Controller, elector leaderelection.Elector, metrics *Metrics, adminToken string, auditLog *audit.Writer, tracer *trace.Tracer) *server {
+	if metrics == nil {
+		metrics = defaultMetrics
+	}
+	return &server{
 		controller: c,
+		elector:    elector,
+		metrics:    metrics,
+		adminToken: adminToken,
+		auditLog:   auditLog,
+		tracer:     tracer,
+		startTime:  time.Now(),
+		inFlight:   newInFlightOps(nil),
 	}
+}
 
+// routes builds the router serving s.
+func (s *server) routes() http.Handler {
 	var router = mux.NewRouter()
 
 	router.HandleFunc("/v2/catalog", s.catalog).Methods("GET")
 	router.HandleFunc("/v2/service_instances/{instance_id}/last_operation", s.getServiceInstanceLastOperation).Methods("GET")
 	router.HandleFunc("/v2/service_instances/{instance_id}", s.createServiceInstance).Methods("PUT")
+	router.HandleFunc("/v2/service_instances/{instance_id}", s.updateServiceInstance).Methods("PATCH")
 	router.HandleFunc("/v2/service_instances/{instance_id}", s.removeServiceInstance).Methods("DELETE")
 	router.HandleFunc("/v2/service_instances/{instance_id}/service_bindings/{binding_id}", s.bind).Methods("PUT")
 	router.HandleFunc("/v2/service_instances/{instance_id}/service_bindings/{binding_id}", s.unBind).Methods("DELETE")
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	router.HandleFunc("/healthz", s.healthz).Methods("GET")
+	router.HandleFunc("/readyz", s.readyz).Methods("GET")
+	router.HandleFunc("/version", s.version).Methods("GET")
+	router.HandleFunc("/admin/state", s.adminAuth(s.adminState)).Methods("GET")
+	router.HandleFunc("/admin/status", s.adminAuth(s.adminStatus)).Methods("GET")
+
+	return accessLog(s.accessLogSampler, router)
+}
+
+// CreateHandler creates Broker HTTP handler based on an implementation
+// of a controller.Controller interface. A nil metrics uses defaultMetrics,
+// which a caller outside this package can scrape via the global
+// promhttp.Handler(); tests pass in one built on a private registry
+// instead. adminToken is the bearer token required by the admin endpoints;
+// an empty adminToken leaves them unreachable. A nil auditLog disables
+// audit logging. A nil tracer disables tracing.
+func createHandler(c controller.Controller, elector leaderelection.Elector, metrics *Metrics, adminToken string, auditLog *audit.Writer, tracer *trace.Tracer) http.Handler {
+	return newServer(c, elector, metrics, adminToken, auditLog, tracer).routes()
+}
+
+// notLeaderError is returned to the caller when this replica is not
+// currently the leader and so must not serve a mutating OSB request.
+type notLeaderError struct{}
+
+func (notLeaderError) Error() string {
+	return "this broker replica is not currently the leader; retry the request"
+}
+
+// requireLeader reports whether this replica may serve a mutating OSB
+// request, writing a retryable error response and returning false if not.
+// A nil elector means leader election is disabled, so every replica leads.
+func (s *server) requireLeader(w http.ResponseWriter) bool {
+	if s.elector == nil || s.elector.IsLeader() {
+		return true
+	}
+	w.Header().Set("Retry-After", "1")
+	util.WriteErrorResponse(w, http.StatusServiceUnavailable, notLeaderError{})
+	return false
+}
+
+// requestIdentity returns the requesting identity to record in the audit
+// log, or "" if the request carried none. It never returns a credential,
+// only the HTTP basic auth username, which is the one identity a platform
+// might already put on an OSB request.
+func requestIdentity(r *http.Request) string {
+	if user, _, ok := r.BasicAuth(); ok {
+		return user
+	}
+	return ""
+}
 
-	return router
+// endSpan attaches instanceID, bindingID, serviceID (whichever are
+// non-empty), and the operation's outcome to span before ending it.
+func (s *server) endSpan(span *trace.Span, instanceID, bindingID, serviceID string, err error) {
+	if instanceID != "" {
+		span.SetAttribute("instanceID", instanceID)
+	}
+	if bindingID != "" {
+		span.SetAttribute("bindingID", bindingID)
+	}
+	if serviceID != "" {
+		span.SetAttribute("serviceID", serviceID)
+	}
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	span.SetAttribute("outcome", outcome)
+	span.End()
+}
+
+// recordAudit appends an audit.Record for one dispatched OSB operation to
+// s.auditLog. It never includes request parameters or credentials.
+func (s *server) recordAudit(r *http.Request, operation, instanceID, bindingID, serviceID string, start time.Time, err error) {
+	outcome := "success"
+	cause := ""
+	if err != nil {
+		outcome = "error"
+		cause = kube.ClassifyError(err)
+	}
+	s.auditLog.Record(audit.Record{
+		Time:       time.Now(),
+		Operation:  operation,
+		InstanceID: instanceID,
+		BindingID:  bindingID,
+		ServiceID:  serviceID,
+		Identity:   requestIdentity(r),
+		Outcome:    outcome,
+		Cause:      cause,
+		Latency:    time.Since(start),
+	})
 }
 
 // Run creates the HTTP handler based on an implementation of a
-// controller.Controller interface, and begins to listen on the specified address.
-func Run(ctx context.Context, addr string, c controller.Controller) error {
-	glog.Infof("Starting server on %d\n", addr)
+// controller.Controller interface, and begins to listen on the specified
+// address. elector may be nil, in which case leader election is disabled
+// and this replica serves every request.
+//
+// metricsAddr, when non-empty and different from addr, starts a second
+// listener serving only /metrics off the same collectors, so a caller can
+// keep Prometheus scrapes off the address that also serves OSB traffic.
+// An empty metricsAddr leaves /metrics reachable only on addr, alongside
+// the OSB routes, as before.
+//
+// adminToken is the bearer token required by the admin endpoints,
+// /admin/state and /admin/status; an empty adminToken leaves them
+// unreachable.
+//
+// auditLog, when non-nil, receives a Record of every OSB operation this
+// server serves. A nil auditLog disables audit logging.
+//
+// tracer, when non-nil, starts a root span for every OSB operation this
+// server serves. A nil tracer disables tracing.
+//
+// slowOperationThreshold, when positive, starts a watchdog that logs a
+// warning and increments a metric for any OSB operation still running
+// past that duration. A zero or negative slowOperationThreshold disables
+// the watchdog.
+//
+// stateSummaryInterval, when positive, starts a goroutine that logs a
+// single heartbeat line summarizing this broker's in-memory state at that
+// cadence, for an operator without Prometheus scraping this broker. A
+// zero or negative stateSummaryInterval disables it.
+func Run(ctx context.Context, addr, metricsAddr string, c controller.Controller, elector leaderelection.Elector, adminToken string, auditLog *audit.Writer, tracer *trace.Tracer, slowOperationThreshold, stateSummaryInterval time.Duration) error {
+	glog.Infof("service-catalog broker version=%s gitCommit=%s buildDate=%s\n", pkg.VERSION, pkg.GitCommit, pkg.BuildDate)
+	glog.Infof("Starting server on %s\n", addr)
+	s := newServer(c, elector, nil, adminToken, auditLog, tracer)
+	if slowOperationThreshold > 0 {
+		go s.watchSlowOperations(ctx, slowOperationThreshold)
+	}
+	if stateSummaryInterval > 0 {
+		go s.logStateSummary(ctx, stateSummaryInterval)
+	}
+	if reporter, ok := c.(controller.StateReporter); ok {
+		prometheus.MustRegister(newInstanceCollector(reporter))
+	}
 	srv := http.Server{
 		Addr:    addr,
-		Handler: createHandler(c),
+		Handler: s.routes(),
 	}
+
+	var metricsSrv *http.Server
+	if metricsAddr != "" && metricsAddr != addr {
+		glog.Infof("Starting metrics server on %s\n", metricsAddr)
+		metricsSrv = &http.Server{
+			Addr:    metricsAddr,
+			Handler: promhttp.Handler(),
+		}
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				glog.Errorf("metrics server: %v", err)
+			}
+		}()
+	}
+
 	go func() {
 		<-ctx.Done()
 		c, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -68,17 +270,30 @@ func Run(ctx context.Context, addr string, c controller.Controller) error {
 		if srv.Shutdown(c) != nil {
 			srv.Close()
 		}
+		if metricsSrv != nil {
+			if metricsSrv.Shutdown(c) != nil {
+				metricsSrv.Close()
+			}
+		}
 	}()
 	return srv.ListenAndServe()
 }
 
 func (s *server) catalog(w http.ResponseWriter, r *http.Request) {
-	glog.Infof("Get Service Broker Catalog...")
-
-	if result, err := s.controller.Catalog(); err == nil {
+	reqlog.New("operation", "catalog").Infof("Get Service Broker Catalog...")
+	_, span := s.tracer.Start(r.Context(), "catalog")
+
+	start := time.Now()
+	token := s.inFlight.start("catalog", "", "")
+	result, err := s.controller.Catalog()
+	s.inFlight.end(token, err)
+	s.metrics.record("catalog", "", start, err)
+	s.recordAudit(r, "catalog", "", "", "", start, err)
+	s.endSpan(span, "", "", "", err)
+	if err == nil {
 		util.WriteResponse(w, http.StatusOK, result)
 	} else {
-		util.WriteErrorResponse(w, http.StatusBadRequest, err)
+		util.WriteErrorResponse(w, kube.HTTPStatus(err), err)
 	}
 }
 
@@ -88,25 +303,42 @@ func (s *server) getServiceInstanceLastOperation(w http.ResponseWriter, r *http.
 	serviceID := q.Get("service_id")
 	planID := q.Get("plan_id")
 	operation := q.Get("operation")
-	glog.Infof("GetServiceInstance ... %s\n", instanceID)
-
-	if result, err := s.controller.GetServiceInstanceLastOperation(instanceID, serviceID, planID, operation); err == nil {
+	log := reqlog.New("operation", "last_operation", "instanceID", instanceID, "serviceID", serviceID)
+	log.Infof("GetServiceInstance ...")
+	ctx, span := s.tracer.Start(r.Context(), "last_operation")
+	ctx = reqlog.NewContext(ctx, log)
+
+	start := time.Now()
+	token := s.inFlight.start("last_operation", instanceID, serviceID)
+	result, err := s.controller.GetServiceInstanceLastOperation(ctx, instanceID, serviceID, planID, operation)
+	s.inFlight.end(token, err)
+	s.metrics.record("last_operation", serviceID, start, err)
+	s.recordAudit(r, "last_operation", instanceID, "", serviceID, start, err)
+	s.endSpan(span, instanceID, "", serviceID, err)
+	if err == nil {
 		util.WriteResponse(w, http.StatusOK, result)
 	} else {
-		util.WriteErrorResponse(w, http.StatusBadRequest, err)
+		util.WriteErrorResponse(w, kube.HTTPStatus(err), err)
 	}
 }
 
 func (s *server) createServiceInstance(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["instance_id"]
-	glog.Infof("CreateServiceInstance %s...\n", id)
+	log := reqlog.New("operation", "provision", "instanceID", id)
+	log.Infof("CreateServiceInstance...")
+	ctx, span := s.tracer.Start(r.Context(), "provision")
+
+	if !s.requireLeader(w) {
+		return
+	}
 
 	var req brokerapi.CreateServiceInstanceRequest
 	if err := util.BodyToObject(r, &req); err != nil {
-		glog.Errorf("error unmarshalling: %v", err)
+		log.Errorf("error unmarshalling: %v", err)
 		util.WriteErrorResponse(w, http.StatusBadRequest, err)
 		return
 	}
+	log = log.With("serviceID", req.ServiceID)
 
 	// TODO: Check if parameters are required, if not, this thing below is ok to leave in,
 	// if they are ,they should be checked. Because if no parameters are passed in, this will
@@ -115,10 +347,54 @@ func (s *server) createServiceInstance(w http.ResponseWriter, r *http.Request) {
 		req.Parameters = make(map[string]interface{})
 	}
 
-	if result, err := s.controller.CreateServiceInstance(id, &req); err == nil {
+	start := time.Now()
+	token := s.inFlight.start("provision", id, req.ServiceID)
+	result, err := s.controller.CreateServiceInstance(reqlog.NewContext(ctx, log), id, &req)
+	s.inFlight.end(token, err)
+	s.metrics.record("provision", req.ServiceID, start, err)
+	s.recordAudit(r, "provision", id, "", req.ServiceID, start, err)
+	s.endSpan(span, id, "", req.ServiceID, err)
+	if err == nil {
+		s.metrics.instanceProvisioned(req.ServiceID)
 		util.WriteResponse(w, http.StatusCreated, result)
 	} else {
+		util.WriteErrorResponse(w, kube.HTTPStatus(err), err)
+	}
+}
+
+func (s *server) updateServiceInstance(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["instance_id"]
+	log := reqlog.New("operation", "update", "instanceID", id)
+	log.Infof("UpdateServiceInstance...")
+	ctx, span := s.tracer.Start(r.Context(), "update")
+
+	if !s.requireLeader(w) {
+		return
+	}
+
+	var req brokerapi.UpdateServiceInstanceRequest
+	if err := util.BodyToObject(r, &req); err != nil {
+		log.Errorf("error unmarshalling: %v", err)
 		util.WriteErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	log = log.With("serviceID", req.ServiceID)
+
+	if req.Parameters == nil {
+		req.Parameters = make(map[string]interface{})
+	}
+
+	start := time.Now()
+	token := s.inFlight.start("update", id, req.ServiceID)
+	result, err := s.controller.UpdateServiceInstance(reqlog.NewContext(ctx, log), id, &req)
+	s.inFlight.end(token, err)
+	s.metrics.record("update", req.ServiceID, start, err)
+	s.recordAudit(r, "update", id, "", req.ServiceID, start, err)
+	s.endSpan(span, id, "", req.ServiceID, err)
+	if err == nil {
+		util.WriteResponse(w, http.StatusOK, result)
+	} else {
+		util.WriteErrorResponse(w, kube.HTTPStatus(err), err)
 	}
 }
 
@@ -128,12 +404,27 @@ func (s *server) removeServiceInstance(w http.ResponseWriter, r *http.Request) {
 	serviceID := q.Get("service_id")
 	planID := q.Get("plan_id")
 	acceptsIncomplete := q.Get("accepts_incomplete") == "true"
-	glog.Infof("RemoveServiceInstance %s...\n", instanceID)
+	force := q.Get("force") == "true"
+	log := reqlog.New("operation", "deprovision", "instanceID", instanceID, "serviceID", serviceID)
+	log.Infof("RemoveServiceInstance...")
+	ctx, span := s.tracer.Start(r.Context(), "deprovision")
+
+	if !s.requireLeader(w) {
+		return
+	}
 
-	if result, err := s.controller.RemoveServiceInstance(instanceID, serviceID, planID, acceptsIncomplete); err == nil {
+	start := time.Now()
+	token := s.inFlight.start("deprovision", instanceID, serviceID)
+	result, err := s.controller.RemoveServiceInstance(reqlog.NewContext(ctx, log), instanceID, serviceID, planID, acceptsIncomplete, force)
+	s.inFlight.end(token, err)
+	s.metrics.record("deprovision", serviceID, start, err)
+	s.recordAudit(r, "deprovision", instanceID, "", serviceID, start, err)
+	s.endSpan(span, instanceID, "", serviceID, err)
+	if err == nil {
+		s.metrics.instanceDeprovisioned(serviceID)
 		util.WriteResponse(w, http.StatusOK, result)
 	} else {
-		util.WriteErrorResponse(w, http.StatusBadRequest, err)
+		util.WriteErrorResponse(w, kube.HTTPStatus(err), err)
 	}
 }
 
@@ -141,15 +432,22 @@ func (s *server) bind(w http.ResponseWriter, r *http.Request) {
 	bindingID := mux.Vars(r)["binding_id"]
 	instanceID := mux.Vars(r)["instance_id"]
 
-	glog.Infof("Bind binding_id=%s, instance_id=%s\n", bindingID, instanceID)
+	log := reqlog.New("operation", "bind", "instanceID", instanceID, "bindingID", bindingID)
+	log.Infof("Bind...")
+	ctx, span := s.tracer.Start(r.Context(), "bind")
+
+	if !s.requireLeader(w) {
+		return
+	}
 
 	var req brokerapi.BindingRequest
 
 	if err := util.BodyToObject(r, &req); err != nil {
-		glog.Errorf("Failed to unmarshall request: %v", err)
+		log.Errorf("Failed to unmarshall request: %v", err)
 		util.WriteErrorResponse(w, http.StatusBadRequest, err)
 		return
 	}
+	log = log.With("serviceID", req.ServiceID)
 
 	// TODO: Check if parameters are required, if not, this thing below is ok to leave in,
 	// if they are ,they should be checked. Because if no parameters are passed in, this will
@@ -161,10 +459,18 @@ func (s *server) bind(w http.ResponseWriter, r *http.Request) {
 	// Pass in the instanceId to the template.
 	req.Parameters["instanceId"] = instanceID
 
-	if result, err := s.controller.Bind(instanceID, bindingID, &req); err == nil {
+	start := time.Now()
+	token := s.inFlight.start("bind", instanceID, req.ServiceID)
+	result, err := s.controller.Bind(reqlog.NewContext(ctx, log), instanceID, bindingID, &req)
+	s.inFlight.end(token, err)
+	s.metrics.record("bind", req.ServiceID, start, err)
+	s.recordAudit(r, "bind", instanceID, bindingID, req.ServiceID, start, err)
+	s.endSpan(span, instanceID, bindingID, req.ServiceID, err)
+	if err == nil {
+		s.metrics.bindingCreated(req.ServiceID)
 		util.WriteResponse(w, http.StatusOK, result)
 	} else {
-		util.WriteErrorResponse(w, http.StatusBadRequest, err)
+		util.WriteErrorResponse(w, kube.HTTPStatus(err), err)
 	}
 }
 
@@ -174,13 +480,27 @@ func (s *server) unBind(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 	serviceID := q.Get("service_id")
 	planID := q.Get("plan_id")
-	glog.Infof("UnBind: Service instance guid: %s:%s", bindingID, instanceID)
+	log := reqlog.New("operation", "unbind", "instanceID", instanceID, "bindingID", bindingID, "serviceID", serviceID)
+	log.Infof("UnBind...")
+	ctx, span := s.tracer.Start(r.Context(), "unbind")
 
-	if err := s.controller.UnBind(instanceID, bindingID, serviceID, planID); err == nil {
+	if !s.requireLeader(w) {
+		return
+	}
+
+	start := time.Now()
+	token := s.inFlight.start("unbind", instanceID, serviceID)
+	err := s.controller.UnBind(reqlog.NewContext(ctx, log), instanceID, bindingID, serviceID, planID)
+	s.inFlight.end(token, err)
+	s.metrics.record("unbind", serviceID, start, err)
+	s.recordAudit(r, "unbind", instanceID, bindingID, serviceID, start, err)
+	s.endSpan(span, instanceID, bindingID, serviceID, err)
+	if err == nil {
+		s.metrics.bindingRemoved(serviceID)
 		w.WriteHeader(http.StatusOK)
 		w.Header().Set("Content-Type", "application/json")
 		fmt.Fprint(w, "{}") //id)
 	} else {
-		util.WriteErrorResponse(w, http.StatusBadRequest, err)
+		util.WriteErrorResponse(w, kube.HTTPStatus(err), err)
 	}
 }
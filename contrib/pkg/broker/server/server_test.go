@@ -17,10 +17,12 @@ limitations under the License.
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
@@ -34,20 +36,22 @@ import (
 // Make sure that Controller stub implements controller.Controller interface
 var _ controller.Controller = &Controller{}
 
-// /v2/catalog returns HTTP error on error.
+// /v2/catalog returns HTTP error on error. An error the controller returns
+// without any more specific kube.ErrorKind is treated as internal to the
+// broker, since the caller can't have caused a failure it can't classify.
 func TestCatalogReturnsHTTPErrorOnError(t *testing.T) {
 	handler := createHandler(&Controller{
 		t: t,
 		catalog: func() (*brokerapi.Catalog, error) {
 			return nil, errors.New("Catalog retrieval error")
 		},
-	})
+	}, nil, nil, "", nil, nil)
 
 	rr := httptest.NewRecorder()
 	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/v2/catalog", nil))
 
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("Expected HTTP status http.StatusBadRequest (%d), got %d", http.StatusBadRequest, rr.Code)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected HTTP status http.StatusInternalServerError (%d), got %d", http.StatusInternalServerError, rr.Code)
 	}
 
 	if contentType := rr.Header().Get("content-type"); contentType != "application/json" {
@@ -69,7 +73,7 @@ func TestCatalogReturnsCompliantJSON(t *testing.T) {
 					Name: "foo",
 				},
 			}}, nil
-		}})
+		}}, nil, nil, "", nil, nil)
 
 	rr := httptest.NewRecorder()
 	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/v2/catalog", nil))
@@ -116,12 +120,77 @@ func readJSON(rr *httptest.ResponseRecorder) (map[string]interface{}, error) {
 	return result, err
 }
 
+// fakeElector is a leaderelection.Elector whose IsLeader answer is fixed by
+// the test, exercising the server's gating logic without the real Lease
+// machinery.
+type fakeElector bool
+
+func (f fakeElector) IsLeader() bool { return bool(f) }
+
+func TestCreateServiceInstanceReturns503WhenNotLeader(t *testing.T) {
+	handler := createHandler(&Controller{t: t}, fakeElector(false), nil, "", nil, nil)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("PUT", "/v2/service_instances/instance-1", strings.NewReader("{}")))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Code = %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header on a not-leader response")
+	}
+}
+
+func TestCreateServiceInstanceServesWhenLeader(t *testing.T) {
+	handler := createHandler(&Controller{
+		t: t,
+		createServiceInstance: func(id string, req *brokerapi.CreateServiceInstanceRequest) (*brokerapi.CreateServiceInstanceResponse, error) {
+			return &brokerapi.CreateServiceInstanceResponse{}, nil
+		},
+	}, fakeElector(true), nil, "", nil, nil)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("PUT", "/v2/service_instances/instance-1", strings.NewReader("{}")))
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Code = %d, want %d", rr.Code, http.StatusCreated)
+	}
+}
+
+func TestCreateServiceInstanceServesWhenElectionDisabled(t *testing.T) {
+	handler := createHandler(&Controller{
+		t: t,
+		createServiceInstance: func(id string, req *brokerapi.CreateServiceInstanceRequest) (*brokerapi.CreateServiceInstanceResponse, error) {
+			return &brokerapi.CreateServiceInstanceResponse{}, nil
+		},
+	}, nil, nil, "", nil, nil)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("PUT", "/v2/service_instances/instance-1", strings.NewReader("{}")))
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Code = %d, want %d", rr.Code, http.StatusCreated)
+	}
+}
+
+func TestMetricsIsServedWithoutRequiringLeadership(t *testing.T) {
+	handler := createHandler(&Controller{t: t}, fakeElector(false), nil, "", nil, nil)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Code = %d, want %d", rr.Code, http.StatusOK)
+	}
+}
+
 type Controller struct {
 	t *testing.T
 
 	catalog                         func() (*brokerapi.Catalog, error)
 	getServiceInstanceLastOperation func(id string) (*brokerapi.LastOperationResponse, error)
 	createServiceInstance           func(id string, req *brokerapi.CreateServiceInstanceRequest) (*brokerapi.CreateServiceInstanceResponse, error)
+	updateServiceInstance           func(id string, req *brokerapi.UpdateServiceInstanceRequest) (*brokerapi.UpdateServiceInstanceResponse, error)
 	removeServiceInstance           func(id string) (*brokerapi.DeleteServiceInstanceResponse, error)
 	bind                            func(instanceID string, bindingID string, req *brokerapi.BindingRequest) (*brokerapi.CreateServiceBindingResponse, error)
 	unBind                          func(instanceID string, bindingID string) error
@@ -135,7 +204,7 @@ func (controller *Controller) Catalog() (*brokerapi.Catalog, error) {
 	return controller.catalog()
 }
 
-func (controller *Controller) GetServiceInstanceLastOperation(instanceID, serviceID, planID, operation string) (*brokerapi.LastOperationResponse, error) {
+func (controller *Controller) GetServiceInstanceLastOperation(ctx context.Context, instanceID, serviceID, planID, operation string) (*brokerapi.LastOperationResponse, error) {
 	if controller.getServiceInstanceLastOperation == nil {
 		controller.t.Error("Test failed to provide 'getServiceInstanceLastOperation' handler")
 	}
@@ -143,7 +212,7 @@ func (controller *Controller) GetServiceInstanceLastOperation(instanceID, servic
 	return controller.getServiceInstanceLastOperation(instanceID)
 }
 
-func (controller *Controller) CreateServiceInstance(id string, req *brokerapi.CreateServiceInstanceRequest) (*brokerapi.CreateServiceInstanceResponse, error) {
+func (controller *Controller) CreateServiceInstance(ctx context.Context, id string, req *brokerapi.CreateServiceInstanceRequest) (*brokerapi.CreateServiceInstanceResponse, error) {
 	if controller.createServiceInstance == nil {
 		controller.t.Error("Test failed to provide 'createServiceInstance' handler")
 	}
@@ -151,7 +220,15 @@ func (controller *Controller) CreateServiceInstance(id string, req *brokerapi.Cr
 	return controller.createServiceInstance(id, req)
 }
 
-func (controller *Controller) RemoveServiceInstance(instanceID, serviceID, planID string, acceptsIncomplete bool) (*brokerapi.DeleteServiceInstanceResponse, error) {
+func (controller *Controller) UpdateServiceInstance(ctx context.Context, id string, req *brokerapi.UpdateServiceInstanceRequest) (*brokerapi.UpdateServiceInstanceResponse, error) {
+	if controller.updateServiceInstance == nil {
+		controller.t.Error("Test failed to provide 'updateServiceInstance' handler")
+	}
+
+	return controller.updateServiceInstance(id, req)
+}
+
+func (controller *Controller) RemoveServiceInstance(ctx context.Context, instanceID, serviceID, planID string, acceptsIncomplete, force bool) (*brokerapi.DeleteServiceInstanceResponse, error) {
 	if controller.removeServiceInstance == nil {
 		controller.t.Error("Test failed to provide 'removeServiceInstance' handler")
 	}
@@ -159,7 +236,7 @@ func (controller *Controller) RemoveServiceInstance(instanceID, serviceID, planI
 	return controller.removeServiceInstance(instanceID)
 }
 
-func (controller *Controller) Bind(instanceID string, bindingID string, req *brokerapi.BindingRequest) (*brokerapi.CreateServiceBindingResponse, error) {
+func (controller *Controller) Bind(ctx context.Context, instanceID string, bindingID string, req *brokerapi.BindingRequest) (*brokerapi.CreateServiceBindingResponse, error) {
 	if controller.bind == nil {
 		controller.t.Error("Test failed to provide 'bind' handler")
 	}
@@ -167,7 +244,7 @@ func (controller *Controller) Bind(instanceID string, bindingID string, req *bro
 	return controller.bind(instanceID, bindingID, req)
 }
 
-func (controller *Controller) UnBind(instanceID, bindingID, serviceID, planID string) error {
+func (controller *Controller) UnBind(ctx context.Context, instanceID, bindingID, serviceID, planID string) error {
 	if controller.unBind == nil {
 		controller.t.Error("Test failed to provide 'unBind' handler")
 	}
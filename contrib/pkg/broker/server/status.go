@@ -0,0 +1,115 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+	"github.com/kubernetes-incubator/service-catalog/pkg"
+	"github.com/kubernetes-incubator/service-catalog/pkg/util"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// InventorySummary counts the instances or bindings a controller currently
+// reports, broken down by serviceID. Since a broker process serves a single
+// OSB service, ByServiceID normally has at most one entry; it's keyed
+// rather than flattened so a status consumer doesn't need to already know
+// that.
+type InventorySummary struct {
+	Total       int            `json:"total"`
+	ByServiceID map[string]int `json:"byServiceID,omitempty"`
+}
+
+// Status is served by /admin/status: a cheap-to-poll summary of a running
+// broker, for a dashboard or CLI to check without scraping and interpreting
+// the full /metrics or /admin/state output.
+type Status struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildDate string `json:"buildDate"`
+	Uptime    string `json:"uptime"`
+
+	Instances InventorySummary `json:"instances"`
+
+	// ByPhase counts instances by their controller-reported phase (e.g.
+	// "Provisioning", "Ready", "Failed"), for controllers that report one.
+	// An instance with no phase is omitted here, not counted under "".
+	ByPhase map[string]int `json:"instancesByPhase,omitempty"`
+
+	Bindings InventorySummary `json:"bindings"`
+
+	// Config summarizes the non-secret options this controller was
+	// started with, as reported by controller.StateReporter.
+	Config map[string]string `json:"config,omitempty"`
+
+	// QueueDepth is always 0: this broker dispatches every OSB operation
+	// synchronously from its HTTP handler rather than through a work
+	// queue, so there is never a backlog to report. For the same reason
+	// there is no reconcile loop and so no drift summary to include here:
+	// every instance's state reflects the OSB call that last touched it.
+	QueueDepth int `json:"queueDepth"`
+}
+
+// adminStatus serves a cheap-to-poll summary of this broker: its version,
+// how long it's been running, and an inventory of instances and bindings
+// drawn from the controller's state snapshot and the bindings gauge,
+// without making any fresh Kubernetes API calls. A Controller that doesn't
+// implement controller.StateReporter reports an empty inventory.
+func (s *server) adminStatus(w http.ResponseWriter, r *http.Request) {
+	status := Status{
+		Version:   pkg.VERSION,
+		GitCommit: pkg.GitCommit,
+		BuildDate: pkg.BuildDate,
+		Uptime:    time.Since(s.startTime).String(),
+	}
+
+	reporter, ok := s.controller.(controller.StateReporter)
+	if !ok {
+		util.WriteResponse(w, http.StatusOK, status)
+		return
+	}
+
+	snapshot := reporter.SnapshotState()
+	status.Config = snapshot.Config
+	status.Instances.ByServiceID = make(map[string]int)
+	status.Bindings.ByServiceID = make(map[string]int)
+	byPhase := make(map[string]int)
+
+	for _, instance := range snapshot.Instances {
+		status.Instances.Total++
+		status.Instances.ByServiceID[instance.ServiceID]++
+		if instance.Phase != "" {
+			byPhase[instance.Phase]++
+		}
+		if _, seen := status.Bindings.ByServiceID[instance.ServiceID]; !seen {
+			var metric dto.Metric
+			if err := s.metrics.bindings.WithLabelValues(instance.ServiceID).Write(&metric); err == nil {
+				count := int(metric.GetGauge().GetValue())
+				status.Bindings.ByServiceID[instance.ServiceID] = count
+				status.Bindings.Total += count
+			}
+		}
+	}
+	if len(byPhase) > 0 {
+		status.ByPhase = byPhase
+	}
+
+	util.WriteResponse(w, http.StatusOK, status)
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestAdminStatusRejectsARequestWithNoAdminTokenConfigured(t *testing.T) {
+	handler := createHandler(&Controller{t: t}, nil, nil, "", nil, nil)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin/status", nil)
+	req.Header.Set("Authorization", "Bearer anything")
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Code = %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAdminStatusRejectsAMissingOrWrongToken(t *testing.T) {
+	handler := createHandler(&Controller{t: t}, nil, nil, "s3cr3t", nil, nil)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/admin/status", nil),
+		func() *http.Request {
+			r := httptest.NewRequest("GET", "/admin/status", nil)
+			r.Header.Set("Authorization", "Bearer wrong")
+			return r
+		}(),
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Code = %d, want %d", rr.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestAdminStatusReportsAnEmptyInventoryWhenControllerHasNoState(t *testing.T) {
+	handler := createHandler(&Controller{t: t}, nil, nil, "s3cr3t", nil, nil)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin/status", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Code = %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	var status Status
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatalf("decoding response body: %v", err)
+	}
+	if status.Instances.Total != 0 {
+		t.Errorf("Instances.Total = %d, want 0", status.Instances.Total)
+	}
+	if status.QueueDepth != 0 {
+		t.Errorf("QueueDepth = %d, want 0", status.QueueDepth)
+	}
+}
+
+func TestAdminStatusSummarizesInventoryAcrossInstancesAndBindings(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics := NewMetrics(reg)
+	metrics.bindingCreated("test-service")
+	metrics.bindingCreated("test-service")
+
+	handler := createHandler(&stateReportingController{
+		Controller: Controller{t: t},
+		snapshotState: func() controller.StateSnapshot {
+			return controller.StateSnapshot{
+				Instances: []controller.InstanceSnapshot{
+					{ID: "instance-1", Namespace: "default", ServiceID: "test-service", Phase: "Ready"},
+					{ID: "instance-2", Namespace: "default", ServiceID: "test-service", Phase: "Provisioning"},
+				},
+				Config: map[string]string{"skipPVCDelete": "false"},
+			}
+		},
+	}, nil, metrics, "s3cr3t", nil, nil)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin/status", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Code = %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	var status Status
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatalf("decoding response body: %v", err)
+	}
+	if status.Instances.Total != 2 {
+		t.Errorf("Instances.Total = %d, want 2", status.Instances.Total)
+	}
+	if got := status.Instances.ByServiceID["test-service"]; got != 2 {
+		t.Errorf("Instances.ByServiceID[test-service] = %d, want 2", got)
+	}
+	if got := status.ByPhase["Ready"]; got != 1 {
+		t.Errorf("ByPhase[Ready] = %d, want 1", got)
+	}
+	if got := status.ByPhase["Provisioning"]; got != 1 {
+		t.Errorf("ByPhase[Provisioning] = %d, want 1", got)
+	}
+	if status.Bindings.Total != 2 {
+		t.Errorf("Bindings.Total = %d, want 2", status.Bindings.Total)
+	}
+	if got := status.Bindings.ByServiceID["test-service"]; got != 2 {
+		t.Errorf("Bindings.ByServiceID[test-service] = %d, want 2", got)
+	}
+	if status.Config["skipPVCDelete"] != "false" {
+		t.Errorf("Config[skipPVCDelete] = %q, want %q", status.Config["skipPVCDelete"], "false")
+	}
+	if status.QueueDepth != 0 {
+		t.Errorf("QueueDepth = %d, want 0", status.QueueDepth)
+	}
+}
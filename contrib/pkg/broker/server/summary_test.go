@@ -0,0 +1,181 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+)
+
+// captureStateSummary swaps stateSummarySink so lines can be asserted on
+// instead of going to glog's own output, restoring it when the caller's
+// test returns.
+func captureStateSummary() (lines *[]string, restore func()) {
+	var captured []string
+	orig := stateSummarySink
+	stateSummarySink = func(args ...interface{}) {
+		var line string
+		for _, a := range args {
+			if s, ok := a.(string); ok {
+				line += s
+			}
+		}
+		captured = append(captured, line)
+	}
+	return &captured, func() { stateSummarySink = orig }
+}
+
+// tickClock is a waitutil.Clock whose After returns a channel the test
+// controls directly, so a summary can be triggered exactly when the test
+// wants instead of on a real ticker.
+type tickClock struct {
+	now  time.Time
+	tick chan time.Time
+}
+
+func newTickClock() *tickClock {
+	return &tickClock{now: time.Now(), tick: make(chan time.Time)}
+}
+
+func (c *tickClock) Now() time.Time { return c.now }
+
+func (c *tickClock) After(d time.Duration) <-chan time.Time { return c.tick }
+
+func TestSummarizeStateReportsInstancesBindingsAndInFlightOps(t *testing.T) {
+	s := newServer(&stateReportingController{
+		Controller: Controller{t: t},
+		snapshotState: func() controller.StateSnapshot {
+			return controller.StateSnapshot{
+				Instances: []controller.InstanceSnapshot{
+					{ID: "instance-1", ServiceID: "test-service", Phase: "Ready", BindingCount: 2},
+					{ID: "instance-2", ServiceID: "test-service", Phase: "Provisioning"},
+				},
+			}
+		},
+	}, nil, nil, "", nil, nil)
+
+	token := s.inFlight.start("bind", "instance-1", "test-service")
+
+	line := s.summarizeState()
+
+	for _, want := range []string{
+		"instances=2",
+		"instancesByService=test-service:2",
+		"instancesByPhase=Provisioning:1,Ready:1",
+		"bindings=2",
+		"inFlight=1",
+		"queueDepth=0",
+		"sinceLastSuccess=never",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected summary line to contain %q, got %q", want, line)
+		}
+	}
+
+	s.inFlight.end(token, nil)
+}
+
+func TestSummarizeStateReportsTimeSinceLastSuccess(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	s := newServer(&Controller{t: t}, nil, nil, "", nil, nil)
+	s.inFlight = newInFlightOps(clock)
+
+	token := s.inFlight.start("provision", "instance-1", "test-service")
+	s.inFlight.end(token, nil)
+	clock.now = clock.now.Add(90 * time.Second)
+
+	if got := s.summarizeState(); !strings.Contains(got, "sinceLastSuccess=1m30s") {
+		t.Errorf("expected summary line to report time since the last success, got %q", got)
+	}
+}
+
+func TestSummarizeStateReportsAnEmptySnapshotWhenControllerHasNoState(t *testing.T) {
+	s := newServer(&Controller{t: t}, nil, nil, "", nil, nil)
+
+	if got := s.summarizeState(); !strings.Contains(got, "instances=0") {
+		t.Errorf("expected summary line to report no instances, got %q", got)
+	}
+}
+
+func TestLogStateSummaryLogsOneLinePerTickUntilContextIsDone(t *testing.T) {
+	lines, restore := captureStateSummary()
+	defer restore()
+
+	clock := newTickClock()
+	s := newServer(&Controller{t: t}, nil, nil, "", nil, nil)
+	s.inFlight = newInFlightOps(clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.logStateSummary(ctx, time.Minute)
+		close(done)
+	}()
+
+	clock.tick <- clock.now
+	clock.tick <- clock.now
+	waitForLines(t, lines, 2)
+
+	cancel()
+	<-done
+
+	if got := len(*lines); got != 2 {
+		t.Errorf("expected exactly 2 summary lines for 2 ticks, got %d: %v", got, *lines)
+	}
+}
+
+func TestLogStateSummaryDoesNothingWhenIntervalIsNotPositive(t *testing.T) {
+	lines, restore := captureStateSummary()
+	defer restore()
+
+	s := newServer(&Controller{t: t}, nil, nil, "", nil, nil)
+
+	done := make(chan struct{})
+	go func() {
+		s.logStateSummary(context.Background(), 0)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("logStateSummary with a zero interval did not return")
+	}
+
+	if len(*lines) != 0 {
+		t.Errorf("expected no summary lines, got %v", *lines)
+	}
+}
+
+// waitForLines polls lines until it holds at least n entries or fails the
+// test after a short timeout, since logStateSummary runs in its own
+// goroutine.
+func waitForLines(t *testing.T, lines *[]string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if len(*lines) >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d summary lines, got %d: %v", n, len(*lines), *lines)
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg"
+	"github.com/kubernetes-incubator/service-catalog/pkg/util"
+)
+
+// buildInfo is the payload served by /version: the version, git SHA, and
+// build date this binary was built with. Unlike /admin/status, it needs no
+// admin token, since a build identification check shouldn't require
+// operator credentials.
+type buildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildDate string `json:"buildDate"`
+}
+
+func (s *server) version(w http.ResponseWriter, r *http.Request) {
+	util.WriteResponse(w, http.StatusOK, buildInfo{
+		Version:   pkg.VERSION,
+		GitCommit: pkg.GitCommit,
+		BuildDate: pkg.BuildDate,
+	})
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/pkg"
+)
+
+func TestVersionServesTheBuiltInVersionInfo(t *testing.T) {
+	origVersion, origCommit, origDate := pkg.VERSION, pkg.GitCommit, pkg.BuildDate
+	pkg.VERSION, pkg.GitCommit, pkg.BuildDate = "v1.2.3", "abc1234", "2017-11-01T00:00:00Z"
+	defer func() { pkg.VERSION, pkg.GitCommit, pkg.BuildDate = origVersion, origCommit, origDate }()
+
+	handler := createHandler(&Controller{t: t}, nil, nil, "", nil, nil)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/version", nil))
+
+	if rr.Code != 200 {
+		t.Fatalf("status = %d, want 200", rr.Code)
+	}
+	var got buildInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := buildInfo{Version: "v1.2.3", GitCommit: "abc1234", BuildDate: "2017-11-01T00:00:00Z"}
+	if got != want {
+		t.Errorf("/version = %+v, want %+v", got, want)
+	}
+}
+
+func TestVersionRequiresNoAdminToken(t *testing.T) {
+	handler := createHandler(&Controller{t: t}, nil, nil, "some-admin-token", nil, nil)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/version", nil))
+
+	if rr.Code != 200 {
+		t.Errorf("status with an admin token configured but not presented = %d, want 200", rr.Code)
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/reqlog"
+)
+
+// slowOperationCheckInterval bounds how long a slow operation can run past
+// threshold before the watchdog notices it.
+const slowOperationCheckInterval = 5 * time.Second
+
+// watchSlowOperations logs a warning and increments s.metrics' slow
+// operation counter for every operation in s.inFlight that's been running
+// longer than threshold, checking every slowOperationCheckInterval until
+// ctx is done.
+func (s *server) watchSlowOperations(ctx context.Context, threshold time.Duration) {
+	ticker := time.NewTicker(slowOperationCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkSlowOperations(threshold)
+		}
+	}
+}
+
+// checkSlowOperations warns about every operation that's run past
+// threshold. It copies s.inFlight's operations out before logging, so it
+// never holds the tracker's lock while doing I/O.
+func (s *server) checkSlowOperations(threshold time.Duration) {
+	now := s.inFlight.clock.Now()
+	for _, op := range s.inFlight.snapshot() {
+		elapsed := now.Sub(op.Start)
+		if elapsed < threshold {
+			continue
+		}
+		reqlog.New("operation", op.Operation, "instanceID", op.InstanceID, "serviceID", op.ServiceID).
+			Warningf("operation has been running for %s, past the %s slow-operation threshold (phase: %s)",
+				elapsed.Round(time.Second), threshold, s.instancePhase(op.InstanceID))
+		s.metrics.slowOperationDetected(op.Operation, op.ServiceID)
+	}
+}
+
+// instancePhase looks up instanceID's current phase from the controller's
+// state snapshot, or "" if the controller doesn't implement
+// controller.StateReporter or reports no matching instance.
+func (s *server) instancePhase(instanceID string) string {
+	reporter, ok := s.controller.(controller.StateReporter)
+	if !ok {
+		return ""
+	}
+	for _, instance := range reporter.SnapshotState().Instances {
+		if instance.ID == instanceID {
+			return instance.Phase
+		}
+	}
+	return ""
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// fakeClock is a waitutil.Clock whose Now is fixed at whatever the test
+// sets it to, so a test can drive an operation's elapsed time past a
+// threshold without actually sleeping.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.now.Add(d)
+	return ch
+}
+
+func TestCheckSlowOperationsWarnsOnlyAboutOperationsPastTheThreshold(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	reg := prometheus.NewRegistry()
+	s := newServer(&stateReportingController{
+		Controller: Controller{t: t},
+		snapshotState: func() controller.StateSnapshot {
+			return controller.StateSnapshot{
+				Instances: []controller.InstanceSnapshot{
+					{ID: "slow-instance", ServiceID: "test-service", Phase: "Provisioning"},
+				},
+			}
+		},
+	}, nil, NewMetrics(reg), "", nil, nil)
+	s.inFlight = newInFlightOps(clock)
+
+	slowToken := s.inFlight.start("provision", "slow-instance", "test-service")
+	clock.now = clock.now.Add(40 * time.Second)
+	fastToken := s.inFlight.start("bind", "fast-instance", "test-service")
+
+	s.checkSlowOperations(30 * time.Second)
+
+	if got := slowOperationCount(s.metrics, "provision", "test-service"); got != 1 {
+		t.Errorf("slow operations counted for provision/test-service = %d, want 1", got)
+	}
+	if got := slowOperationCount(s.metrics, "bind", "test-service"); got != 0 {
+		t.Errorf("slow operations counted for bind/test-service = %d, want 0 (it just started)", got)
+	}
+
+	s.inFlight.end(slowToken, nil)
+	clock.now = clock.now.Add(40 * time.Second)
+	s.checkSlowOperations(30 * time.Second)
+
+	if got := slowOperationCount(s.metrics, "provision", "test-service"); got != 1 {
+		t.Errorf("slow operations counted after end = %d, want still 1", got)
+	}
+	if got := slowOperationCount(s.metrics, "bind", "test-service"); got != 1 {
+		t.Errorf("slow operations counted for bind/test-service = %d, want 1 (now past threshold)", got)
+	}
+
+	s.inFlight.end(fastToken, nil)
+}
+
+func TestCheckSlowOperationsDoesNotWarnBeforeTheThresholdElapses(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	reg := prometheus.NewRegistry()
+	s := newServer(&Controller{t: t}, nil, NewMetrics(reg), "", nil, nil)
+	s.inFlight = newInFlightOps(clock)
+
+	s.inFlight.start("provision", "instance-1", "test-service")
+	clock.now = clock.now.Add(10 * time.Second)
+
+	s.checkSlowOperations(30 * time.Second)
+
+	if got := slowOperationCount(s.metrics, "provision", "test-service"); got != 0 {
+		t.Errorf("slow operations counted before threshold elapsed = %d, want 0", got)
+	}
+}
+
+func TestInstancePhaseReturnsEmptyWhenControllerHasNoState(t *testing.T) {
+	s := newServer(&Controller{t: t}, nil, NewMetrics(prometheus.NewRegistry()), "", nil, nil)
+
+	if got := s.instancePhase("anything"); got != "" {
+		t.Errorf("instancePhase with no StateReporter = %q, want %q", got, "")
+	}
+}
+
+// slowOperationCount reads back the slowOperationsTotal counter's current
+// value for operation and serviceID.
+func slowOperationCount(m *Metrics, operation, serviceID string) uint64 {
+	var metric dto.Metric
+	if err := m.slowOperationsTotal.WithLabelValues(operation, serviceID).Write(&metric); err != nil {
+		panic(err)
+	}
+	return uint64(metric.GetCounter().GetValue())
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/golang/glog"
+)
+
+// httpExporterBufferSize bounds how many ended spans can be queued for
+// export before HTTPExporter starts dropping them, so a slow or
+// unreachable tracing endpoint can't stall OSB traffic.
+const httpExporterBufferSize = 1024
+
+// HTTPExporter posts each Span, as JSON, to an HTTP endpoint from a single
+// background goroutine. The zero value is not usable; construct one with
+// NewHTTPExporter.
+type HTTPExporter struct {
+	endpoint string
+	client   *http.Client
+	spans    chan *Span
+}
+
+// NewHTTPExporter creates an HTTPExporter posting to endpoint.
+func NewHTTPExporter(endpoint string) *HTTPExporter {
+	e := &HTTPExporter{
+		endpoint: endpoint,
+		client:   &http.Client{},
+		spans:    make(chan *Span, httpExporterBufferSize),
+	}
+	go e.run()
+	return e
+}
+
+func (e *HTTPExporter) run() {
+	for span := range e.spans {
+		body, err := json.Marshal(span)
+		if err != nil {
+			glog.Errorf("trace: failed to encode span %q: %v", span.Name, err)
+			continue
+		}
+		resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+		if err != nil {
+			glog.Errorf("trace: failed to export span %q to %s: %v", span.Name, e.endpoint, err)
+			continue
+		}
+		resp.Body.Close()
+	}
+}
+
+// Export enqueues span to be posted to e.endpoint. It never blocks: a full
+// buffer drops span rather than stalling the caller.
+func (e *HTTPExporter) Export(span *Span) {
+	select {
+	case e.spans <- span:
+	default:
+		glog.Errorf("trace: export buffer full, dropped span %q", span.Name)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package trace instruments the provision flow with a lightweight span
+// hierarchy: a root span per OSB operation, with child spans for the kube
+// calls and wait phases nested underneath it, propagated through the
+// context we already thread from the server package down into a
+// controller. Spans are exported to an Exporter, such as one that ships
+// them to a tracing backend; a nil *Tracer -- the default when tracing is
+// disabled -- makes Start a no-op that neither allocates a Span nor
+// touches ctx.
+package trace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+type contextKey struct{}
+
+// Span is one traced unit of work. The zero value is not usable; create
+// one with a Tracer's Start method.
+type Span struct {
+	Name         string
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   map[string]string
+
+	exporter Exporter
+}
+
+// SetAttribute records a key/value pair on s, such as instanceID,
+// serviceID, namespace, or outcome. A nil Span discards it.
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]string)
+	}
+	s.Attributes[key] = value
+}
+
+// End marks s finished and hands it to its Tracer's Exporter. A nil Span
+// is a no-op.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.EndTime = time.Now()
+	if s.exporter != nil {
+		s.exporter.Export(s)
+	}
+}
+
+// Exporter receives every Span as it ends.
+type Exporter interface {
+	Export(*Span)
+}
+
+// Tracer starts spans, threading a parent/child relationship through
+// context.Context. The zero value is not usable; construct one with New.
+// A nil *Tracer disables tracing: Start returns ctx unmodified and a nil
+// Span, so every Span method call on it is a no-op.
+type Tracer struct {
+	exporter Exporter
+}
+
+// New creates a Tracer that hands every span it starts to exporter, once
+// ended.
+func New(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Start begins a new Span named name, as a child of whatever Span is
+// already attached to ctx, and returns a context carrying it. Call
+// (*Span).End when the traced work finishes.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		Name:      name,
+		SpanID:    uuid.NewV4().String(),
+		StartTime: time.Now(),
+		exporter:  t.exporter,
+	}
+	if parent, ok := ctx.Value(contextKey{}).(*Span); ok && parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentSpanID = parent.SpanID
+	} else {
+		span.TraceID = uuid.NewV4().String()
+	}
+	return context.WithValue(ctx, contextKey{}, span), span
+}
+
+// FromContext returns the Span most recently started against ctx, or nil
+// if none was.
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(contextKey{}).(*Span)
+	return span
+}
+
+// MemoryExporter accumulates every exported Span, for asserting on a span
+// hierarchy in a test.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// Export appends s to e.Spans.
+func (e *MemoryExporter) Export(s *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+// Spans returns every Span exported so far, in the order they ended.
+func (e *MemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span{}, e.spans...)
+}
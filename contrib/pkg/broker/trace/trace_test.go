@@ -0,0 +1,94 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStartBuildsAParentChildHierarchy(t *testing.T) {
+	exporter := &MemoryExporter{}
+	tracer := New(exporter)
+
+	ctx, root := tracer.Start(context.Background(), "provision")
+	root.SetAttribute("instanceID", "instance-1")
+
+	ctx, createPod := tracer.Start(ctx, "create-pod")
+	createPod.SetAttribute("namespace", "default")
+	createPod.End()
+
+	_, wait := tracer.Start(ctx, "wait-for-ready")
+	wait.End()
+
+	root.SetAttribute("outcome", "success")
+	root.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 exported spans, got %d", len(spans))
+	}
+
+	byName := make(map[string]*Span, len(spans))
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+
+	for _, name := range []string{"create-pod", "wait-for-ready"} {
+		child, ok := byName[name]
+		if !ok {
+			t.Fatalf("expected a %q span, got %v", name, byName)
+		}
+		if child.TraceID != root.TraceID {
+			t.Errorf("%s.TraceID = %q, want the root's TraceID %q", name, child.TraceID, root.TraceID)
+		}
+		if child.ParentSpanID != root.SpanID {
+			t.Errorf("%s.ParentSpanID = %q, want the root's SpanID %q", name, child.ParentSpanID, root.SpanID)
+		}
+	}
+
+	if byName["create-pod"].Attributes["namespace"] != "default" {
+		t.Errorf("expected create-pod span to carry its namespace attribute, got %v", byName["create-pod"].Attributes)
+	}
+	if byName["provision"].Attributes["outcome"] != "success" {
+		t.Errorf("expected root span to carry its outcome attribute, got %v", byName["provision"].Attributes)
+	}
+}
+
+func TestNilTracerStartIsANoOp(t *testing.T) {
+	var tracer *Tracer
+
+	ctx := context.Background()
+	newCtx, span := tracer.Start(ctx, "provision")
+
+	if newCtx != ctx {
+		t.Errorf("expected a nil Tracer to return ctx unmodified")
+	}
+	if span != nil {
+		t.Errorf("expected a nil Tracer to return a nil Span, got %v", span)
+	}
+
+	// None of these should panic on the nil Span Start returned.
+	span.SetAttribute("instanceID", "instance-1")
+	span.End()
+}
+
+func TestFromContextWithNoSpanStartedReturnsNil(t *testing.T) {
+	if span := FromContext(context.Background()); span != nil {
+		t.Errorf("expected no span, got %v", span)
+	}
+}
@@ -17,23 +17,23 @@ limitations under the License.
 package controller
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
 	"sync"
 
 	"github.com/golang/glog"
 	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/kube"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/redact"
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/reqlog"
 	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
 )
 
-type errNoSuchInstance struct {
-	instanceID string
-}
-
-func (e errNoSuchInstance) Error() string {
-	return fmt.Sprintf("no such instance with ID %s", e.instanceID)
-}
+// instanceLogSink is package-level indirection around the log line in
+// CreateServiceInstance so a test can capture it and assert that a
+// credential's values never made it into the log, without asserting
+// against glog's own output.
+var instanceLogSink = glog.Infof
 
 type userProvidedServiceInstance struct {
 	Name       string
@@ -75,10 +75,11 @@ func (c *userProvidedController) Catalog() (*brokerapi.Catalog, error) {
 }
 
 func (c *userProvidedController) CreateServiceInstance(
+	ctx context.Context,
 	id string,
 	req *brokerapi.CreateServiceInstanceRequest,
 ) (*brokerapi.CreateServiceInstanceResponse, error) {
-	glog.Info("CreateServiceInstance()")
+	reqlog.FromContext(ctx).Infof("CreateServiceInstance()")
 	credString, ok := req.Parameters["credentials"]
 	c.rwMutex.Lock()
 	defer c.rwMutex.Unlock()
@@ -105,27 +106,39 @@ func (c *userProvidedController) CreateServiceInstance(
 		}
 	}
 
-	glog.Infof("Created User Provided Service Instance:\n%v\n", c.instanceMap[id])
+	instance := c.instanceMap[id]
+	instanceLogSink("Created User Provided Service Instance %s with credentials %v", instance.Name, redact.Credential(*instance.Credential))
 	return &brokerapi.CreateServiceInstanceResponse{}, nil
 }
 
+func (c *userProvidedController) UpdateServiceInstance(
+	ctx context.Context,
+	instanceID string,
+	req *brokerapi.UpdateServiceInstanceRequest,
+) (*brokerapi.UpdateServiceInstanceResponse, error) {
+	reqlog.FromContext(ctx).Infof("UpdateServiceInstance()")
+	return nil, kube.NewInternalError("Unimplemented", nil)
+}
+
 func (c *userProvidedController) GetServiceInstanceLastOperation(
+	ctx context.Context,
 	instanceID,
 	serviceID,
 	planID,
 	operation string,
 ) (*brokerapi.LastOperationResponse, error) {
-	glog.Info("GetServiceInstanceLastOperation()")
-	return nil, errors.New("Unimplemented")
+	reqlog.FromContext(ctx).Infof("GetServiceInstanceLastOperation()")
+	return nil, kube.NewInternalError("Unimplemented", nil)
 }
 
 func (c *userProvidedController) RemoveServiceInstance(
+	ctx context.Context,
 	instanceID,
 	serviceID,
 	planID string,
-	acceptsIncomplete bool,
+	acceptsIncomplete, force bool,
 ) (*brokerapi.DeleteServiceInstanceResponse, error) {
-	glog.Info("RemoveServiceInstance()")
+	reqlog.FromContext(ctx).Infof("RemoveServiceInstance()")
 	c.rwMutex.Lock()
 	defer c.rwMutex.Unlock()
 	_, ok := c.instanceMap[instanceID]
@@ -138,23 +151,24 @@ func (c *userProvidedController) RemoveServiceInstance(
 }
 
 func (c *userProvidedController) Bind(
+	ctx context.Context,
 	instanceID,
 	bindingID string,
 	req *brokerapi.BindingRequest,
 ) (*brokerapi.CreateServiceBindingResponse, error) {
-	glog.Info("Bind()")
+	reqlog.FromContext(ctx).Infof("Bind()")
 	c.rwMutex.RLock()
 	defer c.rwMutex.RUnlock()
 	instance, ok := c.instanceMap[instanceID]
 	if !ok {
-		return nil, errNoSuchInstance{instanceID: instanceID}
+		return nil, kube.ErrNoSuchInstance{InstanceID: instanceID}
 	}
 	cred := instance.Credential
 	return &brokerapi.CreateServiceBindingResponse{Credentials: *cred}, nil
 }
 
-func (c *userProvidedController) UnBind(instanceID, bindingID, serviceID, planID string) error {
-	glog.Info("UnBind()")
+func (c *userProvidedController) UnBind(ctx context.Context, instanceID, bindingID, serviceID, planID string) error {
+	reqlog.FromContext(ctx).Infof("UnBind()")
 	// Since we don't persist the binding, there's nothing to do here.
 	return nil
 }
@@ -17,8 +17,13 @@ limitations under the License.
 package controller
 
 import (
-	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+	"context"
+	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/kubernetes-incubator/service-catalog/contrib/pkg/broker/controller"
+	"github.com/kubernetes-incubator/service-catalog/pkg/brokerapi"
 )
 
 // Make sure that userProvidedController implements Controller interface
@@ -26,3 +31,48 @@ var _ controller.Controller = &userProvidedController{}
 
 func TestController(t *testing.T) {
 }
+
+// captureInstanceLog swaps instanceLogSink so lines can be asserted on
+// instead of going to glog's own output, restoring it when the caller's
+// test returns.
+func captureInstanceLog() (lines *[]string, restore func()) {
+	var captured []string
+	orig := instanceLogSink
+	instanceLogSink = func(format string, args ...interface{}) {
+		captured = append(captured, fmt.Sprintf(format, args...))
+	}
+	return &captured, func() { instanceLogSink = orig }
+}
+
+func TestProvisionAndBindNeverLogTheCredentialValue(t *testing.T) {
+	lines, restore := captureInstanceLog()
+	defer restore()
+
+	const secret = "super-secret-password"
+	c := CreateController()
+	ctx := context.Background()
+
+	if _, err := c.CreateServiceInstance(ctx, "instance-1", &brokerapi.CreateServiceInstanceRequest{
+		Parameters: map[string]interface{}{
+			"credentials": map[string]interface{}{
+				"password": secret,
+			},
+		},
+	}); err != nil {
+		t.Fatalf("CreateServiceInstance() returned error: %v", err)
+	}
+
+	resp, err := c.Bind(ctx, "instance-1", "binding-1", &brokerapi.BindingRequest{})
+	if err != nil {
+		t.Fatalf("Bind() returned error: %v", err)
+	}
+	if resp.Credentials["password"] != secret {
+		t.Fatalf("Bind() response credentials = %v, want the real password to reach the caller", resp.Credentials)
+	}
+
+	for _, line := range *lines {
+		if strings.Contains(line, secret) {
+			t.Errorf("log line %q leaked the credential value", line)
+		}
+	}
+}
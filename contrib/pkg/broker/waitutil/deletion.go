@@ -0,0 +1,191 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package waitutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Kind identifies one resource type ForDeletion lists and watches while
+// waiting for every object matching a selector to disappear. Callers
+// outside this package can build their own Kind for a resource type it
+// doesn't already provide one for.
+type Kind struct {
+	// Name is used only to describe what ForDeletion is waiting for in its
+	// TimeoutError.
+	Name  string
+	Count func(kubernetes.Interface, string, metav1.ListOptions) (int, error)
+	Watch func(kubernetes.Interface, string, metav1.ListOptions) (watch.Interface, error)
+}
+
+// Kinds ForDeletion knows how to wait on out of the box. Add to this list
+// as a broker starts creating a new kind of instance resource that needs
+// to be waited out on deprovision.
+var (
+	Pods = Kind{
+		Name: "pods",
+		Count: func(c kubernetes.Interface, ns string, opts metav1.ListOptions) (int, error) {
+			list, err := c.Core().Pods(ns).List(opts)
+			if err != nil {
+				return 0, err
+			}
+			return len(list.Items), nil
+		},
+		Watch: func(c kubernetes.Interface, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+			return c.Core().Pods(ns).Watch(opts)
+		},
+	}
+
+	Services = Kind{
+		Name: "services",
+		Count: func(c kubernetes.Interface, ns string, opts metav1.ListOptions) (int, error) {
+			list, err := c.Core().Services(ns).List(opts)
+			if err != nil {
+				return 0, err
+			}
+			return len(list.Items), nil
+		},
+		Watch: func(c kubernetes.Interface, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+			return c.Core().Services(ns).Watch(opts)
+		},
+	}
+
+	Deployments = Kind{
+		Name: "deployments",
+		Count: func(c kubernetes.Interface, ns string, opts metav1.ListOptions) (int, error) {
+			list, err := c.Apps().Deployments(ns).List(opts)
+			if err != nil {
+				return 0, err
+			}
+			return len(list.Items), nil
+		},
+		Watch: func(c kubernetes.Interface, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+			return c.Apps().Deployments(ns).Watch(opts)
+		},
+	}
+
+	PersistentVolumeClaims = Kind{
+		Name: "persistentvolumeclaims",
+		Count: func(c kubernetes.Interface, ns string, opts metav1.ListOptions) (int, error) {
+			list, err := c.Core().PersistentVolumeClaims(ns).List(opts)
+			if err != nil {
+				return 0, err
+			}
+			return len(list.Items), nil
+		},
+		Watch: func(c kubernetes.Interface, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+			return c.Core().PersistentVolumeClaims(ns).Watch(opts)
+		},
+	}
+)
+
+// ForDeletion blocks until nothing matching selector remains for any of
+// kinds in namespace, returning a TimeoutError if timeout elapses first.
+// It watches each kind for a change worth re-checking, falling back to
+// polling every pollInterval for any kind whose watch can't be
+// established. It returns early if ctx is canceled.
+func ForDeletion(ctx context.Context, client kubernetes.Interface, namespace, selector string, kinds []Kind, timeout time.Duration) error {
+	return forDeletion(ctx, client, namespace, selector, kinds, timeout, realClock{})
+}
+
+func forDeletion(ctx context.Context, client kubernetes.Interface, namespace, selector string, kinds []Kind, timeout time.Duration, clock Clock) error {
+	opts := metav1.ListOptions{LabelSelector: selector}
+	deadline := clock.Now().Add(timeout)
+
+	names := make([]string, len(kinds))
+	for i, kind := range kinds {
+		names[i] = kind.Name
+	}
+	waiting := fmt.Sprintf("%s matching %q in namespace %s to be deleted", strings.Join(names, ", "), selector, namespace)
+
+	check := func() (bool, error) {
+		for _, kind := range kinds {
+			n, err := kind.Count(client, namespace, opts)
+			if err != nil {
+				return false, err
+			}
+			if n > 0 {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	// changed is signaled whenever any watched kind reports an event worth
+	// re-running check for. A kind whose watch can't be established is
+	// silently left out: pollFallback below still re-checks it on a timer.
+	changed := make(chan struct{}, 1)
+	pollFallback := false
+	var watchers []watch.Interface
+	for _, kind := range kinds {
+		w, err := kind.Watch(client, namespace, opts)
+		if err != nil {
+			pollFallback = true
+			continue
+		}
+		watchers = append(watchers, w)
+		go func(w watch.Interface) {
+			for range w.ResultChan() {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}(w)
+	}
+	defer func() {
+		for _, w := range watchers {
+			w.Stop()
+		}
+	}()
+
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		remaining := deadline.Sub(clock.Now())
+		if remaining <= 0 {
+			return TimeoutError{Waiting: waiting, Timeout: timeout}
+		}
+		wait := remaining
+		if pollFallback && wait > pollInterval {
+			wait = pollInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %s: %v", waiting, ctx.Err())
+		case <-clock.After(wait):
+			if wait == remaining {
+				return TimeoutError{Waiting: waiting, Timeout: timeout}
+			}
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package waitutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	ktesting "k8s.io/client-go/testing"
+)
+
+func TestForDeletionSucceedsImmediatelyWhenNothingMatches(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	if err := ForDeletion(context.Background(), client, "default", "app=test", []Kind{Pods, Services}, time.Second); err != nil {
+		t.Fatalf("ForDeletion: %v", err)
+	}
+}
+
+func TestForDeletionSucceedsWhenWatchDeliversDeletion(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p1", Namespace: "default", Labels: map[string]string{"app": "test"}}}
+	client := fake.NewSimpleClientset(pod)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ForDeletion(context.Background(), client, "default", "app=test", []Kind{Pods}, 5*time.Second)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if err := client.Core().Pods("default").Delete("p1", nil); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ForDeletion: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ForDeletion did not return after the pod was deleted")
+	}
+}
+
+func TestForDeletionTimesOutWhenObjectSurvives(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p1", Namespace: "default", Labels: map[string]string{"app": "test"}}}
+	client := fake.NewSimpleClientset(pod)
+	clock := &fakeClock{now: time.Now()}
+
+	err := forDeletion(context.Background(), client, "default", "app=test", []Kind{Pods}, time.Second, clock)
+	if _, ok := err.(TimeoutError); !ok {
+		t.Fatalf("err = %v (%T), want a TimeoutError", err, err)
+	}
+}
+
+func TestForDeletionFallsBackToPollingWhenWatchFails(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependWatchReactor("pods", func(ktesting.Action) (bool, watch.Interface, error) {
+		return true, nil, fmt.Errorf("watch not supported")
+	})
+
+	if err := ForDeletion(context.Background(), client, "default", "app=test", []Kind{Pods}, time.Second); err != nil {
+		t.Fatalf("ForDeletion: %v", err)
+	}
+}
+
+func TestForDeletionWaitsOnEveryKind(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p1", Namespace: "default", Labels: map[string]string{"app": "test"}}}
+	svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "s1", Namespace: "default", Labels: map[string]string{"app": "test"}}}
+	client := fake.NewSimpleClientset(pod, svc)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ForDeletion(context.Background(), client, "default", "app=test", []Kind{Pods, Services}, 5*time.Second)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if err := client.Core().Pods("default").Delete("p1", nil); err != nil {
+		t.Fatalf("Delete pod: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		t.Fatalf("ForDeletion returned %v before the service was deleted too", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := client.Core().Services("default").Delete("s1", nil); err != nil {
+		t.Fatalf("Delete service: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ForDeletion: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ForDeletion did not return after both resources were deleted")
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package waitutil
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeoutError is returned when a wait's timeout elapses before its
+// condition is met. Callers that want to distinguish "gave up" from a
+// terminal failure like PodFailedError can type-assert for it.
+type TimeoutError struct {
+	// Waiting describes what the caller was waiting for, for inclusion in
+	// Error().
+	Waiting string
+	Timeout time.Duration
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s waiting for %s", e.Timeout, e.Waiting)
+}
+
+// PodFailedError is returned by ForPodReady the moment it observes a pod
+// reach a state it will never recover from on its own (an image it can't
+// pull, a crash loop, or a scheduling failure), so a caller doesn't have to
+// wait out the full timeout to learn the pod is never coming up.
+type PodFailedError struct {
+	Namespace, Name, Reason, Message string
+}
+
+func (e PodFailedError) Error() string {
+	return fmt.Sprintf("pod %s/%s failed: %s: %s", e.Namespace, e.Name, e.Reason, e.Message)
+}
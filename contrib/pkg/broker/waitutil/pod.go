@@ -0,0 +1,174 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package waitutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// pollInterval bounds how long the polling fallback waits between List
+// calls when a watch can't be established or drops before the condition is
+// met, so a wait still makes progress if the watch connection needs a
+// retry.
+const pollInterval = 2 * time.Second
+
+// ForPodReady blocks until a pod matching selector in namespace has a Ready
+// condition of True, returning a PodFailedError the instant one enters a
+// state it will never recover from on its own (ImagePullBackOff,
+// CrashLoopBackOff, or a failed scheduling attempt) and a TimeoutError if
+// timeout elapses first. It watches for the matching pod to change,
+// falling back to polling every pollInterval if the watch can't be
+// established or is dropped. It returns early if ctx is canceled.
+func ForPodReady(ctx context.Context, client kubernetes.Interface, namespace, selector string, timeout time.Duration) error {
+	return forPodReady(ctx, client, namespace, selector, timeout, realClock{})
+}
+
+func forPodReady(ctx context.Context, client kubernetes.Interface, namespace, selector string, timeout time.Duration, clock Clock) error {
+	opts := metav1.ListOptions{LabelSelector: selector}
+	deadline := clock.Now().Add(timeout)
+	waiting := fmt.Sprintf("a pod matching %q in namespace %s to become ready", selector, namespace)
+
+	check := func() (bool, error) {
+		pods, err := client.Core().Pods(namespace).List(opts)
+		if err != nil {
+			return false, nil
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if err := terminalPodFailure(pod); err != nil {
+				return false, err
+			}
+			if podReady(pod) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	watcher, err := client.Core().Pods(namespace).Watch(opts)
+	if err != nil {
+		return pollUntil(ctx, clock, deadline, waiting, timeout, check)
+	}
+	defer watcher.Stop()
+
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		remaining := deadline.Sub(clock.Now())
+		if remaining <= 0 {
+			return TimeoutError{Waiting: waiting, Timeout: timeout}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %s: %v", waiting, ctx.Err())
+		case <-clock.After(remaining):
+			return TimeoutError{Waiting: waiting, Timeout: timeout}
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return pollUntil(ctx, clock, deadline, waiting, timeout, check)
+			}
+			pod, ok := event.Object.(*v1.Pod)
+			if !ok {
+				continue
+			}
+			if err := terminalPodFailure(pod); err != nil {
+				return err
+			}
+			if podReady(pod) {
+				return nil
+			}
+		}
+	}
+}
+
+// pollUntil re-runs check every pollInterval until it succeeds, errors, or
+// deadline passes, for use when a watch isn't available.
+func pollUntil(ctx context.Context, clock Clock, deadline time.Time, waiting string, timeout time.Duration, check func() (bool, error)) error {
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		remaining := deadline.Sub(clock.Now())
+		if remaining <= 0 {
+			return TimeoutError{Waiting: waiting, Timeout: timeout}
+		}
+		wait := pollInterval
+		if remaining < wait {
+			wait = remaining
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %s: %v", waiting, ctx.Err())
+		case <-clock.After(wait):
+		}
+	}
+}
+
+// podReady reports whether pod is Running with a Ready condition of True.
+func podReady(pod *v1.Pod) bool {
+	if pod.Status.Phase != v1.PodRunning {
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// terminalPodFailure inspects pod for a state it will never recover from on
+// its own, returning a PodFailedError describing it.
+func terminalPodFailure(pod *v1.Pod) error {
+	if pod.Status.Phase == v1.PodFailed {
+		return PodFailedError{Namespace: pod.Namespace, Name: pod.Name, Reason: pod.Status.Reason, Message: pod.Status.Message}
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodScheduled && cond.Status == v1.ConditionFalse {
+			return PodFailedError{Namespace: pod.Namespace, Name: pod.Name, Reason: "Unschedulable", Message: cond.Message}
+		}
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting == nil {
+			continue
+		}
+		switch cs.State.Waiting.Reason {
+		case "ImagePullBackOff", "ErrImagePull", "CrashLoopBackOff":
+			return PodFailedError{Namespace: pod.Namespace, Name: pod.Name, Reason: cs.State.Waiting.Reason, Message: cs.State.Waiting.Message}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package waitutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	ktesting "k8s.io/client-go/testing"
+)
+
+func readyPod(name, namespace string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": "test"}},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func pendingPod(name, namespace string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": "test"}},
+		Status:     v1.PodStatus{Phase: v1.PodPending},
+	}
+}
+
+func TestForPodReadySucceedsImmediatelyWhenAlreadyReady(t *testing.T) {
+	client := fake.NewSimpleClientset(readyPod("p1", "default"))
+
+	if err := ForPodReady(context.Background(), client, "default", "app=test", time.Second); err != nil {
+		t.Fatalf("ForPodReady: %v", err)
+	}
+}
+
+func TestForPodReadySucceedsWhenWatchDeliversReadiness(t *testing.T) {
+	pod := pendingPod("p1", "default")
+	client := fake.NewSimpleClientset(pod)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ForPodReady(context.Background(), client, "default", "app=test", 5*time.Second)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	updated := readyPod("p1", "default")
+	if _, err := client.Core().Pods("default").Update(updated); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ForPodReady: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ForPodReady did not return after the pod became ready")
+	}
+}
+
+func TestForPodReadyReturnsPodFailedErrorOnImagePullBackOff(t *testing.T) {
+	pod := pendingPod("p1", "default")
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{
+		{State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "no such image"}}},
+	}
+	client := fake.NewSimpleClientset(pod)
+
+	err := ForPodReady(context.Background(), client, "default", "app=test", time.Second)
+	failed, ok := err.(PodFailedError)
+	if !ok {
+		t.Fatalf("err = %v (%T), want a PodFailedError", err, err)
+	}
+	if failed.Reason != "ImagePullBackOff" {
+		t.Errorf("Reason = %q, want ImagePullBackOff", failed.Reason)
+	}
+}
+
+func TestForPodReadyTimesOutWhenPodNeverBecomesReady(t *testing.T) {
+	client := fake.NewSimpleClientset(pendingPod("p1", "default"))
+	clock := &fakeClock{now: time.Now()}
+
+	err := forPodReady(context.Background(), client, "default", "app=test", time.Second, clock)
+	if _, ok := err.(TimeoutError); !ok {
+		t.Fatalf("err = %v (%T), want a TimeoutError", err, err)
+	}
+}
+
+func TestForPodReadyFallsBackToPollingWhenWatchFails(t *testing.T) {
+	client := fake.NewSimpleClientset(readyPod("p1", "default"))
+	client.PrependWatchReactor("pods", func(ktesting.Action) (bool, watch.Interface, error) {
+		return true, nil, fmt.Errorf("watch not supported")
+	})
+
+	if err := ForPodReady(context.Background(), client, "default", "app=test", time.Second); err != nil {
+		t.Fatalf("ForPodReady: %v", err)
+	}
+}
+
+func TestForPodReadyRespectsContextCancellation(t *testing.T) {
+	client := fake.NewSimpleClientset(pendingPod("p1", "default"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := ForPodReady(ctx, client, "default", "app=test", time.Second)
+	if err == nil {
+		t.Fatal("expected ForPodReady to fail once ctx is canceled")
+	}
+}
@@ -63,6 +63,20 @@ type CreateServiceInstanceResponse struct {
 	Operation    string `json:"operation, omitempty"`
 }
 
+// UpdateServiceInstanceRequest represents a request to a broker to update the
+// plan or parameters of an existing instance of a service
+type UpdateServiceInstanceRequest struct {
+	ServiceID  string                 `json:"service_id"`
+	PlanID     string                 `json:"plan_id,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// UpdateServiceInstanceResponse represents the response from a broker after a
+// request to update an instance of a service
+type UpdateServiceInstanceResponse struct {
+	Operation string `json:"operation,omitempty"`
+}
+
 // DeleteServiceInstanceRequest represents a request to a broker to deprovision an
 // instance of a service
 type DeleteServiceInstanceRequest struct {
@@ -27,6 +27,16 @@ import (
 // not be changed in this codebase
 var VERSION = "UNKNOWN"
 
+// GitCommit is the short git SHA this binary was built from. It's set by
+// the build system alongside VERSION, and should not be changed in this
+// codebase.
+var GitCommit = "UNKNOWN"
+
+// BuildDate is when this binary was built, as an RFC3339 timestamp. It's
+// set by the build system alongside VERSION and GitCommit, and should not
+// be changed in this codebase.
+var BuildDate = "UNKNOWN"
+
 // Version decides whether we should print the version and leave.
 type Version struct {
 	print bool